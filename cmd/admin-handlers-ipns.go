@@ -0,0 +1,98 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	iampolicy "github.com/RTradeLtd/s3x/pkg/iam/policy"
+	"github.com/gorilla/mux"
+)
+
+// BucketIPNSInfo is the response of GetBucketIPNSHandler
+type BucketIPNSInfo struct {
+	Bucket string `json:"bucket"`
+	// Name is the ipns name the root of the bucket is published under
+	Name string `json:"name"`
+}
+
+// validateAdminReqIPNS validates an admin request for the ipns publishing of a bucket, and
+// returns the object layer if it publishes bucket roots, or nil after writing an error
+func validateAdminReqIPNS(ctx context.Context, w http.ResponseWriter, r *http.Request) BucketIPNSPublisher {
+	objectAPI, _ := validateAdminReq(ctx, w, r, iampolicy.ConfigUpdateAdminAction)
+	if objectAPI == nil {
+		return nil
+	}
+	publisher, ok := objectAPI.(BucketIPNSPublisher)
+	if !ok {
+		writeErrorResponseJSON(ctx, w, errorCodes.ToAPIErr(ErrNotImplemented), r.URL)
+		return nil
+	}
+	return publisher
+}
+
+// SetBucketIPNSHandler - PUT /minio/admin/v2/bucket-ipns?bucket=<bucket>&enabled=<true|false>
+// ----------
+// Enables or disables publishing the root of a bucket to ipns
+func (a adminAPIHandlers) SetBucketIPNSHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := newContext(r, w, "SetBucketIPNS")
+
+	publisher := validateAdminReqIPNS(ctx, w, r)
+	if publisher == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	enabled, err := strconv.ParseBool(vars["enabled"])
+	if err != nil {
+		writeErrorResponseJSON(ctx, w, errorCodes.ToAPIErr(ErrInvalidRequest), r.URL)
+		return
+	}
+	if err := publisher.SetBucketIPNS(ctx, vars["bucket"], enabled); err != nil {
+		writeErrorResponseJSON(ctx, w, toAdminAPIErr(ctx, err), r.URL)
+		return
+	}
+	writeSuccessNoContent(w)
+}
+
+// GetBucketIPNSHandler - GET /minio/admin/v2/bucket-ipns?bucket=<bucket>
+// ----------
+// Returns the ipns name the root of a bucket is published under
+func (a adminAPIHandlers) GetBucketIPNSHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := newContext(r, w, "GetBucketIPNS")
+
+	publisher := validateAdminReqIPNS(ctx, w, r)
+	if publisher == nil {
+		return
+	}
+
+	bucket := mux.Vars(r)["bucket"]
+	name, err := publisher.GetBucketIPNSName(ctx, bucket)
+	if err != nil {
+		writeErrorResponseJSON(ctx, w, toAdminAPIErr(ctx, err), r.URL)
+		return
+	}
+	jsonBytes, err := json.Marshal(BucketIPNSInfo{Bucket: bucket, Name: name})
+	if err != nil {
+		writeErrorResponseJSON(ctx, w, toAdminAPIErr(ctx, err), r.URL)
+		return
+	}
+	writeSuccessResponseJSON(w, jsonBytes)
+}
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -295,6 +296,77 @@ func TestAdminServerInfo(t *testing.T) {
 	}
 }
 
+// bucketIPNSLayer is an object layer publishing bucket roots to ipns, like the s3x gateway.
+type bucketIPNSLayer struct {
+	ObjectLayer
+	enabled map[string]bool
+}
+
+func (l bucketIPNSLayer) SetBucketIPNS(ctx context.Context, bucket string, enabled bool) error {
+	l.enabled[bucket] = enabled
+	return nil
+}
+
+func (l bucketIPNSLayer) GetBucketIPNSName(ctx context.Context, bucket string) (string, error) {
+	if !l.enabled[bucket] {
+		return "", InvalidRequest{Err: errors.New("bucket root is not published to ipns")}
+	}
+	return "name-" + bucket, nil
+}
+
+func TestAdminBucketIPNS(t *testing.T) {
+	adminTestBed, err := prepareAdminXLTestBed()
+	if err != nil {
+		t.Fatal("Failed to initialize a single node XL backend for admin handler tests.")
+	}
+	defer adminTestBed.TearDown()
+
+	serve := func(method string, queryVal url.Values) *httptest.ResponseRecorder {
+		req, err := buildAdminRequest(queryVal, method, "/bucket-ipns", 0, nil)
+		if err != nil {
+			t.Fatalf("Failed to construct bucket-ipns request - %v", err)
+		}
+		rec := httptest.NewRecorder()
+		adminTestBed.router.ServeHTTP(rec, req)
+		return rec
+	}
+	set := url.Values{"bucket": {"bucket"}, "enabled": {"true"}}
+	get := url.Values{"bucket": {"bucket"}}
+
+	if rec := serve(http.MethodPut, set); rec.Code != http.StatusNotImplemented {
+		t.Fatalf("Expected %d without ipns publishing, got %d", http.StatusNotImplemented, rec.Code)
+	}
+
+	layer := bucketIPNSLayer{ObjectLayer: adminTestBed.objLayer, enabled: make(map[string]bool)}
+	globalObjLayerMutex.Lock()
+	globalObjectAPI = layer
+	globalObjLayerMutex.Unlock()
+
+	if rec := serve(http.MethodGet, get); rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected %d before enabling, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if rec := serve(http.MethodPut, url.Values{"bucket": {"bucket"}, "enabled": {"yes please"}}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected %d for an invalid enabled value, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if rec := serve(http.MethodPut, set); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected to succeed but failed with %d", rec.Code)
+	}
+	if !layer.enabled["bucket"] {
+		t.Fatal("Expected the bucket to be enabled")
+	}
+	rec := serve(http.MethodGet, get)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected to succeed but failed with %d", rec.Code)
+	}
+	var info BucketIPNSInfo
+	if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to decode bucket-ipns result json %v", err)
+	}
+	if info.Bucket != "bucket" || info.Name != "name-bucket" {
+		t.Errorf("Expected the name of the bucket, got %+v", info)
+	}
+}
+
 // TestToAdminAPIErrCode - test for toAdminAPIErrCode helper function.
 func TestToAdminAPIErrCode(t *testing.T) {
 	testCases := []struct {
@@ -166,6 +166,12 @@ func registerAdminRouter(router *mux.Router, enableConfigOps, enableIAMOps bool)
 	//
 	adminRouter.Methods(http.MethodGet).Path(adminAPIVersionPrefix + "/kms/key/status").HandlerFunc(httpTraceAll(adminAPI.KMSKeyStatusHandler))
 
+	// -- Bucket IPNS APIs --
+	//
+	adminRouter.Methods(http.MethodPut).Path(adminAPIVersionPrefix+"/bucket-ipns").HandlerFunc(httpTraceHdrs(adminAPI.SetBucketIPNSHandler)).
+		Queries("bucket", "{bucket:.*}").Queries("enabled", "{enabled:.*}")
+	adminRouter.Methods(http.MethodGet).Path(adminAPIVersionPrefix+"/bucket-ipns").HandlerFunc(httpTraceHdrs(adminAPI.GetBucketIPNSHandler)).Queries("bucket", "{bucket:.*}")
+
 	// If none of the routes match add default error handler routes
 	adminRouter.NotFoundHandler = http.HandlerFunc(httpTraceAll(errorResponseHandler))
 	adminRouter.MethodNotAllowedHandler = http.HandlerFunc(httpTraceAll(errorResponseHandler))
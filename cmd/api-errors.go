@@ -85,6 +85,8 @@ const (
 	ErrInvalidMaxUploads
 	ErrInvalidMaxParts
 	ErrInvalidPartNumberMarker
+	ErrInvalidPartNumber
+	ErrInvalidRangePartNumber
 	ErrInvalidRequestBody
 	ErrInvalidCopySource
 	ErrInvalidMetadataDirective
@@ -196,6 +198,8 @@ const (
 
 	// S3 extended errors.
 	ErrContentSHA256Mismatch
+	ErrChecksumMismatch
+	ErrInvalidChecksum
 
 	// Add new extended error codes here.
 
@@ -204,6 +208,7 @@ const (
 	ErrWriteQuorum
 	ErrParentIsObject
 	ErrStorageFull
+	ErrBucketQuotaExceeded
 	ErrRequestBodyParse
 	ErrObjectExistsAsDirectory
 	ErrInvalidObjectName
@@ -400,6 +405,16 @@ var errorCodes = errorCodeMap{
 		Description:    "Argument partNumberMarker must be an integer.",
 		HTTPStatusCode: http.StatusBadRequest,
 	},
+	ErrInvalidPartNumber: {
+		Code:           "InvalidPartNumber",
+		Description:    "The requested partnumber is not satisfiable",
+		HTTPStatusCode: http.StatusRequestedRangeNotSatisfiable,
+	},
+	ErrInvalidRangePartNumber: {
+		Code:           "InvalidRequest",
+		Description:    "Cannot specify both Range header and partNumber query parameter",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
 	ErrInvalidPolicyDocument: {
 		Code:           "InvalidPolicyDocument",
 		Description:    "The content of the form does not meet the conditions specified in the policy document.",
@@ -957,6 +972,16 @@ var errorCodes = errorCodeMap{
 		Description:    "The provided 'x-amz-content-sha256' header does not match what was computed.",
 		HTTPStatusCode: http.StatusBadRequest,
 	},
+	ErrChecksumMismatch: {
+		Code:           "BadDigest",
+		Description:    "The checksum you specified did not match the calculated checksum.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidChecksum: {
+		Code:           "InvalidRequest",
+		Description:    "The additional checksum headers of the request are invalid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
 
 	/// MinIO extensions.
 	ErrStorageFull: {
@@ -964,6 +989,11 @@ var errorCodes = errorCodeMap{
 		Description:    "Storage backend has reached its minimum free disk threshold. Please delete a few objects to proceed.",
 		HTTPStatusCode: http.StatusInsufficientStorage,
 	},
+	ErrBucketQuotaExceeded: {
+		Code:           "XMinioBucketQuotaExceeded",
+		Description:    "The bucket has reached its quota, no more objects can be added to it.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
 	ErrParentIsObject: {
 		Code:           "XMinioParentIsObject",
 		Description:    "Object-prefix is already an object, please choose a different object-prefix name.",
@@ -1710,6 +1740,8 @@ func toAPIErrorCode(ctx context.Context, err error) (apiErr APIErrorCode) {
 		apiErr = ErrBucketAlreadyOwnedByYou
 	case BucketNotEmpty:
 		apiErr = ErrBucketNotEmpty
+	case BucketQuotaExceeded:
+		apiErr = ErrBucketQuotaExceeded
 	case BucketAlreadyExists:
 		apiErr = ErrBucketAlreadyExists
 	case BucketExists:
@@ -1718,6 +1750,8 @@ func toAPIErrorCode(ctx context.Context, err error) (apiErr APIErrorCode) {
 		apiErr = ErrNoSuchKey
 	case ObjectAlreadyExists:
 		apiErr = ErrMethodNotAllowed
+	case ObjectLocked:
+		apiErr = ErrObjectLocked
 	case ObjectNameInvalid:
 		apiErr = ErrInvalidObjectName
 	case ObjectNamePrefixAsSlash:
@@ -1726,6 +1760,8 @@ func toAPIErrorCode(ctx context.Context, err error) (apiErr APIErrorCode) {
 		apiErr = ErrNoSuchUpload
 	case InvalidPart:
 		apiErr = ErrInvalidPart
+	case InvalidPartNumber:
+		apiErr = ErrInvalidPartNumber
 	case InsufficientWriteQuorum:
 		apiErr = ErrSlowDown
 	case InsufficientReadQuorum:
@@ -1744,6 +1780,8 @@ func toAPIErrorCode(ctx context.Context, err error) (apiErr APIErrorCode) {
 		apiErr = ErrSignatureDoesNotMatch
 	case hash.SHA256Mismatch:
 		apiErr = ErrContentSHA256Mismatch
+	case ChecksumMismatch:
+		apiErr = ErrChecksumMismatch
 	case ObjectTooLarge:
 		apiErr = ErrEntityTooLarge
 	case ObjectTooSmall:
@@ -1754,12 +1792,17 @@ func toAPIErrorCode(ctx context.Context, err error) (apiErr APIErrorCode) {
 		apiErr = ErrEntityTooLarge
 	case UnsupportedMetadata:
 		apiErr = ErrUnsupportedMetadata
+	case InvalidRequest:
+		apiErr = ErrInvalidRequest
 	case BucketPolicyNotFound:
 		apiErr = ErrNoSuchBucketPolicy
 	case BucketLifecycleNotFound:
 		apiErr = ErrNoSuchLifecycleConfiguration
 	case BucketSSEConfigNotFound:
 		apiErr = ErrNoSuchBucketSSEConfig
+	case ObjectEncryptionRequired:
+		// unencrypted puts only reach the object layer when the server can't encrypt them
+		apiErr = ErrKMSNotConfigured
 	case *event.ErrInvalidEventName:
 		apiErr = ErrEventNotification
 	case *event.ErrInvalidARN:
@@ -1788,6 +1831,8 @@ func toAPIErrorCode(ctx context.Context, err error) (apiErr APIErrorCode) {
 		apiErr = ErrBackendDown
 	case ObjectNameTooLong:
 		apiErr = ErrKeyTooLongError
+	case MetadataTooLarge:
+		apiErr = ErrMetadataTooLarge
 	default:
 		var ie, iw int
 		// This work-around is to handle the issue golang/go#30648
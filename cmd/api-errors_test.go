@@ -51,6 +51,11 @@ var toAPIErrorTests = []struct {
 	{err: BucketNotEmpty{}, errCode: ErrBucketNotEmpty},
 	{err: BucketNotFound{}, errCode: ErrNoSuchBucket},
 	{err: StorageFull{}, errCode: ErrStorageFull},
+	{err: BucketQuotaExceeded{}, errCode: ErrBucketQuotaExceeded},
+	{err: MetadataTooLarge{}, errCode: ErrMetadataTooLarge},
+	{err: InvalidPartNumber{}, errCode: ErrInvalidPartNumber},
+	{err: ObjectEncryptionRequired{}, errCode: ErrKMSNotConfigured},
+	{err: InvalidRequest{Err: errors.New("too many parts")}, errCode: ErrInvalidRequest},
 	{err: NotImplemented{}, errCode: ErrNotImplemented},
 	{err: errSignatureMismatch, errCode: ErrSignatureDoesNotMatch},
 
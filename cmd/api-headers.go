@@ -41,8 +41,9 @@ func mustGetRequestID(t time.Time) string {
 func setCommonHeaders(w http.ResponseWriter) {
 	w.Header().Set(xhttp.ServerInfo, "MinIO/"+ReleaseTag)
 	// Set `x-amz-bucket-region` only if region is set on the server
-	// by default minio uses an empty region.
-	if region := globalServerRegion; region != "" {
+	// by default minio uses an empty region. A bucket region set by
+	// the handler is kept.
+	if region := globalServerRegion; region != "" && w.Header().Get(xhttp.AmzBucketRegion) == "" {
 		w.Header().Set(xhttp.AmzBucketRegion, region)
 	}
 	w.Header().Set(xhttp.AcceptRanges, "bytes")
@@ -119,3 +119,37 @@ func TestGetURLScheme(t *testing.T) {
 		t.Errorf("Expected %s, got %s", httpsScheme, gotScheme)
 	}
 }
+
+// Tests that keys, prefixes and delimiters of listings are only url encoded when requested.
+func TestListObjectsResponseEncodingType(t *testing.T) {
+	resp := ListObjectsInfo{
+		Objects:  []ObjectInfo{{Name: "dir\nname/key é"}},
+		Prefixes: []string{"dir\nname/sub dir/"},
+	}
+	testCases := []struct {
+		encodingType                         string
+		key, prefix, delimiter, commonPrefix string
+	}{
+		{"", "dir\nname/key é", "dir\nname/", "/ ", "dir\nname/sub dir/"},
+		{"url", "dir%0Aname/key+%C3%A9", "dir%0Aname/", "/+", "dir%0Aname/sub+dir/"},
+	}
+	for _, tc := range testCases {
+		v1 := generateListObjectsV1Response("bucket", "dir\nname/", "", "/ ", tc.encodingType, 1000, resp)
+		v2 := generateListObjectsV2Response("bucket", "dir\nname/", "", "", "", "/ ", tc.encodingType, false, false, 1000, resp.Objects, resp.Prefixes, false)
+		if v1.EncodingType != tc.encodingType || v2.EncodingType != tc.encodingType {
+			t.Errorf("expected encoding type %q, got %q and %q", tc.encodingType, v1.EncodingType, v2.EncodingType)
+		}
+		if v1.Contents[0].Key != tc.key || v2.Contents[0].Key != tc.key {
+			t.Errorf("expected key %q, got %q and %q", tc.key, v1.Contents[0].Key, v2.Contents[0].Key)
+		}
+		if v1.Prefix != tc.prefix || v2.Prefix != tc.prefix {
+			t.Errorf("expected prefix %q, got %q and %q", tc.prefix, v1.Prefix, v2.Prefix)
+		}
+		if v1.Delimiter != tc.delimiter || v2.Delimiter != tc.delimiter {
+			t.Errorf("expected delimiter %q, got %q and %q", tc.delimiter, v1.Delimiter, v2.Delimiter)
+		}
+		if v1.CommonPrefixes[0].Prefix != tc.commonPrefix || v2.CommonPrefixes[0].Prefix != tc.commonPrefix {
+			t.Errorf("expected common prefix %q, got %q and %q", tc.commonPrefix, v1.CommonPrefixes[0].Prefix, v2.CommonPrefixes[0].Prefix)
+		}
+	}
+}
@@ -261,6 +261,54 @@ func checkRequestAuthType(ctx context.Context, r *http.Request, action policy.Ac
 	return s3Err
 }
 
+// requestPrincipalKey is the context key of the principal of a request authenticated by the object layer.
+type requestPrincipalKey struct{}
+
+// isRequestAWSSigned - returns true if the request is signed with an AWS signature.
+func isRequestAWSSigned(aType authType) bool {
+	switch aType {
+	case authTypeSigned, authTypePresigned, authTypeSignedV2, authTypePresignedV2, authTypeStreamingSigned, authTypePostPolicy:
+		return true
+	}
+	return false
+}
+
+// authenticateByObjectLayer - authenticates an S3 API request that is not signed with an AWS
+// signature if the object layer is a RequestAuthenticator. Returns the request with its principal
+// if the object layer authenticated it, or the same request if it's left to the server.
+// STS requests and the requests of the other routers, such as the web, admin and peer routers,
+// are always left to the server, so the object layer never sees their credentials.
+func authenticateByObjectLayer(r *http.Request) (*http.Request, error) {
+	authenticator, ok := newObjectLayerFn().(RequestAuthenticator)
+	// STS requests are recognized by their action alone, since a bearer token makes them JWT requests
+	_, isSTS := r.URL.Query()[xhttp.Action]
+	if !ok || isRequestAWSSigned(getRequestAuthType(r)) || isSTS ||
+		strings.HasPrefix(r.URL.Path, minioReservedBucketPath) {
+		return r, nil
+	}
+	principal, err := authenticator.AuthenticateRequest(r)
+	if err != nil || principal == "" {
+		return r, err
+	}
+	return r.WithContext(context.WithValue(r.Context(), requestPrincipalKey{}, principal)), nil
+}
+
+// getRequestPrincipal - returns the principal of a request authenticated by the object layer.
+func getRequestPrincipal(r *http.Request) (string, bool) {
+	principal, ok := r.Context().Value(requestPrincipalKey{}).(string)
+	return principal, ok
+}
+
+// isPrincipalAllowed - checks if the principal of a request authenticated by the object layer
+// may perform the action, which is decided by the object layer.
+func isPrincipalAllowed(principal string, action policy.Action, bucketName, objectName string) APIErrorCode {
+	authenticator, ok := newObjectLayerFn().(RequestAuthenticator)
+	if ok && authenticator.AuthorizeRequest(principal, action, bucketName, objectName) {
+		return ErrNone
+	}
+	return ErrAccessDenied
+}
+
 // Check request auth type verifies the incoming http request
 // - validates the request signature
 // - validates the policy action if anonymous tests bucket policies if any,
@@ -268,6 +316,12 @@ func checkRequestAuthType(ctx context.Context, r *http.Request, action policy.Ac
 // returns APIErrorCode if any to be replied to the client.
 // Additionally returns the accessKey used in the request, and if this request is by an admin.
 func checkRequestAuthTypeToAccessKey(ctx context.Context, r *http.Request, action policy.Action, bucketName, objectName string) (accessKey string, owner bool, s3Err APIErrorCode) {
+	if principal, ok := getRequestPrincipal(r); ok {
+		if s3Err = isPrincipalAllowed(principal, action, bucketName, objectName); s3Err != ErrNone {
+			return accessKey, owner, s3Err
+		}
+		return principal, owner, ErrNone
+	}
 	var cred auth.Credentials
 	switch getRequestAuthType(r) {
 	case authTypeUnknown, authTypeStreamingSigned:
@@ -443,6 +497,16 @@ func isSupportedS3AuthType(aType authType) bool {
 
 // handler for validating incoming authorization headers.
 func (a authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, err := authenticateByObjectLayer(r)
+	if err != nil {
+		writeErrorResponse(context.Background(), w, errorCodes.ToAPIErr(ErrAccessDenied), r.URL, guessIsBrowserReq(r))
+		return
+	}
+	if _, ok := getRequestPrincipal(r); ok {
+		// Authenticated by the object layer.
+		a.handler.ServeHTTP(w, r)
+		return
+	}
 	aType := getRequestAuthType(r)
 	if isSupportedS3AuthType(aType) {
 		// Let top level caller validate for anonymous and known signed requests.
@@ -467,6 +531,9 @@ func (a authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // call verifies bucket policies and IAM policies, supports multi user
 // checks etc.
 func isPutActionAllowed(atype authType, bucketName, objectName string, r *http.Request, action iampolicy.Action) (s3Err APIErrorCode) {
+	if principal, ok := getRequestPrincipal(r); ok {
+		return isPrincipalAllowed(principal, policy.Action(action), bucketName, objectName)
+	}
 	var cred auth.Credentials
 	var owner bool
 	switch atype {
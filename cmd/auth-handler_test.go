@@ -19,15 +19,19 @@ package cmd
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"testing"
 	"time"
 
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
 	"github.com/RTradeLtd/s3x/pkg/auth"
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
 	iampolicy "github.com/RTradeLtd/s3x/pkg/iam/policy"
 )
 
@@ -425,3 +429,144 @@ func TestCheckAdminRequestAuthType(t *testing.T) {
 		}
 	}
 }
+
+// tokenAuthenticatorLayer is an object layer authenticating requests with a single bearer token.
+type tokenAuthenticatorLayer struct {
+	ObjectLayer
+	token string
+}
+
+func (l tokenAuthenticatorLayer) AuthenticateRequest(r *http.Request) (string, error) {
+	header := r.Header.Get(xhttp.Authorization)
+	if header == "" {
+		return "", nil
+	}
+	if header != "Bearer "+l.token {
+		return "", errors.New("invalid token")
+	}
+	return "token-user", nil
+}
+
+func (l tokenAuthenticatorLayer) AuthorizeRequest(principal string, action policy.Action, bucket, object string) bool {
+	return principal == "token-user" && action == policy.GetObjectAction && object == "test-object"
+}
+
+// Wrapper for calling GetObject HTTP handler tests with an object layer authenticating requests.
+func TestRequestAuthenticator(t *testing.T) {
+	globalPolicySys = NewPolicySys()
+	defer func() { globalPolicySys = nil }()
+
+	ExecObjectLayerAPITest(t, testRequestAuthenticator, []string{"GetObject"})
+}
+
+func testRequestAuthenticator(obj ObjectLayer, instanceType, bucketName string, apiRouter http.Handler,
+	credentials auth.Credentials, t *testing.T) {
+	layer := tokenAuthenticatorLayer{ObjectLayer: obj, token: "valid-token"}
+	globalObjLayerMutex.Lock()
+	globalObjectAPI = layer
+	globalObjLayerMutex.Unlock()
+	defer func() {
+		globalObjLayerMutex.Lock()
+		globalObjectAPI = obj
+		globalObjLayerMutex.Unlock()
+	}()
+
+	objectName := "test-object"
+	data := []byte("hello world")
+	if _, err := obj.PutObject(context.Background(), bucketName, objectName, mustGetPutObjReader(t, bytes.NewReader(data), int64(len(data)), "", ""), ObjectOptions{}); err != nil {
+		t.Fatalf("%s: Error uploading object: <ERROR> %v", instanceType, err)
+	}
+
+	handler := setAuthHandler(apiRouter)
+	testCases := []struct {
+		objectName         string
+		authorization      string
+		expectedRespStatus int
+	}{
+		// Test case - 1.
+		// The token accepted by the authenticator.
+		{objectName, "Bearer valid-token", http.StatusOK},
+		// Test case - 2.
+		// A token rejected by the authenticator.
+		{objectName, "Bearer invalid-token", http.StatusForbidden},
+		// Test case - 3.
+		// Requests without a token are left to the server, which denies anonymous access.
+		{objectName, "", http.StatusForbidden},
+		// Test case - 4.
+		// An authenticated principal the authenticator doesn't allow to read the object.
+		{"other-object", "Bearer valid-token", http.StatusForbidden},
+	}
+	for i, testCase := range testCases {
+		rec := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, getGetObjectURL("", bucketName, testCase.objectName), nil)
+		if err != nil {
+			t.Fatalf("Test %d: Failed to create HTTP request for Get Object: <ERROR> %v", i+1, err)
+		}
+		if testCase.authorization != "" {
+			req.Header.Set(xhttp.Authorization, testCase.authorization)
+		}
+		handler.ServeHTTP(rec, req)
+		if rec.Code != testCase.expectedRespStatus {
+			t.Fatalf("Test %d: %s: Expected the response status to be `%d`, but instead found `%d`", i+1, instanceType, testCase.expectedRespStatus, rec.Code)
+		}
+		if rec.Code == http.StatusOK && !bytes.Equal(rec.Body.Bytes(), data) {
+			t.Errorf("Test %d: %s: Object content differs from expected value", i+1, instanceType)
+		}
+	}
+
+	// Requests signed with AWS signatures are still verified by the server.
+	rec := httptest.NewRecorder()
+	req, err := newTestSignedRequestV4(http.MethodGet, getGetObjectURL("", bucketName, objectName), 0, nil, credentials.AccessKey, credentials.SecretKey, nil)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP request for Get Object: <ERROR> %v", err)
+	}
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: Expected signed requests to be allowed, but the response status was `%d`", instanceType, rec.Code)
+	}
+}
+
+// TestAuthenticateByObjectLayerScope - only S3 API requests are authenticated by the object layer.
+func TestAuthenticateByObjectLayerScope(t *testing.T) {
+	globalObjLayerMutex.Lock()
+	globalObjectAPI = tokenAuthenticatorLayer{token: "valid-token"}
+	globalObjLayerMutex.Unlock()
+	defer func() {
+		globalObjLayerMutex.Lock()
+		globalObjectAPI = nil
+		globalObjLayerMutex.Unlock()
+	}()
+
+	testCases := []struct {
+		url string
+		// whether the invalid token is seen by the authenticator, which rejects it
+		authenticated bool
+	}{
+		// Test case - 1.
+		// S3 API requests.
+		{"http://127.0.0.1:9000/bucket/object", true},
+		// Test case - 2.
+		// Admin requests.
+		{"http://127.0.0.1:9000" + adminPathPrefix + adminAPIVersionPrefix + "/info", false},
+		// Test case - 3.
+		// Web requests, with the JWTs of the server.
+		{"http://127.0.0.1:9000" + minioReservedBucketPath + "/webrpc", false},
+		// Test case - 4.
+		// STS requests.
+		{"http://127.0.0.1:9000/?Action=AssumeRoleWithWebIdentity", false},
+	}
+	for i, testCase := range testCases {
+		req, err := http.NewRequest(http.MethodPost, testCase.url, nil)
+		if err != nil {
+			t.Fatalf("Test %d: Failed to create HTTP request: <ERROR> %v", i+1, err)
+		}
+		req.Header.Set(xhttp.Authorization, "Bearer invalid-token")
+		req, err = authenticateByObjectLayer(req)
+		if (err != nil) != testCase.authenticated {
+			t.Fatalf("Test %d: Expected the request to be authenticated by the object layer: %v, but got error %v", i+1, testCase.authenticated, err)
+		}
+		if _, ok := getRequestPrincipal(req); ok {
+			t.Fatalf("Test %d: Expected no principal", i+1)
+		}
+	}
+}
@@ -177,8 +177,17 @@ func (api objectAPIHandlers) GetBucketLocationHandler(w http.ResponseWriter, r *
 
 	// Generate response.
 	encodedSuccessResponse := encodeResponse(LocationResponse{})
-	// Get current region.
+	// Get current region, or the bucket location if kept by the object layer.
 	region := globalServerRegion
+	if locator, ok := objectAPI.(BucketLocator); ok {
+		location, err := locator.GetBucketLocation(ctx, bucket)
+		if err != nil {
+			writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+			return
+		}
+		region = location
+	}
+	// us-east-1 is sent as an empty location constraint, as AWS does.
 	if region != globalMinioDefaultRegion {
 		encodedSuccessResponse = encodeResponse(LocationResponse{
 			Location: region,
@@ -504,7 +513,9 @@ func (api objectAPIHandlers) PutBucketHandler(w http.ResponseWriter, r *http.Req
 
 	// Validate if location sent by the client is valid, reject
 	// requests which do not follow valid region requirements.
-	if !isValidLocation(location) {
+	// Object layers keeping bucket locations accept any well formed location.
+	_, locator := objectAPI.(BucketLocator)
+	if (locator && !isValidLocationName(location)) || (!locator && !isValidLocation(location)) {
 		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrInvalidRegion), r.URL, guessIsBrowserReq(r))
 		return
 	}
@@ -865,6 +876,16 @@ func (api objectAPIHandlers) HeadBucketHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	// Report the bucket location if kept by the object layer, instead of the server region.
+	if locator, ok := objectAPI.(BucketLocator); ok {
+		location, err := locator.GetBucketLocation(ctx, bucket)
+		if err != nil {
+			writeErrorResponseHeadersOnly(w, toAPIError(ctx, err))
+			return
+		}
+		w.Header().Set(xhttp.AmzBucketRegion, location)
+	}
+
 	writeSuccessResponseHeadersOnly(w)
 }
 
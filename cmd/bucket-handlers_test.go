@@ -20,13 +20,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/xml"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/RTradeLtd/s3x/pkg/auth"
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
 )
 
 // Wrapper for calling GetBucketPolicy HTTP handler tests for both XL multiple disks and single node setup.
@@ -173,6 +176,144 @@ func testGetBucketLocationHandler(obj ObjectLayer, instanceType, bucketName stri
 	ExecObjectLayerAPINilTest(t, nilBucket, "", instanceType, apiRouter, nilReq)
 }
 
+// bucketPolicyLayer is an object layer keeping bucket policies itself, like the s3x gateway.
+type bucketPolicyLayer struct {
+	ObjectLayer
+	policies map[string]*policy.Policy
+}
+
+func (l bucketPolicyLayer) GetBucketPolicy(ctx context.Context, bucket string) (*policy.Policy, error) {
+	if p, ok := l.policies[bucket]; ok {
+		return p, nil
+	}
+	return nil, BucketPolicyNotFound{Bucket: bucket}
+}
+
+// Wrapper for calling unsigned ListObjects HTTP handler tests with a gateway object layer keeping bucket policies.
+func TestListObjectsHandlerAnonymousBucketPolicy(t *testing.T) {
+	globalPolicySys = NewPolicySys()
+	defer func() { globalPolicySys = nil }()
+
+	ExecObjectLayerAPITest(t, testListObjectsHandlerAnonymousBucketPolicy, []string{"ListObjectsV1", "ListObjectsV2"})
+}
+
+func testListObjectsHandlerAnonymousBucketPolicy(obj ObjectLayer, instanceType, bucketName string, apiRouter http.Handler,
+	credentials auth.Credentials, t *testing.T) {
+	publicList := fmt.Sprintf(`{
+	"Version": "2012-10-17",
+	"Statement": [{
+		"Effect": "Allow",
+		"Principal": {"AWS": ["*"]},
+		"Action": ["s3:ListBucket"],
+		"Resource": ["arn:aws:s3:::%s"]
+	}]
+}`, bucketName)
+	p, err := policy.ParseConfig(strings.NewReader(publicList), bucketName)
+	if err != nil {
+		t.Fatalf("%s: Failed to parse the bucket policy: <ERROR> %v", instanceType, err)
+	}
+	privateBucket := getRandomBucketName()
+	if err := obj.MakeBucketWithLocation(context.Background(), privateBucket, ""); err != nil {
+		t.Fatalf("%s: Failed to make bucket: <ERROR> %v", instanceType, err)
+	}
+	// gateways are asked for the policy of every anonymous request, instead of the policy cache
+	globalIsGateway = true
+	globalObjLayerMutex.Lock()
+	globalObjectAPI = bucketPolicyLayer{ObjectLayer: obj, policies: map[string]*policy.Policy{bucketName: p}}
+	globalObjLayerMutex.Unlock()
+	defer func() {
+		globalIsGateway = false
+		globalObjLayerMutex.Lock()
+		globalObjectAPI = obj
+		globalObjLayerMutex.Unlock()
+	}()
+
+	testCases := []struct {
+		url                string
+		expectedRespStatus int
+	}{
+		// Test case - 1.
+		// A bucket with a public list policy is listed with ListObjectsV1.
+		{getListObjectsV1URL("", bucketName, "", "", ""), http.StatusOK},
+		// Test case - 2.
+		// A bucket with a public list policy is listed with ListObjectsV2.
+		{getListObjectsV2URL("", bucketName, "", "", "", ""), http.StatusOK},
+		// Test case - 3.
+		// A bucket without a policy is not listed with ListObjectsV1.
+		{getListObjectsV1URL("", privateBucket, "", "", ""), http.StatusForbidden},
+		// Test case - 4.
+		// A bucket without a policy is not listed with ListObjectsV2.
+		{getListObjectsV2URL("", privateBucket, "", "", "", ""), http.StatusForbidden},
+	}
+	for i, testCase := range testCases {
+		rec := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, testCase.url, nil)
+		if err != nil {
+			t.Fatalf("Test %d: Failed to create HTTP request for ListObjects: <ERROR> %v", i+1, err)
+		}
+		apiRouter.ServeHTTP(rec, req)
+		if rec.Code != testCase.expectedRespStatus {
+			t.Errorf("Test %d: %s: Expected the response status to be `%d`, but instead found `%d`", i+1, instanceType, testCase.expectedRespStatus, rec.Code)
+		}
+	}
+}
+
+// bucketLocatorLayer is an object layer keeping bucket locations, like the s3x gateway.
+type bucketLocatorLayer struct {
+	ObjectLayer
+	locations map[string]string
+}
+
+func (l bucketLocatorLayer) GetBucketLocation(ctx context.Context, bucket string) (string, error) {
+	return l.locations[bucket], nil
+}
+
+// Wrapper for calling GetBucketLocation HTTP handler tests with an object layer keeping bucket locations.
+func TestGetBucketLocationHandlerBucketLocator(t *testing.T) {
+	ExecObjectLayerAPITest(t, testGetBucketLocationHandlerBucketLocator, []string{"GetBucketLocation"})
+}
+
+func testGetBucketLocationHandlerBucketLocator(obj ObjectLayer, instanceType, bucketName string, apiRouter http.Handler,
+	credentials auth.Credentials, t *testing.T) {
+	layer := bucketLocatorLayer{ObjectLayer: obj, locations: make(map[string]string)}
+	globalObjLayerMutex.Lock()
+	globalObjectAPI = layer
+	globalObjLayerMutex.Unlock()
+	defer func() {
+		globalObjLayerMutex.Lock()
+		globalObjectAPI = obj
+		globalObjLayerMutex.Unlock()
+	}()
+
+	testCases := []struct {
+		location string
+		// expected location constraint, empty for us-east-1 by the AWS convention.
+		expectedLocation string
+	}{
+		{location: globalMinioDefaultRegion, expectedLocation: ""},
+		{location: "eu-west-1", expectedLocation: "eu-west-1"},
+	}
+	for i, testCase := range testCases {
+		layer.locations[bucketName] = testCase.location
+		rec := httptest.NewRecorder()
+		req, err := newTestSignedRequestV4("GET", getBucketLocationURL("", bucketName), 0, nil, credentials.AccessKey, credentials.SecretKey, nil)
+		if err != nil {
+			t.Fatalf("Test %d: %s: Failed to create HTTP request for GetBucketLocationHandler: <ERROR> %v", i+1, instanceType, err)
+		}
+		apiRouter.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Test %d: %s: Expected the response status to be `%d`, but instead found `%d`", i+1, instanceType, http.StatusOK, rec.Code)
+		}
+		locationResponse := LocationResponse{}
+		if err = xml.Unmarshal(rec.Body.Bytes(), &locationResponse); err != nil {
+			t.Fatalf("Test %d: %s: Unable to unmarshal response body %s", i+1, instanceType, rec.Body.String())
+		}
+		if locationResponse.Location != testCase.expectedLocation {
+			t.Errorf("Test %d: %s: Expected the location to be `%s`, but instead found `%s`", i+1, instanceType, testCase.expectedLocation, locationResponse.Location)
+		}
+	}
+}
+
 // Wrapper for calling HeadBucket HTTP handler tests for both XL multiple disks and single node setup.
 func TestHeadBucketHandler(t *testing.T) {
 	ExecObjectLayerAPITest(t, testHeadBucketHandler, []string{"HeadBucket"})
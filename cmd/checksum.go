@@ -0,0 +1,243 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+	sha256 "github.com/minio/sha256-simd"
+)
+
+// unsignedPayloadTrailer is the 'x-amz-content-sha256' value of requests
+// with an unsigned aws-chunked payload followed by trailing headers.
+const unsignedPayloadTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+// checksumAlgorithms maps the supported additional checksum headers to their hash functions.
+var checksumAlgorithms = map[string]func() hash.Hash{
+	xhttp.AmzChecksumCRC32:  func() hash.Hash { return crc32.NewIEEE() },
+	xhttp.AmzChecksumCRC32C: func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) },
+	xhttp.AmzChecksumSHA1:   sha1.New,
+	xhttp.AmzChecksumSHA256: sha256.New,
+}
+
+// ChecksumMismatch - when the additional checksum of the content does not match the requested one.
+type ChecksumMismatch struct {
+	Header             string
+	ExpectedChecksum   string
+	CalculatedChecksum string
+}
+
+func (e ChecksumMismatch) Error() string {
+	return "Bad checksum: " + e.Header + " expected " + e.ExpectedChecksum + " calculated " + e.CalculatedChecksum
+}
+
+// isRequestUnsignedTrailer - returns true if the payload is unsigned aws-chunked with trailing headers.
+func isRequestUnsignedTrailer(r *http.Request) bool {
+	return r.Header.Get(xhttp.AmzContentSha256) == unsignedPayloadTrailer &&
+		r.Method == http.MethodPut
+}
+
+// getChecksumHeader returns the lower case checksum header requested by the client
+// and its value, the value is empty if the checksum is sent as a trailer.
+// An empty header is returned if no additional checksum is requested.
+func getChecksumHeader(r *http.Request) (header, value string, s3Err APIErrorCode) {
+	if trailer := r.Header.Get(xhttp.AmzTrailer); trailer != "" {
+		header = strings.ToLower(strings.TrimSpace(trailer))
+		if _, ok := checksumAlgorithms[header]; !ok || !isRequestUnsignedTrailer(r) {
+			return "", "", ErrInvalidChecksum
+		}
+		return header, "", ErrNone
+	}
+	for h := range checksumAlgorithms {
+		if v := r.Header.Get(h); v != "" {
+			if header != "" {
+				// only one additional checksum may be sent
+				return "", "", ErrInvalidChecksum
+			}
+			header, value = h, v
+		}
+	}
+	if algo := r.Header.Get(xhttp.AmzChecksumAlgo); algo != "" && header == "" {
+		// the algorithm was requested, but the checksum was not sent
+		return "", "", ErrInvalidChecksum
+	}
+	return header, value, ErrNone
+}
+
+// checksumReader computes an additional checksum of the data read through it
+// and validates it against the expected value once the data is consumed.
+//
+// On success the checksum is recorded in metadata, so object layers must
+// read it after the data has been consumed, as the value of a trailing
+// checksum is not known before.
+type checksumReader struct {
+	reader   io.Reader
+	header   string
+	expected string
+	trailer  *unsignedTrailerReader
+	hasher   hash.Hash
+	metadata map[string]string
+	size     int64 // expected length of the data, -1 if unknown
+	n        int64 // bytes read so far
+	verified bool
+}
+
+// newChecksumReader returns a reader validating the header checksum of the size bytes of data
+// in r, the expected value is taken from trailer if expected is empty. A size of -1 validates
+// the data once r returns io.EOF.
+func newChecksumReader(r io.Reader, size int64, header, expected string, trailer *unsignedTrailerReader, metadata map[string]string) *checksumReader {
+	return &checksumReader{
+		reader:   r,
+		header:   header,
+		expected: expected,
+		trailer:  trailer,
+		hasher:   checksumAlgorithms[header](),
+		metadata: metadata,
+		size:     size,
+	}
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	if c.verified {
+		return 0, io.EOF
+	}
+	n, err := c.reader.Read(p)
+	c.hasher.Write(p[:n])
+	c.n += int64(n)
+	if err == nil && c.size >= 0 && c.n >= c.size {
+		// Readers limited to the content length, such as hash.Reader, stop
+		// once size bytes are read without reading io.EOF from us, so the rest
+		// of the body, which holds the trailer, is consumed here.
+		if _, err = io.Copy(ioutil.Discard, c.reader); err == nil {
+			err = io.EOF
+		}
+	}
+	if err == io.EOF {
+		if cerr := c.verify(); cerr != nil {
+			return n, cerr
+		}
+		c.verified = true
+	}
+	return n, err
+}
+
+func (c *checksumReader) verify() error {
+	expected := c.expected
+	if expected == "" && c.trailer != nil {
+		expected = c.trailer.Trailer.Get(c.header)
+	}
+	calculated := base64.StdEncoding.EncodeToString(c.hasher.Sum(nil))
+	if expected != calculated {
+		return ChecksumMismatch{
+			Header:             c.header,
+			ExpectedChecksum:   expected,
+			CalculatedChecksum: calculated,
+		}
+	}
+	c.metadata[c.header] = calculated
+	return nil
+}
+
+// unsignedTrailerReader decodes an unsigned aws-chunked payload,
+// the trailing headers are available in Trailer once io.EOF is returned.
+type unsignedTrailerReader struct {
+	reader  *bufio.Reader
+	n       uint64 // unread bytes in the current chunk
+	done    bool
+	err     error
+	Trailer http.Header
+}
+
+func newUnsignedTrailerReader(r io.Reader) *unsignedTrailerReader {
+	return &unsignedTrailerReader{
+		reader:  bufio.NewReader(r),
+		Trailer: make(http.Header),
+	}
+}
+
+func (t *unsignedTrailerReader) Read(p []byte) (n int, err error) {
+	for t.err == nil && !t.done && t.n == 0 {
+		t.err = t.readChunkHeader()
+	}
+	if t.err != nil {
+		return 0, t.err
+	}
+	if t.done {
+		return 0, io.EOF
+	}
+	if uint64(len(p)) > t.n {
+		p = p[:t.n]
+	}
+	n, err = t.reader.Read(p)
+	t.n -= uint64(n)
+	if t.n == 0 && err == nil {
+		err = readCRLF(t.reader)
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	t.err = err
+	return n, err
+}
+
+// readChunkHeader reads the size of the next chunk,
+// or the trailing headers after the final 0-length chunk.
+func (t *unsignedTrailerReader) readChunkHeader() error {
+	size, _, err := readChunkLine(t.reader)
+	if err != nil {
+		return err
+	}
+	if t.n, err = parseHexUint(size); err != nil {
+		return err
+	}
+	if t.n == 0 {
+		t.done = true
+		return t.readTrailer()
+	}
+	return nil
+}
+
+func (t *unsignedTrailerReader) readTrailer() error {
+	for {
+		line, err := t.reader.ReadSlice('\n')
+		if err == io.EOF && len(bytes.TrimSpace(line)) == 0 {
+			return nil // final CRLF is optional
+		}
+		if err != nil {
+			return errMalformedEncoding
+		}
+		line = trimTrailingWhitespace(line)
+		if len(line) == 0 {
+			return nil
+		}
+		i := bytes.IndexByte(line, ':')
+		if i <= 0 {
+			return errMalformedEncoding
+		}
+		t.Trailer.Set(string(bytes.TrimSpace(line[:i])), string(bytes.TrimSpace(line[i+1:])))
+	}
+}
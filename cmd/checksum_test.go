@@ -0,0 +1,148 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+	"github.com/RTradeLtd/s3x/pkg/hash"
+)
+
+// Tests validation of checksums sent as trailers of an unsigned aws-chunked payload.
+func TestChecksumReaderTrailer(t *testing.T) {
+	testCases := []struct {
+		body     string
+		wantData string
+		wantErr  bool
+	}{
+		// CRC32C of "123456789" is 0xE3069283
+		{"5\r\n12345\r\n4\r\n6789\r\n0\r\nx-amz-checksum-crc32c:4waSgw==\r\n\r\n", "123456789", false},
+		// final CRLF omitted
+		{"9\r\n123456789\r\n0\r\nx-amz-checksum-crc32c:4waSgw==\r\n", "123456789", false},
+		// mismatch
+		{"9\r\n123456780\r\n0\r\nx-amz-checksum-crc32c:4waSgw==\r\n\r\n", "", true},
+		// missing trailer
+		{"9\r\n123456789\r\n0\r\n\r\n", "", true},
+	}
+	for i, testCase := range testCases {
+		metadata := make(map[string]string)
+		trailer := newUnsignedTrailerReader(strings.NewReader(testCase.body))
+		r := newChecksumReader(trailer, -1, xhttp.AmzChecksumCRC32C, "", trailer, metadata)
+		data, err := ioutil.ReadAll(r)
+		if testCase.wantErr {
+			if _, ok := err.(ChecksumMismatch); !ok {
+				t.Errorf("Test %d: expected ChecksumMismatch, but got %v", i+1, err)
+			}
+			if _, ok := metadata[xhttp.AmzChecksumCRC32C]; ok {
+				t.Errorf("Test %d: checksum should not be recorded on mismatch", i+1)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test %d: %v", i+1, err)
+		}
+		if string(data) != testCase.wantData {
+			t.Errorf("Test %d: expected data %q, but got %q", i+1, testCase.wantData, data)
+		}
+		if metadata[xhttp.AmzChecksumCRC32C] != "4waSgw==" {
+			t.Errorf("Test %d: expected checksum to be recorded, but got %q", i+1, metadata[xhttp.AmzChecksumCRC32C])
+		}
+	}
+}
+
+// Tests validation of checksums sent as headers.
+func TestChecksumReaderHeader(t *testing.T) {
+	metadata := make(map[string]string)
+	r := newChecksumReader(strings.NewReader("123456789"), -1, xhttp.AmzChecksumCRC32C, "4waSgw==", nil, metadata)
+	if _, err := ioutil.ReadAll(r); err != nil {
+		t.Fatal(err)
+	}
+	r = newChecksumReader(strings.NewReader("123456789"), -1, xhttp.AmzChecksumCRC32C, "AAAAAA==", nil, metadata)
+	if _, err := ioutil.ReadAll(r); err == nil {
+		t.Fatal("expected checksum mismatch")
+	}
+}
+
+// Tests validation of checksums read through a reader limited to the content length,
+// which stops before the end of the body that holds the trailer.
+func TestChecksumReaderLimited(t *testing.T) {
+	testCases := []struct {
+		body    string
+		wantErr bool
+	}{
+		{"9\r\n123456789\r\n0\r\nx-amz-checksum-crc32c:4waSgw==\r\n\r\n", false},
+		{"9\r\n123456780\r\n0\r\nx-amz-checksum-crc32c:4waSgw==\r\n\r\n", true},
+	}
+	for i, testCase := range testCases {
+		metadata := make(map[string]string)
+		trailer := newUnsignedTrailerReader(strings.NewReader(testCase.body))
+		r, err := hash.NewReader(newChecksumReader(trailer, 9, xhttp.AmzChecksumCRC32C, "", trailer, metadata), 9, "", "", 9, false)
+		if err != nil {
+			t.Fatalf("Test %d: %v", i+1, err)
+		}
+		_, err = ioutil.ReadAll(r)
+		if testCase.wantErr {
+			if _, ok := err.(ChecksumMismatch); !ok {
+				t.Errorf("Test %d: expected ChecksumMismatch, but got %v", i+1, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test %d: %v", i+1, err)
+		}
+		if metadata[xhttp.AmzChecksumCRC32C] != "4waSgw==" {
+			t.Errorf("Test %d: expected checksum to be recorded, but got %q", i+1, metadata[xhttp.AmzChecksumCRC32C])
+		}
+	}
+}
+
+// Tests parsing of the requested checksum header.
+func TestGetChecksumHeader(t *testing.T) {
+	testCases := []struct {
+		header     http.Header
+		wantHeader string
+		wantValue  string
+		wantErr    APIErrorCode
+	}{
+		{http.Header{}, "", "", ErrNone},
+		{http.Header{"X-Amz-Checksum-Crc32c": {"4waSgw=="}}, xhttp.AmzChecksumCRC32C, "4waSgw==", ErrNone},
+		{http.Header{
+			"X-Amz-Trailer":        {"x-amz-checksum-crc32c"},
+			"X-Amz-Content-Sha256": {unsignedPayloadTrailer},
+		}, xhttp.AmzChecksumCRC32C, "", ErrNone},
+		{http.Header{"X-Amz-Trailer": {"x-amz-checksum-crc32c"}}, "", "", ErrInvalidChecksum},
+		{http.Header{
+			"X-Amz-Trailer":        {"x-amz-checksum-md5"},
+			"X-Amz-Content-Sha256": {unsignedPayloadTrailer},
+		}, "", "", ErrInvalidChecksum},
+		{http.Header{"X-Amz-Sdk-Checksum-Algorithm": {"CRC32C"}}, "", "", ErrInvalidChecksum},
+	}
+	for i, testCase := range testCases {
+		r := &http.Request{Method: http.MethodPut, Header: testCase.header}
+		header, value, err := getChecksumHeader(r)
+		if err != testCase.wantErr {
+			t.Fatalf("Test %d: expected error %v, but got %v", i+1, testCase.wantErr, err)
+		}
+		if header != testCase.wantHeader || value != testCase.wantValue {
+			t.Errorf("Test %d: expected %q=%q, but got %q=%q", i+1, testCase.wantHeader, testCase.wantValue, header, value)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package s3x
+
+import (
+	"context"
+	"time"
+)
+
+// recordAccess records a read of the object data, at most once per accessInterval per object.
+// A failure to record it doesn't fail the read.
+func (x *xObjects) recordAccess(ctx context.Context, bucket, object string) {
+	if x.accessInterval <= 0 {
+		return
+	}
+	_ = x.ledgerStore.SetObjectLastAccess(ctx, bucket, object, time.Now(), x.accessInterval)
+}
+
+// SetBucketAccessExpiry makes the lifecycle remove objects of a bucket that were not read for
+// the given number of days, 0 disables it. Reads are only recorded if TEMX.AccessInterval is
+// set, without them objects expire the given number of days after they were last written.
+func (x *xObjects) SetBucketAccessExpiry(ctx context.Context, bucket string, days int) error {
+	return x.toMinioErr(x.setBucketNumber(bucket, bucketConfigAccessExpiry, int64(days)), bucket, "", "")
+}
+
+// GetBucketAccessExpiry returns the number of days without reads after which objects of
+// a bucket expire, or 0 if they don't.
+func (x *xObjects) GetBucketAccessExpiry(ctx context.Context, bucket string) (int, error) {
+	days, err := x.bucketAccessExpiry(bucket)
+	return days, x.toMinioErr(err, bucket, "", "")
+}
+
+// bucketAccessExpiry returns the access expiry of a bucket in days, or 0 if none is set
+func (x *xObjects) bucketAccessExpiry(bucket string) (int, error) {
+	days, err := x.bucketNumber(bucket, bucketConfigAccessExpiry)
+	return int(days), err
+}
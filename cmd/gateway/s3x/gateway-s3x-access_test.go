@@ -0,0 +1,137 @@
+package s3x
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_AccessExpiry(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	if err := gateway.SetBucketVersionHistory(ctx, testBucket1, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	gateway.accessInterval = time.Hour
+	lastAccess := func(t *testing.T) time.Time {
+		last, ok, err := gateway.ledgerStore.getLastAccess(testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Fatal("expected the read to be recorded")
+		}
+		return last
+	}
+	read := func(t *testing.T) time.Time {
+		if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, 0, ioutil.Discard, "", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		return lastAccess(t)
+	}
+	hash, _, _, err := gateway.ledgerStore.ObjectVersionHashes(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := read(t)
+	if got := read(t); !got.Equal(first) {
+		t.Fatalf("expected a read within the access interval to not be recorded, but got %v after %v", got, first)
+	}
+	after, _, versions, err := gateway.ledgerStore.ObjectVersionHashes(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after != hash || len(versions) != 0 {
+		t.Fatalf("expected reads not to rewrite the object, but got %v with versions %v", after, versions)
+	}
+
+	if err := gateway.SetBucketAccessExpiry(ctx, testBucket1, 30); err != nil {
+		t.Fatal(err)
+	}
+	if days, err := gateway.GetBucketAccessExpiry(ctx, testBucket1); err != nil || days != 30 {
+		t.Fatalf("expected an access expiry of 30 days, but got %v, %v", days, err)
+	}
+	now := time.Now()
+	removed, err := gateway.applyBucketLifecycle(ctx, testBucket1, now.Add(29*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 0 {
+		t.Fatalf("expected no objects to expire within 30 days of the last read, but got %v", removed)
+	}
+	removed, err = gateway.applyBucketLifecycle(ctx, testBucket1, now.Add(31*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0] != testObject1 {
+		t.Fatalf("expected %v to expire 30 days after the last read, but got %v", testObject1, removed)
+	}
+	if _, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{}); !isObjectNotFound(err) {
+		t.Fatal("expected the expired object to be removed, but got", err)
+	}
+	if _, ok, err := gateway.ledgerStore.getLastAccess(testBucket1, testObject1); err != nil || ok {
+		t.Fatalf("expected the last access of the removed object to be removed, but got %v, %v", ok, err)
+	}
+}
+
+func TestS3X_AccessRecorded(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	gateway.accessInterval = time.Hour
+	gateway.compressTypes = []string{"text/*"}
+	assertRecorded := func(t *testing.T, object string) {
+		if _, ok, err := gateway.ledgerStore.getLastAccess(testBucket1, object); err != nil || !ok {
+			t.Fatalf("expected the read of %v to be recorded, but got %v, %v", object, ok, err)
+		}
+	}
+
+	t.Run("append-only", func(t *testing.T) {
+		if err := gateway.SetBucketAppendOnly(ctx, testBucket1, true); err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := gateway.SetBucketAppendOnly(ctx, testBucket1, false); err != nil {
+				t.Fatal(err)
+			}
+		}()
+		if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, 0, ioutil.Discard, "", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		assertRecorded(t, testObject1)
+	})
+	t.Run("gzip", func(t *testing.T) {
+		const object = "compressed"
+		data := []byte(strings.Repeat("compressible text data ", 1000))
+		opts := minio.ObjectOptions{UserDefined: map[string]string{"content-type": "text/plain"}}
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, data), opts); err != nil {
+			t.Fatal(err)
+		}
+		h := http.Header{}
+		h.Set("Accept-Encoding", "gzip")
+		gr, err := gateway.GetObjectNInfo(ctx, testBucket1, object, nil, h, 0, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer gr.Close()
+		if _, err := ioutil.ReadAll(gr); err != nil {
+			t.Fatal(err)
+		}
+		if gr.ObjInfo.ContentEncoding != "gzip" {
+			t.Fatalf("expected the object to be served compressed, but got %q", gr.ObjInfo.ContentEncoding)
+		}
+		assertRecorded(t, object)
+	})
+}
@@ -0,0 +1,19 @@
+package s3x
+
+import (
+	"context"
+)
+
+// SetBucketAppendOnly makes a bucket append-only, new objects can be added to it, but existing
+// objects can't be overwritten, copied over or restored to a prior version. Only their storage
+// class and legal hold can still change.
+// Unlike retention, there's no time limit, objects are protected until the bucket is no longer append-only.
+func (x *xObjects) SetBucketAppendOnly(ctx context.Context, bucket string, appendOnly bool) error {
+	return x.toMinioErr(x.setBucketFlag(bucket, bucketConfigAppendOnly, appendOnly), bucket, "", "")
+}
+
+// GetBucketAppendOnly returns true if the bucket is append-only
+func (x *xObjects) GetBucketAppendOnly(ctx context.Context, bucket string) (bool, error) {
+	appendOnly, err := x.bucketFlag(bucket, bucketConfigAppendOnly)
+	return appendOnly, x.toMinioErr(err, bucket, "", "")
+}
@@ -0,0 +1,92 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_BucketAppendOnly(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for _, bucket := range []string{testBucket1, testBucket2} {
+		if err := gateway.MakeBucketWithLocation(ctx, bucket, "us-east-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gateway.SetBucketAppendOnly(ctx, testBucket1, true); err != nil {
+		t.Fatal(err)
+	}
+	if appendOnly, err := gateway.GetBucketAppendOnly(ctx, testBucket1); err != nil || !appendOnly {
+		t.Fatalf("expected bucket to be append-only, but got %v (%v)", appendOnly, err)
+	}
+	put := func(bucket, object, data string) error {
+		_, err := gateway.PutObject(ctx, bucket, object, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{})
+		return err
+	}
+	t.Run("append-only", func(t *testing.T) {
+		if err := put(testBucket1, testObject1, "first"); err != nil {
+			t.Fatal(err)
+		}
+		if err := put(testBucket1, "new-object", "new"); err != nil {
+			t.Fatalf("expected new objects to be allowed, but got %v", err)
+		}
+		if _, ok := put(testBucket1, testObject1, "second").(minio.ObjectAlreadyExists); !ok {
+			t.Fatal("expected ObjectAlreadyExists when overwriting an object")
+		}
+		if _, ok := put(testBucket1, testObject1, "first").(minio.ObjectAlreadyExists); !ok {
+			t.Fatal("expected ObjectAlreadyExists when overwriting an object with the same data")
+		}
+		srcInfo, err := gateway.GetObjectInfo(ctx, testBucket1, "new-object", minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = gateway.CopyObject(ctx, testBucket1, "new-object", testBucket1, testObject1, srcInfo, minio.ObjectOptions{}, minio.ObjectOptions{})
+		if _, ok := err.(minio.ObjectAlreadyExists); !ok {
+			t.Fatalf("expected ObjectAlreadyExists when copying over an object, but got %v", err)
+		}
+		info, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size != int64(len("first")) {
+			t.Fatalf("expected the original object to be kept, but got size %v", info.Size)
+		}
+	})
+	t.Run("metadata", func(t *testing.T) {
+		if err := gateway.TransitionObject(ctx, testBucket1, testObject1, storageClassCold); err != nil {
+			t.Fatalf("expected metadata changes to be allowed, but got %v", err)
+		}
+		info, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.StorageClass != storageClassCold {
+			t.Fatalf("expected storage class %v, but got %v", storageClassCold, info.StorageClass)
+		}
+	})
+	t.Run("normal", func(t *testing.T) {
+		if appendOnly, err := gateway.GetBucketAppendOnly(ctx, testBucket2); err != nil || appendOnly {
+			t.Fatalf("expected bucket not to be append-only, but got %v (%v)", appendOnly, err)
+		}
+		for _, data := range []string{"first", "second"} {
+			if err := put(testBucket2, testObject1, data); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	t.Run("disabled", func(t *testing.T) {
+		if err := gateway.SetBucketAppendOnly(ctx, testBucket1, false); err != nil {
+			t.Fatal(err)
+		}
+		if err := put(testBucket1, testObject1, "third"); err != nil {
+			t.Fatalf("expected overwrites once append-only is disabled, but got %v", err)
+		}
+	})
+}
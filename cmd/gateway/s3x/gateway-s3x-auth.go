@@ -0,0 +1,25 @@
+package s3x
+
+import (
+	"net/http"
+
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+)
+
+// AuthenticateRequest authenticates requests with TEMX.Authenticator, requests are left to the
+// gateway to authenticate with AWS signatures if it's not configured.
+func (x *xObjects) AuthenticateRequest(r *http.Request) (string, error) {
+	if x.authenticator == nil {
+		return "", nil
+	}
+	return x.authenticator.AuthenticateRequest(r)
+}
+
+// AuthorizeRequest authorizes the principals of requests authenticated with TEMX.Authenticator,
+// no principal is allowed anything if it's not configured.
+func (x *xObjects) AuthorizeRequest(principal string, action policy.Action, bucket, object string) bool {
+	if x.authenticator == nil {
+		return false
+	}
+	return x.authenticator.AuthorizeRequest(principal, action, bucket, object)
+}
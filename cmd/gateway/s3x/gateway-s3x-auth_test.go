@@ -0,0 +1,94 @@
+package s3x
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+)
+
+// headerAuthenticator authenticates requests with a token in the X-Token header,
+// and only allows reads of testBucket1
+type headerAuthenticator struct{}
+
+func (headerAuthenticator) AuthenticateRequest(r *http.Request) (string, error) {
+	switch token := r.Header.Get("X-Token"); token {
+	case "":
+		return "", nil
+	case "valid":
+		return "token-user", nil
+	default:
+		return "", errors.New("invalid token")
+	}
+}
+
+func (headerAuthenticator) AuthorizeRequest(principal string, action policy.Action, bucket, object string) bool {
+	return principal == "token-user" && action == policy.GetObjectAction && bucket == testBucket1
+}
+
+func TestS3X_AuthenticateRequest(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	var _ minio.RequestAuthenticator = gateway
+	newRequest := func(t *testing.T, token string) *http.Request {
+		r, err := http.NewRequest(http.MethodGet, "http://localhost/"+testBucket1, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			r.Header.Set("X-Token", token)
+		}
+		return r
+	}
+
+	t.Run("Unset", func(t *testing.T) {
+		principal, err := gateway.AuthenticateRequest(newRequest(t, "valid"))
+		if err != nil || principal != "" {
+			t.Fatalf("expected requests to be left to the gateway, got %q, %v", principal, err)
+		}
+	})
+
+	gateway.authenticator = headerAuthenticator{}
+	tests := []struct {
+		name          string
+		token         string
+		wantPrincipal string
+		wantErr       bool
+	}{
+		{"Valid", "valid", "token-user", false},
+		{"Invalid", "invalid", "", true},
+		{"Missing", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := gateway.AuthenticateRequest(newRequest(t, tt.token))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AuthenticateRequest() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if principal != tt.wantPrincipal {
+				t.Fatalf("AuthenticateRequest() principal = %q, want %q", principal, tt.wantPrincipal)
+			}
+		})
+	}
+
+	t.Run("Authorize", func(t *testing.T) {
+		if !gateway.AuthorizeRequest("token-user", policy.GetObjectAction, testBucket1, testObject1) {
+			t.Fatal("expected the authenticator to allow the request")
+		}
+		if gateway.AuthorizeRequest("token-user", policy.PutObjectAction, testBucket1, testObject1) {
+			t.Fatal("expected the authenticator to deny the request")
+		}
+		gateway.authenticator = nil
+		if gateway.AuthorizeRequest("token-user", policy.GetObjectAction, testBucket1, testObject1) {
+			t.Fatal("expected principals to be denied without an authenticator")
+		}
+	})
+}
@@ -10,11 +10,20 @@ import (
 
 var isTest = false
 
+// defaultContentType is the content type used by s3 for objects uploaded without one
+const defaultContentType = "application/octet-stream"
+
 // MakeBucket creates a new bucket container within TemporalX.
 func (x *xObjects) MakeBucketWithLocation(
 	ctx context.Context,
 	name, location string,
 ) error {
+	if err := x.checkWritable(); err != nil {
+		return err
+	}
+	if location == "" {
+		location = x.defaultRegion
+	}
 	b := &Bucket{BucketInfo: BucketInfo{
 		Location: location,
 	}}
@@ -22,6 +31,9 @@ func (x *xObjects) MakeBucketWithLocation(
 		b.BucketInfo.Created = time.Now().UTC()
 	}
 	hash, err := x.ledgerStore.CreateBucket(ctx, name, b)
+	if err == ErrLedgerBucketExists {
+		return x.recreateBucket(ctx, name, location)
+	}
 	if err != nil {
 		return x.toMinioErr(err, name, "", "")
 	}
@@ -29,6 +41,21 @@ func (x *xObjects) MakeBucketWithLocation(
 	return nil
 }
 
+// recreateBucket answers a create of an existing bucket the way S3 does for buckets of the requester.
+// The gateway serves a single account, so every bucket is owned by the requester: a create in the
+// location of the bucket succeeds without changing it, and a create in another location fails
+// with BucketAlreadyOwnedByYou.
+func (x *xObjects) recreateBucket(ctx context.Context, name, location string) error {
+	info, err := x.ledgerStore.GetBucketInfo(ctx, name)
+	if err != nil {
+		return x.toMinioErr(err, name, "", "")
+	}
+	if info.GetLocation() != location {
+		return minio.BucketAlreadyOwnedByYou{Bucket: name}
+	}
+	return nil
+}
+
 // GetBucketInfo gets bucket metadata..
 func (x *xObjects) GetBucketInfo(
 	ctx context.Context,
@@ -48,27 +75,104 @@ func (x *xObjects) GetBucketInfo(
 	}, nil
 }
 
-// ListBuckets lists all S3 buckets
+// GetBucketLocation returns the location constraint the bucket was created with,
+// buckets created without one are in the default region.
+func (x *xObjects) GetBucketLocation(ctx context.Context, bucket string) (string, error) {
+	b, err := x.ledgerStore.GetBucketInfo(ctx, bucket)
+	if err != nil {
+		return "", x.toMinioErr(err, bucket, "", "")
+	}
+	if b.Location == "" {
+		return x.defaultRegion, nil
+	}
+	return b.Location, nil
+}
+
+// ListBuckets lists all S3 buckets in name order
 func (x *xObjects) ListBuckets(ctx context.Context) ([]minio.BucketInfo, error) {
+	infos, _, err := x.ListBucketsPage(ctx, "", 0)
+	return infos, err
+}
+
+// ListBucketsPage lists up to maxBuckets buckets after marker in name order, all buckets
+// after marker are listed if maxBuckets is 0. nextMarker is the marker of the next page,
+// or empty if there are no more buckets.
+func (x *xObjects) ListBucketsPage(ctx context.Context, marker string, maxBuckets int) (infos []minio.BucketInfo, nextMarker string, err error) {
 	// TODO(bonedaddy): decide if we should handle a minio error here
-	names, err := x.ledgerStore.GetBucketNames()
+	names, truncated, err := x.ledgerStore.ListBucketNames(marker, maxBuckets)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	var infos = make([]minio.BucketInfo, len(names))
+	infos = make([]minio.BucketInfo, len(names))
 	for i, name := range names {
 		//TODO(George): detect context cancelation here (or in GetBucketInfo), as this could be a long running process
 		info, err := x.GetBucketInfo(ctx, name)
 		if err != nil {
-			return nil, err // no need to handle GetBucketInfo parses error accordingly
+			return nil, "", err // no need to handle GetBucketInfo parses error accordingly
 		}
 		infos[i] = info
 	}
-	return infos, nil
+	if truncated {
+		nextMarker = names[len(names)-1]
+	}
+	return infos, nextMarker, nil
 }
 
 // DeleteBucket deletes a bucket on S3
 func (x *xObjects) DeleteBucket(ctx context.Context, name string) error {
 	// TODO(bonedaddy): implement removal call from TemporalX
-	return x.toMinioErr(x.ledgerStore.DeleteBucket(name), name, "", "")
+	if err := x.checkWritable(); err != nil {
+		return err
+	}
+	if err := x.ledgerStore.DeleteBucket(name); err != nil {
+		return x.toMinioErr(err, name, "", "")
+	}
+	x.lifecycles.forget(name, nil)
+	return nil
+}
+
+// BucketDigest returns the root cid of the bucket and its number of objects, which together
+// identify the contents of the bucket, so comparing digests detects any change to the bucket.
+func (x *xObjects) BucketDigest(ctx context.Context, bucket string) (cid string, objectCount int64, err error) {
+	cid, objectCount, err = x.ledgerStore.BucketDigest(ctx, bucket)
+	return cid, objectCount, x.toMinioErr(err, bucket, "", "")
+}
+
+// WarmBuckets loads the buckets into the ledger cache ahead of their first requests,
+// buckets that don't exist are ignored.
+func (x *xObjects) WarmBuckets(ctx context.Context, buckets []string) error {
+	return x.toMinioErr(x.ledgerStore.WarmBuckets(ctx, buckets), "", "", "")
+}
+
+// SetBucketDefaultContentType sets the content type of objects uploaded to the bucket without one,
+// an empty contentType removes the default.
+func (x *xObjects) SetBucketDefaultContentType(ctx context.Context, bucket, contentType string) error {
+	return x.toMinioErr(x.setBucketString(bucket, bucketConfigContentType, contentType), bucket, "", "")
+}
+
+// GetBucketDefaultContentType returns the default content type of the bucket, or "" if not set.
+func (x *xObjects) GetBucketDefaultContentType(ctx context.Context, bucket string) (string, error) {
+	contentType, err := x.bucketString(bucket, bucketConfigContentType)
+	if err != nil {
+		return "", x.toMinioErr(err, bucket, "", "")
+	}
+	return contentType, nil
+}
+
+// applyDefaultContentType sets the bucket default content type on obinfo if it has none.
+//
+// The s3 handlers fill in "application/octet-stream" when the client omits a content type,
+// so that is also treated as unset.
+func (x *xObjects) applyDefaultContentType(ctx context.Context, obinfo *ObjectInfo) error {
+	if obinfo.ContentType != "" && obinfo.ContentType != defaultContentType {
+		return nil
+	}
+	ct, err := x.GetBucketDefaultContentType(ctx, obinfo.Bucket)
+	if err != nil {
+		return err
+	}
+	if ct != "" {
+		obinfo.ContentType = ct
+	}
+	return nil
 }
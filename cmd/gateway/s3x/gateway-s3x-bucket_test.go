@@ -2,6 +2,9 @@ package s3x
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -131,3 +134,300 @@ func testS3XBucket(t *testing.T, dsType DSType) {
 		}
 	})
 }
+
+func TestS3X_Bucket_DefaultContentType(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	if err := gateway.SetBucketDefaultContentType(ctx, testBucket1, "text/html"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		object      string
+		contentType string
+		want        string
+	}{
+		{"no content type", "page1.html", "", "text/html"},
+		{"s3 fallback content type", "page2.html", defaultContentType, "text/html"},
+		{"explicit content type", "style.css", "text/css", "text/css"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts minio.ObjectOptions
+			if tt.contentType != "" {
+				opts.UserDefined = map[string]string{"content-type": tt.contentType}
+			}
+			if _, err := gateway.PutObject(ctx, testBucket1, tt.object, getTestPutObjectReader(t, []byte("data")), opts); err != nil {
+				t.Fatal(err)
+			}
+			info, err := gateway.GetObjectInfo(ctx, testBucket1, tt.object, minio.ObjectOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.ContentType != tt.want {
+				t.Fatalf("expected content type %v, but got %v", tt.want, info.ContentType)
+			}
+		})
+	}
+	t.Run("removed with bucket", func(t *testing.T) {
+		if err := gateway.DeleteBucket(ctx, testBucket1); err != nil {
+			t.Fatal(err)
+		}
+		if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
+			t.Fatal(err)
+		}
+		ct, err := gateway.GetBucketDefaultContentType(ctx, testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ct != "" {
+			t.Fatalf("expected no default content type, but got %v", ct)
+		}
+	})
+}
+
+func TestS3X_Bucket_Digest(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	cid1, count1, err := gateway.BucketDigest(ctx, testBucket1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cid1 == "" || count1 != 0 {
+		t.Fatalf("expected an empty bucket with a cid, but got cid %q with %v objects", cid1, count1)
+	}
+	t.Run("stable", func(t *testing.T) {
+		if _, err := gateway.ListObjects(ctx, testBucket1, "", "", "", 1000); err != nil {
+			t.Fatal(err)
+		}
+		cid, count, err := gateway.BucketDigest(ctx, testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cid != cid1 || count != count1 {
+			t.Fatalf("expected digest %v/%v, but got %v/%v", cid1, count1, cid, count)
+		}
+	})
+	t.Run("changed", func(t *testing.T) {
+		if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		cid, count, err := gateway.BucketDigest(ctx, testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cid == cid1 || count != 1 {
+			t.Fatalf("expected a new cid with 1 object, but got %v with %v objects", cid, count)
+		}
+	})
+	t.Run("bucket not found", func(t *testing.T) {
+		_, _, err := gateway.BucketDigest(ctx, testBucket2)
+		if _, ok := err.(minio.BucketNotFound); !ok {
+			t.Fatalf("expected BucketNotFound, but got %v", err)
+		}
+	})
+}
+
+func TestS3X_Bucket_Location(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	tests := []struct {
+		bucket, location, want string
+	}{
+		{"eu-bucket", "eu-west-1", "eu-west-1"},
+		{"default-bucket", "", defaultRegion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.bucket, func(t *testing.T) {
+			if err := gateway.MakeBucketWithLocation(ctx, tt.bucket, tt.location); err != nil {
+				t.Fatal(err)
+			}
+			gateway.restart(t)
+			got, err := gateway.GetBucketLocation(ctx, tt.bucket)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected location %v, but got %v", tt.want, got)
+			}
+		})
+	}
+	if _, err := gateway.GetBucketLocation(ctx, "missing"); err == nil {
+		t.Fatal("expected error for missing bucket")
+	}
+}
+
+func TestS3X_Bucket_Head(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "eu-west-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	gateway.restart(t)
+	t.Run("existing", func(t *testing.T) {
+		info, err := gateway.GetBucketInfo(ctx, testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Name != testBucket1 {
+			t.Fatalf("expected bucket %v, but got %v", testBucket1, info.Name)
+		}
+		location, err := gateway.GetBucketLocation(ctx, testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if location != "eu-west-1" {
+			t.Fatalf("expected location eu-west-1, but got %v", location)
+		}
+		ls := gateway.ledgerStore
+		ls.mapLocker.Lock()
+		entry := ls.l.Buckets[testBucket1]
+		ls.mapLocker.Unlock()
+		if entry == nil || entry.Bucket != nil {
+			t.Fatal("expected the bucket info to be read without loading the bucket")
+		}
+	})
+	t.Run("missing", func(t *testing.T) {
+		_, err := gateway.GetBucketInfo(ctx, "missing")
+		if _, ok := err.(minio.BucketNotFound); !ok {
+			t.Fatal("expected error BucketNotFound, but got", err)
+		}
+	})
+}
+
+func TestS3X_Bucket_Recreate(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "eu-west-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("same location", func(t *testing.T) {
+		if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "eu-west-1"); err != nil {
+			t.Fatal("expected re-creating a bucket in its location to succeed, but got", err)
+		}
+		if _, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{}); err != nil {
+			t.Fatal("expected the objects of the bucket to be kept, but got", err)
+		}
+	})
+	t.Run("other location", func(t *testing.T) {
+		err := gateway.MakeBucketWithLocation(ctx, testBucket1, "")
+		if _, ok := err.(minio.BucketAlreadyOwnedByYou); !ok {
+			t.Fatal("expected error BucketAlreadyOwnedByYou, but got", err)
+		}
+		got, err := gateway.GetBucketLocation(ctx, testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != "eu-west-1" {
+			t.Fatalf("expected location eu-west-1 to be kept, but got %v", got)
+		}
+	})
+}
+
+func TestS3X_ListBucketsPage(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	var want []string
+	for i := 0; i < 300; i++ {
+		// created out of name order
+		name := fmt.Sprintf("bucket-%03d", (i*7)%300)
+		if err := gateway.MakeBucketWithLocation(ctx, name, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 300; i++ {
+		want = append(want, fmt.Sprintf("bucket-%03d", i))
+	}
+	var (
+		got    []string
+		marker string
+		pages  int
+	)
+	for {
+		infos, next, err := gateway.ListBucketsPage(ctx, marker, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(infos) > 100 {
+			t.Fatalf("expected at most 100 buckets in a page, but got %v", len(infos))
+		}
+		for _, info := range infos {
+			got = append(got, info.Name)
+		}
+		pages++
+		if next == "" {
+			break
+		}
+		marker = next
+	}
+	if pages != 3 {
+		t.Fatalf("expected 3 pages, but got %v", pages)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected buckets in name order without gaps or overlaps, but got %v", got)
+	}
+	all, err := gateway.ListBuckets(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 300 || all[0].Name != want[0] || all[299].Name != want[299] {
+		t.Fatalf("expected all buckets in name order, but got %v buckets", len(all))
+	}
+}
+
+func TestS3X_WarmBuckets(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	gateway.restart(t) // start with an empty cache
+	ls := gateway.ledgerStore
+	h, err := ls.GetBucketHash(testBucket1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dag := &getCountingDagClient{NodeAPIClient: ls.dag, hash: h}
+	ls.dag = dag
+	if err := gateway.WarmBuckets(ctx, []string{testBucket1, "missing-bucket"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt64(&dag.gets); n != 1 {
+		t.Fatalf("expected the bucket to be loaded once by warming, but got %v loads", n)
+	}
+	if _, err := ls.GetObjectHash(ctx, testBucket1, testObject1); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt64(&dag.gets); n != 1 {
+		t.Fatalf("expected no bucket load after warming, but got %v loads", n-1)
+	}
+}
@@ -0,0 +1,161 @@
+package s3x
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+)
+
+const (
+	// s3xMetaCompression is the internal metadata key recording the compression
+	// applied to the object data stored on ipfs
+	s3xMetaCompression = minio.ReservedMetadataPrefix + "S3x-Compression"
+	// s3xMetaStoredSize is the internal metadata key recording the size of the
+	// object data as stored on ipfs, which differs from the object size when compressed
+	s3xMetaStoredSize = minio.ReservedMetadataPrefix + "S3x-Stored-Size"
+
+	compressionGzip = "gzip"
+	// gzipETagSuffix is appended to the ETag of objects served as stored compressed,
+	// so the compressed data is never taken for the plain data by its ETag
+	gzipETagSuffix = "-gzip"
+)
+
+// shouldCompress returns true if objects with the given content type
+// should be gzip compressed before being added to ipfs.
+//
+// Entries in compressTypes ending with "/*" match any subtype.
+func (x *xObjects) shouldCompress(contentType string) bool {
+	if len(x.compressTypes) == 0 || contentType == "" {
+		return false
+	}
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	for _, t := range x.compressTypes {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if strings.HasSuffix(t, "/*") {
+			if strings.HasPrefix(contentType, strings.TrimSuffix(t, "*")) {
+				return true
+			}
+		} else if t == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// isCompressed returns true if the object data is stored gzip compressed
+func (m *ObjectInfo) isCompressed() bool {
+	return m.GetUserDefined()[s3xMetaCompression] == compressionGzip
+}
+
+// servedCompressed returns true if the object data is sent as stored to clients accepting gzip
+func (m *ObjectInfo) servedCompressed() bool {
+	return m.isCompressed() && !m.isTransformed() && m.GetContentEncoding() == ""
+}
+
+// storedSize returns the size of the object data as stored, which differs from the size if compressed
+func (m *ObjectInfo) storedSize() int64 {
+	if v, ok := m.GetUserDefined()[s3xMetaStoredSize]; ok {
+		if size, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return size
+		}
+	}
+	return m.GetSize_()
+}
+
+// countingReader counts the number of bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// gzipReader returns a reader of the gzip compressed content of r,
+// the returned reader must be closed to release resources.
+func gzipReader(r io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		gw := gzip.NewWriter(pw)
+		_, err := io.Copy(gw, r)
+		if err == nil {
+			err = gw.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// getObjectGzipInfo returns the minio object info of an object served as stored compressed.
+// The compressed data has its own ETag and varies by Accept-Encoding, so caches don't serve
+// it to clients that don't accept gzip.
+func getObjectGzipInfo(obj *Object) minio.ObjectInfo {
+	info := getObjectETagInfo(&obj.ObjectInfo, obj.GetDataHash())
+	info.Size = obj.ObjectInfo.storedSize()
+	info.ContentEncoding = compressionGzip
+	info.ETag += gzipETagSuffix
+	// the user defined metadata may be shared with the cached object
+	userDefined := make(map[string]string, len(info.UserDefined)+1)
+	for k, v := range info.UserDefined {
+		userDefined[k] = v
+	}
+	userDefined[xhttp.Vary] = xhttp.AcceptEncoding
+	info.UserDefined = userDefined
+	return info
+}
+
+// acceptsGzip returns true if the Accept-Encoding of the request headers allows gzip
+func acceptsGzip(h http.Header) bool {
+	for _, v := range h[xhttp.AcceptEncoding] {
+		for _, coding := range strings.Split(v, ",") {
+			params := strings.Split(coding, ";")
+			name := strings.ToLower(strings.TrimSpace(params[0]))
+			if name != compressionGzip && name != "*" {
+				continue
+			}
+			accepted := true
+			for _, p := range params[1:] {
+				p = strings.TrimSpace(p)
+				if strings.HasPrefix(p, "q=") {
+					q, err := strconv.ParseFloat(p[2:], 64)
+					accepted = err == nil && q > 0
+				}
+			}
+			if accepted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getObjectNInfoGzip returns a reader of the object data as stored, gzip compressed,
+// with the object info describing the compressed data.
+func (x *xObjects) getObjectNInfoGzip(ctx context.Context, bucket, object string, obj *Object, opts minio.ObjectOptions) (*minio.GetObjectReader, error) {
+	info := getObjectGzipInfo(obj)
+	x.pinOnRead(obj.GetDataHash())
+	x.recordAccess(ctx, bucket, object)
+	pr, pw := io.Pipe()
+	go func() {
+		rl := newRequestLog("GetObject", bucket, object)
+		rl.cid = obj.GetDataHash()
+		cw := &countingWriter{w: pw, metrics: x.metrics}
+		_, err := ipfsFileDownload(ctx, x.fileClient, cw, obj.GetDataHash(), 0, 0)
+		rl.bytes = cw.n
+		x.logRequest(ctx, rl, err)
+		_ = pw.CloseWithError(x.toMinioErr(err, bucket, object, ""))
+	}()
+	return minio.NewGetObjectReaderFromReader(pr, info, opts.CheckCopyPrecondFn, func() { pr.Close() })
+}
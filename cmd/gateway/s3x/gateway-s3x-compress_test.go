@@ -0,0 +1,204 @@
+package s3x
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_Compression_Badger(t *testing.T) {
+	testS3XCompression(t, DSTypeBadger)
+}
+func TestS3X_Compression_Crdt(t *testing.T) {
+	testS3XCompression(t, DSTypeCrdt)
+}
+func testS3XCompression(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	gateway.compressTypes = []string{"text/*", "application/json"}
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(strings.Repeat("compressible text data ", 1000))
+	opts := minio.ObjectOptions{UserDefined: map[string]string{"content-type": "text/plain; charset=utf-8"}}
+	info, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, data), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != int64(len(data)) {
+		t.Fatalf("expected size %v, but got %v", len(data), info.Size)
+	}
+	t.Run("round trip", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, info.Size, buf, "", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Fatal("decompressed data does not match original")
+		}
+	})
+	t.Run("range", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		if err := gateway.GetObject(ctx, testBucket1, testObject1, 100, 50, buf, "", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), data[100:150]) {
+			t.Fatalf("unexpected range data: %s", buf.Bytes())
+		}
+	})
+	t.Run("stored data is smaller", func(t *testing.T) {
+		hash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := bytes.NewBuffer(nil)
+		if _, err := ipfsFileDownload(ctx, gateway.fileClient, buf, hash, 0, 0); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() >= len(data) {
+			t.Fatalf("expected stored size %v to be smaller than %v", buf.Len(), len(data))
+		}
+	})
+}
+
+func TestS3X_ShouldCompress(t *testing.T) {
+	x := &xObjects{compressTypes: []string{"text/*", "application/json"}}
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"text/plain", true},
+		{"text/html; charset=utf-8", true},
+		{"application/json", true},
+		{"application/octet-stream", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := x.shouldCompress(tt.contentType); got != tt.want {
+			t.Errorf("shouldCompress(%q) = %v, want %v", tt.contentType, got, tt.want)
+		}
+	}
+	if (&xObjects{}).shouldCompress("text/plain") {
+		t.Error("compression should be disabled without compressTypes")
+	}
+}
+
+func TestS3X_Compression_AcceptEncoding(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	gateway.compressTypes = []string{"text/*"}
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(strings.Repeat("compressible text data ", 1000))
+	opts := minio.ObjectOptions{UserDefined: map[string]string{"content-type": "text/plain"}}
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, data), opts); err != nil {
+		t.Fatal(err)
+	}
+	get := func(t *testing.T, acceptEncoding string, rs *minio.HTTPRangeSpec) (minio.ObjectInfo, []byte) {
+		h := http.Header{}
+		if acceptEncoding != "" {
+			h.Set("Accept-Encoding", acceptEncoding)
+		}
+		gr, err := gateway.GetObjectNInfo(ctx, testBucket1, testObject1, rs, h, 0, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer gr.Close()
+		out, err := ioutil.ReadAll(gr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return gr.ObjInfo, out
+	}
+
+	t.Run("gzip client", func(t *testing.T) {
+		info, out := get(t, "gzip, deflate", nil)
+		if info.ContentEncoding != "gzip" {
+			t.Fatalf("expected content encoding gzip, but got %q", info.ContentEncoding)
+		}
+		if info.Size != int64(len(out)) || len(out) >= len(data) {
+			t.Fatalf("expected compressed size %v, but got %v bytes of %v", info.Size, len(out), len(data))
+		}
+		gr, err := gzip.NewReader(bytes.NewReader(out))
+		if err != nil {
+			t.Fatal(err)
+		}
+		decompressed, err := ioutil.ReadAll(gr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decompressed, data) {
+			t.Fatal("compressed data does not match original")
+		}
+		plain, _ := get(t, "", nil)
+		if info.ETag == plain.ETag || !strings.HasSuffix(info.ETag, gzipETagSuffix) {
+			t.Fatalf("expected an ETag distinct from %v for the compressed data, but got %v", plain.ETag, info.ETag)
+		}
+		if vary := info.UserDefined["Vary"]; vary != "Accept-Encoding" {
+			t.Fatalf("expected the response to vary by Accept-Encoding, but got %q", vary)
+		}
+	})
+	t.Run("copy", func(t *testing.T) {
+		h := http.Header{}
+		h.Set("Accept-Encoding", "gzip")
+		opts := minio.ObjectOptions{CheckCopyPrecondFn: func(minio.ObjectInfo, string) bool { return false }}
+		gr, err := gateway.GetObjectNInfo(ctx, testBucket1, testObject1, nil, h, 0, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer gr.Close()
+		if gr.ObjInfo.ContentEncoding != "" {
+			t.Fatalf("expected copies to read the plain data, but got content encoding %q", gr.ObjInfo.ContentEncoding)
+		}
+	})
+	for _, acceptEncoding := range []string{"", "identity", "gzip;q=0", "br"} {
+		t.Run("client accepting "+acceptEncoding, func(t *testing.T) {
+			info, out := get(t, acceptEncoding, nil)
+			if info.ContentEncoding != "" {
+				t.Fatalf("expected no content encoding, but got %q", info.ContentEncoding)
+			}
+			if !bytes.Equal(out, data) {
+				t.Fatal("expected decompressed data")
+			}
+		})
+	}
+	t.Run("gzip client range", func(t *testing.T) {
+		info, out := get(t, "gzip", &minio.HTTPRangeSpec{Start: 100, End: 149})
+		if info.ContentEncoding != "" {
+			t.Fatalf("expected no content encoding, but got %q", info.ContentEncoding)
+		}
+		if !bytes.Equal(out, data[100:150]) {
+			t.Fatalf("unexpected range data: %s", out)
+		}
+	})
+	for _, acceptEncoding := range []string{"", "gzip", "gzip;q=0"} {
+		t.Run("head accepting "+acceptEncoding, func(t *testing.T) {
+			info, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{AcceptEncoding: acceptEncoding})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, out := get(t, acceptEncoding, nil)
+			if info.Size != int64(len(out)) || info.ContentEncoding != want.ContentEncoding || info.ETag != want.ETag {
+				t.Fatalf("expected HEAD to report %v bytes with encoding %q as served, but got %v bytes with encoding %q",
+					len(out), want.ContentEncoding, info.Size, info.ContentEncoding)
+			}
+		})
+	}
+}
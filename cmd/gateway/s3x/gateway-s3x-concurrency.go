@@ -0,0 +1,46 @@
+package s3x
+
+import (
+	"context"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc"
+)
+
+// defaultDagConcurrency is the default maximum number of concurrent dag requests
+const defaultDagConcurrency = 256
+
+// limitDagClient bounds the number of concurrent dag requests to protect the ipfs node,
+// excess requests wait for a free slot, or fail with minio.SlowDown if reject is set.
+type limitDagClient struct {
+	pb.NodeAPIClient
+	slots  chan struct{}
+	reject bool
+}
+
+func newLimitDagClient(dag pb.NodeAPIClient, concurrency int, reject bool) *limitDagClient {
+	return &limitDagClient{
+		NodeAPIClient: dag,
+		slots:         make(chan struct{}, concurrency),
+		reject:        reject,
+	}
+}
+
+func (c *limitDagClient) Dag(ctx context.Context, in *pb.DagRequest, opts ...grpc.CallOption) (*pb.DagResponse, error) {
+	if c.reject {
+		select {
+		case c.slots <- struct{}{}:
+		default:
+			return nil, minio.SlowDown{}
+		}
+	} else {
+		select {
+		case c.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	defer func() { <-c.slots }()
+	return c.NodeAPIClient.Dag(ctx, in, opts...)
+}
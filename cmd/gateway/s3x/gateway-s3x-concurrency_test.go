@@ -0,0 +1,71 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_DagConcurrency(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	const objects, limit = 100, 10
+	for i := 0; i < objects; i++ {
+		name := fmt.Sprintf("object%d", i)
+		if _, err := gateway.PutObject(ctx, testBucket1, name, getTestPutObjectReader(t, []byte(name)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	node := gateway.ledgerStore.dag
+	gateway.ledgerStore.objects = newObjectCache(0) // make sure reads go to ipfs
+	getAll := func(t *testing.T) []error {
+		var wg sync.WaitGroup
+		errs := make([]error, objects)
+		for i := 0; i < objects; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = gateway.GetObjectInfo(ctx, testBucket1, fmt.Sprintf("object%d", i), minio.ObjectOptions{})
+			}(i)
+		}
+		wg.Wait()
+		return errs
+	}
+	t.Run("queue", func(t *testing.T) {
+		counter := &countingDagClient{NodeAPIClient: &slowDagClient{NodeAPIClient: node, delay: 20 * time.Millisecond}}
+		gateway.ledgerStore.dag = newLimitDagClient(counter, limit, false)
+		for _, err := range getAll(t) {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if counter.maxInFlight > limit {
+			t.Fatalf("expected at most %v dag requests in flight, but got %v", limit, counter.maxInFlight)
+		}
+	})
+	t.Run("reject", func(t *testing.T) {
+		counter := &countingDagClient{NodeAPIClient: &slowDagClient{NodeAPIClient: node, delay: 20 * time.Millisecond}}
+		gateway.ledgerStore.dag = newLimitDagClient(counter, limit, true)
+		var slowDowns int
+		for _, err := range getAll(t) {
+			switch err.(type) {
+			case nil:
+			case minio.SlowDown:
+				slowDowns++
+			default:
+				t.Fatal(err)
+			}
+		}
+		if slowDowns == 0 {
+			t.Fatal("expected excess requests to get SlowDown")
+		}
+		if counter.maxInFlight > limit {
+			t.Fatalf("expected at most %v dag requests in flight, but got %v", limit, counter.maxInFlight)
+		}
+	})
+}
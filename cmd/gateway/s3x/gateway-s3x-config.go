@@ -0,0 +1,135 @@
+package s3x
+
+import (
+	"context"
+	"strconv"
+)
+
+// bucketConfigType is the type of the value of a bucket config settable by name
+type bucketConfigType int
+
+const (
+	// bucketConfigBool configs are switches that are either set or not
+	bucketConfigBool bucketConfigType = iota
+	// bucketConfigInt configs hold a limit, a limit of 0 or less removes them
+	bucketConfigInt
+	// bucketConfigString configs hold a string, an empty string removes them
+	bucketConfigString
+)
+
+// bucketConfigTypes are the bucket configs settable with SetBucketConfig. Configs holding documents,
+// such as the bucket policy, or with side effects, such as ipns publishing, have their own methods.
+var bucketConfigTypes = map[string]bucketConfigType{
+	bucketConfigContentType:    bucketConfigString,
+	bucketConfigDelimiter:      bucketConfigString,
+	bucketConfigRateLimit:      bucketConfigInt,
+	bucketConfigObjectLimit:    bucketConfigInt,
+	bucketConfigAccessExpiry:   bucketConfigInt,
+	bucketConfigAppendOnly:     bucketConfigBool,
+	bucketConfigVersionHistory: bucketConfigBool,
+}
+
+// SetBucketConfig sets the named bucket config from its text: "true" or "false" for switches such
+// as append-only, a number for limits such as rate-limit where 0 removes the limit, and the value
+// itself for the others such as delimiter where "" removes it.
+//
+// New bucket settings are added to bucketConfigTypes, the SetBucketX and GetBucketX methods of
+// the existing settings are shorthands for their configs.
+func (x *xObjects) SetBucketConfig(ctx context.Context, bucket, name, value string) error {
+	typ, ok := bucketConfigTypes[name]
+	if !ok {
+		return x.toMinioErr(ErrUnknownBucketConfig, bucket, "", "")
+	}
+	var err error
+	switch typ {
+	case bucketConfigBool:
+		on, perr := strconv.ParseBool(value)
+		if perr != nil {
+			return x.toMinioErr(ErrInvalidBucketConfig, bucket, "", "")
+		}
+		err = x.setBucketFlag(bucket, name, on)
+	case bucketConfigInt:
+		n, perr := strconv.ParseInt(value, 10, 64)
+		if perr != nil {
+			return x.toMinioErr(ErrInvalidBucketConfig, bucket, "", "")
+		}
+		err = x.setBucketNumber(bucket, name, n)
+	default:
+		err = x.setBucketString(bucket, name, value)
+	}
+	return x.toMinioErr(err, bucket, "", "")
+}
+
+// GetBucketConfig returns the text of the named bucket config as SetBucketConfig takes it,
+// which is "false", "0" or "" if it's not set.
+func (x *xObjects) GetBucketConfig(ctx context.Context, bucket, name string) (string, error) {
+	typ, ok := bucketConfigTypes[name]
+	if !ok {
+		return "", x.toMinioErr(ErrUnknownBucketConfig, bucket, "", "")
+	}
+	var (
+		value string
+		err   error
+	)
+	switch typ {
+	case bucketConfigBool:
+		var on bool
+		on, err = x.bucketFlag(bucket, name)
+		value = strconv.FormatBool(on)
+	case bucketConfigInt:
+		var n int64
+		n, err = x.bucketNumber(bucket, name)
+		value = strconv.FormatInt(n, 10)
+	default:
+		value, err = x.bucketString(bucket, name)
+	}
+	if err != nil {
+		return "", x.toMinioErr(err, bucket, "", "")
+	}
+	return value, nil
+}
+
+// setBucketFlag sets or removes a bucket config switch
+func (x *xObjects) setBucketFlag(bucket, name string, on bool) error {
+	if !on {
+		return x.ledgerStore.DeleteBucketConfig(bucket, name)
+	}
+	return x.ledgerStore.PutBucketConfig(bucket, name, []byte{1})
+}
+
+// bucketFlag returns true if a bucket config switch is set
+func (x *xObjects) bucketFlag(bucket, name string) (bool, error) {
+	data, err := x.ledgerStore.GetBucketConfig(bucket, name)
+	return data != nil, err
+}
+
+// setBucketNumber sets a bucket config limit, a limit of 0 or less removes it
+func (x *xObjects) setBucketNumber(bucket, name string, n int64) error {
+	if n <= 0 {
+		return x.ledgerStore.DeleteBucketConfig(bucket, name)
+	}
+	return x.ledgerStore.PutBucketConfig(bucket, name, []byte(strconv.FormatInt(n, 10)))
+}
+
+// bucketNumber returns a bucket config limit, or 0 if it's not set
+func (x *xObjects) bucketNumber(bucket, name string) (int64, error) {
+	data, err := x.ledgerStore.GetBucketConfig(bucket, name)
+	if err != nil || data == nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(data), 10, 64)
+}
+
+// setBucketString sets a bucket config string, an empty string removes it
+func (x *xObjects) setBucketString(bucket, name, value string) error {
+	if value == "" {
+		return x.ledgerStore.DeleteBucketConfig(bucket, name)
+	}
+	return x.ledgerStore.PutBucketConfig(bucket, name, []byte(value))
+}
+
+// bucketString returns a bucket config string, or "" if it's not set
+func (x *xObjects) bucketString(bucket, name string) (string, error) {
+	data, err := x.ledgerStore.GetBucketConfig(bucket, name)
+	return string(data), err
+}
@@ -0,0 +1,75 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_BucketConfig(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	expect := func(t *testing.T, name, want string) {
+		t.Helper()
+		got, err := gateway.GetBucketConfig(ctx, testBucket1, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("expected %v to be %q, but got %q", name, want, got)
+		}
+	}
+	t.Run("unset", func(t *testing.T) {
+		expect(t, bucketConfigAppendOnly, "false")
+		expect(t, bucketConfigRateLimit, "0")
+		expect(t, bucketConfigDelimiter, "")
+	})
+	t.Run("set", func(t *testing.T) {
+		for name, value := range map[string]string{
+			bucketConfigAppendOnly: "true",
+			bucketConfigRateLimit:  "10",
+			bucketConfigDelimiter:  ":",
+		} {
+			if err := gateway.SetBucketConfig(ctx, testBucket1, name, value); err != nil {
+				t.Fatal(err)
+			}
+			expect(t, name, value)
+		}
+		// the shorthands read the same configs
+		if appendOnly, err := gateway.GetBucketAppendOnly(ctx, testBucket1); err != nil || !appendOnly {
+			t.Fatalf("expected bucket to be append-only, but got %v (%v)", appendOnly, err)
+		}
+		if limit, err := gateway.GetBucketRateLimit(ctx, testBucket1); err != nil || limit != 10 {
+			t.Fatalf("expected a rate limit of 10, but got %v (%v)", limit, err)
+		}
+	})
+	t.Run("remove", func(t *testing.T) {
+		for name, value := range map[string]string{
+			bucketConfigAppendOnly: "false",
+			bucketConfigRateLimit:  "0",
+			bucketConfigDelimiter:  "",
+		} {
+			if err := gateway.SetBucketConfig(ctx, testBucket1, name, value); err != nil {
+				t.Fatal(err)
+			}
+			expect(t, name, value)
+		}
+		if delimiter, err := gateway.GetBucketDelimiter(ctx, testBucket1); err != nil || delimiter != "" {
+			t.Fatalf("expected no delimiter, but got %q (%v)", delimiter, err)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		if err := gateway.SetBucketConfig(ctx, testBucket1, bucketConfigPolicy, "{}"); !isInvalidRequest(err, ErrUnknownBucketConfig) {
+			t.Fatalf("expected InvalidRequest for ErrUnknownBucketConfig, but got %v", err)
+		}
+		if err := gateway.SetBucketConfig(ctx, testBucket1, bucketConfigObjectLimit, "many"); !isInvalidRequest(err, ErrInvalidBucketConfig) {
+			t.Fatalf("expected InvalidRequest for ErrInvalidBucketConfig, but got %v", err)
+		}
+		err := gateway.SetBucketConfig(ctx, testBucket2, bucketConfigAppendOnly, "true")
+		if _, ok := err.(minio.BucketNotFound); !ok {
+			t.Fatalf("expected BucketNotFound, but got %v", err)
+		}
+	})
+}
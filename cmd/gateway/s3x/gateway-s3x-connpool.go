@@ -0,0 +1,105 @@
+package s3x
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	"go.uber.org/multierr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// connPool is a fixed size pool of grpc connections to TemporalX, requests are spread over
+// the connections round-robin, so that concurrent requests don't all queue on one connection.
+type connPool struct {
+	dial  func() (*grpc.ClientConn, error)
+	next  uint64
+	mu    sync.Mutex
+	conns []*grpc.ClientConn
+}
+
+// newConnPool dials size connections, at least one
+func newConnPool(size int, dial func() (*grpc.ClientConn, error)) (*connPool, error) {
+	if size < 1 {
+		size = 1
+	}
+	p := &connPool{dial: dial}
+	for i := 0; i < size; i++ {
+		conn, err := dial()
+		if err != nil {
+			return nil, multierr.Append(err, p.Close())
+		}
+		p.conns = append(p.conns, conn)
+	}
+	return p, nil
+}
+
+// get returns the next connection of the pool. A connection that was shut down is replaced
+// by a new one first, if dialing fails it's kept. Failed connections are kept too, grpc
+// reconnects them on its own, and closing them would fail the requests still using them.
+func (p *connPool) get() *grpc.ClientConn {
+	i := (atomic.AddUint64(&p.next, 1) - 1) % uint64(len(p.conns))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	conn := p.conns[i]
+	if conn.GetState() == connectivity.Shutdown {
+		if c, err := p.dial(); err == nil {
+			p.conns[i] = c
+			conn = c
+		}
+	}
+	return conn
+}
+
+// Close closes all connections of the pool
+func (p *connPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var err error
+	for _, conn := range p.conns {
+		err = multierr.Append(err, conn.Close())
+	}
+	return err
+}
+
+// poolDagClient sends the node requests used by the gateway over the connections of a pool
+type poolDagClient struct {
+	pb.NodeAPIClient
+	pool *connPool
+}
+
+func newPoolDagClient(pool *connPool) *poolDagClient {
+	return &poolDagClient{NodeAPIClient: pb.NewNodeAPIClient(pool.get()), pool: pool}
+}
+
+func (c *poolDagClient) Dag(ctx context.Context, in *pb.DagRequest, opts ...grpc.CallOption) (*pb.DagResponse, error) {
+	return pb.NewNodeAPIClient(c.pool.get()).Dag(ctx, in, opts...)
+}
+
+func (c *poolDagClient) Persist(ctx context.Context, in *pb.PersistRequest, opts ...grpc.CallOption) (*pb.PersistResponse, error) {
+	return pb.NewNodeAPIClient(c.pool.get()).Persist(ctx, in, opts...)
+}
+
+func (c *poolDagClient) Blockstore(ctx context.Context, in *pb.BlockstoreRequest, opts ...grpc.CallOption) (*pb.BlockstoreResponse, error) {
+	return pb.NewNodeAPIClient(c.pool.get()).Blockstore(ctx, in, opts...)
+}
+
+// poolFileClient streams object data over the connections of a pool
+type poolFileClient struct {
+	pb.FileAPIClient
+	pool *connPool
+}
+
+func newPoolFileClient(pool *connPool) *poolFileClient {
+	return &poolFileClient{FileAPIClient: pb.NewFileAPIClient(pool.get()), pool: pool}
+}
+
+func (c *poolFileClient) UploadFile(ctx context.Context, opts ...grpc.CallOption) (pb.FileAPI_UploadFileClient, error) {
+	return pb.NewFileAPIClient(c.pool.get()).UploadFile(ctx, opts...)
+}
+
+func (c *poolFileClient) DownloadFile(ctx context.Context, in *pb.DownloadRequest, opts ...grpc.CallOption) (pb.FileAPI_DownloadFileClient, error) {
+	return pb.NewFileAPIClient(c.pool.get()).DownloadFile(ctx, in, opts...)
+}
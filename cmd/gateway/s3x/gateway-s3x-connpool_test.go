@@ -0,0 +1,136 @@
+package s3x
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/peer"
+)
+
+// peerCountingNodeServer is a fake node that records the client address of every dag request
+type peerCountingNodeServer struct {
+	pb.NodeAPIServer
+	mu    sync.Mutex
+	peers map[string]int
+}
+
+func (s *peerCountingNodeServer) Dag(ctx context.Context, in *pb.DagRequest) (*pb.DagResponse, error) {
+	p, _ := peer.FromContext(ctx)
+	s.mu.Lock()
+	s.peers[p.Addr.String()]++
+	s.mu.Unlock()
+	return &pb.DagResponse{}, nil
+}
+
+func (s *peerCountingNodeServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.peers)
+}
+
+func TestS3X_ConnPool(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	node := &peerCountingNodeServer{peers: make(map[string]int)}
+	pb.RegisterNodeAPIServer(server, node)
+	go server.Serve(lis)
+	defer func() { server.Stop() }()
+
+	const size = 4
+	pool, err := newConnPool(size, func() (*grpc.ClientConn, error) {
+		return grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	dag := newPoolDagClient(pool)
+	ctx := context.Background()
+	dagRequests := func(t *testing.T, n int) {
+		t.Helper()
+		var wg sync.WaitGroup
+		errs := make(chan error, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := dag.Dag(ctx, &pb.DagRequest{}); err != nil {
+					errs <- err
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Fatal(err)
+		}
+	}
+	t.Run("concurrent", func(t *testing.T) {
+		dagRequests(t, 200)
+		if n := node.connections(); n != size {
+			t.Fatalf("expected requests over %v connections, but got %v", size, n)
+		}
+	})
+	t.Run("reconnect", func(t *testing.T) {
+		if err := pool.conns[0].Close(); err != nil {
+			t.Fatal(err)
+		}
+		dagRequests(t, size)
+		if n := node.connections(); n != size+1 {
+			t.Fatalf("expected the closed connection to be replaced, but got %v connections", n)
+		}
+		if len(pool.conns) != size {
+			t.Fatalf("expected the pool to keep %v connections, but got %v", size, len(pool.conns))
+		}
+	})
+	t.Run("transient failure", func(t *testing.T) {
+		conns := append([]*grpc.ClientConn{}, pool.conns...)
+		server.Stop()
+		// wait for the connections to fail, while requests keep using them
+		deadline := time.Now().Add(10 * time.Second)
+		for _, conn := range conns {
+			for conn.GetState() != connectivity.TransientFailure {
+				if time.Now().After(deadline) {
+					t.Fatal("expected the connections to fail")
+				}
+				ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+				conn.WaitForStateChange(ctx, conn.GetState())
+				cancel()
+			}
+		}
+		for range conns {
+			if _, err := dag.Dag(ctx, &pb.DagRequest{}); err == nil {
+				t.Fatal("expected requests to fail while the node is down")
+			}
+		}
+		lis, err := net.Listen("tcp", lis.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		server = grpc.NewServer()
+		pb.RegisterNodeAPIServer(server, node)
+		go server.Serve(lis)
+		// grpc reconnects the failed connections, they are not replaced
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		for range conns {
+			if _, err := dag.Dag(ctx, &pb.DagRequest{}, grpc.WaitForReady(true)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i, conn := range conns {
+			if pool.conns[i] != conn {
+				t.Fatalf("expected failed connection %v to be kept", i)
+			}
+		}
+	})
+}
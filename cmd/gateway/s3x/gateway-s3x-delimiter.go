@@ -0,0 +1,27 @@
+package s3x
+
+import (
+	"context"
+)
+
+// SetBucketDelimiter sets the delimiter used by listings of a bucket that don't specify one,
+// so keys like a:b:c are grouped under the a: prefix with a delimiter of ":".
+// An empty delimiter removes the default, and such listings are flat again.
+func (x *xObjects) SetBucketDelimiter(ctx context.Context, bucket, delimiter string) error {
+	return x.toMinioErr(x.setBucketString(bucket, bucketConfigDelimiter, delimiter), bucket, "", "")
+}
+
+// GetBucketDelimiter returns the default delimiter of listings of a bucket, or "" if not set.
+func (x *xObjects) GetBucketDelimiter(ctx context.Context, bucket string) (string, error) {
+	delimiter, err := x.bucketString(bucket, bucketConfigDelimiter)
+	return delimiter, x.toMinioErr(err, bucket, "", "")
+}
+
+// listDelimiter returns the delimiter of a listing, which is the default of the bucket
+// unless the listing specifies one
+func (x *xObjects) listDelimiter(ctx context.Context, bucket, delimiter string) (string, error) {
+	if delimiter != "" {
+		return delimiter, nil
+	}
+	return x.GetBucketDelimiter(ctx, bucket)
+}
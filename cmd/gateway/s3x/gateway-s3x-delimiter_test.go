@@ -0,0 +1,61 @@
+package s3x
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_BucketDelimiter(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	for _, object := range []string{"a:b:c", "a:d", "e:f", "g"} {
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte(object)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gateway.SetBucketDelimiter(ctx, testBucket1, ":"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := gateway.GetBucketDelimiter(ctx, testBucket1); err != nil || got != ":" {
+		t.Fatalf("expected delimiter %q, but got %q (%v)", ":", got, err)
+	}
+	names := func(objs []minio.ObjectInfo) []string {
+		var names []string
+		for _, obj := range objs {
+			names = append(names, obj.Name)
+		}
+		return names
+	}
+	t.Run("default", func(t *testing.T) {
+		loi, err := gateway.ListObjects(ctx, testBucket1, "", "", "", 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"a:", "e:"}; !reflect.DeepEqual(loi.Prefixes, want) {
+			t.Fatalf("expected prefixes %v, but got %v", want, loi.Prefixes)
+		}
+		if want := []string{"g"}; !reflect.DeepEqual(names(loi.Objects), want) {
+			t.Fatalf("expected objects %v, but got %v", want, names(loi.Objects))
+		}
+		loi2, err := gateway.ListObjectsV2(ctx, testBucket1, "a:", "", "", 1000, false, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"a:b:"}; !reflect.DeepEqual(loi2.Prefixes, want) {
+			t.Fatalf("expected prefixes %v, but got %v", want, loi2.Prefixes)
+		}
+	})
+	t.Run("override", func(t *testing.T) {
+		loi, err := gateway.ListObjects(ctx, testBucket1, "", "", "/", 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(loi.Prefixes) != 0 || len(loi.Objects) != 4 {
+			t.Fatalf("expected the explicit delimiter to be used, but got prefixes %v and objects %v", loi.Prefixes, names(loi.Objects))
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package s3x
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/cmd/crypto"
+	bucketsse "github.com/RTradeLtd/s3x/pkg/bucket/encryption"
+)
+
+// SetBucketSSEConfig sets the default encryption config of a bucket. The handlers apply
+// the default encryption to puts that don't request encryption, see checkObjectEncryption.
+func (x *xObjects) SetBucketSSEConfig(ctx context.Context, bucket string, config *bucketsse.BucketSSEConfig) error {
+	data, err := xml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return x.toMinioErr(x.ledgerStore.PutBucketConfig(bucket, bucketConfigSSE, data), bucket, "", "")
+}
+
+// GetBucketSSEConfig returns the default encryption config of a bucket
+func (x *xObjects) GetBucketSSEConfig(ctx context.Context, bucket string) (*bucketsse.BucketSSEConfig, error) {
+	config, err := x.bucketSSEConfig(bucket)
+	if err != nil {
+		return nil, x.toMinioErr(err, bucket, "", "")
+	}
+	if config == nil {
+		return nil, minio.BucketSSEConfigNotFound{Bucket: bucket}
+	}
+	return config, nil
+}
+
+// DeleteBucketSSEConfig removes the default encryption config of a bucket
+func (x *xObjects) DeleteBucketSSEConfig(ctx context.Context, bucket string) error {
+	return x.toMinioErr(x.ledgerStore.DeleteBucketConfig(bucket, bucketConfigSSE), bucket, "", "")
+}
+
+// bucketSSEConfig returns the default encryption config of a bucket, or nil if none is set
+func (x *xObjects) bucketSSEConfig(bucket string) (*bucketsse.BucketSSEConfig, error) {
+	data, err := x.ledgerStore.GetBucketConfig(bucket, bucketConfigSSE)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	config := &bucketsse.BucketSSEConfig{}
+	if err := xml.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// checkObjectEncryption rejects unencrypted objects in a bucket with a default encryption config.
+// The handlers encrypt puts to such buckets, so unencrypted data only gets here if the
+// server can't encrypt it, such as when no KMS is configured.
+func (x *xObjects) checkObjectEncryption(bucket, object string, meta map[string]string) error {
+	if crypto.IsEncrypted(meta) {
+		return nil
+	}
+	config, err := x.bucketSSEConfig(bucket)
+	if err != nil {
+		return x.toMinioErr(err, bucket, "", "")
+	}
+	if config != nil {
+		return minio.ObjectEncryptionRequired{Bucket: bucket, Object: object}
+	}
+	return nil
+}
+
+// ReencryptOnKeyRotation makes the handlers re-encrypt the data of an object when its SSE-C
+// key is rotated. Resealing only the object key would keep the old ciphertext under the
+// same cid, where anyone with the old key can still read it.
+func (x *xObjects) ReencryptOnKeyRotation() bool {
+	return true
+}
+
+// getObjectNInfoDecrypted returns a reader of the range rs of the decrypted data of an encrypted
+// object, as the handlers expect from an object layer, such as to encrypt a copy with another key.
+// The packages of the stored data holding the range are read and decrypted with the SSE-C key,
+// or the SSE-C copy source key, in h.
+func (x *xObjects) getObjectNInfoDecrypted(
+	ctx context.Context,
+	bucket, object string,
+	rs *minio.HTTPRangeSpec,
+	h http.Header,
+	objinfo minio.ObjectInfo,
+	opts minio.ObjectOptions,
+) (*minio.GetObjectReader, error) {
+	fn, off, length, err := minio.NewGetObjectReader(rs, objinfo, opts.CheckCopyPrecondFn)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		err := x.getObject(ctx, bucket, object, off, length, pw)
+		_ = pw.CloseWithError(err)
+	}()
+	pipeCloser := func() { pr.Close() }
+	return fn(pr, h, opts.CheckCopyPrecondFn, pipeCloser)
+}
+
+// encryptionMetadata are the internal metadata entries the handlers set for encrypted data
+var encryptionMetadata = []string{
+	crypto.SSEMultipart,
+	crypto.SSEIV,
+	crypto.SSESealAlgorithm,
+	crypto.SSECSealedKey,
+	crypto.S3SealedKey,
+	crypto.S3KMSKeyID,
+	crypto.S3KMSSealedKey,
+}
+
+// setEncryptionMetadata replaces the encryption metadata of the object with the entries in meta.
+// setMetadata doesn't take internal entries from requests, but these describe the stored data.
+func (m *ObjectInfo) setEncryptionMetadata(meta map[string]string) {
+	crypto.RemoveInternalEntries(m.UserDefined)
+	for _, k := range encryptionMetadata {
+		v, ok := meta[k]
+		if !ok {
+			continue
+		}
+		if m.UserDefined == nil {
+			m.UserDefined = make(map[string]string)
+		}
+		m.UserDefined[k] = v
+	}
+	if len(m.UserDefined) == 0 {
+		m.UserDefined = nil
+	}
+}
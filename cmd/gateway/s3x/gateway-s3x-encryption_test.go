@@ -0,0 +1,248 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/cmd/crypto"
+	bucketsse "github.com/RTradeLtd/s3x/pkg/bucket/encryption"
+)
+
+const testBucketSSEConfig = `<ServerSideEncryptionConfiguration><Rule><ApplyServerSideEncryptionByDefault><SSEAlgorithm>AES256</SSEAlgorithm></ApplyServerSideEncryptionByDefault></Rule></ServerSideEncryptionConfiguration>`
+
+func TestS3X_BucketSSEConfig(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	config, err := bucketsse.ParseBucketSSEConfig(strings.NewReader(testBucketSSEConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("not set", func(t *testing.T) {
+		if _, err := gateway.GetBucketSSEConfig(ctx, testBucket1); err == nil {
+			t.Fatal("expected error for bucket without encryption config")
+		}
+		if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if err := gateway.SetBucketSSEConfig(ctx, testBucket1, config); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("get", func(t *testing.T) {
+		got, err := gateway.GetBucketSSEConfig(ctx, testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Rules) != 1 || got.Rules[0].DefaultEncryptionAction.Algorithm != bucketsse.AES256 {
+			t.Fatalf("expected the AES256 rule, but got %+v", got.Rules)
+		}
+	})
+	t.Run("default encryption", func(t *testing.T) {
+		// the metadata of a put the handler encrypted with the bucket default
+		opts := minio.ObjectOptions{UserDefined: map[string]string{
+			crypto.SSESealAlgorithm: crypto.SealAlgorithm,
+			crypto.S3SealedKey:      "sealed",
+		}}
+		if _, err := gateway.PutObject(ctx, testBucket1, "encrypted", getTestPutObjectReader(t, []byte(testObject1Data)), opts); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("plaintext rejected", func(t *testing.T) {
+		_, err := gateway.PutObject(ctx, testBucket1, "plaintext", getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{})
+		if _, ok := err.(minio.ObjectEncryptionRequired); !ok {
+			t.Fatal("expected error ObjectEncryptionRequired, but got", err)
+		}
+		_, err = gateway.NewMultipartUpload(ctx, testBucket1, "plaintext", minio.ObjectOptions{})
+		if _, ok := err.(minio.ObjectEncryptionRequired); !ok {
+			t.Fatal("expected error ObjectEncryptionRequired, but got", err)
+		}
+		srcInfo, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = gateway.CopyObject(ctx, testBucket1, testObject1, testBucket1, "plaintext", srcInfo, minio.ObjectOptions{}, minio.ObjectOptions{})
+		if _, ok := err.(minio.ObjectEncryptionRequired); !ok {
+			t.Fatal("expected error ObjectEncryptionRequired, but got", err)
+		}
+		if _, err := gateway.GetObjectInfo(ctx, testBucket1, "plaintext", minio.ObjectOptions{}); !isObjectNotFound(err) {
+			t.Fatalf("expected ObjectNotFound, but got %v", err)
+		}
+	})
+	t.Run("delete", func(t *testing.T) {
+		if err := gateway.DeleteBucketSSEConfig(ctx, testBucket1); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gateway.GetBucketSSEConfig(ctx, testBucket1); err == nil {
+			t.Fatal("expected error after the encryption config is deleted")
+		}
+		if _, err := gateway.PutObject(ctx, testBucket1, "plaintext", getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestS3X_KeyRotation(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	if !gateway.ReencryptOnKeyRotation() {
+		t.Fatal("expected key rotation to re-encrypt the object data")
+	}
+	plaintext := []byte(testObject1Data)
+	oldKey, newKey := [32]byte{1}, [32]byte{2}
+	ciphertext, meta := sealTestObject(t, oldKey, testBucket1, testObject1, plaintext)
+	opts := minio.ObjectOptions{UserDefined: meta}
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, ciphertext), opts); err != nil {
+		t.Fatal(err)
+	}
+	oldHash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the handler decrypts the source with the old key and encrypts it with the new key
+	info, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := openTestObject(t, gateway, oldKey, testBucket1, testObject1, len(plaintext))
+	ciphertext, meta = sealTestObject(t, newKey, testBucket1, testObject1, got)
+	info.UserDefined = meta
+	info.PutObjReader = getTestPutObjectReader(t, ciphertext)
+	if _, err := gateway.CopyObject(ctx, testBucket1, testObject1, testBucket1, testObject1, info, minio.ObjectOptions{}, minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	newHash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newHash == oldHash {
+		t.Fatal("expected the rotated object to be stored with a new cid")
+	}
+	if got := openTestObject(t, gateway, newKey, testBucket1, testObject1, len(plaintext)); !bytes.Equal(got, plaintext) {
+		t.Fatalf("expected plaintext %q after the key rotation, but got %q", plaintext, got)
+	}
+	info, err = gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var key crypto.ObjectKey
+	sealedKey, err := crypto.SSEC.ParseMetadata(info.UserDefined)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Unseal(oldKey, sealedKey, crypto.SSEC.String(), testBucket1, testObject1); err == nil {
+		t.Fatal("expected the old key to no longer unseal the object key")
+	}
+}
+
+func TestS3X_GetObjectNInfoDecrypted(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	plaintext := []byte(testObject1Data)
+	key := [32]byte{1}
+	ciphertext, meta := sealTestObject(t, key, testBucket1, testObject1, plaintext)
+	opts := minio.ObjectOptions{UserDefined: meta}
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, ciphertext), opts); err != nil {
+		t.Fatal(err)
+	}
+	keyMD5 := md5.Sum(key[:])
+	tests := []struct {
+		name string
+		h    http.Header
+		rs   *minio.HTTPRangeSpec
+		want []byte
+	}{
+		{"SSE-C", http.Header{
+			crypto.SSECAlgorithm: []string{crypto.SSEAlgorithmAES256},
+			crypto.SSECKey:       []string{base64.StdEncoding.EncodeToString(key[:])},
+			crypto.SSECKeyMD5:    []string{base64.StdEncoding.EncodeToString(keyMD5[:])},
+		}, nil, plaintext},
+		// copies are decrypted with the copy source key, so the handler can encrypt them with the new key
+		{"SSE-C copy", http.Header{
+			crypto.SSECopyAlgorithm: []string{crypto.SSEAlgorithmAES256},
+			crypto.SSECopyKey:       []string{base64.StdEncoding.EncodeToString(key[:])},
+			crypto.SSECopyKeyMD5:    []string{base64.StdEncoding.EncodeToString(keyMD5[:])},
+		}, nil, plaintext},
+		{"Range", http.Header{
+			crypto.SSECAlgorithm: []string{crypto.SSEAlgorithmAES256},
+			crypto.SSECKey:       []string{base64.StdEncoding.EncodeToString(key[:])},
+			crypto.SSECKeyMD5:    []string{base64.StdEncoding.EncodeToString(keyMD5[:])},
+		}, &minio.HTTPRangeSpec{Start: 2, End: 5}, plaintext[2:6]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gr, err := gateway.GetObjectNInfo(ctx, testBucket1, testObject1, tt.rs, tt.h, 0, minio.ObjectOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer gr.Close()
+			got, err := ioutil.ReadAll(gr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("expected %q, but got %q", tt.want, got)
+			}
+		})
+	}
+	wrongKey := [32]byte{2}
+	wrongKeyMD5 := md5.Sum(wrongKey[:])
+	h := http.Header{
+		crypto.SSECAlgorithm: []string{crypto.SSEAlgorithmAES256},
+		crypto.SSECKey:       []string{base64.StdEncoding.EncodeToString(wrongKey[:])},
+		crypto.SSECKeyMD5:    []string{base64.StdEncoding.EncodeToString(wrongKeyMD5[:])},
+	}
+	if _, err := gateway.GetObjectNInfo(ctx, testBucket1, testObject1, nil, h, 0, minio.ObjectOptions{}); err == nil {
+		t.Fatal("expected reading with another key to fail")
+	}
+}
+
+// sealTestObject encrypts data with a new object key sealed by the SSE-C client key,
+// as the handlers do for SSE-C puts, and returns the ciphertext and its metadata.
+func sealTestObject(t *testing.T, clientKey [32]byte, bucket, object string, data []byte) ([]byte, map[string]string) {
+	objectKey := crypto.GenerateKey(clientKey, rand.Reader)
+	sealedKey := objectKey.Seal(clientKey, crypto.GenerateIV(rand.Reader), crypto.SSEC.String(), bucket, object)
+	ciphertext, err := ioutil.ReadAll(crypto.EncryptSinglePart(bytes.NewReader(data), objectKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ciphertext, crypto.SSEC.CreateMetadata(nil, sealedKey)
+}
+
+// openTestObject decrypts the stored object with the SSE-C client key
+func openTestObject(t *testing.T, gateway *testGateway, clientKey [32]byte, bucket, object string, size int) []byte {
+	ctx := context.Background()
+	info, err := gateway.GetObjectInfo(ctx, bucket, object, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealedKey, err := crypto.SSEC.ParseMetadata(info.UserDefined)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var key crypto.ObjectKey
+	if err := key.Unseal(clientKey, sealedKey, crypto.SSEC.String(), bucket, object); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	w := crypto.DecryptSinglePart(buf, 0, int64(size), key)
+	if err := gateway.GetObject(ctx, bucket, object, 0, info.Size, w, "", minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	minio "github.com/RTradeLtd/s3x/cmd"
+	pkgerrors "github.com/pkg/errors"
 )
 
 var (
@@ -22,9 +23,63 @@ var (
 	// ErrInvalidUploadID is an error message returned when the multipart upload id
 	// does not exist
 	ErrInvalidUploadID = errors.New("invalid multipart upload id")
+	// ErrMultipartIDExists is an error message returned when a multipart upload
+	// is created with the id of an existing upload
+	ErrMultipartIDExists = errors.New("multipart upload id exists")
 	// ErrInvalidPartNumber is an error message returned when the multipart part
 	// number is out of range (not mappable to a minio error type)
 	ErrInvalidPartNumber = errors.New("invalid multipart part number")
+	// ErrTooManyParts is an error message returned when a part is added to
+	// a multipart upload that already has the maximum number of parts
+	ErrTooManyParts = errors.New("too many multipart parts")
+	// ErrInvalidObjectCID is an error message returned when a cid to restore does not
+	// resolve to a prior version of the object
+	ErrInvalidObjectCID = errors.New("cid is not a version of the object")
+	// ErrInvalidStorageClass is an error message returned when an object is transitioned
+	// to a storage class that is not supported
+	ErrInvalidStorageClass = errors.New("invalid storage class")
+	// ErrMetadataIndexDisabled is an error message returned when objects are searched by
+	// metadata while the metadata index is disabled
+	ErrMetadataIndexDisabled = errors.New("metadata index is disabled")
+	// ErrUnknownTransformer is an error message returned when object data was stored
+	// transformed by a transformer that is not configured anymore
+	ErrUnknownTransformer = errors.New("object transformer is not configured")
+	// ErrBucketObjectLimit is an error message returned when an object is added
+	// to a bucket that has reached its object limit
+	ErrBucketObjectLimit = errors.New("bucket object limit reached")
+	// ErrPreconditionFailed is an error message returned when an object is deleted
+	// conditionally and its ETag does not match the expected one
+	ErrPreconditionFailed = errors.New("object etag does not match")
+	// ErrObjectExists is an error message returned when an object
+	// is overwritten in an append-only bucket
+	ErrObjectExists = errors.New("object already exists in append-only bucket")
+	// ErrLedgerCloseTimeout is an error message returned when the ledger
+	// datastore does not shut down in time
+	ErrLedgerCloseTimeout = errors.New("timed out closing ledger datastore")
+	// ErrTooManyUploads is an error message returned when a multipart upload is
+	// started in a bucket that has reached its limit of uploads in progress
+	ErrTooManyUploads = errors.New("too many multipart uploads in progress in bucket")
+	// ErrObjectLegalHold is an error message returned when an object
+	// under legal hold is deleted or overwritten
+	ErrObjectLegalHold = errors.New("object is under legal hold")
+	// ErrUnknownInventoryFormat is an error message returned when a bucket inventory
+	// is exported in a format that is not supported
+	ErrUnknownInventoryFormat = errors.New("unknown inventory format")
+	// ErrIPNSNotConfigured is an error message returned when ipns publishing
+	// is used without an ipns publisher configured
+	ErrIPNSNotConfigured = errors.New("ipns publisher is not configured")
+	// ErrBucketIPNSDisabled is an error message returned when the ipns name
+	// of a bucket is requested while its root is not published to ipns
+	ErrBucketIPNSDisabled = errors.New("bucket root is not published to ipns")
+	// ErrInvalidNamespace is an error message returned when a ledger is
+	// created with a datastore namespace that contains a "/"
+	ErrInvalidNamespace = errors.New("datastore namespace must not contain a /")
+	// ErrUnknownBucketConfig is an error message returned when a bucket config
+	// is set or read by a name that is not settable by name
+	ErrUnknownBucketConfig = errors.New("unknown bucket config")
+	// ErrInvalidBucketConfig is an error message returned when a bucket config
+	// is set to a value that is not valid for its type
+	ErrInvalidBucketConfig = errors.New("invalid bucket config value")
 )
 
 // toMinioErr converts gRPC or ledger errors into compatible minio errors
@@ -41,8 +96,28 @@ func (x *xObjects) toMinioErr(err error, bucket, object, id string) error {
 		err = minio.InvalidUploadID{Bucket: bucket, Object: object, UploadID: id}
 	case ErrLedgerNonEmptyBucket:
 		err = minio.BucketNotEmpty{Bucket: bucket}
+	case ErrBucketObjectLimit:
+		err = minio.BucketQuotaExceeded{Bucket: bucket}
+	case ErrPreconditionFailed:
+		err = minio.PreConditionFailed{}
+	case ErrTooManyUploads:
+		err = minio.SlowDown{}
+	case ErrObjectExists:
+		err = minio.ObjectAlreadyExists{Bucket: bucket, Object: object}
+	case ErrObjectLegalHold:
+		err = minio.ObjectLocked{Bucket: bucket, Object: object}
+	case ErrTooManyParts, ErrInvalidObjectCID, ErrMultipartIDExists, ErrMetadataIndexDisabled,
+		ErrUnknownTransformer, ErrBucketIPNSDisabled, ErrUnknownBucketConfig, ErrInvalidBucketConfig:
+		err = minio.InvalidRequest{Err: err}
+	case ErrIPNSNotConfigured:
+		err = minio.NotImplemented{}
 	case nil:
 		return nil
+	default:
+		// dag errors are wrapped, but the handlers only recognize an unwrapped SlowDown
+		if _, ok := pkgerrors.Cause(err).(minio.SlowDown); ok {
+			err = minio.SlowDown{}
+		}
 	}
 	return err
 }
@@ -0,0 +1,161 @@
+package s3x
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// failoverRetries is the number of times a failed read is retried on the primary
+	// endpoint, before the primary is marked unhealthy and reads fail over to the fallbacks
+	failoverRetries = 2
+	// failoverProbeInterval is the interval between probes of an unhealthy primary endpoint
+	failoverProbeInterval = 10 * time.Second
+)
+
+// failoverDagClient sends dag requests to a primary endpoint, and fails dag reads over to the
+// fallback endpoints in order once the primary failed them. The primary is then marked unhealthy,
+// reads go straight to the fallbacks until a probe of the primary succeeds again.
+// Writes always go to the primary, so that objects are only added to a single node.
+type failoverDagClient struct {
+	pb.NodeAPIClient
+	fallbacks []pb.NodeAPIClient
+
+	mu        sync.Mutex
+	unhealthy bool
+}
+
+func (c *failoverDagClient) Dag(ctx context.Context, in *pb.DagRequest, opts ...grpc.CallOption) (*pb.DagResponse, error) {
+	if in.GetRequestType() == pb.DAGREQTYPE_DAG_PUT || len(c.fallbacks) == 0 {
+		return c.NodeAPIClient.Dag(ctx, in, opts...)
+	}
+	var resp *pb.DagResponse
+	var err error
+	if !c.isUnhealthy() {
+		for i := 0; i <= failoverRetries; i++ {
+			resp, err = c.NodeAPIClient.Dag(ctx, in, opts...)
+			if !isBackendError(err) || ctx.Err() != nil {
+				return resp, err
+			}
+		}
+		c.setUnhealthy(err)
+	}
+	for _, fallback := range c.fallbacks {
+		resp, err = fallback.Dag(ctx, in, opts...)
+		if !isBackendError(err) || ctx.Err() != nil {
+			return resp, err
+		}
+	}
+	return resp, err
+}
+
+// failoverFileClient streams uploads to the primary endpoint, and fails downloads over to the
+// fallback endpoints in order, the same way failoverDagClient does for dag reads.
+// It shares the health of the primary with dag, so both fail over and recover together.
+type failoverFileClient struct {
+	pb.FileAPIClient
+	fallbacks []pb.FileAPIClient
+	dag       *failoverDagClient
+}
+
+func (c *failoverFileClient) DownloadFile(ctx context.Context, in *pb.DownloadRequest, opts ...grpc.CallOption) (pb.FileAPI_DownloadFileClient, error) {
+	if len(c.fallbacks) == 0 {
+		return c.FileAPIClient.DownloadFile(ctx, in, opts...)
+	}
+	var stream pb.FileAPI_DownloadFileClient
+	var err error
+	if !c.dag.isUnhealthy() {
+		for i := 0; i <= failoverRetries; i++ {
+			stream, err = startDownload(ctx, c.FileAPIClient, in, opts...)
+			if !isBackendError(err) || ctx.Err() != nil {
+				return stream, err
+			}
+		}
+		c.dag.setUnhealthy(err)
+	}
+	for _, fallback := range c.fallbacks {
+		stream, err = startDownload(ctx, fallback, in, opts...)
+		if !isBackendError(err) || ctx.Err() != nil {
+			return stream, err
+		}
+	}
+	return stream, err
+}
+
+// startDownload starts a download and receives its first response, since a stream only reports
+// a failing endpoint once it's read. The response is returned by the first Recv of the stream.
+func startDownload(ctx context.Context, files pb.FileAPIClient, in *pb.DownloadRequest, opts ...grpc.CallOption) (pb.FileAPI_DownloadFileClient, error) {
+	stream, err := files.DownloadFile(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := stream.Recv()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return &peekedDownload{FileAPI_DownloadFileClient: stream, resp: resp, err: err, peeked: true}, nil
+}
+
+// peekedDownload is a download stream whose first response was already received
+type peekedDownload struct {
+	pb.FileAPI_DownloadFileClient
+	resp   *pb.DownloadResponse
+	err    error
+	peeked bool
+}
+
+func (d *peekedDownload) Recv() (*pb.DownloadResponse, error) {
+	if d.peeked {
+		d.peeked = false
+		return d.resp, d.err
+	}
+	return d.FileAPI_DownloadFileClient.Recv()
+}
+
+// isBackendError returns true if err means TemporalX is unavailable or did not answer in time,
+// other errors, such as for data that does not exist, are returned the same by any endpoint
+func isBackendError(err error) bool {
+	s, ok := status.FromError(errors.Cause(err))
+	return ok && (s.Code() == codes.Unavailable || s.Code() == codes.DeadlineExceeded)
+}
+
+// isUnhealthy returns true if dag reads fail over without trying the primary
+func (c *failoverDagClient) isUnhealthy() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.unhealthy
+}
+
+// setUnhealthy marks the primary unhealthy after it failed a read with err
+func (c *failoverDagClient) setUnhealthy(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.unhealthy {
+		log.Printf("ipfs reads failed %v times, failing over to fallback endpoints: %v", failoverRetries+1, err)
+	}
+	c.unhealthy = true
+}
+
+// probe tries an ipfs write on the primary while it's unhealthy, which marks it healthy if it succeeds
+func (c *failoverDagClient) probe(ctx context.Context) error {
+	if !c.isUnhealthy() {
+		return nil
+	}
+	if _, err := ipfsSaveBytes(ctx, c.NodeAPIClient, writeProbeData); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	log.Printf("primary ipfs endpoint recovered, reads no longer fail over")
+	c.unhealthy = false
+	return nil
+}
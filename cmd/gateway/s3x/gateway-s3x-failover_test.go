@@ -0,0 +1,189 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// unavailableDagClient wraps a NodeAPIClient, counts dag requests and fails them while fail is set
+type unavailableDagClient struct {
+	pb.NodeAPIClient
+	fail     int32
+	requests int32
+}
+
+func (c *unavailableDagClient) Dag(ctx context.Context, in *pb.DagRequest, opts ...grpc.CallOption) (*pb.DagResponse, error) {
+	atomic.AddInt32(&c.requests, 1)
+	if atomic.LoadInt32(&c.fail) == 1 {
+		return nil, status.Error(codes.Unavailable, "node down")
+	}
+	return c.NodeAPIClient.Dag(ctx, in, opts...)
+}
+
+// unavailableFileClient wraps a FileAPIClient, counts downloads and fails them while fail is set
+type unavailableFileClient struct {
+	pb.FileAPIClient
+	fail      int32
+	downloads int32
+}
+
+func (c *unavailableFileClient) DownloadFile(ctx context.Context, in *pb.DownloadRequest, opts ...grpc.CallOption) (pb.FileAPI_DownloadFileClient, error) {
+	atomic.AddInt32(&c.downloads, 1)
+	if atomic.LoadInt32(&c.fail) == 1 {
+		// streams report errors when they're read
+		return unavailableDownload{}, nil
+	}
+	return c.FileAPIClient.DownloadFile(ctx, in, opts...)
+}
+
+type unavailableDownload struct {
+	pb.FileAPI_DownloadFileClient
+}
+
+func (unavailableDownload) Recv() (*pb.DownloadResponse, error) {
+	return nil, status.Error(codes.Unavailable, "node down")
+}
+
+func TestS3X_DagFailover(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	data := []byte("failover data")
+	hash, err := ipfsSaveBytes(ctx, gateway.dagClient, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary := &unavailableDagClient{NodeAPIClient: gateway.dagClient, fail: 1}
+	secondary := &unavailableDagClient{NodeAPIClient: gateway.dagClient}
+	dag := &failoverDagClient{NodeAPIClient: primary, fallbacks: []pb.NodeAPIClient{secondary}}
+	read := func(t *testing.T) {
+		t.Helper()
+		got, err := ipfsBytes(ctx, dag, hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("expected %q, but got %q", data, got)
+		}
+	}
+	t.Run("fail over", func(t *testing.T) {
+		read(t)
+		if n := atomic.LoadInt32(&primary.requests); n != failoverRetries+1 {
+			t.Fatalf("expected %v requests to the primary, but got %v", failoverRetries+1, n)
+		}
+		if n := atomic.LoadInt32(&secondary.requests); n != 1 {
+			t.Fatalf("expected the secondary to serve the read, but got %v requests", n)
+		}
+		if !dag.isUnhealthy() {
+			t.Fatal("expected the primary to be marked unhealthy")
+		}
+	})
+	t.Run("unhealthy", func(t *testing.T) {
+		read(t)
+		if n := atomic.LoadInt32(&primary.requests); n != failoverRetries+1 {
+			t.Fatalf("expected reads to skip the unhealthy primary, but got %v requests", n)
+		}
+		if _, err := ipfsSaveBytes(ctx, dag, data); status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected writes to go to the primary, but got %v", err)
+		}
+		if n := atomic.LoadInt32(&secondary.requests); n != 2 {
+			t.Fatalf("expected writes not to fail over, but got %v requests to the secondary", n)
+		}
+		if err := dag.probe(ctx); err == nil {
+			t.Fatal("expected the probe of a failing primary to fail")
+		}
+	})
+	t.Run("recovered", func(t *testing.T) {
+		atomic.StoreInt32(&primary.fail, 0)
+		if err := dag.probe(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if dag.isUnhealthy() {
+			t.Fatal("expected the primary to be healthy after a successful probe")
+		}
+		before := atomic.LoadInt32(&primary.requests)
+		read(t)
+		if n := atomic.LoadInt32(&primary.requests); n != before+1 {
+			t.Fatalf("expected reads to go to the primary again, but got %v requests", n-before)
+		}
+		if n := atomic.LoadInt32(&secondary.requests); n != 2 {
+			t.Fatalf("expected no more requests to the secondary, but got %v", n)
+		}
+	})
+}
+
+func TestS3X_FileFailover(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	primary := &unavailableFileClient{FileAPIClient: gateway.fileClient, fail: 1}
+	secondary := &unavailableFileClient{FileAPIClient: gateway.fileClient}
+	dag := &failoverDagClient{NodeAPIClient: gateway.dagClient}
+	gateway.fileClient = &failoverFileClient{FileAPIClient: primary, fallbacks: []pb.FileAPIClient{secondary}, dag: dag}
+	read := func(t *testing.T) {
+		t.Helper()
+		buf := &bytes.Buffer{}
+		if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, 0, buf, "", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != testObject1Data {
+			t.Fatalf("expected %q, but got %q", testObject1Data, buf.String())
+		}
+	}
+	t.Run("fail over", func(t *testing.T) {
+		read(t)
+		if n := atomic.LoadInt32(&primary.downloads); n != failoverRetries+1 {
+			t.Fatalf("expected %v downloads from the primary, but got %v", failoverRetries+1, n)
+		}
+		if n := atomic.LoadInt32(&secondary.downloads); n != 1 {
+			t.Fatalf("expected the secondary to serve the read, but got %v downloads", n)
+		}
+		if !dag.isUnhealthy() {
+			t.Fatal("expected the primary to be marked unhealthy")
+		}
+	})
+	t.Run("unhealthy", func(t *testing.T) {
+		read(t)
+		if n := atomic.LoadInt32(&primary.downloads); n != failoverRetries+1 {
+			t.Fatalf("expected reads to skip the unhealthy primary, but got %v downloads", n)
+		}
+	})
+}
+
+func TestS3X_IsBackendError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{status.Error(codes.Unavailable, "node down"), true},
+		{status.Error(codes.DeadlineExceeded, "timeout"), true},
+		{errors.Wrap(status.Error(codes.Unavailable, "node down"), "dag get"), true},
+		{status.Error(codes.NotFound, "block not found"), false},
+		{status.Error(codes.InvalidArgument, "invalid cid"), false},
+		{status.Error(codes.Canceled, "canceled"), false},
+		{io.EOF, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isBackendError(tt.err); got != tt.want {
+			t.Errorf("isBackendError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package s3x
+
+import (
+	"context"
+)
+
+// FindObjectsByTag returns the sorted names of the objects in the bucket with the tag,
+// it fails with an InvalidRequest if the metadata index is disabled.
+func (x *xObjects) FindObjectsByTag(ctx context.Context, bucket, tagKey, tagValue string) ([]string, error) {
+	if err := x.checkRateLimit(ctx, bucket); err != nil {
+		return nil, err
+	}
+	names, err := x.ledgerStore.FindObjects(bucket, indexFieldTagPrefix+tagKey, tagValue)
+	return names, x.toMinioErr(err, bucket, "", "")
+}
+
+// FindObjectsByContentType returns the sorted names of the objects in the bucket with the
+// content type, it fails with an InvalidRequest if the metadata index is disabled.
+func (x *xObjects) FindObjectsByContentType(ctx context.Context, bucket, contentType string) ([]string, error) {
+	if err := x.checkRateLimit(ctx, bucket); err != nil {
+		return nil, err
+	}
+	names, err := x.ledgerStore.FindObjects(bucket, indexFieldContentType, contentType)
+	return names, x.toMinioErr(err, bucket, "", "")
+}
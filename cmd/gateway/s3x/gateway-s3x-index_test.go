@@ -0,0 +1,85 @@
+package s3x
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+)
+
+func TestS3X_FindObjects(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	if _, err := gateway.FindObjectsByTag(ctx, testBucket1, "team", "a"); !isInvalidRequest(err, ErrMetadataIndexDisabled) {
+		t.Fatalf("expected InvalidRequest for ErrMetadataIndexDisabled, but got %v", err)
+	}
+	gateway.ledgerStore.indexMetadata = true
+	put := func(t *testing.T, object, tags, contentType string) {
+		opts := minio.ObjectOptions{UserDefined: map[string]string{
+			xhttp.AmzObjectTagging: tags,
+			"content-type":         contentType,
+		}}
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte(testObject1Data)), opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := func(t *testing.T, got []string, err error, want ...string) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want == nil {
+			want = []string{}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected %v, but got %v", want, got)
+		}
+	}
+	put(t, "report", "team=a&year=2020", "application/pdf")
+	put(t, "slides", "team=a", "application/pdf")
+	put(t, "notes", "team=b", "text/plain")
+	t.Run("by tag", func(t *testing.T) {
+		names, err := gateway.FindObjectsByTag(ctx, testBucket1, "team", "a")
+		expect(t, names, err, "report", "slides")
+		names, err = gateway.FindObjectsByTag(ctx, testBucket1, "year", "2020")
+		expect(t, names, err, "report")
+		names, err = gateway.FindObjectsByTag(ctx, testBucket1, "team", "c")
+		expect(t, names, err)
+	})
+	t.Run("by content type", func(t *testing.T) {
+		names, err := gateway.FindObjectsByContentType(ctx, testBucket1, "application/pdf")
+		expect(t, names, err, "report", "slides")
+	})
+	t.Run("replace", func(t *testing.T) {
+		put(t, "slides", "team=b", "application/pdf")
+		names, err := gateway.FindObjectsByTag(ctx, testBucket1, "team", "a")
+		expect(t, names, err, "report")
+		names, err = gateway.FindObjectsByTag(ctx, testBucket1, "team", "b")
+		expect(t, names, err, "notes", "slides")
+	})
+	t.Run("delete", func(t *testing.T) {
+		if err := gateway.DeleteObject(ctx, testBucket1, "report"); err != nil {
+			t.Fatal(err)
+		}
+		names, err := gateway.FindObjectsByTag(ctx, testBucket1, "team", "a")
+		expect(t, names, err)
+		names, err = gateway.FindObjectsByContentType(ctx, testBucket1, "application/pdf")
+		expect(t, names, err, "slides")
+	})
+	t.Run("disabled", func(t *testing.T) {
+		gateway.ledgerStore.indexMetadata = false
+		put(t, "late", "team=a", "text/plain")
+		if err := gateway.ledgerStore.dropMetadataIndex(); err != nil {
+			t.Fatal(err)
+		}
+		gateway.ledgerStore.indexMetadata = true
+		names, err := gateway.FindObjectsByTag(ctx, testBucket1, "team", "b")
+		expect(t, names, err)
+		put(t, "late", "team=a", "text/plain")
+		names, err = gateway.FindObjectsByTag(ctx, testBucket1, "team", "a")
+		expect(t, names, err, "late")
+	})
+}
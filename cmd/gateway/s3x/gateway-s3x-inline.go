@@ -0,0 +1,150 @@
+package s3x
+
+import (
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	proto "github.com/gogo/protobuf/proto"
+	"github.com/ipfs/go-merkledag"
+	unixfs_pb "github.com/ipfs/go-unixfs/pb"
+)
+
+// s3xMetaInlineData is the internal metadata key holding the base64 encoded
+// object data for objects that are stored inline in the ledger
+const s3xMetaInlineData = minio.ReservedMetadataPrefix + "S3x-Inline-Data"
+
+// shouldInline returns true if an object of the given size should be stored inline,
+// a negative size indicates an unknown size, which is never inlined.
+//
+// Empty objects are always inlined, ipfs has no file for them, and they get the
+// hash ipfs gives an empty file. Other objects are not inlined while
+// transformers are configured, as inline data is not transformed.
+func (x *xObjects) shouldInline(size int64) bool {
+	if size == 0 {
+		return true
+	}
+	return len(x.transformers) == 0 && x.inlineThreshold > 0 && size > 0 && size <= x.inlineThreshold
+}
+
+// inlineObjectData reads all data from r and records it inline in obinfo.
+//
+// The returned hash is the hash the data gets when it's stored on ipfs, so objects
+// get the same ETag whether they are inlined or not, but it is not added to ipfs.
+func inlineObjectData(r io.Reader, obinfo *ObjectInfo) (string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	hash, err := unixfsFileHash(data)
+	if err != nil {
+		return "", err
+	}
+	if obinfo.UserDefined == nil {
+		obinfo.UserDefined = make(map[string]string)
+	}
+	obinfo.UserDefined[s3xMetaInlineData] = base64.StdEncoding.EncodeToString(data)
+	obinfo.Size_ = int64(len(data))
+	return hash, nil
+}
+
+const (
+	// unixfsChunkSize is the size of the chunks ipfs splits file data into by default
+	unixfsChunkSize = 256 << 10
+	// unixfsLinksPerNode is the maximum number of links of a unixfs file node ipfs creates
+	unixfsLinksPerNode = 174
+)
+
+// unixfsNode is a unixfs file node and the size of the file data under it
+type unixfsNode struct {
+	node *merkledag.ProtoNode
+	size uint64
+}
+
+// unixfsFileHash returns the CIDv0 of data added to ipfs as a unixfs file with the default
+// chunker and balanced layout, the chunks are filled in from the left of a tree just deep
+// enough to link all of them. Nothing is added to ipfs.
+func unixfsFileHash(data []byte) (string, error) {
+	var leaves []unixfsNode
+	for off := 0; off == 0 || off < len(data); off += unixfsChunkSize {
+		end := off + unixfsChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		leaf, err := newUnixfsNode(data[off:end], nil)
+		if err != nil {
+			return "", err
+		}
+		leaves = append(leaves, leaf)
+	}
+	root, rest := leaves[0], leaves[1:]
+	for depth := 1; len(rest) > 0; depth++ {
+		var err error
+		root, rest, err = fillUnixfsNode([]unixfsNode{root}, rest, depth)
+		if err != nil {
+			return "", err
+		}
+	}
+	return root.node.Cid().String(), nil
+}
+
+// fillUnixfsNode returns a node of the depth linking to children followed by as many
+// subtrees of leaves as fit in it, and the leaves that did not fit
+func fillUnixfsNode(children, leaves []unixfsNode, depth int) (unixfsNode, []unixfsNode, error) {
+	for len(children) < unixfsLinksPerNode && len(leaves) > 0 {
+		if depth == 1 {
+			children = append(children, leaves[0])
+			leaves = leaves[1:]
+			continue
+		}
+		child, rest, err := fillUnixfsNode(nil, leaves, depth-1)
+		if err != nil {
+			return unixfsNode{}, nil, err
+		}
+		children, leaves = append(children, child), rest
+	}
+	node, err := newUnixfsNode(nil, children)
+	return node, leaves, err
+}
+
+// newUnixfsNode returns a unixfs file node holding the data or linking to the children
+func newUnixfsNode(data []byte, children []unixfsNode) (unixfsNode, error) {
+	size := uint64(len(data))
+	var blocks []uint64
+	for _, c := range children {
+		size += c.size
+		blocks = append(blocks, c.size)
+	}
+	file := &unixfs_pb.Data{
+		Type:       unixfs_pb.Data_File.Enum(),
+		Filesize:   &size,
+		Blocksizes: blocks,
+	}
+	if len(data) > 0 {
+		// ipfs leaves the data of empty files unset
+		file.Data = data
+	}
+	pbdata, err := proto.Marshal(file)
+	if err != nil {
+		return unixfsNode{}, err
+	}
+	node := merkledag.NodeWithData(pbdata)
+	for _, c := range children {
+		if err := node.AddNodeLink("", c.node); err != nil {
+			return unixfsNode{}, err
+		}
+	}
+	return unixfsNode{node: node, size: size}, nil
+}
+
+// inlineData returns the object data if it's stored inline,
+// ok is false if the object data is stored on ipfs.
+func (m *ObjectInfo) inlineData() (data []byte, ok bool, err error) {
+	encoded, ok := m.GetUserDefined()[s3xMetaInlineData]
+	if !ok {
+		return nil, false, nil
+	}
+	data, err = base64.StdEncoding.DecodeString(encoded)
+	return data, true, err
+}
@@ -0,0 +1,186 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_Inline_Badger(t *testing.T) {
+	testS3XInline(t, DSTypeBadger)
+}
+func TestS3X_Inline_Crdt(t *testing.T) {
+	testS3XInline(t, DSTypeCrdt)
+}
+func testS3XInline(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	gateway.inlineThreshold = 1024
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(testObject1Data)
+	info, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, data), minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := info.UserDefined[s3xMetaInlineData]; ok {
+		t.Fatal("inline data should not be exposed in object info")
+	}
+	obj, err := gateway.ledgerStore.Object(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := obj.ObjectInfo.inlineData(); !ok {
+		t.Fatal("expected object data to be stored inline")
+	}
+	t.Run("read", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, info.Size, buf, "", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Fatalf("expected %s, but got %s", data, buf.Bytes())
+		}
+		buf.Reset()
+		if err := gateway.GetObject(ctx, testBucket1, testObject1, 4, 6, buf, "", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), data[4:10]) {
+			t.Fatalf("expected %s, but got %s", data[4:10], buf.Bytes())
+		}
+	})
+	t.Run("stable hash", func(t *testing.T) {
+		if _, err := gateway.PutObject(ctx, testBucket1, "copy", getTestPutObjectReader(t, data), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		h1, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h2, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, "copy")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h1 != h2 {
+			t.Fatalf("expected identical data hashes, but got %v and %v", h1, h2)
+		}
+	})
+	t.Run("large objects are not inlined", func(t *testing.T) {
+		if _, err := gateway.PutObject(ctx, testBucket1, "large", getTestPutObjectReader(t, make([]byte, 2048)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		obj, err := gateway.ledgerStore.Object(ctx, testBucket1, "large")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok, _ := obj.ObjectInfo.inlineData(); ok {
+			t.Fatal("expected object data to be stored on ipfs")
+		}
+	})
+}
+
+func BenchmarkS3X_TinyObjects(b *testing.B) {
+	const count = 10000
+	for _, inline := range []bool{true, false} {
+		b.Run(fmt.Sprintf("inline=%v", inline), func(b *testing.B) {
+			ctx := context.Background()
+			gateway := newTestGateway(b, DSTypeBadger)
+			defer func() {
+				if err := gateway.Shutdown(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}()
+			if inline {
+				gateway.inlineThreshold = 1024
+			}
+			if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
+				b.Fatal(err)
+			}
+			for i := 0; i < count; i++ {
+				data := []byte(fmt.Sprint(i))
+				if _, err := gateway.PutObject(ctx, testBucket1, fmt.Sprint(i), getTestPutObjectReader(b, data), minio.ObjectOptions{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+			buf := bytes.NewBuffer(nil)
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				for i := 0; i < count; i++ {
+					buf.Reset()
+					if err := gateway.GetObject(ctx, testBucket1, fmt.Sprint(i), 0, 0, buf, "", minio.ObjectOptions{}); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestS3X_EmptyObject(t *testing.T) {
+	// emptyCID is the hash ipfs gives an empty file
+	const emptyCID = "QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH"
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	for _, threshold := range []int64{0, 1024} {
+		t.Run(fmt.Sprintf("inline threshold %v", threshold), func(t *testing.T) {
+			gateway.inlineThreshold = threshold
+			put, err := gateway.PutObject(ctx, testBucket1, "dir/", getTestPutObjectReader(t, nil), minio.ObjectOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := minio.ToS3ETag(emptyCID)
+			if put.ETag != want {
+				t.Fatalf("expected ETag %v, but got %v", want, put.ETag)
+			}
+			info, err := gateway.GetObjectInfo(ctx, testBucket1, "dir/", minio.ObjectOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size != 0 || info.ETag != want {
+				t.Fatalf("expected size 0 and ETag %v, but got size %v and ETag %v", want, info.Size, info.ETag)
+			}
+			buf := bytes.NewBuffer(nil)
+			if err := gateway.GetObject(ctx, testBucket1, "dir/", 0, 0, buf, "", minio.ObjectOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if buf.Len() != 0 {
+				t.Fatalf("expected no data, but got %q", buf.String())
+			}
+		})
+	}
+}
+
+func TestS3X_Inline_ETag(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	sizes := []int{len(testObject1Data), unixfsChunkSize, 2*unixfsChunkSize + 10}
+	for _, size := range sizes {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			data := bytes.Repeat([]byte("x"), size)
+			put := func(object string, threshold int64) minio.ObjectInfo {
+				gateway.inlineThreshold = threshold
+				info, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, data), minio.ObjectOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return info
+			}
+			inlined := put("inlined", int64(size))
+			stored := put("stored", 0)
+			if inlined.ETag != stored.ETag {
+				t.Fatalf("expected the inlined ETag to be the stored ETag %v, but got %v", stored.ETag, inlined.ETag)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package s3x
+
+import (
+	"sort"
+	"strings"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+// s3xUploadInProgressHeader is the metadata key set on listed objects with a multipart upload
+// in progress when TEMX.ListUploadsInProgress is enabled
+const s3xUploadInProgressHeader = "X-Amz-Meta-S3x-Upload-In-Progress"
+
+// mergeUploadsInProgress annotates the listed objects that have a multipart upload in progress,
+// and adds an empty entry for every key of an upload in progress without an object yet.
+// Keys grouped by the delimiter are added to the common prefixes instead.
+func (x *xObjects) mergeUploadsInProgress(bucket, prefix, startAfter, delimiter string,
+	objects []minio.ObjectInfo, prefixes []string) ([]minio.ObjectInfo, []string, error) {
+	uploads, err := x.ledgerStore.GetMultipartUploadTimes(bucket, prefix)
+	if err != nil || len(uploads) == 0 {
+		return objects, prefixes, err
+	}
+	for i, obj := range objects {
+		if _, ok := uploads[obj.Name]; !ok {
+			continue
+		}
+		delete(uploads, obj.Name)
+		// the metadata may be shared with the ledger cache, so it is copied
+		userDefined := make(map[string]string, len(obj.UserDefined)+1)
+		for k, v := range obj.UserDefined {
+			userDefined[k] = v
+		}
+		userDefined[s3xUploadInProgressHeader] = "true"
+		objects[i].UserDefined = userDefined
+	}
+	seen := make(map[string]bool, len(prefixes))
+	for _, p := range prefixes {
+		seen[p] = true
+	}
+	added := false
+	for name, initiated := range uploads {
+		if name < startAfter {
+			continue
+		}
+		if delimiter != "" {
+			if i := strings.Index(name[len(prefix):], delimiter); i >= 0 {
+				if p := name[:len(prefix)+i+len(delimiter)]; !seen[p] {
+					seen[p] = true
+					prefixes = append(prefixes, p)
+					sort.Strings(prefixes)
+				}
+				continue
+			}
+		}
+		added = true
+		objects = append(objects, minio.ObjectInfo{
+			Bucket:      bucket,
+			Name:        name,
+			ModTime:     initiated,
+			UserDefined: map[string]string{s3xUploadInProgressHeader: "true"},
+		})
+	}
+	if added {
+		sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	}
+	return objects, prefixes, nil
+}
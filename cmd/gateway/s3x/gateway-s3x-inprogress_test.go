@@ -0,0 +1,54 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_ListObjects_UploadsInProgress(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	if _, err := gateway.PutObject(ctx, testBucket1, "done", getTestPutObjectReader(t, []byte("done")), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.NewMultipartUpload(ctx, testBucket1, "pending", minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	inProgress := func(objects []minio.ObjectInfo) map[string]bool {
+		m := make(map[string]bool)
+		for _, obj := range objects {
+			m[obj.Name] = obj.UserDefined[s3xUploadInProgressHeader] == "true"
+		}
+		return m
+	}
+
+	loi, err := gateway.ListObjects(ctx, testBucket1, "", "", "", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := inProgress(loi.Objects); len(got) != 1 || got["done"] {
+		t.Fatalf("expected only the completed object without annotation, but got %v", got)
+	}
+
+	gateway.listUploads = true
+	loi, err = gateway.ListObjects(ctx, testBucket1, "", "", "", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loi.Objects) != 2 || loi.Objects[0].Name != "done" || loi.Objects[1].Name != "pending" {
+		t.Fatalf("expected both keys in order, but got %v", loi.Objects)
+	}
+	if got := inProgress(loi.Objects); got["done"] || !got["pending"] {
+		t.Fatalf("expected only the upload in progress to be annotated, but got %v", got)
+	}
+	v2, err := gateway.ListObjectsV2(ctx, testBucket1, "", "", "", 1000, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := inProgress(v2.Objects); len(got) != 2 || got["done"] || !got["pending"] {
+		t.Fatalf("expected only the upload in progress to be annotated, but got %v", got)
+	}
+}
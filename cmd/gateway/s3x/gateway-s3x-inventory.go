@@ -0,0 +1,92 @@
+package s3x
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	// InventoryFormatCSV is an inventory with a header row and a row per object
+	InventoryFormatCSV = "csv"
+	// InventoryFormatJSON is an inventory with a JSON object per line for every object
+	InventoryFormatJSON = "json"
+)
+
+// inventoryHeader is the header row of a csv inventory
+var inventoryHeader = []string{"Key", "Size", "ETag", "LastModified", "StorageClass"}
+
+// inventoryEntry is an object of an inventory, the ETag is the ipfs hash of the object data
+// unless the object has another ETag
+type inventoryEntry struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"lastModified"`
+	StorageClass string    `json:"storageClass"`
+}
+
+// ExportInventory returns an inventory of all objects of a bucket ordered by key, in the
+// InventoryFormatCSV or InventoryFormatJSON format, to be written to an object for reporting.
+// The inventory is generated while it's read, one object at a time, so it's never buffered whole.
+// Closing the reader before the end stops generating the inventory.
+func (x *xObjects) ExportInventory(ctx context.Context, bucket, format string) (io.ReadCloser, error) {
+	if format != InventoryFormatCSV && format != InventoryFormatJSON {
+		return nil, ErrUnknownInventoryFormat
+	}
+	if err := x.ledgerStore.AssertBucketExits(bucket); err != nil {
+		return nil, x.toMinioErr(err, bucket, "", "")
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(x.writeInventory(ctx, pw, bucket, format))
+	}()
+	return pr, nil
+}
+
+// writeInventory writes the inventory of a bucket to w
+func (x *xObjects) writeInventory(ctx context.Context, w io.Writer, bucket, format string) error {
+	var write func(e inventoryEntry) error
+	var flush func() error
+	switch format {
+	case InventoryFormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(inventoryHeader); err != nil {
+			return err
+		}
+		write = func(e inventoryEntry) error {
+			return cw.Write([]string{
+				e.Key,
+				strconv.FormatInt(e.Size, 10),
+				e.ETag,
+				e.LastModified.Format(time.RFC3339Nano),
+				e.StorageClass,
+			})
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		enc := json.NewEncoder(w)
+		write = func(e inventoryEntry) error { return enc.Encode(e) }
+		flush = func() error { return nil }
+	}
+	err := x.ledgerStore.WalkDelimited(ctx, bucket, "", "", func(name string, obj *Object) error {
+		info := getObjectETagInfo(&obj.ObjectInfo, obj.GetDataHash())
+		return write(inventoryEntry{
+			Key:          name,
+			Size:         info.Size,
+			ETag:         info.ETag,
+			LastModified: info.ModTime.UTC(),
+			StorageClass: info.StorageClass,
+		})
+	})
+	if err != nil {
+		return x.toMinioErr(err, bucket, "", "")
+	}
+	return flush()
+}
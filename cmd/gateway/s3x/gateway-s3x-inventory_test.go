@@ -0,0 +1,103 @@
+package s3x
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_ExportInventory(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	objects := map[string]string{"b/two": "second object", "a/one": "first", "c": "third!"}
+	for name, data := range objects {
+		if _, err := gateway.PutObject(ctx, testBucket1, name, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gateway.TransitionObject(ctx, testBucket1, "c", storageClassCold); err != nil {
+		t.Fatal(err)
+	}
+	var want [][]string
+	for _, name := range []string{"a/one", "b/two", "c"} {
+		info, err := gateway.GetObjectInfo(ctx, testBucket1, name, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, []string{name, strconv.Itoa(len(objects[name])), info.ETag, info.ModTime.UTC().Format(time.RFC3339Nano), info.StorageClass})
+	}
+	t.Run("csv", func(t *testing.T) {
+		r, err := gateway.ExportInventory(ctx, testBucket1, InventoryFormatCSV)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		rows, err := csv.NewReader(r).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) == 0 || !reflect.DeepEqual(rows[0], inventoryHeader) {
+			t.Fatalf("expected header %v, but got %v", inventoryHeader, rows)
+		}
+		if !reflect.DeepEqual(rows[1:], want) {
+			t.Fatalf("expected rows %v, but got %v", want, rows[1:])
+		}
+		if want[2][4] != storageClassCold {
+			t.Fatalf("expected the storage class of c to be %v, but got %v", storageClassCold, want[2][4])
+		}
+	})
+	t.Run("json", func(t *testing.T) {
+		r, err := gateway.ExportInventory(ctx, testBucket1, InventoryFormatJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		dec := json.NewDecoder(r)
+		var keys []string
+		for {
+			var e inventoryEntry
+			if err := dec.Decode(&e); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if e.Size != int64(len(objects[e.Key])) {
+				t.Fatalf("expected size %v of %v, but got %v", len(objects[e.Key]), e.Key, e.Size)
+			}
+			keys = append(keys, e.Key)
+		}
+		if want := []string{"a/one", "b/two", "c"}; !reflect.DeepEqual(keys, want) {
+			t.Fatalf("expected keys %v, but got %v", want, keys)
+		}
+	})
+	t.Run("closed early", func(t *testing.T) {
+		r, err := gateway.ExportInventory(ctx, testBucket1, InventoryFormatCSV)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ioutil.ReadAll(r); err != io.ErrClosedPipe {
+			t.Fatalf("expected io.ErrClosedPipe, but got %v", err)
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		if _, err := gateway.ExportInventory(ctx, testBucket1, "xml"); err != ErrUnknownInventoryFormat {
+			t.Fatalf("expected ErrUnknownInventoryFormat, but got %v", err)
+		}
+		_, err := gateway.ExportInventory(ctx, "missing-bucket", InventoryFormatCSV)
+		if _, ok := err.(minio.BucketNotFound); !ok {
+			t.Fatalf("expected BucketNotFound, but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package s3x
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"sync"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	"github.com/mr-tron/base58"
+)
+
+// the prefixes of ed25519 keys marshaled as libp2p crypto.PublicKey and crypto.PrivateKey
+// protobuf messages: the Type field set to Ed25519 and the length of the Data field
+var (
+	ed25519PublicKeyPrefix  = []byte{0x08, 0x01, 0x12, ed25519.PublicKeySize}
+	ed25519PrivateKeyPrefix = []byte{0x08, 0x01, 0x12, ed25519.PrivateKeySize}
+)
+
+// errUnsupportedIPNSKey is returned when an ipns key in the keystore of the TemporalX node
+// is not an ed25519 key, such as a key created outside of the gateway
+var errUnsupportedIPNSKey = errors.New("ipns key is not an ed25519 key")
+
+// nameSysPublisher is an IPNSPublisher publishing with the NameSys api of the TemporalX node,
+// see TEMX.IPNSNameSys. Its ed25519 keys are created in the keystore of the node on first use,
+// so the names of buckets stay the same across gateway restarts.
+type nameSysPublisher struct {
+	node    pb.NodeAPIClient
+	namesys pb.NameSysAPIClient
+
+	// mu serializes key creation, so concurrent first uses of a key don't create two keys
+	mu sync.Mutex
+}
+
+func newNameSysPublisher(node pb.NodeAPIClient, namesys pb.NameSysAPIClient) *nameSysPublisher {
+	return &nameSysPublisher{node: node, namesys: namesys}
+}
+
+// Name returns the peer id of the key, which is the ipns name it publishes under
+func (p *nameSysPublisher) Name(ctx context.Context, key string) (string, error) {
+	priv, err := p.privateKey(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return ed25519PeerID(priv), nil
+}
+
+// Publish publishes the ipfs path of hash under the name of the key
+func (p *nameSysPublisher) Publish(ctx context.Context, key, hash string) error {
+	priv, err := p.privateKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	_, err = p.namesys.NameSysPublish(ctx, &pb.NameSysPublishRequest{
+		PrivateKey: marshalEd25519PrivateKey(priv),
+		Value:      "/ipfs/" + hash,
+	})
+	return err
+}
+
+// privateKey returns the key from the keystore of the node, creating it if it doesn't exist
+func (p *nameSysPublisher) privateKey(ctx context.Context, key string) (ed25519.PrivateKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	has, err := p.node.Keystore(ctx, &pb.KeystoreRequest{
+		RequestType: pb.KSREQTYPE_KS_HAS,
+		Name:        key,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !has.GetHas() {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.node.Keystore(ctx, &pb.KeystoreRequest{
+			RequestType: pb.KSREQTYPE_KS_PUT,
+			Name:        key,
+			PrivateKey:  marshalEd25519PrivateKey(priv),
+		}); err != nil {
+			return nil, err
+		}
+		return priv, nil
+	}
+	resp, err := p.node.Keystore(ctx, &pb.KeystoreRequest{
+		RequestType: pb.KSREQTYPE_KS_GET,
+		Name:        key,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalEd25519PrivateKey(resp.GetPrivateKey())
+}
+
+// marshalEd25519PrivateKey marshals the key the way libp2p crypto.MarshalPrivateKey does,
+// which is the format of the keystore and the NameSys api of TemporalX
+func marshalEd25519PrivateKey(priv ed25519.PrivateKey) []byte {
+	return append(append([]byte{}, ed25519PrivateKeyPrefix...), priv...)
+}
+
+// unmarshalEd25519PrivateKey is the reverse of marshalEd25519PrivateKey
+func unmarshalEd25519PrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	n := len(ed25519PrivateKeyPrefix)
+	if len(data) != n+ed25519.PrivateKeySize || string(data[:n]) != string(ed25519PrivateKeyPrefix) {
+		return nil, errUnsupportedIPNSKey
+	}
+	return ed25519.PrivateKey(data[n:]), nil
+}
+
+// ed25519PeerID returns the libp2p peer id of the key: the identity multihash of its
+// marshaled public key, which fits in the 42 bytes libp2p inlines, encoded in base58
+func ed25519PeerID(priv ed25519.PrivateKey) string {
+	pub := append(append([]byte{}, ed25519PublicKeyPrefix...), priv.Public().(ed25519.PublicKey)...)
+	// 0x00 is the identity multihash code, followed by the varint length of the digest
+	return base58.Encode(append([]byte{0x00, byte(len(pub))}, pub...))
+}
@@ -0,0 +1,106 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	"google.golang.org/grpc"
+)
+
+// keystoreClient keeps the keys of a fake TemporalX keystore in memory
+type keystoreClient struct {
+	pb.NodeAPIClient
+
+	mu   sync.Mutex
+	keys map[string][]byte
+	puts int
+}
+
+func (c *keystoreClient) Keystore(ctx context.Context, in *pb.KeystoreRequest, opts ...grpc.CallOption) (*pb.KeystoreResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch in.GetRequestType() {
+	case pb.KSREQTYPE_KS_HAS:
+		_, ok := c.keys[in.GetName()]
+		return &pb.KeystoreResponse{Has: ok}, nil
+	case pb.KSREQTYPE_KS_GET:
+		return &pb.KeystoreResponse{PrivateKey: c.keys[in.GetName()]}, nil
+	case pb.KSREQTYPE_KS_PUT:
+		c.puts++
+		c.keys[in.GetName()] = in.GetPrivateKey()
+	}
+	return &pb.KeystoreResponse{}, nil
+}
+
+// nameSysClient records the requests of NameSysPublish
+type nameSysClient struct {
+	pb.NameSysAPIClient
+
+	published []*pb.NameSysPublishRequest
+}
+
+func (c *nameSysClient) NameSysPublish(ctx context.Context, in *pb.NameSysPublishRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	c.published = append(c.published, in)
+	return &pb.Empty{}, nil
+}
+
+func TestS3X_NameSysPublisher(t *testing.T) {
+	ctx := context.Background()
+	keystore := &keystoreClient{keys: make(map[string][]byte)}
+	namesys := &nameSysClient{}
+	publisher := newNameSysPublisher(keystore, namesys)
+
+	name, err := publisher.Name(ctx, ipnsKey(testBucket1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the peer ids of ed25519 keys are inlined, so they all start the same
+	if !strings.HasPrefix(name, "12D3KooW") {
+		t.Fatalf("expected the peer id of an ed25519 key, but got %s", name)
+	}
+	t.Run("Stable", func(t *testing.T) {
+		// a new publisher stands for a gateway restart, the key is kept by the node
+		again, err := newNameSysPublisher(keystore, namesys).Name(ctx, ipnsKey(testBucket1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again != name {
+			t.Fatalf("expected the name %s to stay the same, but got %s", name, again)
+		}
+		if keystore.puts != 1 {
+			t.Fatalf("expected the key to be created once, but got %v creations", keystore.puts)
+		}
+		other, err := publisher.Name(ctx, ipnsKey(testBucket2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other == name {
+			t.Fatal("expected buckets to have different names")
+		}
+	})
+	t.Run("Publish", func(t *testing.T) {
+		if err := publisher.Publish(ctx, ipnsKey(testBucket1), "hash"); err != nil {
+			t.Fatal(err)
+		}
+		if len(namesys.published) != 1 {
+			t.Fatalf("expected 1 publish, but got %v", len(namesys.published))
+		}
+		req := namesys.published[0]
+		if req.GetValue() != "/ipfs/hash" {
+			t.Fatalf("expected the ipfs path of the hash to be published, but got %s", req.GetValue())
+		}
+		if !bytes.Equal(req.GetPrivateKey(), keystore.keys[ipnsKey(testBucket1)]) {
+			t.Fatal("expected the key of the bucket to be published with")
+		}
+	})
+	t.Run("Unsupported", func(t *testing.T) {
+		keystore.keys["rsa"] = []byte{0x08, 0x00, 0x12, 0x01, 0x00}
+		if _, err := publisher.Name(ctx, "rsa"); err != errUnsupportedIPNSKey {
+			t.Fatalf("expected errUnsupportedIPNSKey, but got %v", err)
+		}
+	})
+}
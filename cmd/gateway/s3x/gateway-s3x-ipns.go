@@ -0,0 +1,134 @@
+package s3x
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// defaultIPNSPublishInterval is the default interval between ipns publishes of bucket roots
+const defaultIPNSPublishInterval = time.Minute
+
+// IPNSPublisher publishes ipfs hashes under ipns names, such as with the name api of an ipfs node.
+// Publishing is slow, so it's only done in the background. Configured with TEMX.IPNSPublisher,
+// or TEMX.IPNSNameSys to publish with the NameSys api of the TemporalX node.
+type IPNSPublisher interface {
+	// Name returns the ipns name of the key, creating the key if it doesn't exist,
+	// without publishing anything
+	Name(ctx context.Context, key string) (string, error)
+	// Publish publishes hash under the ipns name of the key
+	Publish(ctx context.Context, key, hash string) error
+}
+
+// ipnsKey returns the name of the ipns key of a bucket
+func ipnsKey(bucket string) string {
+	return "s3x-bucket-" + bucket
+}
+
+// SetBucketIPNS enables or disables publishing the root of a bucket to ipns, the root is published
+// in the background after every update of the bucket, at most once every TEMX.IPNSPublishInterval.
+// Disabling it stops publishing, but the last published root stays reachable by the name.
+// It fails with minio.NotImplemented if no ipns publisher is configured.
+func (x *xObjects) SetBucketIPNS(ctx context.Context, bucket string, enabled bool) error {
+	if x.ipns == nil {
+		return x.toMinioErr(ErrIPNSNotConfigured, bucket, "", "")
+	}
+	if !enabled {
+		return x.toMinioErr(x.ledgerStore.DeleteBucketConfig(bucket, bucketConfigIPNS), bucket, "", "")
+	}
+	if err := x.ledgerStore.PutBucketConfig(bucket, bucketConfigIPNS, []byte{1}); err != nil {
+		return x.toMinioErr(err, bucket, "", "")
+	}
+	// the current root is published, so the name resolves without waiting for an update
+	hash, err := x.ledgerStore.GetBucketHash(bucket)
+	if err != nil {
+		return x.toMinioErr(err, bucket, "", "")
+	}
+	x.ipns.add(bucket, hash)
+	return nil
+}
+
+// GetBucketIPNSName returns the ipns name the root of a bucket is published under,
+// it fails with a minio.InvalidRequest of ErrBucketIPNSDisabled if the bucket root is not published.
+// The name is returned right away, it may still resolve to an older root until the next publish.
+func (x *xObjects) GetBucketIPNSName(ctx context.Context, bucket string) (string, error) {
+	if x.ipns == nil {
+		return "", x.toMinioErr(ErrIPNSNotConfigured, bucket, "", "")
+	}
+	data, err := x.ledgerStore.GetBucketConfig(bucket, bucketConfigIPNS)
+	if err != nil {
+		return "", x.toMinioErr(err, bucket, "", "")
+	}
+	if data == nil {
+		return "", x.toMinioErr(ErrBucketIPNSDisabled, bucket, "", "")
+	}
+	return x.ipns.publisher.Name(ctx, ipnsKey(bucket))
+}
+
+// publishBucketRoots publishes the latest roots of the buckets updated since the last call,
+// for the buckets that have ipns publishing enabled. Failed publishes are tried again next time.
+func (x *xObjects) publishBucketRoots(ctx context.Context) error {
+	var err error
+	for bucket, hash := range x.ipns.take() {
+		if ctx.Err() != nil {
+			x.ipns.add(bucket, hash)
+			continue
+		}
+		data, cerr := x.ledgerStore.GetBucketConfig(bucket, bucketConfigIPNS)
+		if cerr == ErrLedgerBucketDoesNotExist || (cerr == nil && data == nil) {
+			continue
+		}
+		if cerr == nil {
+			cerr = x.ipns.publisher.Publish(ctx, ipnsKey(bucket), hash)
+		}
+		if cerr != nil {
+			x.ipns.add(bucket, hash)
+			err = multierr.Append(err, cerr)
+		}
+	}
+	return err
+}
+
+// bucketPublisher collects the roots of updated buckets to publish to ipns
+type bucketPublisher struct {
+	publisher IPNSPublisher
+
+	mu      sync.Mutex
+	pending map[string]string //bucket names to their latest root not published yet
+}
+
+func newBucketPublisher(publisher IPNSPublisher) *bucketPublisher {
+	return &bucketPublisher{
+		publisher: publisher,
+		pending:   make(map[string]string),
+	}
+}
+
+// saved queues the new root of a bucket, replacing the previous one if it's not published yet.
+// It's called on every bucket update, so it doesn't block.
+func (p *bucketPublisher) saved(bucket, hash string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending[bucket] = hash
+}
+
+// take returns the queued roots and clears the queue
+func (p *bucketPublisher) take() map[string]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pending := p.pending
+	p.pending = make(map[string]string)
+	return pending
+}
+
+// add queues a root unless another one is queued, which is newer since the bucket was
+// updated after the root was read, such as while a failed publish was in progress
+func (p *bucketPublisher) add(bucket, hash string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.pending[bucket]; !ok {
+		p.pending[bucket] = hash
+	}
+}
@@ -0,0 +1,165 @@
+package s3x
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+// fakeIPNSPublisher keeps published names in memory
+type fakeIPNSPublisher struct {
+	mu        sync.Mutex
+	records   map[string]string //names to published hashes
+	publishes int
+	fail      error
+}
+
+func newFakeIPNSPublisher() *fakeIPNSPublisher {
+	return &fakeIPNSPublisher{records: make(map[string]string)}
+}
+
+func (p *fakeIPNSPublisher) Name(ctx context.Context, key string) (string, error) {
+	return "/ipns/" + key, nil
+}
+
+func (p *fakeIPNSPublisher) Publish(ctx context.Context, key, hash string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fail != nil {
+		return p.fail
+	}
+	p.publishes++
+	p.records["/ipns/"+key] = hash
+	return nil
+}
+
+func (p *fakeIPNSPublisher) resolve(name string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.records[name]
+}
+
+func isBucketNotFound(err error) bool {
+	_, ok := err.(minio.BucketNotFound)
+	return ok
+}
+
+func isBucketIPNSDisabled(err error) bool {
+	e, ok := err.(minio.InvalidRequest)
+	return ok && e.Err == ErrBucketIPNSDisabled
+}
+
+func TestS3X_BucketIPNS(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	var _ minio.BucketIPNSPublisher = gateway
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket2, "us-east-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.SetBucketIPNS(ctx, testBucket1, true); err != (minio.NotImplemented{}) {
+		t.Fatalf("expected NotImplemented, but got %v", err)
+	}
+
+	publisher := newFakeIPNSPublisher()
+	gateway.ipns = newBucketPublisher(publisher)
+	gateway.ledgerStore.OnBucketSaved(gateway.ipns.saved)
+	put := func(t *testing.T, bucket, object string) {
+		if _, err := gateway.PutObject(ctx, bucket, object, getTestPutObjectReader(t, []byte(object)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	publish := func(t *testing.T) {
+		if err := gateway.publishBucketRoots(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertPublished := func(t *testing.T, name string) {
+		hash, err := gateway.ledgerStore.GetBucketHash(testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := publisher.resolve(name); got != hash {
+			t.Fatalf("expected %s to resolve to the bucket root %s, but got %q", name, hash, got)
+		}
+	}
+
+	if _, err := gateway.GetBucketIPNSName(ctx, testBucket1); !isBucketIPNSDisabled(err) {
+		t.Fatalf("expected ErrBucketIPNSDisabled, but got %v", err)
+	}
+	if _, err := gateway.GetBucketIPNSName(ctx, "not-a-bucket"); !isBucketNotFound(err) {
+		t.Fatalf("expected BucketNotFound, but got %v", err)
+	}
+	if err := gateway.SetBucketIPNS(ctx, testBucket1, true); err != nil {
+		t.Fatal(err)
+	}
+	name, err := gateway.GetBucketIPNSName(ctx, testBucket1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Enable", func(t *testing.T) {
+		publish(t)
+		assertPublished(t, name)
+	})
+	t.Run("Update", func(t *testing.T) {
+		before := publisher.resolve(name)
+		put(t, testBucket1, "a")
+		put(t, testBucket1, "b")
+		publishes := publisher.publishes
+		publish(t)
+		assertPublished(t, name)
+		if publisher.resolve(name) == before {
+			t.Fatal("expected the updated bucket root to be published")
+		}
+		if publisher.publishes != publishes+1 {
+			t.Fatalf("expected updates between publishes to be published once, but got %v publishes", publisher.publishes-publishes)
+		}
+		publish(t)
+		if publisher.publishes != publishes+1 {
+			t.Fatal("expected no publish without an update")
+		}
+	})
+	t.Run("NotEnabled", func(t *testing.T) {
+		publishes := publisher.publishes
+		put(t, testBucket2, "a")
+		publish(t)
+		if publisher.publishes != publishes {
+			t.Fatal("expected the root of a bucket without ipns not to be published")
+		}
+	})
+	t.Run("Retry", func(t *testing.T) {
+		publisher.fail = errors.New("publish failed")
+		put(t, testBucket1, "c")
+		if err := gateway.publishBucketRoots(ctx); err != publisher.fail {
+			t.Fatalf("expected the publish error, but got %v", err)
+		}
+		publisher.fail = nil
+		publish(t)
+		assertPublished(t, name)
+	})
+	t.Run("Disable", func(t *testing.T) {
+		if err := gateway.SetBucketIPNS(ctx, testBucket1, false); err != nil {
+			t.Fatal(err)
+		}
+		published := publisher.resolve(name)
+		put(t, testBucket1, "d")
+		publish(t)
+		if publisher.resolve(name) != published {
+			t.Fatal("expected the root of a disabled bucket not to be published")
+		}
+		if _, err := gateway.GetBucketIPNSName(ctx, testBucket1); !isBucketIPNSDisabled(err) {
+			t.Fatalf("expected ErrBucketIPNSDisabled, but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package s3x
+
+import (
+	"strings"
+)
+
+// objectKey returns the key an object is stored under, which is the canonical form
+// of the key if canonical keys are enabled, see TEMX.CanonicalKeys
+func (x *xObjects) objectKey(object string) string {
+	if !x.canonicalKeys {
+		return object
+	}
+	return canonicalObjectKey(object)
+}
+
+// canonicalObjectKey removes leading slashes and collapses empty and "." segments of a key,
+// so that "/foo/./bar" and "foo//bar" both become "foo/bar". A trailing slash is kept,
+// as it marks a directory. ".." segments are kept as they are, keys are not paths.
+func canonicalObjectKey(object string) string {
+	segments := strings.Split(object, "/")
+	kept := segments[:0]
+	for _, s := range segments {
+		if s != "" && s != "." {
+			kept = append(kept, s)
+		}
+	}
+	key := strings.Join(kept, "/")
+	if key != "" && strings.HasSuffix(object, "/") {
+		key += "/"
+	}
+	return key
+}
@@ -0,0 +1,103 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_CanonicalObjectKey(t *testing.T) {
+	tests := []struct {
+		object, want string
+	}{
+		{"foo/bar", "foo/bar"},
+		{"/foo/bar", "foo/bar"},
+		{"//foo/bar", "foo/bar"},
+		{"foo//bar", "foo/bar"},
+		{"/foo/./bar", "foo/bar"},
+		{"./foo/bar", "foo/bar"},
+		{"foo/bar/", "foo/bar/"},
+		{"foo/bar//", "foo/bar/"},
+		{"foo/../bar", "foo/../bar"},
+		{"/", ""},
+	}
+	for _, tt := range tests {
+		if got := canonicalObjectKey(tt.object); got != tt.want {
+			t.Errorf("canonicalObjectKey(%q) = %q, want %q", tt.object, got, tt.want)
+		}
+	}
+}
+
+func TestS3X_CanonicalKeys(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	put := func(t *testing.T, variants []string) {
+		for _, object := range variants {
+			if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte(object)), minio.ObjectOptions{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	names := func(t *testing.T) []string {
+		loi, err := gateway.ListObjects(ctx, testBucket1, "", "", "", 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, obj := range loi.Objects {
+			names = append(names, obj.Name)
+		}
+		return names
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		// the other variants are not valid object names as they are
+		variants := []string{"/foo/bar", "foo/bar"}
+		put(t, variants)
+		if got := names(t); len(got) != len(variants) {
+			t.Fatalf("expected literal keys %v, but got %v", variants, got)
+		}
+		for _, object := range variants {
+			buf := bytes.NewBuffer(nil)
+			if err := gateway.GetObject(ctx, testBucket1, object, 0, 0, buf, "", minio.ObjectOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != object {
+				t.Fatalf("expected data of %q, but got %q", object, buf.String())
+			}
+		}
+		if _, err := gateway.PutObject(ctx, testBucket1, "foo//bar", getTestPutObjectReader(t, nil), minio.ObjectOptions{}); err == nil {
+			t.Fatal("expected literal foo//bar to be rejected")
+		}
+		if _, err := gateway.DeleteObjects(ctx, testBucket1, variants); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("enabled", func(t *testing.T) {
+		gateway.canonicalKeys = true
+		defer func() { gateway.canonicalKeys = false }()
+		variants := []string{"/foo/./bar", "foo//bar", "/foo/bar", "foo/bar"}
+		put(t, variants)
+		if got := names(t); len(got) != 1 || got[0] != "foo/bar" {
+			t.Fatalf("expected only foo/bar, but got %v", got)
+		}
+		for _, object := range variants {
+			buf := bytes.NewBuffer(nil)
+			if err := gateway.GetObject(ctx, testBucket1, object, 0, 0, buf, "", minio.ObjectOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != "foo/bar" {
+				t.Fatalf("expected data of the last put, but got %q", buf.String())
+			}
+		}
+		if err := gateway.DeleteObject(ctx, testBucket1, "/foo/bar"); err != nil {
+			t.Fatal(err)
+		}
+		if got := names(t); len(got) != 0 {
+			t.Fatalf("expected no objects, but got %v", got)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package s3x
+
+import (
+	"context"
+	"encoding/base64"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+)
+
+var dsAccessKey = datastore.NewKey("a") //bucket name and object name to the time the object data was last read
+
+// accessKey returns the datastore key of the time the data of an object was last read,
+// the object name is encoded as it may contain characters that are not valid in keys.
+func accessKey(bucket, object string) datastore.Key {
+	return dsAccessKey.ChildString(bucket).ChildString(base64.RawURLEncoding.EncodeToString([]byte(object)))
+}
+
+// getLastAccess returns the recorded time the data of an object was last read,
+// ok is false if no read was recorded.
+func (ls *ledgerStore) getLastAccess(bucket, object string) (t time.Time, ok bool, err error) {
+	data, err := ls.ds.Get(accessKey(bucket, object))
+	if err == datastore.ErrNotFound {
+		return t, false, nil
+	}
+	if err != nil {
+		return t, false, err
+	}
+	t, err = time.Parse(time.RFC3339Nano, string(data))
+	return t, err == nil, err
+}
+
+// lastAccess returns when the data of an object was last read, or when the object was written
+// if no read was recorded since.
+func (ls *ledgerStore) lastAccess(bucket, object string, info *ObjectInfo) (time.Time, error) {
+	t, ok, err := ls.getLastAccess(bucket, object)
+	if err != nil || !ok || t.Before(info.GetModTime()) {
+		return info.GetModTime(), err
+	}
+	return t, nil
+}
+
+// SetObjectLastAccess records now as the time the object data was last read, unless a read
+// was recorded less than interval before now. The time is kept in the datastore, so recording
+// a read neither rewrites the object nor adds a version of it.
+func (ls *ledgerStore) SetObjectLastAccess(ctx context.Context, bucket, object string, now time.Time, interval time.Duration) error {
+	unlock := ls.locker.read(bucket)
+	t, ok, err := ls.getLastAccess(bucket, object)
+	unlock()
+	if err != nil || (ok && now.Sub(t) < interval) {
+		return err
+	}
+	defer ls.locker.write(bucket)()
+	// the object may have been removed since it was read
+	if _, err := ls.getObjectHash(ctx, bucket, object); err != nil {
+		return err
+	}
+	return ls.ds.Put(accessKey(bucket, object), []byte(now.UTC().Format(time.RFC3339Nano)))
+}
+
+// deleteLastAccessBatch removes the last read time of an object in w
+func (ls *ledgerStore) deleteLastAccessBatch(w *writeBatch, bucket, object string) error {
+	return w.Delete(accessKey(bucket, object))
+}
+
+// deleteLastAccesses removes the last read times of all objects of a bucket in w
+func (ls *ledgerStore) deleteLastAccesses(w *writeBatch, bucket string) error {
+	return ls.deleteKeysBatch(w, dsAccessKey.ChildString(bucket))
+}
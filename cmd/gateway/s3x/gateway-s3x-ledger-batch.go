@@ -0,0 +1,67 @@
+package s3x
+
+import (
+	"github.com/ipfs/go-datastore"
+)
+
+// writeBatch groups datastore writes so they are committed together,
+// writes are applied individually if the datastore does not support batching.
+//
+// Functions registered with onCommit are called after a successful commit,
+// this is used to update caches only once the data is persisted.
+type writeBatch struct {
+	ds       datastore.Datastore
+	batch    datastore.Batch
+	sync     bool // sync the datastore on commit
+	onCommit []func()
+}
+
+func (ls *ledgerStore) newWriteBatch() *writeBatch {
+	w := &writeBatch{ds: ls.ds, sync: ls.syncCommits}
+	if !ls.noBatch {
+		if b, err := ls.ds.Batch(); err == nil {
+			w.batch = b
+		}
+	}
+	return w
+}
+
+func (w *writeBatch) Put(key datastore.Key, value []byte) error {
+	if w.batch != nil {
+		return w.batch.Put(key, value)
+	}
+	return w.ds.Put(key, value)
+}
+
+func (w *writeBatch) Delete(key datastore.Key) error {
+	if w.batch != nil {
+		return w.batch.Delete(key)
+	}
+	err := w.ds.Delete(key)
+	if err == datastore.ErrNotFound {
+		return nil // consistent with batch deletes
+	}
+	return err
+}
+
+// OnCommit registers f to be called after the batch is committed
+func (w *writeBatch) OnCommit(f func()) {
+	w.onCommit = append(w.onCommit, f)
+}
+
+func (w *writeBatch) Commit() error {
+	if w.batch != nil {
+		if err := w.batch.Commit(); err != nil {
+			return err
+		}
+	}
+	if w.sync {
+		if err := w.ds.Sync(datastore.NewKey("/")); err != nil {
+			return err
+		}
+	}
+	for _, f := range w.onCommit {
+		f()
+	}
+	return nil
+}
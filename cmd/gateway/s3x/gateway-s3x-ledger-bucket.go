@@ -3,6 +3,7 @@ package s3x
 import (
 	"context"
 	"fmt"
+	"time"
 
 	pb "github.com/RTradeLtd/TxPB/v3/go"
 	"github.com/ipfs/go-datastore"
@@ -17,7 +18,7 @@ func (m *LedgerBucketEntry) ensureCache(ctx context.Context, dag pb.NodeAPIClien
 	// because it's cannot change without retrieving the old value.
 	defer cacheLocker.write(m.IpfsHash)()
 	if m.Bucket == nil {
-		b, err := ipfsBucket(ctx, dag, m.IpfsHash)
+		b, err := ipfsBucketLoaded(ctx, dag, m.IpfsHash)
 		if err != nil {
 			return err
 		}
@@ -31,13 +32,25 @@ func (m *LedgerBucketEntry) ensureCache(ctx context.Context, dag pb.NodeAPIClien
 
 //GetBucketInfo returns the BucketInfo in ledger,
 //possible errors include ErrLedgerBucketDoesNotExist and dag network errors.
+//
+//The bucket info is read from the root node of the bucket if the bucket is not cached,
+//without loading the shards of its objects.
 func (ls *ledgerStore) GetBucketInfo(ctx context.Context, bucket string) (*BucketInfo, error) {
 	defer ls.locker.read(bucket)()
-	b, err := ls.getBucketLoaded(ctx, bucket)
+	b, err := ls.getBucketEntry(ctx, bucket)
 	if err != nil {
 		return nil, err
 	}
-	bi := b.Bucket.GetBucketInfo()
+	unlock := cacheLocker.read(b.IpfsHash)
+	cached := b.Bucket
+	unlock()
+	if cached == nil {
+		cached, err = ipfsBucket(ctx, ls.dag, b.IpfsHash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	bi := cached.GetBucketInfo()
 	return &bi, nil
 }
 
@@ -51,6 +64,33 @@ func (ls *ledgerStore) GetBucketHash(bucket string) (string, error) {
 	return b.IpfsHash, nil
 }
 
+// BucketDigest returns the ipfs hash of the bucket and its number of objects, both are read
+// under the same lock, so they describe the same state of the bucket.
+func (ls *ledgerStore) BucketDigest(ctx context.Context, bucket string) (string, int64, error) {
+	defer ls.locker.read(bucket)()
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return "", 0, err
+	}
+	return b.IpfsHash, int64(len(b.GetBucket().GetObjects())), nil
+}
+
+// warmConcurrency is the maximum number of buckets loaded at the same time by WarmBuckets
+const warmConcurrency = 8
+
+// WarmBuckets loads the objects of the buckets into the cache, so that the first requests
+// to them don't wait for the buckets to be fetched from ipfs. Missing buckets are ignored.
+func (ls *ledgerStore) WarmBuckets(ctx context.Context, buckets []string) error {
+	return runBounded(ctx, len(buckets), warmConcurrency, func(ctx context.Context, i int) error {
+		defer ls.locker.read(buckets[i])()
+		_, err := ls.getBucketLoaded(ctx, buckets[i])
+		if err == ErrLedgerBucketDoesNotExist {
+			return nil
+		}
+		return err
+	})
+}
+
 // getBucketNilable returns a lazy loading LedgerBucketEntry
 //
 // if err is returned, then the datastore can not be read
@@ -59,6 +99,9 @@ func (ls *ledgerStore) getBucketNilable(bucket string) (*LedgerBucketEntry, erro
 	ls.mapLocker.Lock()
 	b, ok := ls.l.Buckets[bucket]
 	ls.mapLocker.Unlock()
+	if ok && ls.reconcileInterval > 0 {
+		return ls.reconcileBucket(bucket, b)
+	}
 	if !ok {
 		bHash, err := ls.ds.Get(dsBucketKey.ChildString(bucket))
 		if err != nil {
@@ -99,12 +142,28 @@ func (ls *ledgerStore) getBucketRequired(bucket string) (*LedgerBucketEntry, err
 	return b, nil
 }
 
+// getBucketRefreshed returns a lazy loading LedgerBucketEntry like getBucketRequired,
+// but checks the cached entry against the datastore first, see WithStrongRead
+func (ls *ledgerStore) getBucketRefreshed(bucket string) (*LedgerBucketEntry, error) {
+	ls.mapLocker.Lock()
+	b := ls.l.Buckets[bucket]
+	ls.mapLocker.Unlock()
+	b, err := ls.refreshBucket(bucket, b, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, ErrLedgerBucketDoesNotExist
+	}
+	return b, nil
+}
+
 // getBucketLoaded returns a loaded LedgerBucketEntry
 //
 // if err is returned, then the datastore can not be read,
 // or the bucket does not exit
 func (ls *ledgerStore) getBucketLoaded(ctx context.Context, bucket string) (*LedgerBucketEntry, error) {
-	b, err := ls.getBucketRequired(bucket)
+	b, err := ls.getBucketEntry(ctx, bucket)
 	if err != nil {
 		return nil, err
 	}
@@ -114,6 +173,15 @@ func (ls *ledgerStore) getBucketLoaded(ctx context.Context, bucket string) (*Led
 	return b, nil
 }
 
+// getBucketEntry returns a lazy loading LedgerBucketEntry, which is checked
+// against the datastore first for strong reads, see WithStrongRead
+func (ls *ledgerStore) getBucketEntry(ctx context.Context, bucket string) (*LedgerBucketEntry, error) {
+	if isStrongRead(ctx) {
+		return ls.getBucketRefreshed(bucket)
+	}
+	return ls.getBucketRequired(bucket)
+}
+
 //CreateBucket saves a new bucket iff it did not exist
 func (ls *ledgerStore) CreateBucket(ctx context.Context, bucket string, b *Bucket) (string, error) {
 	defer ls.locker.write(bucket)()
@@ -142,17 +210,30 @@ func (ls *ledgerStore) createBucket(ctx context.Context, bucket string, b *Bucke
 }
 
 func (ls *ledgerStore) saveBucket(ctx context.Context, bucket string, b *Bucket) (*LedgerBucketEntry, error) {
+	w := ls.newWriteBatch()
+	lb, err := ls.saveBucketBatch(ctx, w, bucket, b, nil)
+	if err != nil {
+		return nil, err
+	}
+	return lb, w.Commit()
+}
+
+// saveBucketBatch saves the bucket to ipfs and writes its hash to w,
+// the ledger cache is updated once w is committed.
+//
+// changed are the names of the objects modified in b, see ipfsSaveBucket.
+func (ls *ledgerStore) saveBucketBatch(ctx context.Context, w *writeBatch, bucket string, b *Bucket, changed []string) (*LedgerBucketEntry, error) {
 	//check if bucket is valid
 	if b.BucketInfo.Name != bucket {
 		return nil, fmt.Errorf("bucket name miss match %v != %v", bucket, b.BucketInfo.Name)
 	}
 
 	//save to ipfs and get hash
-	bHash, err := ipfsSave(ctx, ls.dag, b)
+	bHash, err := ipfsSaveBucket(ctx, ls.dag, b, ls.shardThreshold, changed, ls.metadataCodec)
 	if err != nil {
 		return nil, err
 	}
-	if err := ls.ds.Put(dsBucketKey.ChildString(bucket), []byte(bHash)); err != nil {
+	if err := w.Put(dsBucketKey.ChildString(bucket), []byte(bHash)); err != nil {
 		return nil, err
 	}
 
@@ -161,12 +242,25 @@ func (ls *ledgerStore) saveBucket(ctx context.Context, bucket string, b *Bucket)
 		Bucket:   b,
 		IpfsHash: bHash,
 	}
-	ls.mapLocker.Lock()
-	ls.l.Buckets[bucket] = lb
-	ls.mapLocker.Unlock()
+	w.OnCommit(func() {
+		ls.mapLocker.Lock()
+		ls.l.Buckets[bucket] = lb
+		ls.mapLocker.Unlock()
+		if ls.onSaved != nil {
+			ls.onSaved(bucket, bHash)
+		}
+	})
 	return lb, nil
 }
 
+// OnBucketSaved sets fn to be called with the name and new ipfs hash of a bucket every time
+// this ledger saves it. It must be set before the ledger is used.
+//
+// fn is called while the bucket is locked, so it must not block or use the ledger.
+func (ls *ledgerStore) OnBucketSaved(fn func(bucket, hash string)) {
+	ls.onSaved = fn
+}
+
 func (ls *ledgerStore) AssertBucketExits(bucket string) error {
 	unlock := ls.locker.read(bucket)
 	err := ls.assertBucketExits(bucket)
@@ -197,9 +291,40 @@ func (ls *ledgerStore) DeleteBucket(bucket string) error {
 	if err != nil {
 		return err
 	}
-	ls.mapLocker.Lock()
-	delete(ls.l.Buckets, bucket)
-	ls.mapLocker.Unlock()
-	return ls.ds.Delete(dsBucketKey.ChildString(bucket))
+	w := ls.newWriteBatch()
+	if err := ls.deleteBucketConfigs(w, bucket); err != nil {
+		return err
+	}
+	if err := ls.deleteBucketIndex(w, bucket); err != nil {
+		return err
+	}
+	if err := ls.deleteUploadCheckpoints(w, bucket); err != nil {
+		return err
+	}
+	if err := ls.deleteObjectVersions(w, bucket); err != nil {
+		return err
+	}
+	if err := ls.deleteLastAccesses(w, bucket); err != nil {
+		return err
+	}
+	if err := ls.deleteExpiries(w, bucket); err != nil {
+		return err
+	}
+	if err := ls.deleteLegalHolds(w, bucket); err != nil {
+		return err
+	}
+	if err := w.Delete(dsBucketKey.ChildString(bucket)); err != nil {
+		return err
+	}
+	w.OnCommit(func() {
+		ls.mapLocker.Lock()
+		b, cached := ls.l.Buckets[bucket]
+		delete(ls.l.Buckets, bucket)
+		ls.mapLocker.Unlock()
+		if cached && b != nil {
+			ls.evicted(bucket)
+		}
+	})
+	return w.Commit()
 	//todo: remove from ipfs
 }
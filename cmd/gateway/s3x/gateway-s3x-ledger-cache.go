@@ -0,0 +1,217 @@
+package s3x
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultObjectCacheSize is the number of serialized objects kept in memory
+	defaultObjectCacheSize = 4096
+	// defaultListCacheTTL is the default time listing results are cached
+	defaultListCacheTTL = time.Second
+	// defaultListCacheSize is the default number of cached listing results
+	defaultListCacheSize = 256
+	// defaultObjectLoadTimeout is the time a shared fetch of an uncached object may take
+	defaultObjectLoadTimeout = defaultDagReadTimeout
+)
+
+// objectCache keeps recently used serialized objects by ipfs hash, once full the oldest entry is evicted.
+//
+// Objects are content addressed, so a cached entry never has to be invalidated.
+// Serialized data is cached instead of objects, so callers can not modify cached entries.
+type objectCache struct {
+	hits, misses int64 // first for atomic alignment
+	size         int
+	loadTimeout  time.Duration
+
+	mu      sync.Mutex
+	data    map[string][]byte
+	order   []string
+	loading map[string]*objectLoad
+}
+
+// objectLoad is a fetch of an uncached object, that concurrent misses of the object wait for
+type objectLoad struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+func newObjectCache(size int) *objectCache {
+	return &objectCache{
+		size:        size,
+		loadTimeout: defaultObjectLoadTimeout,
+		data:        make(map[string][]byte, size),
+		loading:     make(map[string]*objectLoad),
+	}
+}
+
+// load returns the cached data of h, or calls fetch on a miss. Concurrent misses of the
+// same hash share a single fetch, which is not added to the cache, see add.
+//
+// The shared fetch runs on its own context limited by loadTimeout, so a caller that gives up
+// doesn't fail the fetch for the others. Every caller waits until the fetch is done or ctx is done.
+func (c *objectCache) load(ctx context.Context, h string, fetch func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	c.mu.Lock()
+	if data, ok := c.data[h]; ok {
+		c.mu.Unlock()
+		atomic.AddInt64(&c.hits, 1)
+		return data, nil
+	}
+	l, ok := c.loading[h]
+	if ok {
+		atomic.AddInt64(&c.hits, 1)
+	} else {
+		atomic.AddInt64(&c.misses, 1)
+		l = &objectLoad{done: make(chan struct{})}
+		c.loading[h] = l
+		go c.fetch(h, l, fetch)
+	}
+	c.mu.Unlock()
+	select {
+	case <-l.done:
+		return l.data, l.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetch runs the shared fetch of l and wakes up the callers waiting for it
+func (c *objectCache) fetch(h string, l *objectLoad, fetch func(ctx context.Context) ([]byte, error)) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.loadTimeout)
+	defer cancel()
+	l.data, l.err = fetch(ctx)
+	c.mu.Lock()
+	delete(c.loading, h)
+	c.mu.Unlock()
+	close(l.done)
+}
+
+// stats returns the number of cache hits and misses
+func (c *objectCache) stats() (hits, misses int64) {
+	return atomic.LoadInt64(&c.hits), atomic.LoadInt64(&c.misses)
+}
+
+func (c *objectCache) add(h string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.data[h]; ok || c.size <= 0 {
+		return
+	}
+	if len(c.order) >= c.size {
+		delete(c.data, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.data[h] = data
+	c.order = append(c.order, h)
+}
+
+// ipfsObject returns an object by hash from the object cache, or from ipfs if it's not cached
+func (ls *ledgerStore) ipfsObject(ctx context.Context, h string) (*Object, error) {
+	data, err := ls.objects.load(ctx, h, func(ctx context.Context) ([]byte, error) {
+		return ipfsMetadataBytes(ctx, ls.dag, h)
+	})
+	if err != nil {
+		return nil, err
+	}
+	obj := &Object{}
+	if err := obj.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	ls.objects.add(h, data)
+	return obj, nil
+}
+
+// OnEvict sets fn to be called with the name of a bucket when its cached entry is dropped,
+// which happens when the bucket is deleted, or replaced by the reconciler after a change by
+// another gateway. It must be set before the ledger is used.
+//
+// fn is called without holding the cache lock, but the bucket may still be locked, so fn
+// must not wait on ledger operations of the bucket, such as warming the cache again.
+func (ls *ledgerStore) OnEvict(fn func(bucket string)) {
+	ls.onEvict = fn
+}
+
+// evicted calls the eviction callback for a bucket, it must not be called with mapLocker held
+func (ls *ledgerStore) evicted(bucket string) {
+	if ls.onEvict != nil {
+		ls.onEvict(bucket)
+	}
+}
+
+// listingKey holds the arguments of a listing
+type listingKey struct {
+	bucket, prefix, startsFrom, delimiter string
+	max                                   int
+}
+
+// listing is a cached listing result
+type listing struct {
+	bucketHash string
+	created    time.Time
+	objects    []*Object
+	prefixes   []string
+}
+
+// listingCache keeps recent listing results for a short ttl, once full the oldest entry is evicted.
+//
+// A listing is only valid for the bucket root hash it was made from, so any write to the
+// bucket invalidates its cached listings. Cached results are shared, callers must not modify them.
+type listingCache struct {
+	hits, misses int64 // first for atomic alignment
+	ttl          time.Duration
+	size         int
+
+	mu    sync.Mutex
+	data  map[listingKey]*listing
+	order []listingKey
+}
+
+// newListingCache returns a listing cache, caching is disabled if ttl or size is 0
+func newListingCache(ttl time.Duration, size int) *listingCache {
+	return &listingCache{
+		ttl:  ttl,
+		size: size,
+		data: make(map[listingKey]*listing),
+	}
+}
+
+// get returns the cached listing for k if it was made from bucketHash less than ttl before now
+func (c *listingCache) get(k listingKey, bucketHash string, now time.Time) (*listing, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.data[k]
+	if ok && (l.bucketHash != bucketHash || now.Sub(l.created) >= c.ttl) {
+		ok = false
+	}
+	if ok {
+		atomic.AddInt64(&c.hits, 1)
+	} else {
+		atomic.AddInt64(&c.misses, 1)
+	}
+	return l, ok
+}
+
+// stats returns the number of cache hits and misses
+func (c *listingCache) stats() (hits, misses int64) {
+	return atomic.LoadInt64(&c.hits), atomic.LoadInt64(&c.misses)
+}
+
+func (c *listingCache) add(k listingKey, l *listing) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size <= 0 || c.ttl <= 0 {
+		return
+	}
+	if _, ok := c.data[k]; !ok {
+		if len(c.order) >= c.size {
+			delete(c.data, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, k)
+	}
+	c.data[k] = l
+}
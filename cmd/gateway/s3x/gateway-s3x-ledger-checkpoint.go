@@ -0,0 +1,68 @@
+package s3x
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/ipfs/go-datastore"
+)
+
+var dsCheckpointKey = datastore.NewKey("r") //bucket name and upload token to the checkpoints of an interrupted put
+
+// uploadCheckpoint is a segment of object data added to ipfs by a put with an upload token
+type uploadCheckpoint struct {
+	Hash string `json:"hash"` //the cid of the segment file
+	Size uint64 `json:"size"` //the size of the segment data
+	Sum  []byte `json:"sum"`  //the sha256 of the segment data, to match it to the data of a retry
+}
+
+// checkpointKey returns the datastore key of the checkpoints of an upload token,
+// the token is encoded as it may contain characters that are not valid in keys.
+func checkpointKey(bucket, token string) datastore.Key {
+	return dsCheckpointKey.ChildString(bucket).ChildString(base64.RawURLEncoding.EncodeToString([]byte(token)))
+}
+
+// GetUploadCheckpoints returns the checkpoints of an upload token in order, or nil if there are none.
+func (ls *ledgerStore) GetUploadCheckpoints(bucket, token string) ([]uploadCheckpoint, error) {
+	defer ls.locker.read(bucket)()
+	data, err := ls.ds.Get(checkpointKey(bucket, token))
+	if err == datastore.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var checkpoints []uploadCheckpoint
+	if err := json.Unmarshal(data, &checkpoints); err != nil {
+		return nil, err
+	}
+	return checkpoints, nil
+}
+
+// PutUploadCheckpoints saves the checkpoints of an upload token, replacing any saved before.
+func (ls *ledgerStore) PutUploadCheckpoints(bucket, token string, checkpoints []uploadCheckpoint) error {
+	defer ls.locker.write(bucket)()
+	if err := ls.assertBucketExits(bucket); err != nil {
+		return err
+	}
+	data, err := json.Marshal(checkpoints)
+	if err != nil {
+		return err
+	}
+	return ls.ds.Put(checkpointKey(bucket, token), data)
+}
+
+// DeleteUploadCheckpoints removes the checkpoints of an upload token, it's not an error if there are none.
+func (ls *ledgerStore) DeleteUploadCheckpoints(bucket, token string) error {
+	defer ls.locker.write(bucket)()
+	err := ls.ds.Delete(checkpointKey(bucket, token))
+	if err == datastore.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// deleteUploadCheckpoints removes the checkpoints of all upload tokens of a bucket in w
+func (ls *ledgerStore) deleteUploadCheckpoints(w *writeBatch, bucket string) error {
+	return ls.deleteKeysBatch(w, dsCheckpointKey.ChildString(bucket))
+}
@@ -0,0 +1,116 @@
+package s3x
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+/* Checksum Notes
+-----------------
+
+Every value written to the ledger datastore is sealed as checksumMagic + value + crc32c(value),
+and verified when read back, so a corrupted entry is reported as LedgerCorruption instead of
+being used. Values written before checksums were added do not start with checksumMagic,
+they are returned as is to stay readable.
+*/
+
+// checksumMagic marks a sealed value, no unsealed ledger value starts with a zero byte
+var checksumMagic = []byte{0, 's', '3', 'x'}
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// LedgerCorruption is returned when the checksum of a ledger entry does not match its value
+type LedgerCorruption struct {
+	Key string
+}
+
+func (e LedgerCorruption) Error() string {
+	return fmt.Sprintf("ledger entry %v is corrupted", e.Key)
+}
+
+// sealEntry returns the value with its checksum
+func sealEntry(value []byte) []byte {
+	sealed := make([]byte, 0, len(checksumMagic)+len(value)+crc32.Size)
+	sealed = append(sealed, checksumMagic...)
+	sealed = append(sealed, value...)
+	sum := make([]byte, crc32.Size)
+	binary.BigEndian.PutUint32(sum, crc32.Checksum(value, crc32c))
+	return append(sealed, sum...)
+}
+
+// openEntry verifies the checksum of a sealed value and returns the value
+func openEntry(key datastore.Key, sealed []byte) ([]byte, error) {
+	if !bytes.HasPrefix(sealed, checksumMagic) {
+		return sealed, nil
+	}
+	if len(sealed) < len(checksumMagic)+crc32.Size {
+		return nil, LedgerCorruption{Key: key.String()}
+	}
+	value := sealed[len(checksumMagic) : len(sealed)-crc32.Size]
+	if crc32.Checksum(value, crc32c) != binary.BigEndian.Uint32(sealed[len(sealed)-crc32.Size:]) {
+		return nil, LedgerCorruption{Key: key.String()}
+	}
+	return value, nil
+}
+
+// checksumDatastore seals all values written to a datastore and verifies them on read
+type checksumDatastore struct {
+	datastore.Batching
+}
+
+func (d *checksumDatastore) Put(key datastore.Key, value []byte) error {
+	return d.Batching.Put(key, sealEntry(value))
+}
+
+func (d *checksumDatastore) Get(key datastore.Key) ([]byte, error) {
+	sealed, err := d.Batching.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return openEntry(key, sealed)
+}
+
+func (d *checksumDatastore) GetSize(key datastore.Key) (int, error) {
+	value, err := d.Get(key)
+	return len(value), err
+}
+
+func (d *checksumDatastore) Query(q query.Query) (query.Results, error) {
+	rs, err := d.Batching.Query(q)
+	if err != nil || q.KeysOnly {
+		return rs, err
+	}
+	return query.ResultsFromIterator(q, query.Iterator{
+		Next: func() (query.Result, bool) {
+			r, ok := rs.NextSync()
+			if !ok || r.Error != nil {
+				return r, ok
+			}
+			r.Value, r.Error = openEntry(datastore.RawKey(r.Key), r.Value)
+			return r, true
+		},
+		Close: rs.Close,
+	}), nil
+}
+
+func (d *checksumDatastore) Batch() (datastore.Batch, error) {
+	b, err := d.Batching.Batch()
+	if err != nil {
+		return nil, err
+	}
+	return &checksumBatch{b}, nil
+}
+
+// checksumBatch seals all values written to a batch
+type checksumBatch struct {
+	datastore.Batch
+}
+
+func (b *checksumBatch) Put(key datastore.Key, value []byte) error {
+	return b.Batch.Put(key, sealEntry(value))
+}
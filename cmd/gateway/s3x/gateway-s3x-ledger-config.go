@@ -0,0 +1,140 @@
+package s3x
+
+import (
+	"strconv"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+/* Design Notes
+---------------
+
+Internal functions should never claim or release locks.
+Any claiming or releasing of locks should be done in the public setter+getter functions.
+The reason for this is so that we can enable easy reuse of internal code.
+*/
+
+var dsConfigKey = datastore.NewKey("c") //bucket name and config name to bucket config data
+
+const (
+	// bucketConfigContentType holds the default content type of objects in a bucket
+	bucketConfigContentType = "content-type"
+	// bucketConfigPolicy holds the json encoded bucket policy
+	bucketConfigPolicy = "policy"
+	// bucketConfigRateLimit holds the maximum number of requests per second to a bucket
+	bucketConfigRateLimit = "rate-limit"
+	// bucketConfigTagging holds the json encoded map of bucket tag keys to values
+	bucketConfigTagging = "tagging"
+	// bucketConfigLifecycle holds the xml encoded bucket lifecycle
+	bucketConfigLifecycle = "lifecycle"
+	// bucketConfigObjectLimit holds the maximum number of objects in a bucket
+	bucketConfigObjectLimit = "object-limit"
+	// bucketConfigAppendOnly is set on buckets whose objects can't be overwritten
+	bucketConfigAppendOnly = "append-only"
+	// bucketConfigDelimiter holds the default delimiter of object listings of a bucket
+	bucketConfigDelimiter = "delimiter"
+	// bucketConfigSSE holds the xml encoded default encryption config of a bucket
+	bucketConfigSSE = "sse"
+	// bucketConfigAccessExpiry holds the number of days without reads after which objects expire
+	bucketConfigAccessExpiry = "access-expiry"
+	// bucketConfigIPNS is set on buckets whose root is published to ipns
+	bucketConfigIPNS = "ipns"
+	// bucketConfigVersionHistory is set on buckets that record the prior versions of their objects
+	bucketConfigVersionHistory = "version-history"
+)
+
+func bucketConfigKey(bucket, name string) datastore.Key {
+	return dsConfigKey.ChildString(bucket).ChildString(name)
+}
+
+// PutBucketConfig saves the named bucket config,
+// bucket configs are stored along side the bucket and removed with it.
+func (ls *ledgerStore) PutBucketConfig(bucket, name string, data []byte) error {
+	defer ls.locker.write(bucket)()
+	if err := ls.assertBucketExits(bucket); err != nil {
+		return err
+	}
+	return ls.ds.Put(bucketConfigKey(bucket, name), data)
+}
+
+// GetBucketConfig returns the named bucket config, or nil if it's not set.
+func (ls *ledgerStore) GetBucketConfig(bucket, name string) ([]byte, error) {
+	defer ls.locker.read(bucket)()
+	if err := ls.assertBucketExits(bucket); err != nil {
+		return nil, err
+	}
+	return ls.getBucketConfig(bucket, name)
+}
+
+// DeleteBucketConfig removes the named bucket config, it's not an error if it's not set.
+func (ls *ledgerStore) DeleteBucketConfig(bucket, name string) error {
+	defer ls.locker.write(bucket)()
+	if err := ls.assertBucketExits(bucket); err != nil {
+		return err
+	}
+	err := ls.ds.Delete(bucketConfigKey(bucket, name))
+	if err == datastore.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (ls *ledgerStore) getBucketConfig(bucket, name string) ([]byte, error) {
+	data, err := ls.ds.Get(bucketConfigKey(bucket, name))
+	if err == datastore.ErrNotFound {
+		return nil, nil
+	}
+	return data, err
+}
+
+// getObjectLimit returns the maximum number of objects in a bucket, or 0 if unlimited
+func (ls *ledgerStore) getObjectLimit(bucket string) (int, error) {
+	data, err := ls.getBucketConfig(bucket, bucketConfigObjectLimit)
+	if err != nil || data == nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(data))
+}
+
+// isAppendOnly returns true if the objects of a bucket can't be overwritten
+func (ls *ledgerStore) isAppendOnly(bucket string) (bool, error) {
+	data, err := ls.getBucketConfig(bucket, bucketConfigAppendOnly)
+	return data != nil, err
+}
+
+// hasVersionHistory returns true if the prior versions of the objects of a bucket are recorded
+func (ls *ledgerStore) hasVersionHistory(bucket string) (bool, error) {
+	data, err := ls.getBucketConfig(bucket, bucketConfigVersionHistory)
+	return data != nil, err
+}
+
+// deleteBucketConfigs removes all configs of a bucket in w
+func (ls *ledgerStore) deleteBucketConfigs(w *writeBatch, bucket string) error {
+	return ls.deleteKeysBatch(w, dsConfigKey.ChildString(bucket))
+}
+
+// deleteKeysBatch removes all keys under prefix in w
+func (ls *ledgerStore) deleteKeysBatch(w *writeBatch, prefix datastore.Key) error {
+	rs, err := ls.ds.Query(query.Query{
+		Prefix:   prefix.String(),
+		KeysOnly: true,
+	})
+	if err != nil {
+		return err
+	}
+	entries, err := rs.Rest()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		k := datastore.NewKey(e.Key)
+		if !prefix.IsAncestorOf(k) {
+			continue // a bucket sharing the name prefix, e.g. "bucket" and "bucket2"
+		}
+		if err := w.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package s3x
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+/* Index Notes
+--------------
+
+The metadata index maps a field and value to the names of the objects having them, an
+object is indexed by its content type and by each of its tags. The index entries of an
+object are written in the same batch as the bucket, so they change together with the object.
+
+Each indexed object also records its index entries, so they can be removed once the object
+is replaced or removed without loading the previous object from ipfs.
+*/
+
+var (
+	dsIndexKey   = datastore.NewKey("i") //bucket name, field, value and object name of indexed objects
+	dsIndexedKey = datastore.NewKey("x") //bucket name and object name to the index entries of an object
+)
+
+const (
+	// indexFieldContentType indexes objects by content type
+	indexFieldContentType = "content-type"
+	// indexFieldTagPrefix prefixes the tag key to index objects by tag value
+	indexFieldTagPrefix = "tag:"
+)
+
+// indexEntry is a field and value an object is indexed by
+type indexEntry struct {
+	Field string `json:"f"`
+	Value string `json:"v"`
+}
+
+func encodeKeyPart(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+// indexPrefix returns the datastore key prefix of the objects indexed by the field and value
+func indexPrefix(bucket, field, value string) datastore.Key {
+	return dsIndexKey.ChildString(bucket).ChildString(encodeKeyPart(field)).ChildString(encodeKeyPart(value))
+}
+
+func indexedKey(bucket, object string) datastore.Key {
+	return dsIndexedKey.ChildString(bucket).ChildString(encodeKeyPart(object))
+}
+
+// indexEntries returns the index entries of an object
+func (m *ObjectInfo) indexEntries() []indexEntry {
+	var entries []indexEntry
+	if m.GetContentType() != "" {
+		entries = append(entries, indexEntry{Field: indexFieldContentType, Value: m.GetContentType()})
+	}
+	for k, v := range m.GetUserDefined() {
+		if !strings.EqualFold(k, xhttp.AmzObjectTagging) {
+			continue
+		}
+		tags, err := url.ParseQuery(v)
+		if err != nil {
+			continue // invalid tags are rejected by the handlers
+		}
+		for key, values := range tags {
+			for _, value := range values {
+				entries = append(entries, indexEntry{Field: indexFieldTagPrefix + key, Value: value})
+			}
+		}
+	}
+	return entries
+}
+
+// indexObjectBatch replaces the index entries of an object in w with those of obj,
+// a nil obj only removes them. A disabled index is not maintained, see dropMetadataIndex.
+func (ls *ledgerStore) indexObjectBatch(w *writeBatch, bucket, object string, obj *Object) error {
+	if !ls.indexMetadata {
+		return nil
+	}
+	var old, entries []indexEntry
+	data, err := ls.ds.Get(indexedKey(bucket, object))
+	if err != nil && err != datastore.ErrNotFound {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &old); err != nil {
+			return err
+		}
+	}
+	if obj != nil {
+		entries = obj.ObjectInfo.indexEntries()
+	}
+	keep := make(map[indexEntry]bool, len(entries))
+	for _, e := range entries {
+		keep[e] = true
+	}
+	for _, e := range old {
+		if keep[e] {
+			continue
+		}
+		if err := w.Delete(indexPrefix(bucket, e.Field, e.Value).ChildString(encodeKeyPart(object))); err != nil {
+			return err
+		}
+	}
+	if len(entries) == 0 {
+		if len(old) == 0 {
+			return nil
+		}
+		return w.Delete(indexedKey(bucket, object))
+	}
+	for _, e := range entries {
+		if err := w.Put(indexPrefix(bucket, e.Field, e.Value).ChildString(encodeKeyPart(object)), nil); err != nil {
+			return err
+		}
+	}
+	data, err = json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return w.Put(indexedKey(bucket, object), data)
+}
+
+// deleteBucketIndex removes all index entries of a bucket in w
+func (ls *ledgerStore) deleteBucketIndex(w *writeBatch, bucket string) error {
+	if err := ls.deleteKeysBatch(w, dsIndexKey.ChildString(bucket)); err != nil {
+		return err
+	}
+	return ls.deleteKeysBatch(w, dsIndexedKey.ChildString(bucket))
+}
+
+// dropMetadataIndex removes the entries of all buckets, since objects changed while the index is
+// disabled are not indexed, the entries would go stale once it's enabled again
+func (ls *ledgerStore) dropMetadataIndex() error {
+	w := ls.newWriteBatch()
+	if err := ls.deleteKeysBatch(w, dsIndexKey); err != nil {
+		return err
+	}
+	if err := ls.deleteKeysBatch(w, dsIndexedKey); err != nil {
+		return err
+	}
+	return w.Commit()
+}
+
+// FindObjects returns the sorted names of the objects in the bucket indexed by the field and value
+func (ls *ledgerStore) FindObjects(bucket, field, value string) ([]string, error) {
+	defer ls.locker.read(bucket)()
+	if !ls.indexMetadata {
+		return nil, ErrMetadataIndexDisabled
+	}
+	if err := ls.assertBucketExits(bucket); err != nil {
+		return nil, err
+	}
+	prefix := indexPrefix(bucket, field, value)
+	rs, err := ls.ds.Query(query.Query{
+		Prefix:   prefix.String(),
+		KeysOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := rs.Rest()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, e := range entries {
+		k := datastore.NewKey(e.Key)
+		if !prefix.IsAncestorOf(k) {
+			continue // a value sharing the encoded prefix
+		}
+		object, err := base64.RawURLEncoding.DecodeString(k.BaseNamespace())
+		if err != nil {
+			continue
+		}
+		names = append(names, string(object))
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,52 @@
+package s3x
+
+import (
+	"context"
+	"encoding/base64"
+
+	"github.com/ipfs/go-datastore"
+)
+
+var dsLegalHoldKey = datastore.NewKey("h") //bucket name and object name of objects under legal hold
+
+// legalHoldKey returns the datastore key set while an object is under legal hold,
+// the object name is encoded as it may contain characters that are not valid in keys.
+func legalHoldKey(bucket, object string) datastore.Key {
+	return dsLegalHoldKey.ChildString(bucket).ChildString(base64.RawURLEncoding.EncodeToString([]byte(object)))
+}
+
+// isLegalHold returns true if the object is under legal hold
+func (ls *ledgerStore) isLegalHold(bucket, object string) (bool, error) {
+	return ls.ds.Has(legalHoldKey(bucket, object))
+}
+
+// SetObjectLegalHold places the object under legal hold, or releases it. The hold is kept
+// in the datastore, so placing or releasing it neither rewrites the object nor adds a version of it.
+func (ls *ledgerStore) SetObjectLegalHold(ctx context.Context, bucket, object string, on bool) error {
+	defer ls.locker.write(bucket)()
+	if _, err := ls.liveObject(ctx, bucket, object); err != nil {
+		return err
+	}
+	if !on {
+		err := ls.ds.Delete(legalHoldKey(bucket, object))
+		if err == datastore.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	return ls.ds.Put(legalHoldKey(bucket, object), []byte{1})
+}
+
+// ObjectLegalHold returns true if the object is under legal hold
+func (ls *ledgerStore) ObjectLegalHold(ctx context.Context, bucket, object string) (bool, error) {
+	defer ls.locker.read(bucket)()
+	if _, err := ls.liveObject(ctx, bucket, object); err != nil {
+		return false, err
+	}
+	return ls.isLegalHold(bucket, object)
+}
+
+// deleteLegalHolds removes the legal holds of all objects of a bucket in w
+func (ls *ledgerStore) deleteLegalHolds(w *writeBatch, bucket string) error {
+	return ls.deleteKeysBatch(w, dsLegalHoldKey.ChildString(bucket))
+}
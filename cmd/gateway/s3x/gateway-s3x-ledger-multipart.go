@@ -1,10 +1,20 @@
 package s3x
 
 import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
 	minio "github.com/RTradeLtd/s3x/cmd"
 	"github.com/ipfs/go-datastore"
+	"github.com/segmentio/ksuid"
 )
 
+// defaultMaxParts is the maximum number of parts of a multipart upload allowed by S3
+const defaultMaxParts = 10000
+
 /* Design Notes
 ---------------
 
@@ -26,26 +36,56 @@ func (ls *ledgerStore) AbortMultipartUpload(bucket, multipartID string) error {
 	return ls.DeleteMultipartID(multipartID)
 }
 
-// NewMultipartUpload is used to store the initial start of a multipart upload request
-func (ls *ledgerStore) NewMultipartUpload(multipartID string, info *ObjectInfo) error {
+// NewMultipartUpload is used to store the initial start of a multipart upload request,
+// and returns the generated id of the upload.
+func (ls *ledgerStore) NewMultipartUpload(info *ObjectInfo) (string, error) {
+	for {
+		multipartID := ksuid.New().String()
+		err := ls.NewMultipartUploadWithID(multipartID, info)
+		if err == ErrMultipartIDExists {
+			continue // practically impossible, but never reuse an id
+		}
+		return multipartID, err
+	}
+}
+
+// NewMultipartUploadWithID is like NewMultipartUpload with an id chosen by the caller,
+// ErrMultipartIDExists is returned if there is already an upload with the id.
+func (ls *ledgerStore) NewMultipartUploadWithID(multipartID string, info *ObjectInfo) error {
 	bucket := info.GetBucket()
-	err := ls.assertBucketExits(bucket)
+	err := ls.AssertBucketExits(bucket)
+	if err != nil {
+		return err
+	}
+	defer ls.plocker.write(multipartID)()
+	existing, err := ls.getMultipartNilable(multipartID)
 	if err != nil {
 		return err
 	}
+	if existing != nil {
+		return ErrMultipartIDExists
+	}
 	m := &MultipartUpload{
 		ObjectInfo:  info,
 		Id:          multipartID,
 		ObjectParts: make(map[int64]ObjectPartInfo),
 	}
-	ls.pmapLocker.Lock()
-	ls.l.MultipartUploads[multipartID] = m
-	ls.pmapLocker.Unlock()
 	data, err := m.Marshal()
 	if err != nil {
 		return err
 	}
-	return ls.ds.Put(dsPartKey.ChildString(multipartID), data)
+	// the datastore is written without holding pmapLocker, which would block every other upload
+	if err := ls.reserveBucketUpload(bucket); err != nil {
+		return err
+	}
+	if err := ls.ds.Put(dsPartKey.ChildString(multipartID), data); err != nil {
+		ls.releaseBucketUpload(bucket)
+		return err
+	}
+	ls.pmapLocker.Lock()
+	ls.l.MultipartUploads[multipartID] = m
+	ls.pmapLocker.Unlock()
+	return nil
 }
 
 // PutObjectPart is used to record an individual object part within a multipart upload
@@ -68,6 +108,9 @@ func (ls *ledgerStore) PutObjectPart(bucketName, objectName, multipartID string,
 	if m.ObjectParts == nil {
 		m.ObjectParts = make(map[int64]ObjectPartInfo)
 	}
+	if _, ok := m.ObjectParts[pn]; !ok && len(m.ObjectParts) >= ls.maxParts {
+		return ErrTooManyParts
+	}
 	m.ObjectParts[pn] = ObjectPartInfo{
 		Number:       pn,
 		Name:         objectName,
@@ -83,6 +126,50 @@ func (ls *ledgerStore) PutObjectPart(bucketName, objectName, multipartID string,
 	return ls.ds.Put(dsPartKey.ChildString(multipartID), data)
 }
 
+// AbortStaleMultipartUpload aborts a multipart upload returned by GetStaleMultipartUploads,
+// aborted is false if the upload was already completed or aborted, which can happen when
+// the ledger is shared by multiple gateways that all abort stale uploads.
+//
+// The parts of the upload that no object and no other upload in progress references are unpinned.
+// All buckets are read locked until then, so no object can be saved with one of the parts while
+// it's unpinned. The locks are only held by this gateway, not by other gateways sharing the ledger.
+func (ls *ledgerStore) AbortStaleMultipartUpload(ctx context.Context, multipartID string) (aborted bool, err error) {
+	defer ls.plocker.write(multipartID)()
+	m, err := ls.getMultipartNilable(multipartID)
+	if err != nil || m == nil {
+		return false, err
+	}
+	buckets, err := ls.GetBucketNames()
+	if err != nil {
+		return false, err
+	}
+	//lock ordering by bucket name, as CopyObject does
+	sort.Sort(sort.Reverse(sort.StringSlice(buckets)))
+	for _, bucket := range buckets {
+		defer ls.locker.read(bucket)()
+	}
+	err = ls.DeleteMultipartID(multipartID)
+	if err == ErrInvalidUploadID {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	parts := make([]string, 0, len(m.ObjectParts))
+	for _, part := range m.ObjectParts {
+		parts = append(parts, part.DataHash)
+	}
+	unpin, err := ls.unreferencedCIDs(ctx, buckets, parts)
+	if err != nil || len(unpin) == 0 {
+		return true, err
+	}
+	_, err = ls.dag.Blockstore(ctx, &pb.BlockstoreRequest{
+		RequestType: pb.BSREQTYPE_BS_DELETE,
+		Cids:        unpin,
+	})
+	return true, err
+}
+
 /////////////////////
 // GETTER FUNCTINS //
 /////////////////////
@@ -99,6 +186,50 @@ func (ls *ledgerStore) GetObjectDetails(id string) (*MultipartUpload, func(), er
 	return m, unlock, nil
 }
 
+// GetStaleMultipartUploads returns the ids of all multipart uploads initiated before the given time
+func (ls *ledgerStore) GetStaleMultipartUploads(before time.Time) ([]string, error) {
+	ids, err := ls.getMultipartIDs()
+	if err != nil {
+		return nil, err
+	}
+	var stale []string
+	for _, id := range ids {
+		if initiated, ok := multipartInitiated(id); ok && initiated.Before(before) {
+			stale = append(stale, id)
+		}
+	}
+	return stale, nil
+}
+
+// GetMultipartUploadTimes returns the names of the objects in the bucket with the prefix that
+// have multipart uploads in progress, mapped to when their latest upload was initiated
+func (ls *ledgerStore) GetMultipartUploadTimes(bucket, prefix string) (map[string]time.Time, error) {
+	ids, err := ls.getMultipartIDs()
+	if err != nil {
+		return nil, err
+	}
+	uploads := make(map[string]time.Time)
+	for _, id := range ids {
+		m, unlock, err := ls.GetObjectDetails(id)
+		if err == ErrInvalidUploadID {
+			continue // upload completed or aborted while listing
+		}
+		if err != nil {
+			return nil, err
+		}
+		info := m.GetObjectInfo()
+		unlock()
+		if info.GetBucket() != bucket || !strings.HasPrefix(info.GetName(), prefix) {
+			continue
+		}
+		initiated, _ := multipartInitiated(id)
+		if t, ok := uploads[info.GetName()]; !ok || initiated.After(t) {
+			uploads[info.GetName()] = initiated
+		}
+	}
+	return uploads, nil
+}
+
 // MultipartIDExists is used to lookup if the given multipart id exists
 func (ls *ledgerStore) MultipartIDExists(id string) error {
 	return ls.assertValidUploadID(id)
@@ -135,6 +266,16 @@ func (ls *ledgerStore) assertValidUploadID(uploadID string) error {
 	return err
 }
 
+// multipartInitiated returns when a multipart upload was initiated,
+// upload ids are ksuids which start with their creation time.
+func multipartInitiated(uploadID string) (time.Time, bool) {
+	id, err := ksuid.Parse(uploadID)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return id.Time(), true
+}
+
 func (ls *ledgerStore) getMultipartLoaded(uploadID string) (*MultipartUpload, error) {
 	m, err := ls.getMultipartNilable(uploadID)
 	if err != nil {
@@ -147,13 +288,25 @@ func (ls *ledgerStore) getMultipartLoaded(uploadID string) (*MultipartUpload, er
 }
 
 func (ls *ledgerStore) DeleteMultipartID(uploadID string) error {
+	var bucket string
+	if ls.maxBucketUploads > 0 {
+		// the bucket of the upload is needed to stop counting it
+		m, err := ls.getMultipartNilable(uploadID)
+		if err != nil {
+			return err
+		}
+		bucket = m.GetObjectInfo().GetBucket()
+	}
 	ls.pmapLocker.Lock()
-	defer ls.pmapLocker.Unlock()
 	delete(ls.l.MultipartUploads, uploadID)
+	ls.pmapLocker.Unlock()
 	err := ls.ds.Delete(dsPartKey.ChildString(uploadID))
 	if err == datastore.ErrNotFound {
 		return ErrInvalidUploadID
 	}
+	if err == nil {
+		ls.releaseBucketUpload(bucket)
+	}
 	return err
 }
 
@@ -0,0 +1,79 @@
+package s3x
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+)
+
+/* Reconcile Notes
+------------------
+
+Gateways sharing a datastore each keep their own cache of bucket entries, so a bucket
+changed by one gateway is stale in the cache of the others. With a reconcile interval,
+a cached bucket entry is checked against the bucket root hash in the datastore before it's
+used, at most once per interval, and replaced by a lazy loading entry if the hash changed.
+
+The root hash is used as the bucket epoch, instead of a separate counter, because it changes
+with every save and can not be incremented to the same value by two gateways writing at once.
+
+A strong read, see WithStrongRead, checks the cached bucket entry regardless of the interval.
+*/
+
+type strongReadKey struct{}
+
+// WithStrongRead returns a context for authoritative reads, which check the cached bucket
+// against the datastore before using it, so changes made outside of this gateway are seen.
+// Reads without it use the cache, checked at most once per reconcile interval.
+func WithStrongRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, strongReadKey{}, true)
+}
+
+// isStrongRead returns true if ctx is for authoritative reads, see WithStrongRead
+func isStrongRead(ctx context.Context) bool {
+	strong, _ := ctx.Value(strongReadKey{}).(bool)
+	return strong
+}
+
+// reconcileBucket returns b, the cached entry of the bucket, or a new lazy loading entry if
+// the bucket changed in the datastore since it was cached. A nil entry is a missing bucket.
+func (ls *ledgerStore) reconcileBucket(bucket string, b *LedgerBucketEntry) (*LedgerBucketEntry, error) {
+	now := time.Now()
+	ls.mapLocker.Lock()
+	last := ls.reconciled[bucket]
+	ls.mapLocker.Unlock()
+	if now.Sub(last) < ls.reconcileInterval {
+		return b, nil
+	}
+	return ls.refreshBucket(bucket, b, now)
+}
+
+// refreshBucket checks b, the cached entry of the bucket, against the datastore, and returns
+// it or a new lazy loading entry if the bucket changed. A nil entry is a missing bucket.
+func (ls *ledgerStore) refreshBucket(bucket string, b *LedgerBucketEntry, now time.Time) (*LedgerBucketEntry, error) {
+	bHash, err := ls.ds.Get(dsBucketKey.ChildString(bucket))
+	if err != nil && err != datastore.ErrNotFound {
+		return nil, err
+	}
+	stale := b
+	ls.mapLocker.Lock()
+	ls.reconciled[bucket] = now
+	switch {
+	case err == datastore.ErrNotFound:
+		b = nil
+	case b == nil || b.IpfsHash != string(bHash):
+		b = &LedgerBucketEntry{
+			IpfsHash: string(bHash),
+		}
+	default:
+		ls.mapLocker.Unlock()
+		return b, nil
+	}
+	ls.l.Buckets[bucket] = b
+	ls.mapLocker.Unlock()
+	if stale != nil {
+		ls.evicted(bucket)
+	}
+	return b, nil
+}
@@ -0,0 +1,128 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+)
+
+/* Sharding Notes
+-----------------
+
+Once a bucket holds more objects than the shard threshold, its objects map is split
+into bucketShardCount shards, each saved to ipfs as a Bucket with only the objects map set.
+The root bucket saved to ipfs then has no objects, and its Data holds the shard index,
+a marshalled Bucket whose objects map shard ids to the hashes of the shards.
+
+In memory, a loaded bucket always holds all of its objects, so readers are not affected.
+On save, only the shards of changed objects are written again.
+*/
+
+// bucketShardCount is the number of shards of a sharded bucket
+const bucketShardCount = 256
+
+// defaultShardThreshold is the default number of objects above which a bucket is sharded
+const defaultShardThreshold = 10000
+
+// shardID returns the id of the shard holding the object
+func shardID(object string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(object))
+	return fmt.Sprintf("%02x", h.Sum32()%bucketShardCount)
+}
+
+// shardIndex returns a map of shard ids to shard hashes, or nil if the bucket is not sharded
+func (m *Bucket) shardIndex() (map[string]string, error) {
+	if len(m.Data) == 0 {
+		return nil, nil
+	}
+	index := &Bucket{}
+	if err := index.Unmarshal(m.Data); err != nil {
+		return nil, err
+	}
+	return index.Objects, nil
+}
+
+// ipfsSaveBucket saves a bucket with the codec and returns it's IPFS hash,
+// the objects are sharded if there are more than threshold, a threshold of 0 disables sharding.
+//
+// changed are the names of objects that were added or removed since the bucket was loaded,
+// only their shards are saved again, all shards are saved if changed is nil.
+func ipfsSaveBucket(ctx context.Context, dag pb.NodeAPIClient, b *Bucket, threshold int, changed []string, codec MetadataCodec) (string, error) {
+	if threshold <= 0 || len(b.Objects) <= threshold {
+		b.Data = nil
+		return ipfsSave(ctx, dag, b, codec)
+	}
+	index, err := b.shardIndex()
+	if err != nil {
+		return "", err
+	}
+	shards := make(map[string]*Bucket)
+	if index == nil || changed == nil {
+		index = make(map[string]string, bucketShardCount)
+		for i := 0; i < bucketShardCount; i++ {
+			shards[fmt.Sprintf("%02x", i)] = &Bucket{}
+		}
+	} else {
+		for _, object := range changed {
+			shards[shardID(object)] = &Bucket{}
+		}
+	}
+	for name, h := range b.Objects {
+		if s, ok := shards[shardID(name)]; ok {
+			if s.Objects == nil {
+				s.Objects = make(map[string]string)
+			}
+			s.Objects[name] = h
+		}
+	}
+	for id, s := range shards {
+		h, err := ipfsSave(ctx, dag, s, codec)
+		if err != nil {
+			return "", err
+		}
+		index[id] = h
+	}
+	data, err := (&Bucket{Objects: index}).Marshal()
+	if err != nil {
+		return "", err
+	}
+	b.Data = data
+	return ipfsSave(ctx, dag, &Bucket{
+		Data:       data,
+		BucketInfo: b.BucketInfo,
+	}, codec)
+}
+
+// ipfsBucketLoaded returns a bucket from IPFS using its hash, with the objects of all shards
+func ipfsBucketLoaded(ctx context.Context, dag pb.NodeAPIClient, h string) (*Bucket, error) {
+	b, err := ipfsBucket(ctx, dag, h)
+	if err != nil {
+		return nil, err
+	}
+	index, err := b.shardIndex()
+	if err != nil || index == nil {
+		return b, err
+	}
+	ids := make([]string, 0, len(index))
+	for id := range index {
+		ids = append(ids, id)
+	}
+	shards := make([]*Bucket, len(ids))
+	if err := runBounded(ctx, len(ids), 8, func(ctx context.Context, i int) error {
+		s, err := ipfsBucket(ctx, dag, index[ids[i]])
+		shards[i] = s
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	b.Objects = make(map[string]string)
+	for _, s := range shards {
+		for name, h := range s.Objects {
+			b.Objects[name] = h
+		}
+	}
+	return b, nil
+}
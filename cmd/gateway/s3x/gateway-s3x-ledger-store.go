@@ -2,11 +2,16 @@ package s3x
 
 import (
 	"context"
+	"encoding/base64"
+	"strings"
 	"sync"
+	"time"
 
 	pb "github.com/RTradeLtd/TxPB/v3/go"
+	minio "github.com/RTradeLtd/s3x/cmd"
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
 )
 
 /* Design Notes
@@ -19,8 +24,10 @@ The reason for this is so that we can enable easy reuse of internal code.
 
 var (
 	dsPrefix    = datastore.NewKey("ledgerRoot")
-	dsBucketKey = datastore.NewKey("b") //bucket name to ipfsHash of LedgerBucketEntry
-	dsPartKey   = datastore.NewKey("p") //part ID to MultipartUpload
+	dsBucketKey = datastore.NewKey("b")  //bucket name to ipfsHash of LedgerBucketEntry
+	dsPartKey   = datastore.NewKey("p")  //part ID to MultipartUpload
+	dsExpiryKey = datastore.NewKey("e")  //bucket name and object name to expiry time of objects with a ttl
+	dsNsKey     = datastore.NewKey("ns") //namespace to the keys of a namespaced ledger
 )
 
 // ledgerStore is an internal bookkeeper that
@@ -34,18 +41,56 @@ type ledgerStore struct {
 	dag pb.NodeAPIClient //to be used as direct access to ipfs to optimize algorithm
 	l   *Ledger          //a cache of the values in datastore and ipfs
 
+	objects  *objectCache  //a cache of recently used objects
+	listings *listingCache //a cache of recent listing results, disabled by default
+
 	locker     bucketLocker //a locker to protect buckets from concurrent access (per bucket)
 	plocker    bucketLocker //a locker to protect MultipartUploads from concurrent access (per upload ID)
 	mapLocker  sync.Mutex   //a lock to protect the l.Buckets map from concurrent access
 	pmapLocker sync.Mutex   //a lock to protect the l.MultipartUploads map from concurrent access
 
 	cleanup []func() error //a list of functions to call before we close the backing database.
+
+	shardThreshold int           //the number of objects above which bucket objects are sharded, disabled if 0
+	metadataCodec  MetadataCodec //the codec of saved object and bucket nodes, see MetadataCodec
+	maxParts       int           //the maximum number of distinct parts of a multipart upload
+	closeTimeout   time.Duration //the time Close waits for the datastore to shut down, disabled if 0
+
+	maxBucketUploads int            //the maximum number of multipart uploads in progress per bucket, disabled if 0
+	bucketUploads    map[string]int //the number of multipart uploads in progress per bucket, protected by pmapLocker
+
+	reconcileInterval time.Duration        //the interval between checks of cached buckets against the datastore, disabled if 0
+	reconciled        map[string]time.Time //the last check of each bucket, protected by mapLocker
+
+	onEvict func(bucket string)       //called when a cached bucket entry is dropped, see OnEvict
+	onSaved func(bucket, hash string) //called when a bucket is saved with a new hash, see OnBucketSaved
+
+	indexMetadata bool //maintains the metadata index of objects, see FindObjects
+	syncCommits   bool //syncs the datastore after every commit, see DurabilityFsync
+	noBatch       bool //disables batching of datastore writes, only used for benchmarks.
 }
 
-func newLedgerStore(ds datastore.Batching, dag pb.NodeAPIClient) (*ledgerStore, error) {
+// newLedgerStore returns a ledgerStore that keeps its keys under ns in ds,
+// so that multiple ledgers with different namespaces can share a datastore.
+//
+// Namespaced keys are kept apart from the keys of an unnamespaced ledger,
+// ErrInvalidNamespace is returned if ns contains a "/".
+func newLedgerStore(ds datastore.Batching, dag pb.NodeAPIClient, ns string) (*ledgerStore, error) {
+	prefix := dsPrefix
+	if ns != "" {
+		if strings.Contains(ns, "/") {
+			return nil, ErrInvalidNamespace
+		}
+		prefix = prefix.Child(dsNsKey).Child(datastore.NewKey(ns))
+	}
 	ls := &ledgerStore{
-		ds:  namespace.Wrap(ds, dsPrefix),
-		dag: dag,
+		ds:       &checksumDatastore{namespace.Wrap(ds, prefix)},
+		dag:      dag,
+		objects:  newObjectCache(defaultObjectCacheSize),
+		listings: newListingCache(0, 0),
+		maxParts: defaultMaxParts,
+
+		reconciled: make(map[string]time.Time),
 		l: &Ledger{
 			Buckets:          make(map[string]*LedgerBucketEntry),
 			MultipartUploads: make(map[string]*MultipartUpload),
@@ -75,13 +120,31 @@ func (ls *ledgerStore) object(ctx context.Context, bucket, object string) (*Obje
 	if err != nil {
 		return nil, err
 	}
-	return ipfsObject(ctx, ls.dag, h)
+	return ls.ipfsObject(ctx, h)
+}
+
+// liveObject returns the object, expired objects that are not removed yet are treated as not existing.
+func (ls *ledgerStore) liveObject(ctx context.Context, bucket, object string) (*Object, error) {
+	obj, err := ls.object(ctx, bucket, object)
+	if err != nil {
+		return nil, err
+	}
+	if obj.ObjectInfo.expired(time.Now()) {
+		return nil, ErrLedgerObjectDoesNotExist
+	}
+	return obj, nil
+}
+
+//Object returns the object stored in the ledger.
+func (ls *ledgerStore) Object(ctx context.Context, bucket, object string) (*Object, error) {
+	defer ls.locker.read(bucket)()
+	return ls.liveObject(ctx, bucket, object)
 }
 
 //ObjectInfo returns the ObjectInfo of the object.
 func (ls *ledgerStore) ObjectInfo(ctx context.Context, bucket, object string) (*ObjectInfo, error) {
 	defer ls.locker.read(bucket)()
-	obj, err := ls.object(ctx, bucket, object)
+	obj, err := ls.liveObject(ctx, bucket, object)
 	if err != nil {
 		return nil, err
 	}
@@ -90,7 +153,7 @@ func (ls *ledgerStore) ObjectInfo(ctx context.Context, bucket, object string) (*
 
 func (ls *ledgerStore) GetObjectDataHash(ctx context.Context, bucket, object string) (string, int64, error) {
 	defer ls.locker.read(bucket)()
-	obj, err := ls.object(ctx, bucket, object)
+	obj, err := ls.liveObject(ctx, bucket, object)
 	if err != nil {
 		return "", 0, err
 	}
@@ -99,7 +162,7 @@ func (ls *ledgerStore) GetObjectDataHash(ctx context.Context, bucket, object str
 
 func (ls *ledgerStore) ObjectData(ctx context.Context, bucket, object string) ([]byte, error) {
 	defer ls.locker.read(bucket)()
-	obj, err := ls.object(ctx, bucket, object)
+	obj, err := ls.liveObject(ctx, bucket, object)
 	if err != nil {
 		return nil, err
 	}
@@ -119,6 +182,23 @@ func (ls *ledgerStore) RemoveObject(ctx context.Context, bucket, object string)
 	//todo: gc on ipfs
 }
 
+// RemoveObjectIfMatch removes an object only if its current ETag, or the hash of its data,
+// matches etag. The check and the removal happen under the same lock, so a concurrent
+// write can not slip in between them.
+func (ls *ledgerStore) RemoveObjectIfMatch(ctx context.Context, bucket, object, etag string) error {
+	defer ls.locker.write(bucket)()
+	obj, err := ls.liveObject(ctx, bucket, object)
+	if err != nil {
+		return err
+	}
+	dataHash := obj.GetDataHash()
+	if etag != dataHash && minio.ToS3ETag(etag) != getObjectETagInfo(&obj.ObjectInfo, dataHash).ETag {
+		return ErrPreconditionFailed
+	}
+	_, err = ls.removeObjects(ctx, bucket, object)
+	return err
+}
+
 // RemoveObjects efficiently remove many objects, returns a list of objects that did not exist.
 func (ls *ledgerStore) RemoveObjects(ctx context.Context, bucket string, objects ...string) ([]string, error) {
 	unlock := ls.locker.write(bucket)
@@ -128,6 +208,15 @@ func (ls *ledgerStore) RemoveObjects(ctx context.Context, bucket string, objects
 }
 
 func (ls *ledgerStore) removeObjects(ctx context.Context, bucket string, objects ...string) ([]string, error) {
+	w := ls.newWriteBatch()
+	missing, err := ls.removeObjectsBatch(ctx, w, bucket, objects...)
+	if err != nil {
+		return nil, err
+	}
+	return missing, w.Commit()
+}
+
+func (ls *ledgerStore) removeObjectsBatch(ctx context.Context, w *writeBatch, bucket string, objects ...string) ([]string, error) {
 	b, err := ls.getBucketLoaded(ctx, bucket)
 	if err != nil {
 		return nil, err
@@ -137,19 +226,71 @@ func (ls *ledgerStore) removeObjects(ctx context.Context, bucket string, objects
 	}
 
 	missing := []string{}
+	removed := make([]string, 0, len(objects))
+	nb := b.Bucket.copyWithObjects()
 	for _, o := range objects {
-		_, ok := b.Bucket.Objects[o]
+		_, ok := nb.Objects[o]
 		if !ok {
 			missing = append(missing, o)
 			continue
 		}
-		delete(b.Bucket.Objects, o)
+		held, err := ls.isLegalHold(bucket, o)
+		if err != nil {
+			return nil, err
+		}
+		if held {
+			return nil, ErrObjectLegalHold
+		}
+		delete(nb.Objects, o)
+		removed = append(removed, o)
+		if err := ls.indexObjectBatch(w, bucket, o, nil); err != nil {
+			return nil, err
+		}
+		if err := ls.deleteObjectVersionsBatch(w, bucket, o); err != nil {
+			return nil, err
+		}
+		if err := ls.deleteLastAccessBatch(w, bucket, o); err != nil {
+			return nil, err
+		}
 	}
-	_, err = ls.saveBucket(ctx, bucket, b.Bucket)
+	_, err = ls.saveBucketBatch(ctx, w, bucket, nb, removed)
 	return missing, err
 	//todo: gc on ipfs
 }
 
+// RemoveObjectsIf removes the objects for which remove returns true, and returns their names.
+// remove is called while the bucket is locked, so objects that changed since they were chosen
+// for removal are checked as they are now. It must not use the public ledger methods.
+// Objects that don't exist anymore are skipped.
+func (ls *ledgerStore) RemoveObjectsIf(ctx context.Context, bucket string, objects []string, remove func(object string, info *ObjectInfo) (bool, error)) ([]string, error) {
+	defer ls.locker.write(bucket)()
+	var removed []string
+	for _, object := range objects {
+		obj, err := ls.object(ctx, bucket, object)
+		if err == ErrLedgerObjectDoesNotExist {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ok, err := remove(object, &obj.ObjectInfo)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			removed = append(removed, object)
+		}
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	w := ls.newWriteBatch()
+	if _, err := ls.removeObjectsBatch(ctx, w, bucket, removed...); err != nil {
+		return nil, err
+	}
+	return removed, w.Commit()
+}
+
 //PutObject saves an object by hash into the given bucket
 func (ls *ledgerStore) PutObject(ctx context.Context, bucket, object string, obj *Object) error {
 	defer ls.locker.write(bucket)()
@@ -158,23 +299,301 @@ func (ls *ledgerStore) PutObject(ctx context.Context, bucket, object string, obj
 
 //putObject saves an object by hash into the given bucket
 func (ls *ledgerStore) putObject(ctx context.Context, bucket, object string, obj *Object) error {
-	oHash, err := ipfsSave(ctx, ls.dag, obj)
+	return ls.putObjects(ctx, bucket, map[string]*Object{object: obj})
+}
+
+//PutObjects saves many objects into the given bucket, the bucket is only persisted once
+//and all datastore writes are committed in a single batch.
+func (ls *ledgerStore) PutObjects(ctx context.Context, bucket string, objs map[string]*Object) error {
+	defer ls.locker.write(bucket)()
+	return ls.putObjects(ctx, bucket, objs)
+}
+
+func (ls *ledgerStore) putObjects(ctx context.Context, bucket string, objs map[string]*Object) error {
+	if err := ls.checkLegalHolds(bucket, objs); err != nil {
+		return err
+	}
+	if err := ls.checkAppendOnly(ctx, bucket, objs); err != nil {
+		return err
+	}
+	return ls.saveObjects(ctx, bucket, objs)
+}
+
+// checkAppendOnly returns ErrObjectExists if any of objs would overwrite an existing object
+// in an append-only bucket, even with the same data.
+func (ls *ledgerStore) checkAppendOnly(ctx context.Context, bucket string, objs map[string]*Object) error {
+	appendOnly, err := ls.isAppendOnly(bucket)
+	if err != nil || !appendOnly {
+		return err
+	}
+	for object := range objs {
+		_, err := ls.getObjectHash(ctx, bucket, object)
+		if err == ErrLedgerObjectDoesNotExist {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		return ErrObjectExists
+	}
+	return nil
+}
+
+// checkLegalHolds returns ErrObjectLegalHold if any of objs would overwrite an object under legal hold
+func (ls *ledgerStore) checkLegalHolds(bucket string, objs map[string]*Object) error {
+	for object := range objs {
+		held, err := ls.isLegalHold(bucket, object)
+		if err != nil {
+			return err
+		}
+		if held {
+			return ErrObjectLegalHold
+		}
+	}
+	return nil
+}
+
+// saveObjects saves objects into the given bucket without checking for legal holds or append-only buckets
+func (ls *ledgerStore) saveObjects(ctx context.Context, bucket string, objs map[string]*Object) error {
+	hashes := make(map[string]string, len(objs))
+	for object, obj := range objs {
+		data, err := obj.Marshal()
+		if err != nil {
+			return err
+		}
+		oHash, err := ipfsSaveMetadata(ctx, ls.dag, data, ls.metadataCodec)
+		if err != nil {
+			return err
+		}
+		ls.objects.add(oHash, data)
+		hashes[object] = oHash
+	}
+	w := ls.newWriteBatch()
+	if err := ls.putObjectHashes(ctx, w, bucket, hashes); err != nil {
+		return err
+	}
+	for object, obj := range objs {
+		if t, ok := obj.ObjectInfo.expiry(); ok {
+			if err := w.Put(expiryKey(bucket, object), []byte(t.Format(time.RFC3339Nano))); err != nil {
+				return err
+			}
+		}
+		if err := ls.indexObjectBatch(w, bucket, object, obj); err != nil {
+			return err
+		}
+	}
+	return w.Commit()
+}
+
+// RestoreObject points an object at obj, a prior version of the object saved to ipfs as oHash
+func (ls *ledgerStore) RestoreObject(ctx context.Context, bucket, object, oHash string, obj *Object) error {
+	defer ls.locker.write(bucket)()
+	if err := ls.checkLegalHolds(bucket, map[string]*Object{object: obj}); err != nil {
+		return err
+	}
+	if err := ls.checkAppendOnly(ctx, bucket, map[string]*Object{object: obj}); err != nil {
+		return err
+	}
+	w := ls.newWriteBatch()
+	if err := ls.putObjectHashes(ctx, w, bucket, map[string]string{object: oHash}); err != nil {
+		return err
+	}
+	if t, ok := obj.ObjectInfo.expiry(); ok {
+		if err := w.Put(expiryKey(bucket, object), []byte(t.Format(time.RFC3339Nano))); err != nil {
+			return err
+		}
+	}
+	if err := ls.indexObjectBatch(w, bucket, object, obj); err != nil {
+		return err
+	}
+	return w.Commit()
+}
+
+// SetObjectStorageClass saves a new version of the object with the storage class
+func (ls *ledgerStore) SetObjectStorageClass(ctx context.Context, bucket, object, storageClass string) error {
+	defer ls.locker.write(bucket)()
+	obj, err := ls.liveObject(ctx, bucket, object)
 	if err != nil {
 		return err
 	}
-	return ls.putObjectHash(ctx, bucket, object, oHash)
+	if obj.ObjectInfo.GetStorageClass() == storageClass {
+		return nil
+	}
+	obj.ObjectInfo.StorageClass = storageClass
+	// the data is kept, so held objects can change their storage class
+	return ls.saveObjects(ctx, bucket, map[string]*Object{object: obj})
 }
 
-// putObjectHash saves an object by hash into the given bucket
-func (ls *ledgerStore) putObjectHash(ctx context.Context, bucket, object, objHash string) error {
+// putObjectHashes saves objects by hash into the given bucket
+//
+// The cached bucket is only replaced once the new bucket has been persisted,
+// so a successful commit guarantees that subsequent reads on this ledgerStore
+// observe the objects (read-your-writes), while a failed save leaves the cache untouched.
+func (ls *ledgerStore) putObjectHashes(ctx context.Context, w *writeBatch, bucket string, hashes map[string]string) error {
 	b, err := ls.getBucketLoaded(ctx, bucket)
 	if err != nil {
 		return err
 	}
-	if b.Bucket.Objects == nil {
-		b.Bucket.Objects = make(map[string]string)
+	nb := b.Bucket.copyWithObjects()
+	changed := make([]string, 0, len(hashes))
+	for object, objHash := range hashes {
+		nb.Objects[object] = objHash
+		changed = append(changed, object)
+	}
+	// overwrites are allowed in a bucket at its limit, only new objects are rejected
+	if len(nb.Objects) > len(b.Bucket.Objects) {
+		limit, err := ls.getObjectLimit(bucket)
+		if err != nil {
+			return err
+		}
+		if limit > 0 && len(nb.Objects) > limit {
+			return ErrBucketObjectLimit
+		}
+	}
+	replaced := make(map[string]string)
+	for object, objHash := range hashes {
+		if prev, ok := b.Bucket.Objects[object]; ok && prev != objHash {
+			replaced[object] = prev
+		}
+	}
+	// the history is only looked up on overwrites, new objects have no prior versions
+	if len(replaced) != 0 {
+		history, err := ls.hasVersionHistory(bucket)
+		if err != nil {
+			return err
+		}
+		if !history {
+			replaced = nil
+		}
+	}
+	for object, prev := range replaced {
+		if err := ls.addObjectVersionBatch(w, bucket, object, prev); err != nil {
+			return err
+		}
 	}
-	b.Bucket.Objects[object] = objHash
-	_, err = ls.saveBucket(ctx, bucket, b.Bucket)
+	_, err = ls.saveBucketBatch(ctx, w, bucket, nb, changed)
 	return err
 }
+
+// copyWithObjects returns a shallow copy of the bucket with its own objects map,
+// so that modifications do not affect the cached bucket until it's saved.
+func (m *Bucket) copyWithObjects() *Bucket {
+	objs := make(map[string]string, len(m.Objects)+1)
+	for k, v := range m.Objects {
+		objs[k] = v
+	}
+	return &Bucket{
+		Data:       m.Data,
+		BucketInfo: m.BucketInfo,
+		Objects:    objs,
+	}
+}
+
+// expiryKey returns the datastore key of the expiry time of an object,
+// the object name is encoded as it may contain characters that are not valid in keys.
+func expiryKey(bucket, object string) datastore.Key {
+	return dsExpiryKey.ChildString(bucket).ChildString(base64.RawURLEncoding.EncodeToString([]byte(object)))
+}
+
+// deleteExpiries removes the expiry times of all objects of a bucket in w
+func (ls *ledgerStore) deleteExpiries(w *writeBatch, bucket string) error {
+	return ls.deleteKeysBatch(w, dsExpiryKey.ChildString(bucket))
+}
+
+// getExpiry returns the expiry time of an object recorded in the datastore
+func (ls *ledgerStore) getExpiry(bucket, object string) (t time.Time, ok bool, err error) {
+	data, err := ls.ds.Get(expiryKey(bucket, object))
+	if err == datastore.ErrNotFound {
+		return t, false, nil
+	}
+	if err != nil {
+		return t, false, err
+	}
+	t, err = time.Parse(time.RFC3339Nano, string(data))
+	return t, err == nil, nil
+}
+
+// GetExpiredObjects returns a map of bucket names to names of objects which expired before now.
+//
+// Objects that were removed or replaced without a ttl may still be returned,
+// RemoveExpiredObjects checks the objects before removing them.
+func (ls *ledgerStore) GetExpiredObjects(now time.Time) (map[string][]string, error) {
+	//this only reads from the datastore, which have it's own synchronization, so no locking is needed.
+	rs, err := ls.ds.Query(query.Query{
+		Prefix: dsExpiryKey.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	expired := make(map[string][]string)
+	for r := range rs.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		t, err := time.Parse(time.RFC3339Nano, string(r.Value))
+		if err == nil && now.Before(t) {
+			continue
+		}
+		ns := datastore.NewKey(r.Key).Namespaces()
+		if len(ns) != 3 {
+			continue
+		}
+		object, err := base64.RawURLEncoding.DecodeString(ns[2])
+		if err != nil {
+			continue
+		}
+		expired[ns[1]] = append(expired[ns[1]], string(object))
+	}
+	return expired, nil
+}
+
+// RemoveExpiredObjects removes the given objects if they expired before now,
+// and returns the names of the removed objects.
+func (ls *ledgerStore) RemoveExpiredObjects(ctx context.Context, bucket string, now time.Time, objects ...string) ([]string, error) {
+	defer ls.locker.write(bucket)()
+	ex, err := ls.bucketExists(bucket)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	w := ls.newWriteBatch()
+	for _, object := range objects {
+		if ex {
+			// held objects keep their expiry, they are removed once released
+			held, err := ls.isLegalHold(bucket, object)
+			if err != nil {
+				return nil, err
+			}
+			if held {
+				continue
+			}
+			obj, err := ls.object(ctx, bucket, object)
+			if err != nil && err != ErrLedgerObjectDoesNotExist {
+				return nil, err
+			}
+			if err == nil && obj.ObjectInfo.expired(now) {
+				removed = append(removed, object)
+			}
+		}
+		// the expiry may have been updated after the objects were listed
+		t, ok, err := ls.getExpiry(bucket, object)
+		if err != nil {
+			return nil, err
+		}
+		if ok && now.Before(t) {
+			continue
+		}
+		if err := w.Delete(expiryKey(bucket, object)); err != nil {
+			return nil, err
+		}
+	}
+	if len(removed) != 0 {
+		if _, err := ls.removeObjectsBatch(ctx, w, bucket, removed...); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Commit(); err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
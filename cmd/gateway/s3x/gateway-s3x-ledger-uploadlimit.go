@@ -0,0 +1,58 @@
+package s3x
+
+// limitBucketUploads limits the number of multipart uploads in progress in each bucket.
+// The uploads in progress are counted once, then the count of a bucket is kept as
+// uploads are created, and completed or aborted.
+func (ls *ledgerStore) limitBucketUploads(limit int) error {
+	ids, err := ls.getMultipartIDs()
+	if err != nil {
+		return err
+	}
+	counts := make(map[string]int)
+	for _, id := range ids {
+		m, err := ls.getMultipartNilable(id)
+		if err != nil {
+			return err
+		}
+		if m != nil {
+			counts[m.GetObjectInfo().GetBucket()]++
+		}
+	}
+	ls.pmapLocker.Lock()
+	ls.maxBucketUploads = limit
+	ls.bucketUploads = counts
+	ls.pmapLocker.Unlock()
+	return nil
+}
+
+// reserveBucketUpload counts a new upload in the bucket, or returns ErrTooManyUploads
+// if the bucket has reached its limit. The slot is taken under pmapLocker, so the upload
+// can be saved without holding it, and must be released if saving the upload fails.
+func (ls *ledgerStore) reserveBucketUpload(bucket string) error {
+	if ls.maxBucketUploads <= 0 {
+		return nil
+	}
+	ls.pmapLocker.Lock()
+	defer ls.pmapLocker.Unlock()
+	if ls.bucketUploads[bucket] >= ls.maxBucketUploads {
+		return ErrTooManyUploads
+	}
+	ls.bucketUploads[bucket]++
+	return nil
+}
+
+// releaseBucketUpload stops counting an upload in the bucket
+func (ls *ledgerStore) releaseBucketUpload(bucket string) {
+	if ls.maxBucketUploads <= 0 {
+		return
+	}
+	ls.pmapLocker.Lock()
+	defer ls.pmapLocker.Unlock()
+	if ls.bucketUploads[bucket] == 0 {
+		return
+	}
+	ls.bucketUploads[bucket]--
+	if ls.bucketUploads[bucket] == 0 {
+		delete(ls.bucketUploads, bucket)
+	}
+}
@@ -0,0 +1,140 @@
+package s3x
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+var dsVersionKey = datastore.NewKey("v") //bucket name and object name to the ipfs hashes of prior versions of an object
+
+// maxObjectVersions is the number of prior versions recorded for an object, older versions are dropped
+const maxObjectVersions = 100
+
+// versionsKey returns the datastore key of the prior versions of an object,
+// the object name is encoded as it may contain characters that are not valid in keys.
+func versionsKey(bucket, object string) datastore.Key {
+	return dsVersionKey.ChildString(bucket).ChildString(base64.RawURLEncoding.EncodeToString([]byte(object)))
+}
+
+// getObjectVersions returns the ipfs hashes of the prior versions of an object, newest first,
+// or nil if the object was never replaced.
+func (ls *ledgerStore) getObjectVersions(bucket, object string) ([]string, error) {
+	data, err := ls.ds.Get(versionsKey(bucket, object))
+	if err == datastore.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	if err := json.Unmarshal(data, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// addObjectVersionBatch records oHash as the newest prior version of an object in w
+func (ls *ledgerStore) addObjectVersionBatch(w *writeBatch, bucket, object, oHash string) error {
+	versions, err := ls.getObjectVersions(bucket, object)
+	if err != nil {
+		return err
+	}
+	versions = append([]string{oHash}, versions...)
+	if len(versions) > maxObjectVersions {
+		versions = versions[:maxObjectVersions]
+	}
+	data, err := json.Marshal(versions)
+	if err != nil {
+		return err
+	}
+	return w.Put(versionsKey(bucket, object), data)
+}
+
+// deleteObjectVersionsBatch removes the prior versions of an object in w, so a new object
+// with the same name does not inherit them
+func (ls *ledgerStore) deleteObjectVersionsBatch(w *writeBatch, bucket, object string) error {
+	return w.Delete(versionsKey(bucket, object))
+}
+
+// deleteObjectVersions removes the prior versions of all objects of a bucket in w
+func (ls *ledgerStore) deleteObjectVersions(w *writeBatch, bucket string) error {
+	return ls.deleteKeysBatch(w, dsVersionKey.ChildString(bucket))
+}
+
+// purgeObjectVersionsBatch drops the prior versions of the objects of a bucket whose proto, data
+// or one of its parts is the ipfs hash cid in w, so they can't be listed or restored once cid is deleted from ipfs.
+// The versions of the skipped objects are left alone, as they are removed with the objects.
+func (ls *ledgerStore) purgeObjectVersionsBatch(ctx context.Context, w *writeBatch, bucket, cid string, skip []string) error {
+	skipped := make(map[datastore.Key]bool, len(skip))
+	for _, object := range skip {
+		skipped[versionsKey(bucket, object)] = true
+	}
+	prefix := dsVersionKey.ChildString(bucket)
+	rs, err := ls.ds.Query(query.Query{Prefix: prefix.String()})
+	if err != nil {
+		return err
+	}
+	entries, err := rs.Rest()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		k := datastore.NewKey(e.Key)
+		if !prefix.IsAncestorOf(k) || skipped[k] {
+			continue // a bucket sharing the name prefix, or an object being removed
+		}
+		var versions []string
+		if err := json.Unmarshal(e.Value, &versions); err != nil {
+			return err
+		}
+		kept := make([]string, 0, len(versions))
+		for _, h := range versions {
+			ok, err := ls.referencesCID(ctx, h, cid)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				kept = append(kept, h)
+			}
+		}
+		switch {
+		case len(kept) == len(versions):
+			continue
+		case len(kept) == 0:
+			err = w.Delete(k)
+		default:
+			var data []byte
+			if data, err = json.Marshal(kept); err == nil {
+				err = w.Put(k, data)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ObjectVersionHashes returns the ipfs hash and the object of the current version of an object,
+// and the ipfs hashes of its prior versions, newest first.
+func (ls *ledgerStore) ObjectVersionHashes(ctx context.Context, bucket, object string) (string, *Object, []string, error) {
+	defer ls.locker.read(bucket)()
+	h, err := ls.getObjectHash(ctx, bucket, object)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	obj, err := ls.ipfsObject(ctx, h)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if obj.ObjectInfo.expired(time.Now()) {
+		return "", nil, nil, ErrLedgerObjectDoesNotExist
+	}
+	versions, err := ls.getObjectVersions(bucket, object)
+	return h, obj, versions, err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/query"
@@ -18,13 +19,28 @@ Any claiming or releasing of locks should be done in the public setter+getter fu
 The reason for this is so that we can enable easy reuse of internal code.
 */
 
-// Close shuts down the ledger datastore
+// Close shuts down the ledger datastore, if it doesn't shut down within closeTimeout,
+// ErrLedgerCloseTimeout is returned and the datastore is left to close in the background.
 func (ls *ledgerStore) Close() error {
-	var err error
-	for _, f := range ls.cleanup {
-		err = multierr.Append(err, f())
+	done := make(chan error, 1)
+	go func() {
+		var err error
+		for _, f := range ls.cleanup {
+			err = multierr.Append(err, f())
+		}
+		done <- multierr.Append(err, ls.ds.Close())
+	}()
+	if ls.closeTimeout <= 0 {
+		return <-done
+	}
+	timer := time.NewTimer(ls.closeTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return ErrLedgerCloseTimeout
 	}
-	return multierr.Append(err, ls.ds.Close())
 }
 
 /////////////////////
@@ -33,31 +49,187 @@ func (ls *ledgerStore) Close() error {
 
 // GetObjectInfos returns a list of ordered ObjectInfos with given prefix ordered by name
 func (ls *ledgerStore) GetObjectInfos(ctx context.Context, bucket, prefix, startsFrom string, max int) ([]ObjectInfo, error) {
+	objs, _, err := ls.GetObjectsDelimited(ctx, bucket, prefix, startsFrom, "", max)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]ObjectInfo, 0, len(objs))
+	for _, obj := range objs {
+		infos = append(infos, obj.GetObjectInfo())
+	}
+	return infos, nil
+}
+
+// GetObjectsDelimited returns the objects with the given prefix ordered by name, but objects with
+// the delimiter in their name after the prefix are grouped into common prefixes, which are returned
+// ordered instead of the objects. Grouped objects are never loaded, so listing the top level of a
+// deep hierarchy stays cheap. Max limits the number of objects and common prefixes together.
+//
+// Results may come from the listing cache, so they must not be modified.
+func (ls *ledgerStore) GetObjectsDelimited(ctx context.Context, bucket, prefix, startsFrom, delimiter string, max int) ([]*Object, []string, error) {
 	defer ls.locker.read(bucket)()
 	b, err := ls.getBucketLoaded(ctx, bucket)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	key := listingKey{bucket: bucket, prefix: prefix, startsFrom: startsFrom, delimiter: delimiter, max: max}
+	now := time.Now()
+	if l, ok := ls.listings.get(key, b.IpfsHash, now); ok {
+		return l.objects, l.prefixes, nil
 	}
-	var names []string
-	objs := b.GetBucket().GetObjects()
-	for name := range objs {
-		if strings.HasPrefix(name, prefix) && strings.Compare(startsFrom, name) <= 0 {
-			names = append(names, name)
+	// the entries are sorted, so the names and prefixes are sorted as well
+	var names, prefixes []string
+	for _, e := range sortedObjectEntries(b.GetBucket().GetObjects(), prefix) {
+		if strings.Compare(startsFrom, e.Name) > 0 {
+			continue
+		}
+		if p, ok := commonPrefix(e.Name, prefix, delimiter); ok {
+			if len(prefixes) == 0 || prefixes[len(prefixes)-1] != p {
+				prefixes = append(prefixes, p)
+			}
+			continue
 		}
+		names = append(names, e.Name)
 	}
-	sort.Strings(names)
-	if max > 0 && len(names) > max {
-		names = names[:max]
+	if max > 0 && len(names)+len(prefixes) > max {
+		names, prefixes = truncateSorted(names, prefixes, max)
 	}
-	list := make([]ObjectInfo, 0, len(names))
+	list := make([]*Object, 0, len(names))
 	for _, name := range names {
 		obj, err := ls.object(ctx, bucket, name)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		if obj.ObjectInfo.expired(now) {
+			continue
+		}
+		list = append(list, obj)
+	}
+	ls.listings.add(key, &listing{
+		bucketHash: b.IpfsHash,
+		created:    now,
+		objects:    list,
+		prefixes:   prefixes,
+	})
+	return list, prefixes, nil
+}
+
+// Walk calls fn with the info of every object with the prefix, ordered by name, until fn returns
+// an error or ctx is done, which is then returned. Objects are loaded one at a time, so memory
+// is bounded by the names of matching objects, the rest of the bucket is never loaded.
+//
+// The bucket is only locked to find the matching objects, so fn may change the bucket, which is
+// not reflected in the walk.
+func (ls *ledgerStore) Walk(ctx context.Context, bucket, prefix string, fn func(ObjectInfo) error) error {
+	return ls.WalkDelimited(ctx, bucket, prefix, "", func(name string, obj *Object) error {
+		return fn(obj.GetObjectInfo())
+	})
+}
+
+// WalkDelimited is like Walk, but fn is called with the whole object, and objects with the delimiter
+// in their name after the prefix are grouped into common prefixes like in GetObjectsDelimited.
+// Common prefixes are passed in order as the name with a nil object, the grouped objects are never loaded.
+func (ls *ledgerStore) WalkDelimited(ctx context.Context, bucket, prefix, delimiter string, fn func(name string, obj *Object) error) error {
+	return ls.WalkEntries(ctx, bucket, prefix, delimiter, func(e ObjectEntry, obj *Object) error {
+		return fn(e.Name, obj)
+	})
+}
+
+// WalkEntries is like WalkDelimited, but fn is called with the entry of the object holding its ipfs hash,
+// common prefixes are passed as an entry with only the name set.
+func (ls *ledgerStore) WalkEntries(ctx context.Context, bucket, prefix, delimiter string, fn func(e ObjectEntry, obj *Object) error) error {
+	entries, err := ls.getObjectsSorted(ctx, bucket, prefix)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var last string
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p, ok := commonPrefix(e.Name, prefix, delimiter); ok {
+			if p == last {
+				continue
+			}
+			last = p
+			if err := fn(ObjectEntry{Name: p}, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		obj, err := ls.ipfsObject(ctx, e.Hash)
+		if err != nil {
+			return err
+		}
+		if obj.ObjectInfo.expired(now) {
+			continue
+		}
+		if err := fn(e, obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commonPrefix returns the common prefix grouping name, which is name up to and including the first
+// delimiter after the prefix, or false if there is none.
+func commonPrefix(name, prefix, delimiter string) (string, bool) {
+	if delimiter == "" {
+		return "", false
+	}
+	i := strings.Index(name[len(prefix):], delimiter)
+	if i < 0 {
+		return "", false
+	}
+	return name[:len(prefix)+i+len(delimiter)], true
+}
+
+// ObjectEntry is the name and hash of an object in a bucket
+type ObjectEntry struct {
+	Name string
+	Hash string
+}
+
+// GetObjectsSorted returns the names and hashes of all objects in a bucket ordered by name
+func (ls *ledgerStore) GetObjectsSorted(ctx context.Context, bucket string) ([]ObjectEntry, error) {
+	return ls.getObjectsSorted(ctx, bucket, "")
+}
+
+// getObjectsSorted returns the entries of the objects with the prefix ordered by name
+func (ls *ledgerStore) getObjectsSorted(ctx context.Context, bucket, prefix string) ([]ObjectEntry, error) {
+	defer ls.locker.read(bucket)()
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	return sortedObjectEntries(b.GetBucket().GetObjects(), prefix), nil
+}
+
+// sortedObjectEntries returns the entries of the objects map with the prefix ordered by name
+func sortedObjectEntries(objects map[string]string, prefix string) []ObjectEntry {
+	var entries []ObjectEntry
+	for name, h := range objects {
+		if strings.HasPrefix(name, prefix) {
+			entries = append(entries, ObjectEntry{Name: name, Hash: h})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// truncateSorted returns the first max entries of the merged sorted lists a and b,
+// split back into their lists
+func truncateSorted(a, b []string, max int) ([]string, []string) {
+	var i, j int
+	for i+j < max && (i < len(a) || j < len(b)) {
+		if j == len(b) || (i < len(a) && a[i] < b[j]) {
+			i++
+		} else {
+			j++
 		}
-		list = append(list, obj.GetObjectInfo())
 	}
-	return list, nil
+	return a[:i], b[:j]
 }
 
 // GetObjectHash is used to retrieve the corresponding IPFS CID for an object
@@ -102,3 +274,372 @@ func (ls *ledgerStore) GetBucketNames() ([]string, error) {
 	}
 	return names, nil
 }
+
+// ListBucketNames returns up to limit bucket names after marker in name order, and whether
+// there are more names after them. All names after marker are returned if limit is 0.
+//
+// Pages are consistent as they only depend on the marker, a bucket created or deleted
+// between pages is only listed if it's after the marker of the next page.
+func (ls *ledgerStore) ListBucketNames(marker string, limit int) ([]string, bool, error) {
+	names, err := ls.GetBucketNames()
+	if err != nil {
+		return nil, false, err
+	}
+	sort.Strings(names)
+	names = names[sort.SearchStrings(names, marker):]
+	if len(names) > 0 && names[0] == marker {
+		names = names[1:]
+	}
+	if limit > 0 && len(names) > limit {
+		return names[:limit], true, nil
+	}
+	return names, false, nil
+}
+
+// ListReferencedCIDs returns every distinct ipfs hash referenced by the ledger,
+// this includes bucket roots, object protos, object data and multipart upload parts.
+//
+// Data of objects stored inline is not on ipfs, so it is not included.
+func (ls *ledgerStore) ListReferencedCIDs(ctx context.Context) ([]string, error) {
+	seen := make(map[string]struct{})
+	var hashes []string
+	add := func(h string) {
+		if h == "" {
+			return
+		}
+		if _, ok := seen[h]; ok {
+			return
+		}
+		seen[h] = struct{}{}
+		hashes = append(hashes, h)
+	}
+	buckets, err := ls.GetBucketNames()
+	if err != nil {
+		return nil, err
+	}
+	for _, bucket := range buckets {
+		if err := ls.referencedBucketCIDs(ctx, bucket, add); err != nil {
+			return nil, err
+		}
+	}
+	ids, err := ls.getMultipartIDs()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		m, unlock, err := ls.GetObjectDetails(id)
+		if err == ErrInvalidUploadID {
+			continue // upload completed or aborted while listing
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range m.GetObjectParts() {
+			add(part.GetDataHash())
+		}
+		unlock()
+	}
+	return hashes, nil
+}
+
+// referencedBucketCIDs calls add with the hashes referenced by a bucket
+func (ls *ledgerStore) referencedBucketCIDs(ctx context.Context, bucket string, add func(string)) error {
+	defer ls.locker.read(bucket)()
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err == ErrLedgerBucketDoesNotExist {
+		return nil // bucket deleted while listing
+	}
+	if err != nil {
+		return err
+	}
+	add(b.IpfsHash)
+	index, err := b.Bucket.shardIndex()
+	if err != nil {
+		return err
+	}
+	for _, h := range index {
+		add(h)
+	}
+	return ls.forEachObject(ctx, b, func(h string, obj *Object) {
+		add(h)
+		if _, inline := obj.ObjectInfo.GetUserDefined()[s3xMetaInlineData]; !inline {
+			add(obj.GetDataHash())
+		}
+	})
+}
+
+// forEachObject calls fn with the hash and content of every object in a loaded bucket
+func (ls *ledgerStore) forEachObject(ctx context.Context, b *LedgerBucketEntry, fn func(h string, obj *Object)) error {
+	for _, h := range b.GetBucket().GetObjects() {
+		obj, err := ls.ipfsObject(ctx, h)
+		if err != nil {
+			return err
+		}
+		fn(h, obj)
+	}
+	return nil
+}
+
+// DedupStats returns the sum of the sizes of all objects as logicalBytes, and the size of
+// the data actually stored as physicalBytes, where objects with the same data hash are only
+// counted once. The difference is the storage saved by deduplication.
+//
+// Data of objects stored inline is part of each object, so it is never deduplicated.
+func (ls *ledgerStore) DedupStats(ctx context.Context) (logicalBytes, physicalBytes int64, err error) {
+	buckets, err := ls.GetBucketNames()
+	if err != nil {
+		return 0, 0, err
+	}
+	seen := make(map[string]struct{})
+	for _, bucket := range buckets {
+		err := func() error {
+			defer ls.locker.read(bucket)()
+			b, err := ls.getBucketLoaded(ctx, bucket)
+			if err == ErrLedgerBucketDoesNotExist {
+				return nil // bucket deleted while listing
+			}
+			if err != nil {
+				return err
+			}
+			return ls.forEachObject(ctx, b, func(h string, obj *Object) {
+				logicalBytes += obj.ObjectInfo.GetSize_()
+				if _, inline := obj.ObjectInfo.GetUserDefined()[s3xMetaInlineData]; !inline {
+					if _, ok := seen[obj.GetDataHash()]; ok {
+						return
+					}
+					seen[obj.GetDataHash()] = struct{}{}
+				}
+				physicalBytes += obj.ObjectInfo.storedSize()
+			})
+		}()
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return logicalBytes, physicalBytes, nil
+}
+
+// ObjectRef identifies an object in a bucket
+type ObjectRef struct {
+	Bucket string
+	Object string
+}
+
+// ObjectsForCID returns every object whose proto, data or one of its parts is the ipfs hash cid, sorted by
+// bucket and object name, to find the objects affected by unpinning the hash.
+//
+// There is no index of hashes to objects, so every object of every bucket is read.
+func (ls *ledgerStore) ObjectsForCID(ctx context.Context, cid string) ([]ObjectRef, error) {
+	buckets, err := ls.GetBucketNames()
+	if err != nil {
+		return nil, err
+	}
+	refs := []ObjectRef{}
+	for _, bucket := range buckets {
+		objects, err := func() ([]string, error) {
+			defer ls.locker.read(bucket)()
+			return ls.bucketObjectsForCID(ctx, bucket, cid)
+		}()
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range objects {
+			refs = append(refs, ObjectRef{Bucket: bucket, Object: object})
+		}
+	}
+	sortObjectRefs(refs)
+	return refs, nil
+}
+
+// RemoveObjectsForCID removes every object whose proto, data or one of its parts is the ipfs hash cid,
+// and returns the removed objects sorted by bucket and object name, with the sorted data hashes
+// of the removed objects, so they can be unpinned. Prior versions that reference cid are dropped
+// from the version history of the objects that are kept.
+//
+// Nothing is removed and ErrObjectLegalHold is returned if any of the objects is under legal hold.
+// All buckets are locked until the removals are committed together, so that a hold placed while
+// the buckets are read can't leave the purge half done.
+func (ls *ledgerStore) RemoveObjectsForCID(ctx context.Context, cid string) ([]ObjectRef, []string, error) {
+	buckets, err := ls.GetBucketNames()
+	if err != nil {
+		return nil, nil, err
+	}
+	//lock ordering by bucket name, as CopyObject does
+	sort.Sort(sort.Reverse(sort.StringSlice(buckets)))
+	for _, bucket := range buckets {
+		defer ls.locker.write(bucket)()
+	}
+	refs := []ObjectRef{}
+	hashes := map[string]bool{}
+	found := make(map[string][]string, len(buckets))
+	for _, bucket := range buckets {
+		objects, err := ls.bucketObjectsForCID(ctx, bucket, cid)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, object := range objects {
+			held, err := ls.isLegalHold(bucket, object)
+			if err != nil {
+				return nil, nil, err
+			}
+			if held {
+				return nil, nil, ErrObjectLegalHold
+			}
+			obj, err := ls.object(ctx, bucket, object)
+			if err != nil {
+				return nil, nil, err
+			}
+			if _, inline := obj.ObjectInfo.GetUserDefined()[s3xMetaInlineData]; !inline {
+				hashes[obj.GetDataHash()] = true
+			}
+			refs = append(refs, ObjectRef{Bucket: bucket, Object: object})
+		}
+		found[bucket] = objects
+	}
+	w := ls.newWriteBatch()
+	for _, bucket := range buckets {
+		objects := found[bucket]
+		if err := ls.purgeObjectVersionsBatch(ctx, w, bucket, cid, objects); err != nil {
+			return nil, nil, err
+		}
+		if len(objects) == 0 {
+			continue
+		}
+		if _, err := ls.removeObjectsBatch(ctx, w, bucket, objects...); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := w.Commit(); err != nil {
+		return nil, nil, err
+	}
+	sortObjectRefs(refs)
+	dataHashes := make([]string, 0, len(hashes))
+	for h := range hashes {
+		dataHashes = append(dataHashes, h)
+	}
+	sort.Strings(dataHashes)
+	return refs, dataHashes, nil
+}
+
+// bucketObjectsForCID returns the objects of a bucket whose proto, data or one of its parts is the ipfs hash cid,
+// no objects are returned if the bucket was deleted.
+func (ls *ledgerStore) bucketObjectsForCID(ctx context.Context, bucket, cid string) ([]string, error) {
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err == ErrLedgerBucketDoesNotExist {
+		return nil, nil // bucket deleted while listing
+	}
+	if err != nil {
+		return nil, err
+	}
+	var objects []string
+	for object, h := range b.GetBucket().GetObjects() {
+		ok, err := ls.referencesCID(ctx, h, cid)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			objects = append(objects, object)
+		}
+	}
+	return objects, nil
+}
+
+// sortObjectRefs sorts refs by bucket and object name
+func sortObjectRefs(refs []ObjectRef) {
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Bucket != refs[j].Bucket {
+			return refs[i].Bucket < refs[j].Bucket
+		}
+		return refs[i].Object < refs[j].Object
+	})
+}
+
+// referencesCID returns true if the object saved to ipfs as h has cid as its proto or data hash,
+// or as the data hash of one of the parts it was completed from
+func (ls *ledgerStore) referencesCID(ctx context.Context, h, cid string) (bool, error) {
+	if h == cid {
+		return true, nil
+	}
+	obj, err := ls.ipfsObject(ctx, h)
+	if err != nil {
+		return false, err
+	}
+	if _, inline := obj.ObjectInfo.GetUserDefined()[s3xMetaInlineData]; inline {
+		return false, nil
+	}
+	if obj.GetDataHash() == cid {
+		return true, nil
+	}
+	for _, part := range obj.ObjectInfo.Parts {
+		if part.DataHash == cid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// unreferencedCIDs returns the sorted cids that are neither the data of an object of the buckets or
+// of one of its parts, nor a part of a multipart upload in progress. The buckets must be locked.
+func (ls *ledgerStore) unreferencedCIDs(ctx context.Context, buckets, cids []string) ([]string, error) {
+	left := make(map[string]bool, len(cids))
+	for _, cid := range cids {
+		left[cid] = true
+	}
+	ids, err := ls.getMultipartIDs()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		m, err := ls.getMultipartNilable(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range m.GetObjectParts() {
+			delete(left, part.DataHash)
+		}
+	}
+	for _, bucket := range buckets {
+		if len(left) == 0 {
+			break
+		}
+		b, err := ls.getBucketLoaded(ctx, bucket)
+		if err == ErrLedgerBucketDoesNotExist {
+			continue // bucket deleted while listing
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range b.GetBucket().GetObjects() {
+			obj, err := ls.ipfsObject(ctx, h)
+			if err != nil {
+				return nil, err
+			}
+			delete(left, obj.GetDataHash())
+			for _, part := range obj.ObjectInfo.Parts {
+				delete(left, part.DataHash)
+			}
+		}
+	}
+	unreferenced := make([]string, 0, len(left))
+	for cid := range left {
+		unreferenced = append(unreferenced, cid)
+	}
+	sort.Strings(unreferenced)
+	return unreferenced, nil
+}
+
+// getMultipartIDs returns the ids of all multipart uploads in the datastore
+func (ls *ledgerStore) getMultipartIDs() ([]string, error) {
+	rs, err := ls.ds.Query(query.Query{
+		Prefix:   dsPartKey.String(),
+		KeysOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for r := range rs.Next() {
+		ids = append(ids, datastore.NewKey(r.Key).BaseNamespace())
+	}
+	return ids, nil
+}
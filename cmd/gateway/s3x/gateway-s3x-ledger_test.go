@@ -2,9 +2,20 @@ package s3x
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	minio "github.com/RTradeLtd/s3x/cmd"
 	"github.com/ipfs/go-datastore"
+	"google.golang.org/grpc"
 
 	dssync "github.com/ipfs/go-datastore/sync"
 )
@@ -24,7 +35,7 @@ func testS3XLedgerStore(t *testing.T, dsType DSType) {
 		}
 	}()
 
-	ledger, err := newLedgerStore(dssync.MutexWrap(datastore.NewMapDatastore()), gateway.dagClient)
+	ledger, err := newLedgerStore(dssync.MutexWrap(datastore.NewMapDatastore()), gateway.dagClient, "")
 
 	if err != nil {
 		t.Fatal(err)
@@ -92,3 +103,900 @@ func testS3XLedgerStore(t *testing.T, dsType DSType) {
 		}
 	})
 }
+
+func TestS3X_LedgerStore_ListReferencedCIDs(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	want := make(map[string]bool)
+	for _, object := range []string{testObject1, "testobject2"} {
+		// both objects have the same data, so the data hash must only be listed once
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte("hello")), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		oHash, err := gateway.ledgerStore.GetObjectHash(ctx, testBucket1, object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dHash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[oHash] = true
+		want[dHash] = true
+	}
+	bHash, err := gateway.ledgerStore.GetBucketHash(testBucket1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want[bHash] = true
+	uID, err := gateway.NewMultipartUpload(ctx, testBucket1, "multipart", minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pi, err := gateway.PutObjectPart(ctx, testBucket1, "multipart", uID, 1, getTestPutObjectReader(t, []byte("part")), minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want[pi.ETag] = true
+
+	hashes, err := gateway.ledgerStore.ListReferencedCIDs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]bool)
+	for _, h := range hashes {
+		if got[h] {
+			t.Fatalf("hash %v returned more than once", h)
+		}
+		got[h] = true
+		if !want[h] {
+			t.Fatalf("unexpected hash %v", h)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v hashes, but got %v", len(want), len(got))
+	}
+}
+
+func TestS3X_LedgerStore_PutObjects(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	objs := make(map[string]*Object)
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("object%d", i)
+		objs[name] = &Object{
+			ObjectInfo: ObjectInfo{Bucket: testBucket1, Name: name, Size_: int64(i)},
+		}
+	}
+	if err := gateway.ledgerStore.PutObjects(ctx, testBucket1, objs); err != nil {
+		t.Fatal(err)
+	}
+	check := func(t *testing.T) {
+		for name, obj := range objs {
+			info, err := gateway.ledgerStore.ObjectInfo(ctx, testBucket1, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.GetName() != name || info.GetSize_() != obj.ObjectInfo.Size_ {
+				t.Fatalf("expected object %v of size %v, but got %v of size %v", name, obj.ObjectInfo.Size_, info.GetName(), info.GetSize_())
+			}
+		}
+	}
+	t.Run("readable after commit", check)
+	gateway.restart(t)
+	t.Run("readable after restart", check)
+}
+
+func BenchmarkS3X_LedgerStore_Persist(b *testing.B) {
+	for _, batched := range []bool{false, true} {
+		b.Run(fmt.Sprintf("batched=%v", batched), func(b *testing.B) {
+			ctx := context.Background()
+			gateway := newTestGateway(b, DSTypeBadger)
+			defer func() {
+				if err := gateway.Shutdown(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}()
+			if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
+				b.Fatal(err)
+			}
+			gateway.ledgerStore.noBatch = !batched
+			objs := make(map[string]*Object, 1000)
+			for i := 0; i < 1000; i++ {
+				name := fmt.Sprintf("object%d", i)
+				objs[name] = &Object{ObjectInfo: ObjectInfo{Bucket: testBucket1, Name: name}}
+			}
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				if batched {
+					if err := gateway.ledgerStore.PutObjects(ctx, testBucket1, objs); err != nil {
+						b.Fatal(err)
+					}
+					continue
+				}
+				for name, obj := range objs {
+					if err := gateway.ledgerStore.PutObject(ctx, testBucket1, name, obj); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestS3X_LedgerStore_Sharding(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	const threshold = 5
+	gateway.temx.ShardThreshold = threshold
+	gateway.ledgerStore.shardThreshold = threshold
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
+		t.Fatal(err)
+	}
+	want := make(map[string]bool)
+	check := func(t *testing.T, sharded bool) {
+		b, err := gateway.ledgerStore.getBucketLoaded(ctx, testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if index, _ := b.Bucket.shardIndex(); (index != nil) != sharded {
+			t.Fatalf("expected sharded %v, but got shard index %v", sharded, index)
+		}
+		for name := range want {
+			if _, err := gateway.ledgerStore.GetObjectHash(ctx, testBucket1, name); err != nil {
+				t.Fatalf("failed to find %v: %v", name, err)
+			}
+		}
+		infos, err := gateway.ledgerStore.GetObjectInfos(ctx, testBucket1, "", "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(infos) != len(want) {
+			t.Fatalf("expected %v objects to be listed, but got %v", len(want), len(infos))
+		}
+		for _, info := range infos {
+			if !want[info.Name] {
+				t.Fatalf("unexpected object %v", info.Name)
+			}
+		}
+	}
+	for i := 0; i < threshold*4; i++ {
+		name := fmt.Sprintf("object%d", i)
+		if _, err := gateway.PutObject(ctx, testBucket1, name, getTestPutObjectReader(t, []byte(name)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		want[name] = true
+		if i == threshold-1 {
+			t.Run("below threshold", func(t *testing.T) { check(t, false) })
+		}
+	}
+	t.Run("above threshold", func(t *testing.T) { check(t, true) })
+	gateway.restart(t)
+	t.Run("above threshold after restart", func(t *testing.T) { check(t, true) })
+	var remove []string
+	for name := range want {
+		if len(want)-len(remove) == threshold {
+			break
+		}
+		remove = append(remove, name)
+	}
+	if _, err := gateway.ledgerStore.RemoveObjects(ctx, testBucket1, remove...); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range remove {
+		delete(want, name)
+	}
+	t.Run("back below threshold", func(t *testing.T) { check(t, false) })
+}
+
+func BenchmarkS3X_LedgerStore_ShardedPut(b *testing.B) {
+	for _, threshold := range []int{0, defaultShardThreshold} {
+		b.Run(fmt.Sprintf("threshold=%v", threshold), func(b *testing.B) {
+			ctx := context.Background()
+			gateway := newTestGateway(b, DSTypeBadger)
+			defer func() {
+				if err := gateway.Shutdown(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}()
+			gateway.ledgerStore.shardThreshold = threshold
+			if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
+				b.Fatal(err)
+			}
+			obj := &Object{ObjectInfo: ObjectInfo{Bucket: testBucket1, Name: "object"}}
+			oHash, err := ipfsSave(ctx, gateway.dagClient, obj, MetadataCodecDefault)
+			if err != nil {
+				b.Fatal(err)
+			}
+			// fill the bucket directly, adding 100k objects one by one would take too long
+			lb, err := gateway.ledgerStore.getBucketLoaded(ctx, testBucket1)
+			if err != nil {
+				b.Fatal(err)
+			}
+			nb := lb.Bucket.copyWithObjects()
+			for i := 0; i < 100000; i++ {
+				nb.Objects[fmt.Sprintf("object%d", i)] = oHash
+			}
+			if _, err := gateway.ledgerStore.saveBucket(ctx, testBucket1, nb); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				if err := gateway.ledgerStore.PutObject(ctx, testBucket1, fmt.Sprintf("new%d", n), obj); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestS3X_LedgerStore_Corruption(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	ledger, err := newLedgerStore(ds, gateway.dagClient, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ledger.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	key := dsPrefix.Child(dsBucketKey).ChildString(testBucket1)
+	data, err := ds.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(checksumMagic)] ^= 0xff
+	if err := ds.Put(key, data); err != nil {
+		t.Fatal(err)
+	}
+	// a new ledger does not have the bucket cached, so it is read from the datastore
+	ledger, err = newLedgerStore(ds, gateway.dagClient, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ledger.GetBucketInfo(ctx, testBucket1)
+	if _, ok := err.(LedgerCorruption); !ok {
+		t.Fatalf("expected LedgerCorruption, but got %v", err)
+	}
+}
+
+func TestS3X_LedgerStore_ObjectsForCID(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for _, bucket := range []string{testBucket1, testBucket2} {
+		if err := gateway.MakeBucketWithLocation(ctx, bucket, "us-east-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put := func(bucket, object, data string) {
+		if _, err := gateway.PutObject(ctx, bucket, object, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// both objects have the same data, so they reference the same data hash
+	put(testBucket2, testObject1, "shared")
+	put(testBucket1, testObject1, "shared")
+	put(testBucket1, "other", "other")
+	dHash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs, err := gateway.ledgerStore.ObjectsForCID(ctx, dHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ObjectRef{{Bucket: testBucket1, Object: testObject1}, {Bucket: testBucket2, Object: testObject1}}
+	if !reflect.DeepEqual(refs, want) {
+		t.Fatalf("expected %v, but got %v", want, refs)
+	}
+	oHash, err := gateway.ledgerStore.GetObjectHash(ctx, testBucket1, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs, err = gateway.ledgerStore.ObjectsForCID(ctx, oHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []ObjectRef{{Bucket: testBucket1, Object: "other"}}; !reflect.DeepEqual(refs, want) {
+		t.Fatalf("expected %v, but got %v", want, refs)
+	}
+	if refs, err := gateway.ledgerStore.ObjectsForCID(ctx, "not a cid"); err != nil || len(refs) != 0 {
+		t.Fatalf("expected no objects, but got %v (%v)", refs, err)
+	}
+}
+
+func TestS3X_LedgerStore_RemoveObjectsIf(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	for _, object := range []string{"remove", "keep"} {
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte(object)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	removed, err := gateway.ledgerStore.RemoveObjectsIf(ctx, testBucket1, []string{"remove", "keep", "missing"}, func(object string, info *ObjectInfo) (bool, error) {
+		return info.GetSize_() == int64(len("remove")), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"remove"}; !reflect.DeepEqual(removed, want) {
+		t.Fatalf("expected %v to be removed, but got %v", want, removed)
+	}
+	if _, err := gateway.GetObjectInfo(ctx, testBucket1, "remove", minio.ObjectOptions{}); !isObjectNotFound(err) {
+		t.Fatal("expected the object to be removed, but got", err)
+	}
+	if _, err := gateway.GetObjectInfo(ctx, testBucket1, "keep", minio.ObjectOptions{}); err != nil {
+		t.Fatal("expected the object to be kept, but got", err)
+	}
+}
+
+func TestS3X_LedgerStore_DedupStats(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	const size = 1 << 20
+	blob := make([]byte, size)
+	for i := range blob {
+		blob[i] = byte(i)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := gateway.PutObject(ctx, testBucket1, fmt.Sprintf("blob%d", i), getTestPutObjectReader(t, blob), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logical, physical, err := gateway.ledgerStore.DedupStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logical != 3*size {
+		t.Fatalf("expected %v logical bytes, but got %v", 3*size, logical)
+	}
+	if physical != size {
+		t.Fatalf("expected %v physical bytes, but got %v", size, physical)
+	}
+}
+
+func TestS3X_LedgerStore_Namespace(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	tenants := map[string]string{"tenant1": testBucket1, "tenant2": testBucket2}
+	ledgers := make(map[string]*ledgerStore)
+	for ns, bucket := range tenants {
+		ledger, err := newLedgerStore(ds, gateway.dagClient, ns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ledger.CreateBucket(ctx, bucket, &Bucket{}); err != nil {
+			t.Fatal(err)
+		}
+		ledgers[ns] = ledger
+	}
+	for ns, bucket := range tenants {
+		names, err := ledgers[ns].GetBucketNames()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(names) != 1 || names[0] != bucket {
+			t.Fatalf("expected namespace %v to only have bucket %v, but got %v", ns, bucket, names)
+		}
+	}
+	if _, err := newLedgerStore(ds, gateway.dagClient, "tenant/1"); err != ErrInvalidNamespace {
+		t.Fatalf("expected ErrInvalidNamespace, but got %v", err)
+	}
+}
+
+func TestS3X_LedgerStore_NamespaceShared(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	// a namespace named after a ledger prefix must not show up in the unnamespaced ledger
+	root, err := newLedgerStore(ds, gateway.dagClient, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenant, err := newLedgerStore(ds, gateway.dagClient, dsBucketKey.BaseNamespace())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := root.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tenant.CreateBucket(ctx, testBucket2, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	for ls, bucket := range map[*ledgerStore]string{root: testBucket1, tenant: testBucket2} {
+		names, err := ls.GetBucketNames()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(names) != 1 || names[0] != bucket {
+			t.Fatalf("expected only bucket %v, but got %v", bucket, names)
+		}
+	}
+}
+
+func TestS3X_LedgerStore_Walk(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	const prefix = "narrow/"
+	var want []string
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("%sobject%d", prefix, i)
+		if _, err := gateway.PutObject(ctx, testBucket1, name, getTestPutObjectReader(t, []byte(name)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, name)
+	}
+	// fill the bucket in memory only, saving a million objects would take too long
+	const count = 1000000
+	func() {
+		defer gateway.ledgerStore.locker.write(testBucket1)()
+		b, err := gateway.ledgerStore.getBucketLoaded(ctx, testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := b.Bucket.Objects[want[0]]
+		for i := 0; i < count; i++ {
+			b.Bucket.Objects[fmt.Sprintf("wide/%07d", i)] = h
+		}
+	}()
+	t.Run("prefix", func(t *testing.T) {
+		var got []string
+		var err error
+		allocs := testing.AllocsPerRun(5, func() {
+			got = got[:0]
+			err = gateway.ledgerStore.Walk(ctx, testBucket1, prefix, func(info ObjectInfo) error {
+				got = append(got, info.Name)
+				return nil
+			})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("expected %v, but got %v", want, got)
+		}
+		// the walk allocates for the objects under the prefix, not for every object of the bucket
+		if allocs > count/100 {
+			t.Fatalf("expected walk to allocate less than %v times, but it allocated %v times", count/100, allocs)
+		}
+	})
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var count int
+		err := gateway.ledgerStore.Walk(ctx, testBucket1, prefix, func(info ObjectInfo) error {
+			count++
+			cancel()
+			return nil
+		})
+		if err != context.Canceled {
+			t.Fatalf("expected context.Canceled, but got %v", err)
+		}
+		if count != 1 {
+			t.Fatalf("expected walk to stop after 1 object, but got %v", count)
+		}
+	})
+}
+
+func TestS3X_LedgerStore_WalkDelimited(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	if _, err := gateway.PutObject(ctx, testBucket1, "narrow/object", getTestPutObjectReader(t, []byte("data")), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// fill the bucket in memory only, saving 50k objects would take too long
+	const count = 50000
+	func() {
+		defer gateway.ledgerStore.locker.write(testBucket1)()
+		b, err := gateway.ledgerStore.getBucketLoaded(ctx, testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := b.Bucket.Objects["narrow/object"]
+		for i := 0; i < count; i++ {
+			b.Bucket.Objects[fmt.Sprintf("wide/%05d", i)] = h
+		}
+	}()
+	walk := func(ctx context.Context, prefix, delimiter string, fn func(name string)) error {
+		return gateway.ledgerStore.WalkDelimited(ctx, testBucket1, prefix, delimiter, func(name string, _ *Object) error {
+			fn(name)
+			return nil
+		})
+	}
+	t.Run("complete", func(t *testing.T) {
+		var n int
+		var last string
+		var peak uint64
+		var stats runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&stats)
+		before := stats.HeapAlloc
+		if err := walk(ctx, "wide/", "", func(name string) {
+			if name <= last {
+				t.Fatalf("expected objects ordered by name, but got %v after %v", name, last)
+			}
+			last = name
+			if n++; n%10000 == 0 {
+				runtime.GC()
+				runtime.ReadMemStats(&stats)
+				if stats.HeapAlloc > peak {
+					peak = stats.HeapAlloc
+				}
+			}
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if n != count {
+			t.Fatalf("expected %v objects, but got %v", count, n)
+		}
+		if peak > before && peak-before > 16<<20 {
+			t.Fatalf("expected walking to use less than 16MB, but it used %v bytes", peak-before)
+		}
+	})
+	t.Run("delimiter", func(t *testing.T) {
+		var got []string
+		if err := walk(ctx, "", "/", func(name string) {
+			got = append(got, name)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"narrow/", "wide/"}; fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("expected common prefixes %v, but got %v", want, got)
+		}
+	})
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var n int
+		err := walk(ctx, "wide/", "", func(string) {
+			n++
+			cancel()
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, but got %v", err)
+		}
+		if n != 1 {
+			t.Fatalf("expected walking to stop after 1 object, but got %v", n)
+		}
+	})
+}
+
+func TestS3X_LedgerStore_GetObjectsSorted(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	ls := gateway.ledgerStore
+	if _, err := ls.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	objs := make(map[string]*Object)
+	for i := 0; i < 100; i++ {
+		objs[fmt.Sprintf("object-%v", i)] = &Object{DataHash: fmt.Sprintf("data-%v", i)}
+	}
+	if err := ls.PutObjects(ctx, testBucket1, objs); err != nil {
+		t.Fatal(err)
+	}
+	first, err := ls.GetObjectsSorted(ctx, testBucket1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != len(objs) {
+		t.Fatalf("expected %v entries, but got %v", len(objs), len(first))
+	}
+	if !sort.SliceIsSorted(first, func(i, j int) bool { return first[i].Name < first[j].Name }) {
+		t.Fatal("expected entries ordered by name")
+	}
+	for i := 0; i < 10; i++ {
+		entries, err := ls.GetObjectsSorted(ctx, testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(entries, first) {
+			t.Fatal("expected the same order on every call")
+		}
+	}
+	if _, err := ls.GetObjectsSorted(ctx, "missing"); err != ErrLedgerBucketDoesNotExist {
+		t.Fatalf("expected ErrLedgerBucketDoesNotExist, but got %v", err)
+	}
+}
+
+func TestS3X_LedgerStore_Reconcile(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	a, err := newLedgerStore(ds, gateway.dagClient, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := newLedgerStore(ds, gateway.dagClient, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	// b caches the empty bucket
+	if _, err := b.GetObjectHash(ctx, testBucket1, testObject1); err != ErrLedgerObjectDoesNotExist {
+		t.Fatalf("expected ErrLedgerObjectDoesNotExist, but got %v", err)
+	}
+	if err := a.PutObject(ctx, testBucket1, testObject1, &Object{DataHash: "data"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.GetObjectHash(ctx, testBucket1, testObject1); err != ErrLedgerObjectDoesNotExist {
+		t.Fatalf("expected stale cache without reconciling, but got %v", err)
+	}
+	b.reconcileInterval = time.Nanosecond
+	if _, err := b.GetObjectHash(ctx, testBucket1, testObject1); err != nil {
+		t.Fatalf("expected object written by a after reconciling, but got %v", err)
+	}
+	if err := a.DeleteBucket(testBucket1); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AssertBucketExits(testBucket1); err != ErrLedgerBucketDoesNotExist {
+		t.Fatalf("expected bucket deleted by a to not exist, but got %v", err)
+	}
+}
+
+func TestS3X_LedgerStore_StrongRead(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	a, err := newLedgerStore(ds, gateway.dagClient, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := newLedgerStore(ds, gateway.dagClient, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	// b caches the empty bucket
+	if _, err := b.GetObjectHash(ctx, testBucket1, testObject1); err != ErrLedgerObjectDoesNotExist {
+		t.Fatalf("expected ErrLedgerObjectDoesNotExist, but got %v", err)
+	}
+	if err := a.PutObject(ctx, testBucket1, testObject1, &Object{DataHash: "data"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.GetObjectHash(ctx, testBucket1, testObject1); err != ErrLedgerObjectDoesNotExist {
+		t.Fatalf("expected a cached read to miss the object written by a, but got %v", err)
+	}
+	if _, err := b.GetObjectHash(WithStrongRead(ctx), testBucket1, testObject1); err != nil {
+		t.Fatalf("expected a strong read to see the object written by a, but got %v", err)
+	}
+	if err := a.DeleteBucket(testBucket1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.GetObjectHash(WithStrongRead(ctx), testBucket1, testObject1); err != ErrLedgerBucketDoesNotExist {
+		t.Fatalf("expected a strong read to see the bucket deleted by a, but got %v", err)
+	}
+}
+
+func TestS3X_LedgerStore_OnEvict(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	a, err := newLedgerStore(ds, gateway.dagClient, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := newLedgerStore(ds, gateway.dagClient, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var evicted []string
+	b.OnEvict(func(bucket string) {
+		// the cache lock must not be held
+		b.mapLocker.Lock()
+		b.mapLocker.Unlock()
+		evicted = append(evicted, bucket)
+	})
+	b.reconcileInterval = time.Nanosecond
+	if _, err := a.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.GetObjectHash(ctx, testBucket1, testObject1); err != ErrLedgerObjectDoesNotExist {
+		t.Fatalf("expected ErrLedgerObjectDoesNotExist, but got %v", err)
+	}
+	if len(evicted) != 0 {
+		t.Fatalf("expected no evictions, but got %v", evicted)
+	}
+	if err := a.PutObject(ctx, testBucket1, testObject1, &Object{DataHash: "data"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, err := b.GetObjectHash(ctx, testBucket1, testObject1); err != nil {
+		t.Fatal(err)
+	}
+	if len(evicted) != 1 || evicted[0] != testBucket1 {
+		t.Fatalf("expected eviction of the stale bucket, but got %v", evicted)
+	}
+	if err := b.DeleteBucket(testBucket1); err != nil {
+		t.Fatal(err)
+	}
+	if len(evicted) != 2 || evicted[1] != testBucket1 {
+		t.Fatalf("expected eviction of the deleted bucket, but got %v", evicted)
+	}
+}
+
+func TestS3X_LedgerStore_ObjectSingleFetch(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	ls := gateway.ledgerStore
+	h, err := ls.GetObjectHash(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls.objects = newObjectCache(defaultObjectCacheSize)
+	dag := &getCountingDagClient{NodeAPIClient: &slowDagClient{NodeAPIClient: ls.dag, delay: 100 * time.Millisecond}, hash: h}
+	ls.dag = dag
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			obj, err := ls.Object(ctx, testBucket1, testObject1)
+			if err == nil && obj.ObjectInfo.GetName() != testObject1 {
+				err = fmt.Errorf("unexpected object %v", obj.ObjectInfo.GetName())
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt64(&dag.gets); n != 1 {
+		t.Fatalf("expected the uncached object to be fetched once, but got %v fetches", n)
+	}
+}
+
+func TestS3X_LedgerStore_ObjectFetchCanceled(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	ls := gateway.ledgerStore
+	h, err := ls.GetObjectHash(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls.objects = newObjectCache(defaultObjectCacheSize)
+	dag := &getCountingDagClient{NodeAPIClient: &slowDagClient{NodeAPIClient: ls.dag, delay: 500 * time.Millisecond}, hash: h}
+	ls.dag = dag
+	// the caller starting the fetch gives up, the fetch goes on for the caller still waiting
+	first, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	started := make(chan struct{})
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		close(started)
+		_, err := ls.ipfsObject(first, h)
+		errs <- err
+	}()
+	<-started
+	time.Sleep(10 * time.Millisecond)
+	obj, err := ls.ipfsObject(ctx, h)
+	if err != nil {
+		t.Fatalf("expected the fetch to outlive the canceled caller, but got %v", err)
+	}
+	if obj.ObjectInfo.GetName() != testObject1 {
+		t.Fatalf("unexpected object %v", obj.ObjectInfo.GetName())
+	}
+	if err := <-errs; err != context.DeadlineExceeded {
+		t.Fatalf("expected the canceled caller to return %v, but got %v", context.DeadlineExceeded, err)
+	}
+	if n := atomic.LoadInt64(&dag.gets); n != 1 {
+		t.Fatalf("expected the uncached object to be fetched once, but got %v fetches", n)
+	}
+}
+
+// getCountingDagClient wraps a NodeAPIClient and counts the dag gets of a hash
+type getCountingDagClient struct {
+	pb.NodeAPIClient
+	hash string
+	gets int64
+}
+
+func (c *getCountingDagClient) Dag(ctx context.Context, in *pb.DagRequest, opts ...grpc.CallOption) (*pb.DagResponse, error) {
+	if in.GetRequestType() == pb.DAGREQTYPE_DAG_GET && in.GetHash() == c.hash {
+		atomic.AddInt64(&c.gets, 1)
+	}
+	return c.NodeAPIClient.Dag(ctx, in, opts...)
+}
+
+// blockingCloseDatastore is a datastore whose Close blocks until release is closed
+type blockingCloseDatastore struct {
+	datastore.Batching
+	release chan struct{}
+}
+
+func (ds *blockingCloseDatastore) Close() error {
+	<-ds.release
+	return ds.Batching.Close()
+}
+
+func TestS3X_LedgerStore_CloseTimeout(t *testing.T) {
+	ds := &blockingCloseDatastore{
+		Batching: dssync.MutexWrap(datastore.NewMapDatastore()),
+		release:  make(chan struct{}),
+	}
+	defer close(ds.release)
+	ledger, err := newLedgerStore(ds, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const timeout = 100 * time.Millisecond
+	ledger.closeTimeout = timeout
+	start := time.Now()
+	if err := ledger.Close(); err != ErrLedgerCloseTimeout {
+		t.Fatalf("expected ErrLedgerCloseTimeout, but got %v", err)
+	}
+	if took := time.Since(start); took > 10*timeout {
+		t.Fatalf("expected Close to return after %v, but it took %v", timeout, took)
+	}
+}
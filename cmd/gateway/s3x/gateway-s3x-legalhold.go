@@ -0,0 +1,35 @@
+package s3x
+
+import (
+	"context"
+
+	objectlock "github.com/RTradeLtd/s3x/pkg/bucket/object/lock"
+)
+
+// PutObjectLegalHold places an object under legal hold if the status is ON, or releases it if OFF.
+// An object under legal hold can't be deleted or overwritten, whatever its retention, until it's released.
+func (x *xObjects) PutObjectLegalHold(ctx context.Context, bucket, object string, hold *objectlock.ObjectLegalHold) error {
+	object = x.objectKey(object)
+	if hold.IsEmpty() {
+		return objectlock.ErrMalformedXML
+	}
+	if err := x.checkWritable(); err != nil {
+		return err
+	}
+	err := x.ledgerStore.SetObjectLegalHold(ctx, bucket, object, hold.Status == objectlock.ON)
+	return x.toMinioErr(err, bucket, object, "")
+}
+
+// GetObjectLegalHold returns the legal hold status of an object, OFF if it was never placed under legal hold
+func (x *xObjects) GetObjectLegalHold(ctx context.Context, bucket, object string) (*objectlock.ObjectLegalHold, error) {
+	object = x.objectKey(object)
+	held, err := x.ledgerStore.ObjectLegalHold(ctx, bucket, object)
+	if err != nil {
+		return nil, x.toMinioErr(err, bucket, object, "")
+	}
+	hold := &objectlock.ObjectLegalHold{Status: objectlock.OFF}
+	if held {
+		hold.Status = objectlock.ON
+	}
+	return hold, nil
+}
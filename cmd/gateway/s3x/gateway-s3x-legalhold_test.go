@@ -0,0 +1,135 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	objectlock "github.com/RTradeLtd/s3x/pkg/bucket/object/lock"
+)
+
+func TestS3X_ObjectLegalHold(t *testing.T) {
+	ctx := context.Background()
+	const otherObject = "other-object"
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	var _ minio.ObjectLegalHolder = gateway
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.SetBucketVersionHistory(ctx, testBucket1, true); err != nil {
+		t.Fatal(err)
+	}
+	put := func(object, data string) error {
+		_, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{})
+		return err
+	}
+	setHold := func(object string, status objectlock.LegalHoldStatus) {
+		t.Helper()
+		if err := gateway.PutObjectLegalHold(ctx, testBucket1, object, &objectlock.ObjectLegalHold{Status: status}); err != nil {
+			t.Fatal(err)
+		}
+		hold, err := gateway.GetObjectLegalHold(ctx, testBucket1, object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hold.Status != status {
+			t.Fatalf("expected legal hold %v, but got %v", status, hold.Status)
+		}
+	}
+	for _, object := range []string{testObject1, otherObject} {
+		if err := put(object, "held"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Run("default", func(t *testing.T) {
+		hold, err := gateway.GetObjectLegalHold(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hold.Status != objectlock.OFF {
+			t.Fatalf("expected no legal hold, but got %v", hold.Status)
+		}
+		if err := gateway.PutObjectLegalHold(ctx, testBucket1, testObject1, &objectlock.ObjectLegalHold{}); err != objectlock.ErrMalformedXML {
+			t.Fatalf("expected ErrMalformedXML for an empty status, but got %v", err)
+		}
+	})
+	t.Run("on", func(t *testing.T) {
+		setHold(testObject1, objectlock.ON)
+		// the hold is kept in the datastore, the object is not rewritten
+		if _, _, versions, err := gateway.ledgerStore.ObjectVersionHashes(ctx, testBucket1, testObject1); err != nil {
+			t.Fatal(err)
+		} else if len(versions) != 0 {
+			t.Fatalf("expected placing a hold not to add a version, but got %v", versions)
+		}
+		if _, ok := gateway.DeleteObject(ctx, testBucket1, testObject1).(minio.ObjectLocked); !ok {
+			t.Fatal("expected ObjectLocked when deleting a held object")
+		}
+		if _, ok := put(testObject1, "overwrite").(minio.ObjectLocked); !ok {
+			t.Fatal("expected ObjectLocked when overwriting a held object")
+		}
+		errs, err := gateway.DeleteObjects(ctx, testBucket1, []string{testObject1, otherObject})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := errs[0].(minio.ObjectLocked); !ok {
+			t.Fatalf("expected ObjectLocked for the held object, but got %v", errs[0])
+		}
+		if errs[1] != nil {
+			t.Fatalf("expected the object without a hold to be deleted, but got %v", errs[1])
+		}
+		info, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size != int64(len("held")) {
+			t.Fatalf("expected the held object to be kept, but got size %v", info.Size)
+		}
+	})
+	t.Run("restart", func(t *testing.T) {
+		gateway.restart(t)
+		hold, err := gateway.GetObjectLegalHold(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hold.Status != objectlock.ON {
+			t.Fatalf("expected legal hold to be persisted, but got %v", hold.Status)
+		}
+	})
+	t.Run("off", func(t *testing.T) {
+		setHold(testObject1, objectlock.OFF)
+		if err := gateway.DeleteObject(ctx, testBucket1, testObject1); err != nil {
+			t.Fatalf("expected released object to be deleted, but got %v", err)
+		}
+		if _, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{}); !isObjectNotFound(err) {
+			t.Fatalf("expected ObjectNotFound, but got %v", err)
+		}
+	})
+	t.Run("append-only", func(t *testing.T) {
+		if err := gateway.MakeBucketWithLocation(ctx, testBucket2, "us-east-1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := gateway.SetBucketAppendOnly(ctx, testBucket2, true); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gateway.PutObject(ctx, testBucket2, testObject1, getTestPutObjectReader(t, []byte("held")), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		for _, status := range []objectlock.LegalHoldStatus{objectlock.ON, objectlock.OFF} {
+			if err := gateway.PutObjectLegalHold(ctx, testBucket2, testObject1, &objectlock.ObjectLegalHold{Status: status}); err != nil {
+				t.Fatalf("expected legal hold %v in an append-only bucket, but got %v", status, err)
+			}
+			hold, err := gateway.GetObjectLegalHold(ctx, testBucket2, testObject1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hold.Status != status {
+				t.Fatalf("expected legal hold %v, but got %v", status, hold.Status)
+			}
+		}
+	})
+}
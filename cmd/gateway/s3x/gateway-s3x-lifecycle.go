@@ -0,0 +1,317 @@
+package s3x
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/pkg/bucket/lifecycle"
+	"go.uber.org/multierr"
+)
+
+// defaultLifecycleRetries is the default number of failed removals of an expired object
+// after which it's quarantined
+const defaultLifecycleRetries = 5
+
+// SetBucketLifecycle sets the lifecycle config of a bucket, the objects it expires are only
+// removed if lifecycle rounds are enabled with TEMX.LifecycleInterval
+func (x *xObjects) SetBucketLifecycle(ctx context.Context, bucket string, lc *lifecycle.Lifecycle) error {
+	data, err := xml.Marshal(lc)
+	if err != nil {
+		return err
+	}
+	return x.toMinioErr(x.ledgerStore.PutBucketConfig(bucket, bucketConfigLifecycle, data), bucket, "", "")
+}
+
+// GetBucketLifecycle returns the lifecycle config of a bucket
+func (x *xObjects) GetBucketLifecycle(ctx context.Context, bucket string) (*lifecycle.Lifecycle, error) {
+	lc, err := x.bucketLifecycle(bucket)
+	if err != nil {
+		return nil, x.toMinioErr(err, bucket, "", "")
+	}
+	if lc == nil {
+		return nil, minio.BucketLifecycleNotFound{Bucket: bucket}
+	}
+	return lc, nil
+}
+
+// DeleteBucketLifecycle removes the lifecycle config of a bucket,
+// and stops a lifecycle round that is applying the removed config to the bucket.
+func (x *xObjects) DeleteBucketLifecycle(ctx context.Context, bucket string) error {
+	if err := x.ledgerStore.DeleteBucketConfig(bucket, bucketConfigLifecycle); err != nil {
+		return x.toMinioErr(err, bucket, "", "")
+	}
+	x.lifecycles.cancel(bucket)
+	return nil
+}
+
+// bucketLifecycle returns the lifecycle config of a bucket, or nil if none is set
+func (x *xObjects) bucketLifecycle(bucket string) (*lifecycle.Lifecycle, error) {
+	data, err := x.ledgerStore.GetBucketConfig(bucket, bucketConfigLifecycle)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	lc := &lifecycle.Lifecycle{}
+	if err := xml.Unmarshal(data, lc); err != nil {
+		return nil, err
+	}
+	return lc, nil
+}
+
+// lifecycleRound removes all objects that expired by the lifecycle of their bucket,
+// a bucket that fails doesn't stop the round for the other buckets.
+func (x *xObjects) lifecycleRound(ctx context.Context) error {
+	buckets, err := x.ledgerStore.GetBucketNames()
+	if err != nil {
+		return err
+	}
+	for _, bucket := range buckets {
+		removed, berr := x.applyBucketLifecycle(ctx, bucket, time.Now())
+		if berr != nil {
+			err = multierr.Append(err, fmt.Errorf("bucket %s: %v", bucket, berr))
+			continue
+		}
+		for _, object := range removed {
+			log.Printf("bucket-name: %s, object-name: %s, expired by lifecycle", bucket, object)
+		}
+	}
+	return err
+}
+
+// applyBucketLifecycle removes the objects of a bucket that expired by its lifecycle, or that
+// were not read for the access expiry of the bucket at now, and returns their names.
+// Nothing is removed if the lifecycle is deleted while applying it.
+func (x *xObjects) applyBucketLifecycle(ctx context.Context, bucket string, now time.Time) ([]string, error) {
+	// register before loading the config, so a delete after loading it always cancels the bucket
+	bctx, done := x.lifecycles.start(ctx, bucket)
+	defer done()
+	lc, err := x.bucketLifecycle(bucket)
+	if err == ErrLedgerBucketDoesNotExist {
+		return nil, nil // bucket deleted while listing
+	}
+	if err != nil {
+		return nil, err
+	}
+	days, err := x.bucketAccessExpiry(bucket)
+	if err == ErrLedgerBucketDoesNotExist {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lc == nil && days == 0 {
+		x.lifecycles.forget(bucket, nil)
+		return nil, nil
+	}
+	accessExpiry := time.Duration(days) * 24 * time.Hour
+	// isExpired is checked again while the objects are removed,
+	// so objects written or read since they were listed are kept
+	isExpired := func(object string, info *ObjectInfo) (bool, error) {
+		held, err := x.ledgerStore.isLegalHold(bucket, object)
+		switch {
+		case err != nil || held:
+			return false, err
+		case lc != nil && lc.ComputeAction(object, "", info.GetModTime()) == lifecycle.DeleteAction:
+			return true, nil
+		case accessExpiry > 0:
+			last, err := x.ledgerStore.lastAccess(bucket, object, info)
+			return err == nil && now.Sub(last) >= accessExpiry, err
+		}
+		return false, nil
+	}
+	var expired []ObjectEntry
+	hashes := make(map[string]string)
+	err = x.ledgerStore.WalkEntries(bctx, bucket, "", "", func(e ObjectEntry, obj *Object) error {
+		hashes[e.Name] = e.Hash
+		if x.lifecycles.isQuarantined(ObjectRef{Bucket: bucket, Object: e.Name}, e.Hash, x.lifecycleRetries) {
+			return nil
+		}
+		ok, err := isExpired(e.Name, &obj.ObjectInfo)
+		if ok {
+			expired = append(expired, e)
+		}
+		return err
+	})
+	if bctx.Err() != nil && ctx.Err() == nil {
+		return nil, nil // lifecycle deleted
+	}
+	if err == ErrLedgerBucketDoesNotExist {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// failures of objects that were removed or replaced since are dropped
+	x.lifecycles.forget(bucket, func(object, hash string) bool {
+		return hashes[object] == hash
+	})
+	if len(expired) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(expired))
+	for _, e := range expired {
+		names = append(names, e.Name)
+	}
+	removed, err := x.ledgerStore.RemoveObjectsIf(bctx, bucket, names, isExpired)
+	if err == ErrLedgerBucketDoesNotExist {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("bucket-name: %s, failed to remove expired objects: %v", bucket, err)
+		return x.removeExpiredObjects(bctx, bucket, expired, isExpired)
+	}
+	return removed, nil
+}
+
+// removeExpiredObjects removes expired objects one at a time after removing them together failed,
+// so every failure uses up a retry of the object that can't be removed. Objects out of retries
+// are quarantined, and left alone by lifecycle rounds until they're replaced.
+// Failures because ipfs is unavailable don't use up retries, they are no fault of the object.
+func (x *xObjects) removeExpiredObjects(ctx context.Context, bucket string, expired []ObjectEntry,
+	isExpired func(object string, info *ObjectInfo) (bool, error)) ([]string, error) {
+	var removed []string
+	for _, e := range expired {
+		ref := ObjectRef{Bucket: bucket, Object: e.Name}
+		objs, err := x.ledgerStore.RemoveObjectsIf(ctx, bucket, []string{e.Name}, isExpired)
+		if err == ErrLedgerBucketDoesNotExist || ctx.Err() != nil {
+			return removed, nil
+		}
+		if isBackendError(err) {
+			continue
+		}
+		if err != nil {
+			if x.lifecycles.failed(ref, e.Hash, x.lifecycleRetries) {
+				log.Printf("bucket-name: %s, object-name: %s, quarantined after %d failed removals: %v",
+					bucket, e.Name, x.lifecycleRetries, err)
+			}
+			continue
+		}
+		x.lifecycles.removed(ref)
+		removed = append(removed, objs...)
+	}
+	return removed, nil
+}
+
+// LifecycleQuarantine returns the expired objects that lifecycle rounds failed to remove
+// too many times, see TEMX.LifecycleRetries, sorted by bucket and object name.
+func (x *xObjects) LifecycleQuarantine() []ObjectRef {
+	return x.lifecycles.quarantined(x.lifecycleRetries)
+}
+
+// bucketLifecycles tracks the buckets a lifecycle round is working on,
+// so the work can be cancelled when the lifecycle of a bucket is deleted,
+// and the number of failed removals of expired objects across rounds.
+type bucketLifecycles struct {
+	mu       sync.Mutex
+	cancels  map[string]context.CancelFunc
+	failures map[ObjectRef]lifecycleFailures
+}
+
+// lifecycleFailures is the number of failed removals of an object with the ipfs hash,
+// an object replaced with another hash starts over.
+type lifecycleFailures struct {
+	hash string
+	n    int
+}
+
+// start returns the context of lifecycle work on a bucket, done must be called once the work is done
+func (l *bucketLifecycles) start(ctx context.Context, bucket string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cancels == nil {
+		l.cancels = make(map[string]context.CancelFunc)
+	}
+	l.cancels[bucket] = cancel
+	return ctx, func() {
+		l.mu.Lock()
+		delete(l.cancels, bucket)
+		l.mu.Unlock()
+		cancel()
+	}
+}
+
+// cancel stops the lifecycle work on a bucket, if any
+func (l *bucketLifecycles) cancel(bucket string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if cancel, ok := l.cancels[bucket]; ok {
+		cancel()
+	}
+}
+
+// failed records a failed removal of an expired object with the ipfs hash, and returns true if it used
+// up the last of retries, which quarantines the object. Objects are never quarantined if retries is 0.
+func (l *bucketLifecycles) failed(ref ObjectRef, hash string, retries int) bool {
+	if retries <= 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.failures == nil {
+		l.failures = make(map[ObjectRef]lifecycleFailures)
+	}
+	f := l.failures[ref]
+	if f.hash != hash {
+		f = lifecycleFailures{hash: hash}
+	}
+	f.n++
+	l.failures[ref] = f
+	return f.n == retries
+}
+
+// removed forgets the failed removals of an object once it's removed
+func (l *bucketLifecycles) removed(ref ObjectRef) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.failures, ref)
+}
+
+// isQuarantined returns true if the removal of the object with the ipfs hash failed retries times
+func (l *bucketLifecycles) isQuarantined(ref ObjectRef, hash string, retries int) bool {
+	if retries <= 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, ok := l.failures[ref]
+	return ok && f.hash == hash && f.n >= retries
+}
+
+// forget drops the failed removals of the objects of a bucket, except for those keep returns true for
+func (l *bucketLifecycles) forget(bucket string, keep func(object, hash string) bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ref, f := range l.failures {
+		if ref.Bucket == bucket && (keep == nil || !keep(ref.Object, f.hash)) {
+			delete(l.failures, ref)
+		}
+	}
+}
+
+// quarantined returns the quarantined objects sorted by bucket and object name
+func (l *bucketLifecycles) quarantined(retries int) []ObjectRef {
+	if retries <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var refs []ObjectRef
+	for ref, f := range l.failures {
+		if f.n >= retries {
+			refs = append(refs, ref)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Bucket != refs[j].Bucket {
+			return refs[i].Bucket < refs[j].Bucket
+		}
+		return refs[i].Object < refs[j].Object
+	})
+	return refs
+}
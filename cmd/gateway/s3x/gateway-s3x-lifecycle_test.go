@@ -0,0 +1,213 @@
+package s3x
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/pkg/bucket/lifecycle"
+	"google.golang.org/grpc/codes"
+)
+
+const testExpiredLifecycle = `<LifecycleConfiguration><Rule><Filter><Prefix>logs/</Prefix></Filter><Status>Enabled</Status><Expiration><Date>2020-01-01T00:00:00Z</Date></Expiration></Rule></LifecycleConfiguration>`
+
+func TestS3X_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	lc, err := lifecycle.ParseLifecycleConfig(strings.NewReader(testExpiredLifecycle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(t *testing.T, objects ...string) {
+		for _, object := range objects {
+			if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	exists := func(t *testing.T, object string) bool {
+		_, err := gateway.GetObjectInfo(ctx, testBucket1, object, minio.ObjectOptions{})
+		if _, ok := err.(minio.ObjectNotFound); ok {
+			return false
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return true
+	}
+	t.Run("not set", func(t *testing.T) {
+		if _, err := gateway.GetBucketLifecycle(ctx, testBucket1); err == nil {
+			t.Fatal("expected error for bucket without lifecycle")
+		}
+	})
+	t.Run("expire", func(t *testing.T) {
+		put(t, "logs/1", "keep")
+		if err := gateway.SetBucketLifecycle(ctx, testBucket1, lc); err != nil {
+			t.Fatal(err)
+		}
+		got, err := gateway.GetBucketLifecycle(ctx, testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Rules) != 1 || got.Rules[0].Prefix() != "logs/" {
+			t.Fatalf("unexpected lifecycle %+v", got)
+		}
+		if err := gateway.lifecycleRound(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if exists(t, "logs/1") {
+			t.Fatal("expected logs/1 to be expired")
+		}
+		if !exists(t, "keep") {
+			t.Fatal("expected keep to not be expired")
+		}
+	})
+	t.Run("delete", func(t *testing.T) {
+		put(t, "logs/2")
+		if err := gateway.DeleteBucketLifecycle(ctx, testBucket1); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gateway.GetBucketLifecycle(ctx, testBucket1); err == nil {
+			t.Fatal("expected error for deleted lifecycle")
+		}
+		removed, err := gateway.applyBucketLifecycle(ctx, testBucket1, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(removed) != 0 {
+			t.Fatalf("expected no objects to be removed, but got %v", removed)
+		}
+		if !exists(t, "logs/2") {
+			t.Fatal("expected logs/2 to not be expired")
+		}
+	})
+	t.Run("delete during round", func(t *testing.T) {
+		if err := gateway.SetBucketLifecycle(ctx, testBucket1, lc); err != nil {
+			t.Fatal(err)
+		}
+		bctx, done := gateway.lifecycles.start(ctx, testBucket1)
+		defer done()
+		if err := gateway.DeleteBucketLifecycle(ctx, testBucket1); err != nil {
+			t.Fatal(err)
+		}
+		if bctx.Err() == nil {
+			t.Fatal("expected running lifecycle work to be cancelled")
+		}
+	})
+}
+
+func TestS3X_Lifecycle_BucketErrors(t *testing.T) {
+	ctx := context.Background()
+	const brokenBucket = "broken-lifecycle"
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for _, bucket := range []string{brokenBucket, testBucket1} {
+		if err := gateway.MakeBucketWithLocation(ctx, bucket, "us-east-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lc, err := lifecycle.ParseLifecycleConfig(strings.NewReader(testExpiredLifecycle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.SetBucketLifecycle(ctx, testBucket1, lc); err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.ledgerStore.PutBucketConfig(brokenBucket, bucketConfigLifecycle, []byte("not a lifecycle")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, "logs/1", getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.lifecycleRound(ctx); err == nil {
+		t.Fatal("expected the error of the broken lifecycle")
+	}
+	if _, err := gateway.GetObjectInfo(ctx, testBucket1, "logs/1", minio.ObjectOptions{}); !isObjectNotFound(err) {
+		t.Fatal("expected the lifecycle of other buckets to be applied, but got", err)
+	}
+}
+
+func TestS3X_Lifecycle_Quarantine(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	lc, err := lifecycle.ParseLifecycleConfig(strings.NewReader(testExpiredLifecycle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.SetBucketLifecycle(ctx, testBucket1, lc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, "logs/1", getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	const retries = 3
+	gateway.lifecycleRetries = retries
+	dag := gateway.ledgerStore.dag
+
+	// removals failing because ipfs is unavailable don't use up retries
+	gateway.ledgerStore.dag = &failingDagClient{NodeAPIClient: dag, fail: 1, code: codes.Unavailable}
+	for i := 0; i < retries; i++ {
+		if _, err := gateway.applyBucketLifecycle(ctx, testBucket1, time.Now()); err != nil {
+			t.Fatal("expected a failed removal to not fail the round, but got", err)
+		}
+	}
+	if got := gateway.LifecycleQuarantine(); len(got) != 0 {
+		t.Fatalf("expected no quarantined objects while ipfs is unavailable, but got %v", got)
+	}
+
+	gateway.ledgerStore.dag = &failingDagClient{NodeAPIClient: dag, fail: 1}
+	want := []ObjectRef{{Bucket: testBucket1, Object: "logs/1"}}
+	for i := 1; i <= retries; i++ {
+		removed, err := gateway.applyBucketLifecycle(ctx, testBucket1, time.Now())
+		if err != nil {
+			t.Fatal("expected a failed removal to not fail the round, but got", err)
+		}
+		if len(removed) != 0 {
+			t.Fatalf("expected no objects to be removed, but got %v", removed)
+		}
+		got := gateway.LifecycleQuarantine()
+		if i < retries && len(got) != 0 {
+			t.Fatalf("expected no quarantined objects after %v failures, but got %v", i, got)
+		}
+		if i == retries && !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected %v to be quarantined after %v failures, but got %v", want, i, got)
+		}
+	}
+
+	// a quarantined object is not removed even once removals succeed again
+	gateway.ledgerStore.dag = dag
+	removed, err := gateway.applyBucketLifecycle(ctx, testBucket1, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 0 {
+		t.Fatalf("expected the quarantined object to be skipped, but got %v", removed)
+	}
+	if _, err := gateway.GetObjectInfo(ctx, testBucket1, "logs/1", minio.ObjectOptions{}); err != nil {
+		t.Fatal("expected the quarantined object to be kept, but got", err)
+	}
+
+	// an object replaced with other data is out of quarantine
+	if _, err := gateway.PutObject(ctx, testBucket1, "logs/1", getTestPutObjectReader(t, []byte("other data")), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	removed, err = gateway.applyBucketLifecycle(ctx, testBucket1, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(removed, []string{"logs/1"}) {
+		t.Fatalf("expected the replaced object to be removed, but got %v", removed)
+	}
+	if got := gateway.LifecycleQuarantine(); len(got) != 0 {
+		t.Fatalf("expected no quarantined objects after the removal, but got %v", got)
+	}
+}
@@ -0,0 +1,199 @@
+package s3x
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+)
+
+// Metrics are counters of the gateway since it started
+type Metrics struct {
+	DagGets         int64 // number of ipfs dag gets
+	DagPuts         int64 // number of ipfs dag puts
+	DagErrors       int64 // number of failed ipfs dag requests
+	CacheHits       int64 // number of objects read from the object cache
+	CacheMisses     int64 // number of objects read from ipfs
+	BytesUploaded   int64 // number of object data bytes stored on ipfs
+	BytesDownloaded int64 // number of object data bytes sent to clients
+
+	MultipartInitiated int64 // number of multipart uploads initiated
+	MultipartParts     int64 // number of multipart upload parts uploaded
+	MultipartCompleted int64 // number of multipart uploads completed
+	MultipartAborted   int64 // number of multipart uploads aborted, including stale uploads
+	MultipartInFlight  int64 // number of multipart uploads in progress, including those from before the start
+
+	PinnedCIDs int64 // number of cids pinned on read that are remembered as pinned, see TEMX.PinOnRead
+}
+
+// s3xMetrics holds the counters of Metrics that are not kept elsewhere, a nil s3xMetrics counts nothing
+type s3xMetrics struct {
+	dagGets, dagPuts, dagErrors    int64
+	bytesUploaded, bytesDownloaded int64
+
+	multipartInitiated, multipartParts, multipartCompleted, multipartAborted int64
+	multipartInFlight                                                        int64
+}
+
+func (m *s3xMetrics) add(counter *int64, n int64) {
+	if m != nil {
+		atomic.AddInt64(counter, n)
+	}
+}
+
+// metricsDagClient counts dag requests in metrics
+type metricsDagClient struct {
+	pb.NodeAPIClient
+	metrics *s3xMetrics
+}
+
+func (c *metricsDagClient) Dag(ctx context.Context, in *pb.DagRequest, opts ...grpc.CallOption) (*pb.DagResponse, error) {
+	if in.GetRequestType() == pb.DAGREQTYPE_DAG_PUT {
+		c.metrics.add(&c.metrics.dagPuts, 1)
+	} else {
+		c.metrics.add(&c.metrics.dagGets, 1)
+	}
+	resp, err := c.NodeAPIClient.Dag(ctx, in, opts...)
+	if err != nil {
+		c.metrics.add(&c.metrics.dagErrors, 1)
+	}
+	return resp, err
+}
+
+// countingWriter counts the bytes written to w in n and the metrics
+type countingWriter struct {
+	w       io.Writer
+	n       int64
+	metrics *s3xMetrics
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.metrics.add(&c.metrics.bytesDownloaded, int64(n))
+	return n, err
+}
+
+// GetMetrics returns the current counters of the gateway
+func (x *xObjects) GetMetrics() Metrics {
+	var m Metrics
+	if x.metrics != nil {
+		m.DagGets = atomic.LoadInt64(&x.metrics.dagGets)
+		m.DagPuts = atomic.LoadInt64(&x.metrics.dagPuts)
+		m.DagErrors = atomic.LoadInt64(&x.metrics.dagErrors)
+		m.BytesUploaded = atomic.LoadInt64(&x.metrics.bytesUploaded)
+		m.BytesDownloaded = atomic.LoadInt64(&x.metrics.bytesDownloaded)
+		m.MultipartInitiated = atomic.LoadInt64(&x.metrics.multipartInitiated)
+		m.MultipartParts = atomic.LoadInt64(&x.metrics.multipartParts)
+		m.MultipartCompleted = atomic.LoadInt64(&x.metrics.multipartCompleted)
+		m.MultipartAborted = atomic.LoadInt64(&x.metrics.multipartAborted)
+		m.MultipartInFlight = atomic.LoadInt64(&x.metrics.multipartInFlight)
+	}
+	m.CacheHits, m.CacheMisses = x.ledgerStore.objects.stats()
+	if x.pinner != nil {
+		m.PinnedCIDs = int64(x.pinner.count())
+	}
+	return m
+}
+
+// MetricsHandler returns a handler serving the gateway metrics in the prometheus text format
+func (x *xObjects) MetricsHandler() http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&metricsCollector{x: x})
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// infoHTTPHandler returns the handler of the info http api, serving MetricsHandler on /metrics
+// if metrics is set, see TEMX.InfoMetrics
+func (x *xObjects) infoHTTPHandler(metrics bool) http.Handler {
+	if !metrics {
+		return x.infoAPI.httpMux
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", x.MetricsHandler())
+	mux.Handle("/", x.infoAPI.httpMux)
+	return mux
+}
+
+var (
+	dagRequestsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("s3x", "dag", "requests_total"),
+		"Total number of ipfs dag requests",
+		[]string{"type"}, nil,
+	)
+	dagErrorsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("s3x", "dag", "errors_total"),
+		"Total number of failed ipfs dag requests",
+		nil, nil,
+	)
+	objectCacheDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("s3x", "object_cache", "requests_total"),
+		"Total number of object reads by object cache result",
+		[]string{"result"}, nil,
+	)
+	bytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("s3x", "object", "bytes_total"),
+		"Total number of object data bytes by direction",
+		[]string{"direction"}, nil,
+	)
+	multipartUploadsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("s3x", "multipart", "uploads_total"),
+		"Total number of multipart uploads by event",
+		[]string{"event"}, nil,
+	)
+	multipartPartsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("s3x", "multipart", "parts_total"),
+		"Total number of multipart upload parts uploaded",
+		nil, nil,
+	)
+	multipartInFlightDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("s3x", "multipart", "uploads_in_flight"),
+		"Number of multipart uploads in progress",
+		nil, nil,
+	)
+	pinnedCIDsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("s3x", "pin", "pinned_cids"),
+		"Number of cids pinned on read that are remembered as pinned",
+		nil, nil,
+	)
+)
+
+// metricsCollector exposes the metrics of a gateway to prometheus
+type metricsCollector struct {
+	x *xObjects
+}
+
+// Describe sends the descriptors of all metrics
+func (c *metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dagRequestsDesc
+	ch <- dagErrorsDesc
+	ch <- objectCacheDesc
+	ch <- bytesDesc
+	ch <- multipartUploadsDesc
+	ch <- multipartPartsDesc
+	ch <- multipartInFlightDesc
+	ch <- pinnedCIDsDesc
+}
+
+// Collect sends the current values of all metrics
+func (c *metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	m := c.x.GetMetrics()
+	ch <- prometheus.MustNewConstMetric(dagRequestsDesc, prometheus.CounterValue, float64(m.DagGets), "get")
+	ch <- prometheus.MustNewConstMetric(dagRequestsDesc, prometheus.CounterValue, float64(m.DagPuts), "put")
+	ch <- prometheus.MustNewConstMetric(dagErrorsDesc, prometheus.CounterValue, float64(m.DagErrors))
+	ch <- prometheus.MustNewConstMetric(objectCacheDesc, prometheus.CounterValue, float64(m.CacheHits), "hit")
+	ch <- prometheus.MustNewConstMetric(objectCacheDesc, prometheus.CounterValue, float64(m.CacheMisses), "miss")
+	ch <- prometheus.MustNewConstMetric(bytesDesc, prometheus.CounterValue, float64(m.BytesUploaded), "upload")
+	ch <- prometheus.MustNewConstMetric(bytesDesc, prometheus.CounterValue, float64(m.BytesDownloaded), "download")
+	ch <- prometheus.MustNewConstMetric(multipartUploadsDesc, prometheus.CounterValue, float64(m.MultipartInitiated), "initiated")
+	ch <- prometheus.MustNewConstMetric(multipartUploadsDesc, prometheus.CounterValue, float64(m.MultipartCompleted), "completed")
+	ch <- prometheus.MustNewConstMetric(multipartUploadsDesc, prometheus.CounterValue, float64(m.MultipartAborted), "aborted")
+	ch <- prometheus.MustNewConstMetric(multipartPartsDesc, prometheus.CounterValue, float64(m.MultipartParts))
+	ch <- prometheus.MustNewConstMetric(multipartInFlightDesc, prometheus.GaugeValue, float64(m.MultipartInFlight))
+	ch <- prometheus.MustNewConstMetric(pinnedCIDsDesc, prometheus.GaugeValue, float64(m.PinnedCIDs))
+}
@@ -0,0 +1,146 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_Metrics(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	gateway.pinner = newReadPinner(ctx, &persistCountingClient{NodeAPIClient: gateway.dagClient, pins: make(map[string]int)})
+	defer gateway.pinner.stop()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
+		t.Fatal(err)
+	}
+	before := gateway.GetMetrics()
+	data := []byte(testObject1Data)
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, data), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, 0, ioutil.Discard, "", minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	gateway.pinner.wg.Wait()
+	t.Run("GetMetrics", func(t *testing.T) {
+		m := gateway.GetMetrics()
+		if m.DagPuts <= before.DagPuts {
+			t.Fatal("expected dag puts to be counted")
+		}
+		if m.CacheHits <= before.CacheHits {
+			t.Fatal("expected object cache hits to be counted")
+		}
+		if got := m.BytesUploaded - before.BytesUploaded; got != int64(len(data)) {
+			t.Fatalf("expected %v uploaded bytes, but got %v", len(data), got)
+		}
+		if got := m.BytesDownloaded - before.BytesDownloaded; got != int64(len(data)) {
+			t.Fatalf("expected %v downloaded bytes, but got %v", len(data), got)
+		}
+		if m.PinnedCIDs != 1 {
+			t.Fatalf("expected the read object to be pinned, but got %v pinned cids", m.PinnedCIDs)
+		}
+	})
+	t.Run("MetricsHandler", func(t *testing.T) {
+		m := gateway.GetMetrics()
+		rec := httptest.NewRecorder()
+		gateway.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, but got %v", rec.Code)
+		}
+		body := rec.Body.String()
+		for _, line := range []string{
+			fmt.Sprintf(`s3x_dag_requests_total{type="get"} %d`, m.DagGets),
+			fmt.Sprintf(`s3x_dag_requests_total{type="put"} %d`, m.DagPuts),
+			fmt.Sprintf(`s3x_dag_errors_total %d`, m.DagErrors),
+			fmt.Sprintf(`s3x_object_cache_requests_total{result="hit"} %d`, m.CacheHits),
+			fmt.Sprintf(`s3x_object_cache_requests_total{result="miss"} %d`, m.CacheMisses),
+			fmt.Sprintf(`s3x_object_bytes_total{direction="upload"} %d`, m.BytesUploaded),
+			fmt.Sprintf(`s3x_object_bytes_total{direction="download"} %d`, m.BytesDownloaded),
+			fmt.Sprintf(`s3x_pin_pinned_cids %d`, m.PinnedCIDs),
+		} {
+			if !strings.Contains(body, line+"\n") {
+				t.Fatalf("expected metric %q in:\n%v", line, body)
+			}
+		}
+		if !strings.Contains(body, "# TYPE s3x_dag_requests_total counter") {
+			t.Fatal("expected dag requests to be a counter")
+		}
+		if !strings.Contains(body, "# TYPE s3x_pin_pinned_cids gauge") {
+			t.Fatal("expected pinned cids to be a gauge")
+		}
+	})
+	t.Run("InfoMetrics", func(t *testing.T) {
+		for _, metrics := range []bool{false, true} {
+			rec := httptest.NewRecorder()
+			gateway.infoHTTPHandler(metrics).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			if served := rec.Code == http.StatusOK && strings.Contains(rec.Body.String(), "s3x_dag_requests_total"); served != metrics {
+				t.Fatalf("expected metrics to be served only if enabled, but got status %v with enabled %v", rec.Code, metrics)
+			}
+		}
+	})
+}
+
+func TestS3X_Metrics_Multipart(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	expect := func(t *testing.T, initiated, parts, completed, aborted, inFlight int64) {
+		t.Helper()
+		m := gateway.GetMetrics()
+		got := []int64{m.MultipartInitiated, m.MultipartParts, m.MultipartCompleted, m.MultipartAborted, m.MultipartInFlight}
+		want := []int64{initiated, parts, completed, aborted, inFlight}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("expected initiated, parts, completed, aborted and in flight %v, but got %v", want, got)
+			}
+		}
+	}
+	expect(t, 0, 0, 0, 0, 0)
+
+	completed, err := gateway.NewMultipartUpload(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	aborted, err := gateway.NewMultipartUpload(ctx, testBucket1, "testobject2", minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 2, 0, 0, 0, 2)
+
+	var uploadParts []minio.CompletePart
+	for i := 1; i <= 2; i++ {
+		pi, err := gateway.PutObjectPart(ctx, testBucket1, testObject1, completed, i, getTestPutObjectReader(t, []byte("data")), minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uploadParts = append(uploadParts, minio.CompletePart{PartNumber: pi.PartNumber, ETag: pi.ETag})
+	}
+	expect(t, 2, 2, 0, 0, 2)
+
+	if _, err := gateway.CompleteMultipartUpload(ctx, testBucket1, testObject1, completed, uploadParts, minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 2, 2, 1, 0, 1)
+
+	if err := gateway.AbortMultipartUpload(ctx, testBucket1, "testobject2", aborted); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 2, 2, 1, 1, 0)
+
+	if err := gateway.AbortMultipartUpload(ctx, testBucket1, "testobject2", aborted); err == nil {
+		t.Fatal("expected abort of an unknown upload to fail")
+	}
+	expect(t, 2, 2, 1, 1, 0)
+}
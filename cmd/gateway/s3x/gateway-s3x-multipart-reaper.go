@@ -0,0 +1,38 @@
+package s3x
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	// defaultMultipartMaxAge is the default age after which incomplete multipart uploads are aborted
+	defaultMultipartMaxAge = 7 * 24 * time.Hour
+	// defaultMultipartReapInterval is the default interval between aborts of stale multipart uploads
+	defaultMultipartReapInterval = time.Hour
+)
+
+// abortStaleMultipartUploads aborts all multipart uploads initiated longer than multipartMaxAge ago,
+// so their parts are no longer referenced by the ledger, and unpins the parts nothing else references.
+func (x *xObjects) abortStaleMultipartUploads(ctx context.Context) error {
+	ids, err := x.ledgerStore.GetStaleMultipartUploads(time.Now().Add(-x.multipartMaxAge))
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		aborted, err := x.ledgerStore.AbortStaleMultipartUpload(ctx, id)
+		if aborted {
+			x.metrics.add(&x.metrics.multipartAborted, 1)
+			x.metrics.add(&x.metrics.multipartInFlight, -1)
+			log.Printf("upload-id: %s, stale multipart upload aborted", id)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package s3x
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/segmentio/ksuid"
+)
+
+func TestS3X_Multipart_AbortStale(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	gateway.multipartMaxAge = time.Hour
+	client := &deleteRecordingClient{NodeAPIClient: gateway.ledgerStore.dag}
+	gateway.ledgerStore.dag = client
+	old, err := ksuid.NewRandomWithTime(time.Now().Add(-2 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := newObjectInfo(testBucket1, "old", 0, minio.ObjectOptions{})
+	if err := gateway.ledgerStore.NewMultipartUploadWithID(old.String(), &info); err != nil {
+		t.Fatal(err)
+	}
+	putPart := func(object, uploadID string, number int, data string) minio.PartInfo {
+		pi, err := gateway.PutObjectPart(ctx, testBucket1, object, uploadID, number, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pi
+	}
+	// only the part that is not shared with an upload in progress or a completed object is unpinned
+	orphan := putPart("old", old.String(), 1, "part")
+	putPart("old", old.String(), 2, "in progress")
+	putPart("old", old.String(), 3, "completed")
+	fresh, err := gateway.NewMultipartUpload(ctx, testBucket1, "fresh", minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	putPart("fresh", fresh, 1, "in progress")
+	completed, err := gateway.NewMultipartUpload(ctx, testBucket1, "completed", minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pi := putPart("completed", completed, 1, "completed")
+	if _, err := gateway.CompleteMultipartUpload(ctx, testBucket1, "completed", completed, []minio.CompletePart{{PartNumber: pi.PartNumber, ETag: pi.ETag}}, minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.abortStaleMultipartUploads(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.ledgerStore.MultipartIDExists(old.String()); err != ErrInvalidUploadID {
+		t.Fatalf("expected stale upload to be aborted, but got %v", err)
+	}
+	if err := gateway.ledgerStore.MultipartIDExists(fresh); err != nil {
+		t.Fatalf("expected fresh upload to be kept, but got %v", err)
+	}
+	if want := []string{orphan.ETag}; !reflect.DeepEqual(client.unpinned, want) {
+		t.Fatalf("expected %v to be unpinned, but got %v", want, client.unpinned)
+	}
+	if len(client.deleted) != 0 {
+		t.Fatalf("expected nothing to be force deleted, but got %v", client.deleted)
+	}
+	t.Run("already aborted", func(t *testing.T) {
+		aborted, err := gateway.ledgerStore.AbortStaleMultipartUpload(ctx, old.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if aborted {
+			t.Fatal("expected an upload aborted elsewhere to be skipped")
+		}
+	})
+}
@@ -2,19 +2,30 @@ package s3x
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	fmt "fmt"
+	"io"
+	"strconv"
+	"strings"
 	"time"
 
+	pb "github.com/RTradeLtd/TxPB/v3/go"
 	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/pkg/hash"
 	proto "github.com/gogo/protobuf/proto"
 	"github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
 	"github.com/ipfs/go-merkledag"
 	unixfs_pb "github.com/ipfs/go-unixfs/pb"
-	"github.com/segmentio/ksuid"
+	pkgerrors "github.com/pkg/errors"
 )
 
+// s3xMetaMultipartCompletion is the internal metadata key of objects created by completing a
+// multipart upload, it identifies the completion so that retries of it succeed
+const s3xMetaMultipartCompletion = minio.ReservedMetadataPrefix + "S3x-Multipart-Completion"
+
 // ListMultipartUploads lists all multipart uploads.
 func (x *xObjects) ListMultipartUploads(ctx context.Context, bucket string, prefix string, keyMarker string, uploadIDMarker string, delimiter string, maxUploads int) (lmi minio.ListMultipartsInfo, e error) {
 	fmt.Println("list multipart uploads")
@@ -27,12 +38,32 @@ func (x *xObjects) NewMultipartUpload(
 	bucket, object string,
 	opts minio.ObjectOptions,
 ) (uploadID string, err error) {
-	uploadID = ksuid.New().String()
+	object = x.objectKey(object)
+	if err := x.checkObjectName(bucket, object); err != nil {
+		return "", err
+	}
+	if err := x.checkMetadataSize(bucket, object, opts.UserDefined); err != nil {
+		return "", err
+	}
+	if err := x.checkWritable(); err != nil {
+		return "", err
+	}
+	if err := x.checkRateLimit(ctx, bucket); err != nil {
+		return "", err
+	}
+	if err := x.checkObjectEncryption(bucket, object, opts.UserDefined); err != nil {
+		return "", err
+	}
 	info := newObjectInfo(bucket, object, 0, opts)
-	return uploadID, x.toMinioErr(
-		x.ledgerStore.NewMultipartUpload(uploadID, &info),
-		bucket, object, uploadID,
-	)
+	if err := x.applyDefaultContentType(ctx, &info); err != nil {
+		return "", err
+	}
+	uploadID, err = x.ledgerStore.NewMultipartUpload(&info)
+	if err == nil {
+		x.metrics.add(&x.metrics.multipartInitiated, 1)
+		x.metrics.add(&x.metrics.multipartInFlight, 1)
+	}
+	return uploadID, x.toMinioErr(err, bucket, object, uploadID)
 }
 
 // PutObjectPart puts a part of object in bucket
@@ -43,11 +74,26 @@ func (x *xObjects) PutObjectPart(
 	r *minio.PutObjReader,
 	opts minio.ObjectOptions,
 ) (pi minio.PartInfo, e error) {
+	object = x.objectKey(object)
+	if err := x.checkWritable(); err != nil {
+		return pi, err
+	}
+	if err := x.checkRateLimit(ctx, bucket); err != nil {
+		return pi, err
+	}
 	err := x.ledgerStore.AssertBucketExits(bucket)
 	if err != nil {
 		return pi, x.toMinioErr(err, bucket, "", "")
 	}
-	hash, size, err := ipfsFileUpload(ctx, x.fileClient, r)
+	var body io.Reader = r
+	if x.maxPartSize > 0 {
+		if r.Size() > x.maxPartSize {
+			return pi, minio.PartTooBig{}
+		}
+		// the size is unknown for streamed parts, so it is also enforced while reading
+		body = &partSizeLimiter{r: r, max: x.maxPartSize}
+	}
+	hash, size, err := x.fileUpload(ctx, body)
 	if err != nil {
 		return pi, x.toMinioErr(err, bucket, object, uploadID)
 	}
@@ -58,17 +104,75 @@ func (x *xObjects) PutObjectPart(
 		Size:         int64(size),
 		ActualSize:   int64(size),
 	}
-	return pi, x.toMinioErr(
-		x.ledgerStore.PutObjectPart(bucket, object, uploadID, pi),
-		bucket, object, uploadID)
+	if err := x.ledgerStore.PutObjectPart(bucket, object, uploadID, pi); err != nil {
+		return pi, x.toMinioErr(err, bucket, object, uploadID)
+	}
+	x.metrics.add(&x.metrics.multipartParts, 1)
+	return pi, nil
+}
+
+// completedMultipartUpload returns the info of the object if it was created by completing
+// the upload with the same parts
+func (x *xObjects) completedMultipartUpload(ctx context.Context, bucket, object, uploadID string, parts []minio.CompletePart) (minio.ObjectInfo, bool) {
+	obj, err := x.ledgerStore.Object(ctx, bucket, object)
+	if err != nil {
+		return minio.ObjectInfo{}, false
+	}
+	if obj.ObjectInfo.GetUserDefined()[s3xMetaMultipartCompletion] != multipartCompletion(uploadID, parts) {
+		return minio.ObjectInfo{}, false
+	}
+	return getObjectETagInfo(&obj.ObjectInfo, obj.GetDataHash()), true
+}
+
+// multipartCompletion returns a digest of an upload id and the parts it is completed with
+func multipartCompletion(uploadID string, parts []minio.CompletePart) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", uploadID)
+	for _, p := range parts {
+		fmt.Fprintf(h, "%d:%s\n", p.PartNumber, strings.Trim(p.ETag, `"`))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// partSizeLimiter fails reads with minio.PartTooBig once more than max bytes were read
+type partSizeLimiter struct {
+	r      io.Reader
+	n, max int64
+}
+
+func (l *partSizeLimiter) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.max {
+		return 0, minio.PartTooBig{}
+	}
+	return n, err
 }
 
 // CopyObjectPart creates a part in a multipart upload by copying
 // existing object or a part of it.
+//
+// The handler provides the source range as srcInfo.PutObjReader, otherwise the range is
+// read from the source object in ipfs, so the data never goes through the client.
 func (x *xObjects) CopyObjectPart(ctx context.Context, srcBucket, srcObject, destBucket, destObject, uploadID string,
 	partID int, startOffset, length int64, srcInfo minio.ObjectInfo, srcOpts, dstOpts minio.ObjectOptions) (p minio.PartInfo, err error) {
-	fmt.Println("copy object part")
-	return p, errors.New("not yet implemented")
+	srcObject, destObject = x.objectKey(srcObject), x.objectKey(destObject)
+	if srcInfo.PutObjReader != nil {
+		return x.PutObjectPart(ctx, destBucket, destObject, uploadID, partID, srcInfo.PutObjReader, dstOpts)
+	}
+	if startOffset < 0 || length <= 0 {
+		return p, minio.InvalidRange{OffsetBegin: startOffset, OffsetEnd: startOffset + length, ResourceSize: srcInfo.Size}
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(x.getObject(ctx, srcBucket, srcObject, startOffset, length, pw))
+	}()
+	defer pr.Close()
+	r, err := hash.NewReader(pr, length, "", "", length, false)
+	if err != nil {
+		return p, err
+	}
+	return x.PutObjectPart(ctx, destBucket, destObject, uploadID, partID, minio.NewPutObjReader(r, nil, nil), dstOpts)
 }
 
 // ListObjectParts returns all object parts for specified object in specified bucket
@@ -79,6 +183,7 @@ func (x *xObjects) ListObjectParts(
 	partNumberMarker, maxParts int,
 	opts minio.ObjectOptions,
 ) (lpi minio.ListPartsInfo, e error) {
+	object = x.objectKey(object)
 	lpi = minio.ListPartsInfo{
 		Bucket:           bucket,
 		Object:           object,
@@ -112,13 +217,14 @@ func (x *xObjects) AbortMultipartUpload(
 	ctx context.Context,
 	bucket, object, uploadID string,
 ) error {
+	object = x.objectKey(object)
 	// TODO(bonedaddy): remove the corresponding objects from ipfs
-	return x.toMinioErr(
-		x.ledgerStore.AbortMultipartUpload(bucket, uploadID),
-		bucket,
-		object,
-		uploadID,
-	)
+	err := x.ledgerStore.AbortMultipartUpload(bucket, uploadID)
+	if err == nil {
+		x.metrics.add(&x.metrics.multipartAborted, 1)
+		x.metrics.add(&x.metrics.multipartInFlight, -1)
+	}
+	return x.toMinioErr(err, bucket, object, uploadID)
 }
 
 // CompleteMultipartUpload completes ongoing multipart upload and finalizes object.
@@ -129,18 +235,32 @@ func (x *xObjects) CompleteMultipartUpload(
 	uploadedParts []minio.CompletePart,
 	opts minio.ObjectOptions,
 ) (oi minio.ObjectInfo, e error) {
+	object = x.objectKey(object)
+	if err := x.checkWritable(); err != nil {
+		return oi, err
+	}
+	if err := x.checkRateLimit(ctx, bucket); err != nil {
+		return oi, err
+	}
 	err := x.ledgerStore.AssertBucketExits(bucket)
 	if err != nil {
 		return oi, x.toMinioErr(err, bucket, object, uploadID)
 	}
 	m, unlock, err := x.ledgerStore.GetObjectDetails(uploadID)
+	if err == ErrInvalidUploadID {
+		// a retry of a completion that succeeded, but was not acknowledged to the client
+		if info, ok := x.completedMultipartUpload(ctx, bucket, object, uploadID, uploadedParts); ok {
+			return info, nil
+		}
+	}
 	if err != nil {
 		return oi, x.toMinioErr(err, bucket, object, uploadID)
 	}
 	defer unlock()
+	files := make([]fileLink, 0, len(uploadedParts))
+	numbers := make([]int, 0, len(uploadedParts))
+	parts := make([]ObjectPartInfo, 0, len(uploadedParts))
 	totalSize := uint64(0)
-	links := make([]*ipld.Link, 0, len(uploadedParts))
-	blocks := make([]uint64, 0, len(uploadedParts))
 	for _, p := range uploadedParts {
 		number := int64(p.PartNumber)
 		pi, ok := m.ObjectParts[number]
@@ -156,25 +276,19 @@ func (x *xObjects) CompleteMultipartUpload(
 		}
 		size := uint64(pi.ActualSize)
 		totalSize += size
-		links = append(links, &ipld.Link{
-			Size: size,
-			Cid:  cid,
+		files = append(files, fileLink{cid: cid, size: size})
+		numbers = append(numbers, p.PartNumber)
+		parts = append(parts, ObjectPartInfo{
+			Number:     number,
+			Size_:      pi.Size_,
+			ActualSize: pi.ActualSize,
+			DataHash:   pi.DataHash,
 		})
-		blocks = append(blocks, size)
 	}
-	protoNode := &merkledag.ProtoNode{}
-	protoNode.SetCidBuilder(merkledag.V1CidPrefix())
-	protoNode.SetLinks(links)
-	data, err := proto.Marshal(&unixfs_pb.Data{
-		Type:       unixfs_pb.Data_File.Enum(),
-		Filesize:   &totalSize,
-		Blocksizes: blocks,
-	})
-	if err != nil {
+	if err := verifyFileLinks(ctx, x.dagClient, files, numbers, x.completeConcurrency); err != nil {
 		return oi, x.toMinioErr(err, bucket, object, uploadID)
 	}
-	protoNode.SetData(data)
-	dataHash, err := ipfsSaveProtoNode(ctx, x.dagClient, protoNode)
+	dataHash, levels, err := assembleFileLinks(ctx, x.dagClient, files, x.partLinks(len(files)), x.completeConcurrency)
 	if err != nil {
 		return oi, x.toMinioErr(err, bucket, object, uploadID)
 	}
@@ -186,6 +300,13 @@ func (x *xObjects) CompleteMultipartUpload(
 		loi.Size_ = int64(totalSize)
 		loi.ModTime = time.Now().UTC()
 	}
+	if loi.UserDefined == nil {
+		loi.UserDefined = make(map[string]string)
+	}
+	loi.UserDefined[s3xMetaMultipartCompletion] = multipartCompletion(uploadID, uploadedParts)
+	loi.UserDefined[s3xMetaPartLevels] = strconv.Itoa(levels)
+	// parts are recorded so GET and HEAD can serve them by partNumber, see minio.PartNumberToRangeSpec
+	loi.Parts = parts
 	err = x.ledgerStore.PutObject(ctx, bucket, object, &Object{
 		DataHash:   dataHash,
 		ObjectInfo: *loi,
@@ -193,5 +314,157 @@ func (x *xObjects) CompleteMultipartUpload(
 	if err != nil {
 		return oi, x.toMinioErr(err, bucket, object, uploadID)
 	}
-	return getMinioObjectInfo(loi), x.AbortMultipartUpload(ctx, bucket, object, uploadID)
+	if err := x.ledgerStore.AbortMultipartUpload(bucket, uploadID); err != nil {
+		return oi, x.toMinioErr(err, bucket, object, uploadID)
+	}
+	x.metrics.add(&x.metrics.multipartCompleted, 1)
+	x.metrics.add(&x.metrics.multipartInFlight, -1)
+	return getObjectETagInfo(loi, dataHash), nil
+}
+
+// fileLink is a link to a unixfs file and the size of the file data
+type fileLink struct {
+	cid  cid.Cid
+	size uint64
+}
+
+// verifyFileLinks checks that every block of each part still resolves to data matching its cid,
+// so a part lost or corrupted by the backend fails the upload instead of producing a corrupt object.
+// The whole dag of each part is read, not only its root, since a part whose root survived can
+// still miss data blocks. A bad part is reported as minio.InvalidPart with the given part number.
+func verifyFileLinks(ctx context.Context, dag pb.NodeAPIClient, files []fileLink, numbers []int, concurrency int) error {
+	return runBounded(ctx, len(files), concurrency, func(ctx context.Context, i int) error {
+		want := files[i].cid
+		err := verifyDag(ctx, dag, want)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			return nil
+		}
+		if _, ok := pkgerrors.Cause(err).(minio.SlowDown); ok {
+			return err
+		}
+		invalid := minio.InvalidPart{PartNumber: numbers[i], ExpETag: want.String()}
+		if e, ok := err.(blockMismatchError); ok && e.want.Equals(want) {
+			invalid.GotETag = e.got.String()
+		}
+		return invalid
+	})
+}
+
+// blockMismatchError is returned by verifyDag for a block whose data doesn't match its cid
+type blockMismatchError struct {
+	want, got cid.Cid
+}
+
+func (e blockMismatchError) Error() string {
+	return fmt.Sprintf("block %v has data hashing to %v", e.want, e.got)
+}
+
+// verifyDag checks that every block of the dag under c resolves to data matching its cid,
+// the links of unixfs nodes are followed depth first
+func verifyDag(ctx context.Context, dag pb.NodeAPIClient, c cid.Cid) error {
+	data, err := ipfsBytes(ctx, dag, c.String())
+	if err != nil {
+		return err
+	}
+	got, err := c.Prefix().Sum(data)
+	if err != nil {
+		return err
+	}
+	if !got.Equals(c) {
+		return blockMismatchError{want: c, got: got}
+	}
+	if c.Type() != cid.DagProtobuf {
+		return nil
+	}
+	node, err := merkledag.DecodeProtobuf(data)
+	if err != nil {
+		return err
+	}
+	for _, link := range node.Links() {
+		if err := verifyDag(ctx, dag, link.Cid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assembleFileLinks joins the given unixfs files in order into a single unixfs file
+// and returns its hash, and the number of levels of nodes above the given files.
+//
+// If there are more than maxLinks files, intermediate nodes are created so that no node
+// has more than maxLinks links. The nodes of each level are saved concurrently by at most
+// concurrency workers.
+func assembleFileLinks(ctx context.Context, dag pb.NodeAPIClient, files []fileLink, maxLinks, concurrency int) (string, int, error) {
+	if maxLinks < 2 {
+		maxLinks = 2
+	}
+	levels := 1
+	for len(files) > maxLinks {
+		levels++
+		level := files
+		next := make([]fileLink, (len(level)+maxLinks-1)/maxLinks)
+		err := runBounded(ctx, len(next), concurrency, func(ctx context.Context, i int) error {
+			end := (i + 1) * maxLinks
+			if end > len(level) {
+				end = len(level)
+			}
+			hash, size, err := saveFileNode(ctx, dag, level[i*maxLinks:end])
+			if err != nil {
+				return err
+			}
+			c, err := cid.Decode(hash)
+			if err != nil {
+				return err
+			}
+			next[i] = fileLink{cid: c, size: size}
+			return nil
+		})
+		if err != nil {
+			return "", 0, err
+		}
+		files = next
+	}
+	hash, _, err := saveFileNode(ctx, dag, files)
+	return hash, levels, err
+}
+
+// partLinks returns the maximum number of links in a node of an assembly of n parts
+func (x *xObjects) partLinks(n int) int {
+	if x.partAssembly == PartAssemblySequential {
+		return n
+	}
+	return x.maxPartLinks
+}
+
+// saveFileNode saves a unixfs file node linking to the given files,
+// and returns its hash and the total size of the file data.
+func saveFileNode(ctx context.Context, dag pb.NodeAPIClient, files []fileLink) (string, uint64, error) {
+	totalSize := uint64(0)
+	links := make([]*ipld.Link, 0, len(files))
+	blocks := make([]uint64, 0, len(files))
+	for _, f := range files {
+		totalSize += f.size
+		links = append(links, &ipld.Link{
+			Size: f.size,
+			Cid:  f.cid,
+		})
+		blocks = append(blocks, f.size)
+	}
+	protoNode := &merkledag.ProtoNode{}
+	protoNode.SetCidBuilder(merkledag.V1CidPrefix())
+	protoNode.SetLinks(links)
+	data, err := proto.Marshal(&unixfs_pb.Data{
+		Type:       unixfs_pb.Data_File.Enum(),
+		Filesize:   &totalSize,
+		Blocksizes: blocks,
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	protoNode.SetData(data)
+	hash, err := ipfsSaveProtoNode(ctx, dag, protoNode)
+	return hash, totalSize, err
 }
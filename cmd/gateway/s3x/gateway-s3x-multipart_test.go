@@ -3,9 +3,18 @@ package s3x
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sync/atomic"
 	"testing"
 
+	pb "github.com/RTradeLtd/TxPB/v3/go"
 	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-merkledag"
+	"google.golang.org/grpc"
 )
 
 func TestS3X_Multipart_Badger(t *testing.T) {
@@ -84,3 +93,708 @@ func testS3XMultipart(t *testing.T, dsType DSType) {
 		}
 	})
 }
+
+func TestS3X_Multipart_Concurrency(t *testing.T) {
+	bucket := "my multipart bucket"
+	object := "my multipart object"
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, bucket, "us-east-1"); err != nil {
+		t.Fatal(err)
+	}
+	const (
+		parts       = 200
+		concurrency = 3
+	)
+	dag := &countingDagClient{NodeAPIClient: gateway.dagClient}
+	gateway.dagClient = dag
+	gateway.maxPartLinks = 10
+	gateway.completeConcurrency = concurrency
+
+	uID, err := gateway.NewMultipartUpload(ctx, bucket, object, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		uploadParts = make([]minio.CompletePart, 0, parts)
+		expected    = bytes.NewBuffer(nil)
+	)
+	for i := 1; i <= parts; i++ {
+		data := []byte(fmt.Sprintf("part%04d", i))
+		expected.Write(data)
+		pi, err := gateway.PutObjectPart(ctx, bucket, object, uID, i, getTestPutObjectReader(t, data), minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uploadParts = append(uploadParts, minio.CompletePart{PartNumber: pi.PartNumber, ETag: pi.ETag})
+	}
+	oi, err := gateway.CompleteMultipartUpload(ctx, bucket, object, uID, uploadParts, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oi.Size != int64(expected.Len()) {
+		t.Fatalf("expected file size %v, but received %v", expected.Len(), oi.Size)
+	}
+	if max := atomic.LoadInt64(&dag.maxInFlight); max > concurrency {
+		t.Fatalf("expected at most %v concurrent dag requests, but got %v", concurrency, max)
+	}
+	w := bytes.NewBuffer(nil)
+	if err := gateway.GetObject(ctx, bucket, object, 0, 0, w, "", minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(w.Bytes(), expected.Bytes()) {
+		t.Fatal("completed object content does not match the uploaded parts")
+	}
+}
+
+func TestS3X_Multipart_CopyObjectPart(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	src := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	srcInfo, err := gateway.PutObject(ctx, testBucket1, "source", getTestPutObjectReader(t, src), minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uID, err := gateway.NewMultipartUpload(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uploadParts []minio.CompletePart
+	for i, data := range []string{"first-", "", "-last"} {
+		var (
+			pi  minio.PartInfo
+			err error
+		)
+		if data == "" {
+			pi, err = gateway.CopyObjectPart(ctx, testBucket1, "source", testBucket1, testObject1, uID, i+1, 10, 16, srcInfo, minio.ObjectOptions{}, minio.ObjectOptions{})
+		} else {
+			pi, err = gateway.PutObjectPart(ctx, testBucket1, testObject1, uID, i+1, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		uploadParts = append(uploadParts, minio.CompletePart{PartNumber: pi.PartNumber, ETag: pi.ETag})
+	}
+	if _, err := gateway.CompleteMultipartUpload(ctx, testBucket1, testObject1, uID, uploadParts, minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	w := bytes.NewBuffer(nil)
+	if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, 0, w, "", minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "first-abcdefghijklmnop-last"; w.String() != want {
+		t.Fatalf("expected %q, but got %q", want, w.String())
+	}
+	t.Run("invalid range", func(t *testing.T) {
+		if _, err := gateway.CopyObjectPart(ctx, testBucket1, "source", testBucket1, testObject1, uID, 1, 30, 10, srcInfo, minio.ObjectOptions{}, minio.ObjectOptions{}); err == nil {
+			t.Fatal("expected error for range past the end of the source")
+		}
+	})
+}
+
+func TestS3X_Multipart_CopyObject(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	uID, err := gateway.NewMultipartUpload(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uploadParts []minio.CompletePart
+	for i := 1; i <= 3; i++ {
+		pi, err := gateway.PutObjectPart(ctx, testBucket1, testObject1, uID, i, getTestPutObjectReader(t, []byte(fmt.Sprintf("part%04d", i))), minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uploadParts = append(uploadParts, minio.CompletePart{PartNumber: pi.PartNumber, ETag: pi.ETag})
+	}
+	src, err := gateway.CompleteMultipartUpload(ctx, testBucket1, testObject1, uID, uploadParts, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := gateway.CopyObject(ctx, testBucket1, testObject1, testBucket1, "copy", minio.ObjectInfo{}, minio.ObjectOptions{}, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst.ETag != src.ETag {
+		t.Fatalf("expected copy ETag %v, but got %v", src.ETag, dst.ETag)
+	}
+	srcHash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstHash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, "copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dstHash != srcHash {
+		t.Fatalf("expected copy to reference root cid %v, but got %v", srcHash, dstHash)
+	}
+	info, err := gateway.GetObjectInfo(ctx, testBucket1, "copy", minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ETag != src.ETag {
+		t.Fatalf("expected copy ETag %v, but got %v", src.ETag, info.ETag)
+	}
+}
+
+func TestS3X_Multipart_MaxParts(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	const maxParts = 3
+	gateway.ledgerStore.maxParts = maxParts
+	uID, err := gateway.NewMultipartUpload(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	putPart := func(n int) error {
+		_, err := gateway.PutObjectPart(ctx, testBucket1, testObject1, uID, n, getTestPutObjectReader(t, []byte(fmt.Sprintf("part%d", n))), minio.ObjectOptions{})
+		return err
+	}
+	for i := 1; i <= maxParts; i++ {
+		if err := putPart(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := putPart(maxParts + 1); !isInvalidRequest(err, ErrTooManyParts) {
+		t.Fatalf("expected InvalidRequest for ErrTooManyParts, but got %v", err)
+	}
+	// replacing an existing part does not add a part
+	if err := putPart(1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestS3X_Multipart_MaxUploads(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for _, bucket := range []string{testBucket1, testBucket2} {
+		if err := gateway.MakeBucketWithLocation(ctx, bucket, "us-east-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const maxUploads = 2
+	// an upload in progress before the limit is set is counted
+	uID, err := gateway.NewMultipartUpload(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.ledgerStore.limitBucketUploads(maxUploads); err != nil {
+		t.Fatal(err)
+	}
+	newUpload := func(bucket string) (string, error) {
+		return gateway.NewMultipartUpload(ctx, bucket, testObject1, minio.ObjectOptions{})
+	}
+	// an upload that fails to be saved releases its slot
+	ds := gateway.ledgerStore.ds
+	gateway.ledgerStore.ds = &failingPutDatastore{Batching: ds}
+	if _, err := newUpload(testBucket1); err == nil {
+		t.Fatal("expected the upload to fail when it can't be saved")
+	}
+	gateway.ledgerStore.ds = ds
+	uID2, err := newUpload(testBucket1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newUpload(testBucket1); err != (minio.SlowDown{}) {
+		t.Fatalf("expected SlowDown at the limit, but got %v", err)
+	}
+	if _, err := newUpload(testBucket2); err != nil {
+		t.Fatalf("expected the limit to be per bucket, but got %v", err)
+	}
+	// completing an upload frees a slot
+	pi, err := gateway.PutObjectPart(ctx, testBucket1, testObject1, uID, 1, getTestPutObjectReader(t, []byte("part")), minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.CompleteMultipartUpload(ctx, testBucket1, testObject1, uID, []minio.CompletePart{{PartNumber: pi.PartNumber, ETag: pi.ETag}}, minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	uID3, err := newUpload(testBucket1)
+	if err != nil {
+		t.Fatalf("expected a slot after completing an upload, but got %v", err)
+	}
+	if _, err := newUpload(testBucket1); err != (minio.SlowDown{}) {
+		t.Fatalf("expected SlowDown at the limit, but got %v", err)
+	}
+	// aborting an upload frees a slot, but aborting it again does not
+	for i := 0; i < 2; i++ {
+		_ = gateway.AbortMultipartUpload(ctx, testBucket1, testObject1, uID2)
+	}
+	if _, err := newUpload(testBucket1); err != nil {
+		t.Fatalf("expected a slot after aborting an upload, but got %v", err)
+	}
+	if _, err := newUpload(testBucket1); err != (minio.SlowDown{}) {
+		t.Fatalf("expected SlowDown at the limit, but got %v", err)
+	}
+	if err := gateway.AbortMultipartUpload(ctx, testBucket1, testObject1, uID3); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// failingPutDatastore is a datastore that fails every put
+type failingPutDatastore struct {
+	datastore.Batching
+}
+
+func (ds *failingPutDatastore) Put(key datastore.Key, value []byte) error {
+	return errors.New("put failed")
+}
+
+func TestS3X_Multipart_UniqueIDs(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	ids := make([]string, 2)
+	for i := range ids {
+		id, err := gateway.NewMultipartUpload(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = id
+	}
+	if ids[0] == ids[1] {
+		t.Fatalf("expected distinct upload ids, but got %v twice", ids[0])
+	}
+	etags := make([]string, len(ids))
+	for i, id := range ids {
+		data := []byte(fmt.Sprintf("upload%d", i))
+		pi, err := gateway.PutObjectPart(ctx, testBucket1, testObject1, id, 1, getTestPutObjectReader(t, data), minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		etags[i] = minio.ToS3ETag(pi.ETag)
+	}
+	for i, id := range ids {
+		lpi, err := gateway.ListObjectParts(ctx, testBucket1, testObject1, id, 0, 0, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lpi.Parts) != 1 || lpi.Parts[0].ETag != etags[i] {
+			t.Fatalf("expected upload %v to keep its own part, but got %+v", id, lpi.Parts)
+		}
+	}
+	t.Run("existing id", func(t *testing.T) {
+		info := newObjectInfo(testBucket1, testObject1, 0, minio.ObjectOptions{})
+		err := gateway.ledgerStore.NewMultipartUploadWithID(ids[0], &info)
+		if err != ErrMultipartIDExists {
+			t.Fatalf("expected ErrMultipartIDExists, but got %v", err)
+		}
+		if err := gateway.toMinioErr(err, testBucket1, testObject1, ids[0]); !isInvalidRequest(err, ErrMultipartIDExists) {
+			t.Fatalf("expected InvalidRequest for ErrMultipartIDExists, but got %v", err)
+		}
+	})
+}
+
+func TestS3X_Multipart_LostPart(t *testing.T) {
+	bucket := "my multipart bucket"
+	object := "my multipart object"
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, bucket, "us-east-1"); err != nil {
+		t.Fatal(err)
+	}
+	uID, err := gateway.NewMultipartUpload(ctx, bucket, object, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uploadParts []minio.CompletePart
+	for i := 1; i <= 3; i++ {
+		data := []byte(fmt.Sprintf("part%04d", i))
+		if i == 2 {
+			// large enough to be chunked into several blocks
+			data = bytes.Repeat(data, 256*1024)
+		}
+		pi, err := gateway.PutObjectPart(ctx, bucket, object, uID, i, getTestPutObjectReader(t, data), minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uploadParts = append(uploadParts, minio.CompletePart{PartNumber: pi.PartNumber, ETag: pi.ETag})
+	}
+	m, unlock, err := gateway.ledgerStore.GetObjectDetails(uID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := m.ObjectParts[2].DataHash
+	unlock()
+	data, err := ipfsBytes(ctx, gateway.dagClient, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := merkledag.DecodeProtobuf(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(node.Links()) < 2 {
+		t.Fatalf("expected part 2 to be chunked, but its root has %v links", len(node.Links()))
+	}
+	dag := gateway.dagClient
+	for name, lost := range map[string]string{
+		"root":  root,
+		"block": node.Links()[1].Cid.String(),
+	} {
+		gateway.dagClient = &lostDagClient{NodeAPIClient: dag, lost: lost}
+		_, err = gateway.CompleteMultipartUpload(ctx, bucket, object, uID, uploadParts, minio.ObjectOptions{})
+		if e, ok := err.(minio.InvalidPart); !ok || e.PartNumber != 2 {
+			t.Fatalf("expected InvalidPart naming part 2 with a lost %s, but got %v", name, err)
+		}
+		if _, err := gateway.GetObjectInfo(ctx, bucket, object, minio.ObjectOptions{}); err == nil {
+			t.Fatalf("expected no object after failed completion with a lost %s", name)
+		}
+	}
+	gateway.dagClient = dag
+	if _, err := gateway.CompleteMultipartUpload(ctx, bucket, object, uID, uploadParts, minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// lostDagClient wraps a NodeAPIClient and fails gets of a lost hash
+type lostDagClient struct {
+	pb.NodeAPIClient
+	lost string
+}
+
+func (c *lostDagClient) Dag(ctx context.Context, in *pb.DagRequest, opts ...grpc.CallOption) (*pb.DagResponse, error) {
+	if in.GetRequestType() == pb.DAGREQTYPE_DAG_GET && in.GetHash() == c.lost {
+		return nil, errors.New("block not found")
+	}
+	return c.NodeAPIClient.Dag(ctx, in, opts...)
+}
+
+func TestS3X_Multipart_MaxPartSize(t *testing.T) {
+	bucket := "my multipart bucket"
+	object := "my multipart object"
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	gateway.maxPartSize = 1024
+	if err := gateway.MakeBucketWithLocation(ctx, bucket, "us-east-1"); err != nil {
+		t.Fatal(err)
+	}
+	uID, err := gateway.NewMultipartUpload(ctx, bucket, object, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("declared size", func(t *testing.T) {
+		src := &countingReader{r: bytes.NewReader(make([]byte, 2048))}
+		r := minio.NewPutObjReader(getTestHashReader(t, src, 2048), nil, nil)
+		if _, err := gateway.PutObjectPart(ctx, bucket, object, uID, 1, r, minio.ObjectOptions{}); err != (minio.PartTooBig{}) {
+			t.Fatalf("expected PartTooBig, but got %v", err)
+		}
+		if src.n != 0 {
+			t.Fatalf("expected no data to be read, but read %v bytes", src.n)
+		}
+	})
+	t.Run("streamed", func(t *testing.T) {
+		const total = 64 << 20
+		src := &countingReader{r: bytes.NewReader(make([]byte, total))}
+		r := minio.NewPutObjReader(getTestHashReader(t, src, -1), nil, nil)
+		if _, err := gateway.PutObjectPart(ctx, bucket, object, uID, 1, r, minio.ObjectOptions{}); err != (minio.PartTooBig{}) {
+			t.Fatalf("expected PartTooBig, but got %v", err)
+		}
+		if src.n >= total/2 {
+			t.Fatalf("expected the upload to stop early, but read %v of %v bytes", src.n, total)
+		}
+	})
+	t.Run("within limit", func(t *testing.T) {
+		pi, err := gateway.PutObjectPart(ctx, bucket, object, uID, 1, getTestPutObjectReader(t, make([]byte, 1024)), minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pi.Size != 1024 {
+			t.Fatalf("expected part of 1024 bytes, but got %v", pi.Size)
+		}
+	})
+}
+
+func TestS3X_Multipart_CompleteRetry(t *testing.T) {
+	bucket := "my multipart bucket"
+	object := "my multipart object"
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, bucket, "us-east-1"); err != nil {
+		t.Fatal(err)
+	}
+	uID, err := gateway.NewMultipartUpload(ctx, bucket, object, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uploadParts []minio.CompletePart
+	for i := 1; i <= 3; i++ {
+		pi, err := gateway.PutObjectPart(ctx, bucket, object, uID, i, getTestPutObjectReader(t, []byte(fmt.Sprintf("part%04d", i))), minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uploadParts = append(uploadParts, minio.CompletePart{PartNumber: pi.PartNumber, ETag: pi.ETag})
+	}
+	first, err := gateway.CompleteMultipartUpload(ctx, bucket, object, uID, uploadParts, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := gateway.CompleteMultipartUpload(ctx, bucket, object, uID, uploadParts, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal("expected retry to succeed, but got", err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("expected the same object info, but got %+v and %+v", first, second)
+	}
+	if _, err := gateway.CompleteMultipartUpload(ctx, bucket, object, uID, uploadParts[:2], minio.ObjectOptions{}); err == nil {
+		t.Fatal("expected completion with other parts to fail")
+	}
+	if _, err := gateway.CompleteMultipartUpload(ctx, bucket, object, "unknown", uploadParts, minio.ObjectOptions{}); err == nil {
+		t.Fatal("expected completion of another upload to fail")
+	}
+}
+
+func TestS3X_Multipart_Range(t *testing.T) {
+	bucket := "my multipart bucket"
+	object := "my multipart object"
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	gateway.maxPartLinks = 2 // assemble with intermediate nodes
+	if err := gateway.MakeBucketWithLocation(ctx, bucket, "us-east-1"); err != nil {
+		t.Fatal(err)
+	}
+	uID, err := gateway.NewMultipartUpload(ctx, bucket, object, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		data        []byte
+		uploadParts []minio.CompletePart
+	)
+	for i := 1; i <= 5; i++ {
+		part := bytes.Repeat([]byte{byte('a' + i)}, 1000)
+		pi, err := gateway.PutObjectPart(ctx, bucket, object, uID, i, getTestPutObjectReader(t, part), minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, part...)
+		uploadParts = append(uploadParts, minio.CompletePart{PartNumber: pi.PartNumber, ETag: pi.ETag})
+	}
+	if _, err := gateway.CompleteMultipartUpload(ctx, bucket, object, uID, uploadParts, minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	files := &downloadRecordingFileClient{FileAPIClient: gateway.fileClient}
+	gateway.fileClient = files
+
+	t.Run("straddling two parts", func(t *testing.T) {
+		files.hashes = nil
+		buf := bytes.NewBuffer(nil)
+		if err := gateway.GetObject(ctx, bucket, object, 1500, 1000, buf, "", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), data[1500:2500]) {
+			t.Fatal("bad data")
+		}
+		want := []string{uploadParts[1].ETag, uploadParts[2].ETag}
+		if !reflect.DeepEqual(files.hashes, want) {
+			t.Fatalf("expected only parts 2 and 3 to be downloaded, but got %v", files.hashes)
+		}
+	})
+	for _, r := range [][2]int64{{0, 1}, {999, 2}, {0, 4999}, {1, 0}, {4000, 0}, {4999, 1}, {250, 3500}} {
+		t.Run(fmt.Sprintf("range %v-%v", r[0], r[1]), func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			if err := gateway.GetObject(ctx, bucket, object, r[0], r[1], buf, "", minio.ObjectOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			want := data[r[0]:]
+			if r[1] != 0 {
+				want = want[:r[1]]
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Fatal("bad data")
+			}
+		})
+	}
+}
+
+func TestS3X_Multipart_PartNumber(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	uID, err := gateway.NewMultipartUpload(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		parts       [][]byte
+		uploadParts []minio.CompletePart
+	)
+	for i := 1; i <= 3; i++ {
+		part := bytes.Repeat([]byte{byte('a' + i)}, 1000*i)
+		pi, err := gateway.PutObjectPart(ctx, testBucket1, testObject1, uID, i, getTestPutObjectReader(t, part), minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, part)
+		uploadParts = append(uploadParts, minio.CompletePart{PartNumber: pi.PartNumber, ETag: pi.ETag})
+	}
+	if _, err := gateway.CompleteMultipartUpload(ctx, testBucket1, testObject1, uID, uploadParts, minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	files := &downloadRecordingFileClient{FileAPIClient: gateway.fileClient}
+	gateway.fileClient = files
+
+	// parts are read like the handlers serve partNumber, as the range of the part
+	info, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Parts) != 3 {
+		t.Fatalf("expected 3 parts, but got %v", len(info.Parts))
+	}
+	readPart := func(t *testing.T, partNumber int) []byte {
+		rs, err := minio.PartNumberToRangeSpec(info, partNumber)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gr, err := gateway.GetObjectNInfo(ctx, testBucket1, testObject1, rs, nil, 0, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer gr.Close()
+		got, err := ioutil.ReadAll(gr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	t.Run("part 2", func(t *testing.T) {
+		rs, err := minio.PartNumberToRangeSpec(info, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rs.Start != 1000 || rs.End != 2999 {
+			t.Fatalf("expected part 2 at bytes 1000-2999, but got %v-%v", rs.Start, rs.End)
+		}
+		files.hashes = nil
+		if got := readPart(t, 2); !bytes.Equal(got, parts[1]) {
+			t.Fatalf("expected the data of part 2, but got %v bytes", len(got))
+		}
+		if want := []string{uploadParts[1].ETag}; !reflect.DeepEqual(files.hashes, want) {
+			t.Fatalf("expected only part 2 to be downloaded, but got %v", files.hashes)
+		}
+	})
+	t.Run("all parts", func(t *testing.T) {
+		for i, part := range parts {
+			if got := readPart(t, i+1); !bytes.Equal(got, part) {
+				t.Fatalf("expected the data of part %v, but got %v bytes", i+1, len(got))
+			}
+		}
+	})
+	t.Run("invalid part", func(t *testing.T) {
+		_, err := minio.PartNumberToRangeSpec(info, 4)
+		if _, ok := err.(minio.InvalidPartNumber); !ok {
+			t.Fatal("expected error InvalidPartNumber, but got", err)
+		}
+	})
+}
+
+// downloadRecordingFileClient records the hashes of file downloads
+type downloadRecordingFileClient struct {
+	pb.FileAPIClient
+	hashes []string
+}
+
+func (c *downloadRecordingFileClient) DownloadFile(ctx context.Context, in *pb.DownloadRequest, opts ...grpc.CallOption) (pb.FileAPI_DownloadFileClient, error) {
+	c.hashes = append(c.hashes, in.GetHash())
+	return c.FileAPIClient.DownloadFile(ctx, in, opts...)
+}
+
+func TestS3X_Multipart_Assembly(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	const parts = 1000
+	gateway.maxPartLinks = 10
+	var data []byte
+	for i := 1; i <= parts; i++ {
+		data = append(data, fmt.Sprintf("part%04d", i)...)
+	}
+	partSize := len(data) / parts
+	tests := []struct {
+		assembly PartAssembly
+		levels   int
+	}{
+		{PartAssemblyBalanced, 3},
+		{PartAssemblySequential, 1},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.assembly), func(t *testing.T) {
+			gateway.partAssembly = tt.assembly
+			object := string(tt.assembly)
+			uID, err := gateway.NewMultipartUpload(ctx, testBucket1, object, minio.ObjectOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			uploadParts := make([]minio.CompletePart, 0, parts)
+			for i := 1; i <= parts; i++ {
+				part := data[(i-1)*partSize : i*partSize]
+				pi, err := gateway.PutObjectPart(ctx, testBucket1, object, uID, i, getTestPutObjectReader(t, part), minio.ObjectOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				uploadParts = append(uploadParts, minio.CompletePart{PartNumber: pi.PartNumber, ETag: pi.ETag})
+			}
+			if _, err := gateway.CompleteMultipartUpload(ctx, testBucket1, object, uID, uploadParts, minio.ObjectOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			info, err := gateway.ledgerStore.ObjectInfo(ctx, testBucket1, object)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if levels := info.partLevels(); levels != tt.levels {
+				t.Fatalf("expected %v levels of nodes above the parts, but got %v", tt.levels, levels)
+			}
+			// a range in the middle of the object, over part boundaries
+			start, length := int64(len(data)/2-partSize/2), int64(3*partSize)
+			buf := bytes.NewBuffer(nil)
+			if err := gateway.GetObject(ctx, testBucket1, object, start, length, buf, "", minio.ObjectOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), data[start:start+length]) {
+				t.Fatalf("expected range %q, but got %q", data[start:start+length], buf.Bytes())
+			}
+			buf.Reset()
+			if err := gateway.GetObject(ctx, testBucket1, object, 0, 0, buf, "", minio.ObjectOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), data) {
+				t.Fatal("completed object content does not match the uploaded parts")
+			}
+		})
+	}
+}
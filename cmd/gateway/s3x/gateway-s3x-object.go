@@ -2,13 +2,20 @@ package s3x
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/cmd/crypto"
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
 )
 
 // ListObjects lists all blobs in S3 bucket filtered by prefix
@@ -17,14 +24,28 @@ func (x *xObjects) ListObjects(
 	bucket, prefix, marker, delimiter string,
 	maxKeys int,
 ) (loi minio.ListObjectsInfo, e error) {
+	if err := x.checkRateLimit(ctx, bucket); err != nil {
+		return loi, err
+	}
+	delimiter, err := x.listDelimiter(ctx, bucket, delimiter)
+	if err != nil {
+		return loi, err
+	}
 	// TODO(bonedaddy): implement complex search (George: prefix implemented)
-	objs, err := x.ledgerStore.GetObjectInfos(ctx, bucket, prefix, "", 0)
+	objs, prefixes, err := x.ledgerStore.GetObjectsDelimited(ctx, bucket, prefix, "", delimiter, 0)
 	if err != nil {
 		return loi, x.toMinioErr(err, bucket, "", "")
 	}
 	loi.Objects = make([]minio.ObjectInfo, 0, len(objs))
 	for _, obj := range objs {
-		loi.Objects = append(loi.Objects, getMinioObjectInfo(&obj))
+		loi.Objects = append(loi.Objects, getObjectETagInfo(&obj.ObjectInfo, obj.GetDataHash()))
+	}
+	loi.Prefixes = prefixes
+	if x.listUploads {
+		loi.Objects, loi.Prefixes, err = x.mergeUploadsInProgress(bucket, prefix, "", delimiter, loi.Objects, loi.Prefixes)
+		if err != nil {
+			return loi, x.toMinioErr(err, bucket, "", "")
+		}
 	}
 	// TODO(bonedaddy): consider if we should use the following helper func
 	// return minio.FromMinioClientListBucketResult(bucket, result), nil
@@ -39,13 +60,27 @@ func (x *xObjects) ListObjectsV2(
 	fetchOwner bool,
 	startAfter string,
 ) (loi minio.ListObjectsV2Info, err error) {
-	objs, err := x.ledgerStore.GetObjectInfos(ctx, bucket, prefix, startAfter, 1000)
+	if err := x.checkRateLimit(ctx, bucket); err != nil {
+		return loi, err
+	}
+	delimiter, err = x.listDelimiter(ctx, bucket, delimiter)
+	if err != nil {
+		return loi, err
+	}
+	objs, prefixes, err := x.ledgerStore.GetObjectsDelimited(ctx, bucket, prefix, startAfter, delimiter, 1000)
 	if err != nil {
 		return loi, x.toMinioErr(err, bucket, "", "")
 	}
 	loi.Objects = make([]minio.ObjectInfo, 0, len(objs))
 	for _, obj := range objs {
-		loi.Objects = append(loi.Objects, getMinioObjectInfo(&obj))
+		loi.Objects = append(loi.Objects, getObjectETagInfo(&obj.ObjectInfo, obj.GetDataHash()))
+	}
+	loi.Prefixes = prefixes
+	if x.listUploads {
+		loi.Objects, loi.Prefixes, err = x.mergeUploadsInProgress(bucket, prefix, startAfter, delimiter, loi.Objects, loi.Prefixes)
+		if err != nil {
+			return loi, x.toMinioErr(err, bucket, "", "")
+		}
 	}
 	return loi, nil
 }
@@ -59,18 +94,38 @@ func (x *xObjects) GetObjectNInfo(
 	lockType minio.LockType,
 	opts minio.ObjectOptions,
 ) (gr *minio.GetObjectReader, err error) {
-	objinfo, err := x.GetObjectInfo(ctx, bucket, object, opts)
+	object = x.objectKey(object)
+	if err := x.checkRateLimit(ctx, bucket); err != nil {
+		return gr, err
+	}
+	if rs == nil && opts.CheckCopyPrecondFn == nil && acceptsGzip(h) {
+		// compressed objects are sent as stored to clients accepting gzip, copies read the plain data
+		obj, err := x.ledgerStore.Object(ctx, bucket, object)
+		if err != nil {
+			return gr, x.toMinioErr(err, bucket, object, "")
+		}
+		if obj.ObjectInfo.servedCompressed() {
+			return x.getObjectNInfoGzip(ctx, bucket, object, obj, opts)
+		}
+	}
+	objinfo, err := x.getObjectInfo(ctx, bucket, object)
 	if err != nil {
 		return gr, err // the error from this is already properly converted
 	}
+	if crypto.IsEncrypted(objinfo.UserDefined) {
+		return x.getObjectNInfoDecrypted(ctx, bucket, object, rs, h, objinfo, opts)
+	}
 	var startOffset, length int64
 	startOffset, length, err = rs.GetOffsetLength(objinfo.Size)
 	if err != nil {
 		return nil, err
 	}
+	if rs != nil && length <= maxRangeChecksumSize && wantsRangeChecksum(h) {
+		return x.getObjectNInfoChecksummed(ctx, bucket, object, objinfo, startOffset, length, opts)
+	}
 	pr, pw := io.Pipe()
 	go func() {
-		err := x.GetObject(ctx, bucket, object, startOffset, length, pw, objinfo.ETag, opts)
+		err := x.getObject(ctx, bucket, object, startOffset, length, pw)
 		_ = pw.CloseWithError(err)
 	}()
 	// Setup cleanup function to cause the above go-routine to
@@ -93,10 +148,29 @@ func (x *xObjects) GetObject(
 	etag string,
 	opts minio.ObjectOptions,
 ) error {
-	fileHash, size, err := x.ledgerStore.GetObjectDataHash(ctx, bucket, object)
+	object = x.objectKey(object)
+	if err := x.checkRateLimit(ctx, bucket); err != nil {
+		return err
+	}
+	return x.getObject(ctx, bucket, object, startOffset, length, writer)
+}
+
+func (x *xObjects) getObject(ctx context.Context, bucket, object string, startOffset, length int64, writer io.Writer) (err error) {
+	rl := newRequestLog("GetObject", bucket, object)
+	cw := &countingWriter{w: writer, metrics: x.metrics}
+	writer = cw
+	defer func() {
+		rl.bytes = cw.n
+		x.logRequest(ctx, rl, err)
+	}()
+	obj, err := x.ledgerStore.Object(ctx, bucket, object)
 	if err != nil {
 		return x.toMinioErr(err, bucket, object, "")
 	}
+	rl.cid = obj.GetDataHash()
+	x.pinOnRead(obj.GetDataHash())
+	x.recordAccess(ctx, bucket, object)
+	size := obj.ObjectInfo.GetSize_()
 	if size < startOffset+length {
 		return minio.InvalidRange{
 			OffsetBegin:  startOffset,
@@ -104,7 +178,74 @@ func (x *xObjects) GetObject(
 			ResourceSize: size,
 		}
 	}
-	if _, err := ipfsFileDownload(ctx, x.fileClient, writer, fileHash, startOffset, length); err != nil {
+	if data, ok, err := obj.ObjectInfo.inlineData(); ok || err != nil {
+		if err != nil {
+			return x.toMinioErr(err, bucket, object, "")
+		}
+		if length == 0 {
+			length = size - startOffset
+		}
+		if int64(len(data)) < startOffset+length {
+			return minio.InvalidRange{
+				OffsetBegin:  startOffset,
+				OffsetEnd:    startOffset + length,
+				ResourceSize: int64(len(data)),
+			}
+		}
+		_, err = writer.Write(data[startOffset : startOffset+length])
+		return err
+	}
+	// the data in ipfs is checked against the size in the ledger as it's served
+	expected := length
+	if expected == 0 {
+		expected = size - startOffset
+	}
+	sw := &sizeCheckWriter{
+		w:         writer,
+		remaining: expected,
+		err:       ObjectSizeMismatch{Bucket: bucket, Object: object, Size: size},
+	}
+	writer = sw
+	defer func() {
+		if err == nil {
+			err = sw.check()
+		}
+	}()
+	if startOffset == 0 && length == size {
+		// full reads download all the data, so data past the recorded size is detected
+		length = 0
+	}
+	if levels := obj.ObjectInfo.partLevels(); levels > 0 && (startOffset != 0 || (length != 0 && length != size)) {
+		// only the parts of multipart objects overlapping a range are read
+		if length == 0 {
+			length = size - startOffset
+		}
+		if _, err := ipfsPartsDownload(ctx, x.dagClient, x.fileClient, writer, obj.GetDataHash(), levels, startOffset, length); err != nil {
+			return x.toMinioErr(err, bucket, object, "")
+		}
+		return nil
+	}
+	chain, err := x.readTransformers(&obj.ObjectInfo)
+	if err != nil {
+		return x.toMinioErr(err, bucket, object, "")
+	}
+	download := ipfsFileDownload
+	if len(chain) != 0 {
+		download = transformedDownload(chain)
+	}
+	if x.readAhead <= 0 || startOffset != 0 || (length != 0 && length != size) {
+		// range reads are usually small or random, so they are not read ahead
+		if _, err := download(ctx, x.fileClient, writer, obj.GetDataHash(), startOffset, length); err != nil {
+			return x.toMinioErr(err, bucket, object, "")
+		}
+		return nil
+	}
+	aw := newAheadWriter(writer, x.readAhead)
+	_, err = download(ctx, x.fileClient, aw, obj.GetDataHash(), startOffset, length)
+	if cerr := aw.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
 		return x.toMinioErr(err, bucket, object, "")
 	}
 	return nil
@@ -116,8 +257,117 @@ func (x *xObjects) GetObjectInfo(
 	bucket, object string,
 	opts minio.ObjectOptions,
 ) (objInfo minio.ObjectInfo, err error) {
-	oi, err := x.ledgerStore.ObjectInfo(ctx, bucket, object)
-	return getMinioObjectInfo(oi), x.toMinioErr(err, bucket, object, "")
+	object = x.objectKey(object)
+	if err := x.checkRateLimit(ctx, bucket); err != nil {
+		return objInfo, err
+	}
+	if opts.AcceptEncoding != "" && acceptsGzip(http.Header{xhttp.AcceptEncoding: {opts.AcceptEncoding}}) {
+		// a HEAD reports the size of the compressed data that a GET of the client is served
+		obj, err := x.ledgerStore.Object(ctx, bucket, object)
+		if err != nil {
+			return objInfo, x.toMinioErr(err, bucket, object, "")
+		}
+		if obj.ObjectInfo.servedCompressed() {
+			return getObjectGzipInfo(obj), nil
+		}
+		return getObjectETagInfo(&obj.ObjectInfo, obj.GetDataHash()), nil
+	}
+	return x.getObjectInfo(ctx, bucket, object)
+}
+
+func (x *xObjects) getObjectInfo(ctx context.Context, bucket, object string) (minio.ObjectInfo, error) {
+	obj, err := x.ledgerStore.Object(ctx, bucket, object)
+	if err != nil {
+		return minio.ObjectInfo{}, x.toMinioErr(err, bucket, object, "")
+	}
+	return getObjectETagInfo(&obj.ObjectInfo, obj.GetDataHash()), nil
+}
+
+// getObjectETagInfo returns the minio object info of an object, using the data hash
+// as ETag if none is set, so that conditional requests can be answered from the ledger.
+func getObjectETagInfo(o *ObjectInfo, dataHash string) minio.ObjectInfo {
+	info := getMinioObjectInfo(o)
+	if o.GetEtag() == "" {
+		info.ETag = minio.ToS3ETag(dataHash)
+	}
+	return info
+}
+
+// ResolveObjectForPresign returns the minimal object information needed to serve
+// a presigned GET: bucket, name, size, content type and ETag (the object data hash).
+// It only consults the ledger and does not depend on any request authentication.
+func (x *xObjects) ResolveObjectForPresign(ctx context.Context, bucket, object string) (minio.ObjectInfo, error) {
+	object = x.objectKey(object)
+	obj, err := x.ledgerStore.Object(ctx, bucket, object)
+	if err != nil {
+		return minio.ObjectInfo{}, x.toMinioErr(err, bucket, object, "")
+	}
+	return minio.ObjectInfo{
+		Bucket:      bucket,
+		Name:        object,
+		Size:        obj.ObjectInfo.GetSize_(),
+		ContentType: obj.ObjectInfo.GetContentType(),
+		ETag:        minio.ToS3ETag(obj.GetDataHash()),
+		ModTime:     obj.ObjectInfo.GetModTime(),
+	}, nil
+}
+
+// GetObjectRedirectURL returns the ipfs http gateway url of the object data if it's public, objects that
+// are not readable by anonymous users, compressed, transformed, encrypted or stored inline are not redirected.
+func (x *xObjects) GetObjectRedirectURL(ctx context.Context, bucket, object string) (string, error) {
+	object = x.objectKey(object)
+	if x.ipfsGatewayURL == "" {
+		return "", nil
+	}
+	obj, err := x.ledgerStore.Object(ctx, bucket, object)
+	if err != nil {
+		return "", x.toMinioErr(err, bucket, object, "")
+	}
+	if _, inline := obj.ObjectInfo.GetUserDefined()[s3xMetaInlineData]; inline || obj.ObjectInfo.isCompressed() || obj.ObjectInfo.isTransformed() {
+		return "", nil
+	}
+	if crypto.IsEncrypted(obj.ObjectInfo.GetUserDefined()) {
+		// the gateway would serve the ciphertext
+		return "", nil
+	}
+	public, err := x.isPublicObject(ctx, bucket, object)
+	if err != nil || !public {
+		return "", err
+	}
+	x.pinOnRead(obj.GetDataHash())
+	x.recordAccess(ctx, bucket, object)
+	return x.ipfsGatewayURL + "/ipfs/" + obj.GetDataHash(), nil
+}
+
+// checkObjectName validates an object key before it's saved in the ledger,
+// rejecting keys that are too long, contain control characters, or are otherwise
+// not valid S3 object names.
+func (x *xObjects) checkObjectName(bucket, object string) error {
+	if object == "" || len(object) > x.maxKeyLength || !minio.IsValidObjectPrefix(object) {
+		return minio.ObjectNameInvalid{Bucket: bucket, Object: object}
+	}
+	for _, r := range object {
+		if unicode.IsControl(r) {
+			return minio.ObjectNameInvalid{Bucket: bucket, Object: object}
+		}
+	}
+	return nil
+}
+
+// checkMetadataSize rejects user metadata whose keys and values are larger than
+// TEMX.MaxMetadataSize in total, as S3 does, so the ledger entries stay small.
+func (x *xObjects) checkMetadataSize(bucket, object string, meta map[string]string) error {
+	var size int
+	for k, v := range meta {
+		key := strings.ToLower(k)
+		if strings.HasPrefix(key, "x-amz-meta-") || strings.HasPrefix(key, "x-minio-meta-") {
+			size += len(k) + len(v)
+		}
+	}
+	if size > x.maxMetadataSize {
+		return minio.MetadataTooLarge{Bucket: bucket, Object: object}
+	}
+	return nil
 }
 
 //newObjectInfo create an ObjectInfo
@@ -131,19 +381,108 @@ func newObjectInfo(bucket, object string, size int, opts minio.ObjectOptions) Ob
 	if !isTest { // creates consistent hashes for testing
 		obinfo.ModTime = time.Now().UTC()
 	}
-	for k, v := range opts.UserDefined {
+	obinfo.setMetadata(opts.UserDefined)
+	return obinfo
+}
+
+// setMetadata replaces the content headers and user defined metadata of the object
+// with the given metadata, internal metadata of the object is preserved.
+func (m *ObjectInfo) setMetadata(meta map[string]string) {
+	m.ContentEncoding = ""
+	m.ContentDisposition = ""
+	m.Expires = ""
+	m.ContentLanguage = ""
+	m.ContentType = ""
+	userDefined := make(map[string]string)
+	for k, v := range m.UserDefined {
+		if strings.HasPrefix(k, minio.ReservedMetadataPrefix) {
+			userDefined[k] = v
+		}
+	}
+	for k, v := range meta {
 		switch strings.ToLower(k) {
 		case "content-encoding":
-			obinfo.ContentEncoding = v
+			m.ContentEncoding = v
 		case "content-disposition":
-			obinfo.ContentDisposition = v
+			m.ContentDisposition = encodeContentDisposition(v)
+		case "expires":
+			m.Expires = v
 		case "content-language":
-			obinfo.ContentLanguage = v
+			m.ContentLanguage = v
 		case "content-type":
-			obinfo.ContentType = v
+			m.ContentType = v
+		default:
+			if !strings.HasPrefix(k, minio.ReservedMetadataPrefix) {
+				userDefined[k] = v
+			}
 		}
 	}
-	return obinfo
+	if len(userDefined) == 0 {
+		userDefined = nil // keep the hash of objects without metadata consistent
+	}
+	m.UserDefined = userDefined
+}
+
+// encodeContentDisposition encodes a non ascii filename of a content disposition as described
+// in RFC 6266, with an utf-8 filename* parameter and an ascii fallback filename for old clients.
+// Dispositions that can't be parsed, or have an ascii filename, are returned unchanged.
+func encodeContentDisposition(v string) string {
+	disposition, params, err := mime.ParseMediaType(v)
+	if err != nil {
+		return v
+	}
+	filename, ok := params["filename"]
+	if !ok || isASCII(filename) {
+		return v
+	}
+	delete(params, "filename")
+	var fallback, encoded strings.Builder
+	for _, r := range filename {
+		if r < utf8.RuneSelf && r != '"' && r != '\\' && unicode.IsPrint(r) {
+			fallback.WriteRune(r)
+		} else {
+			fallback.WriteByte('_')
+		}
+	}
+	for _, b := range []byte(filename) {
+		if isAttrChar(b) {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, mime.FormatMediaType(disposition, params), fallback.String(), encoded.String())
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// isAttrChar returns true for the characters that are not percent encoded in RFC 5987 values
+func isAttrChar(b byte) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
+
+// setChecksums records the validated additional checksums of the object data in meta.
+func (m *ObjectInfo) setChecksums(meta map[string]string) {
+	for k, v := range meta {
+		if !strings.HasPrefix(strings.ToLower(k), xhttp.AmzChecksumPrefix) {
+			continue
+		}
+		if m.UserDefined == nil {
+			m.UserDefined = make(map[string]string)
+		}
+		m.UserDefined[strings.ToLower(k)] = v
+	}
 }
 
 // PutObject creates a new object with the incoming data
@@ -153,16 +492,50 @@ func (x *xObjects) PutObject(
 	bucket, object string,
 	r *minio.PutObjReader,
 	opts minio.ObjectOptions,
-) (minio.ObjectInfo, error) {
-	err := x.ledgerStore.AssertBucketExits(bucket)
+) (objInfo minio.ObjectInfo, err error) {
+	object = x.objectKey(object)
+	rl := newRequestLog("PutObject", bucket, object)
+	defer func() { x.logRequest(ctx, rl, err) }()
+	if err := x.checkObjectName(bucket, object); err != nil {
+		return minio.ObjectInfo{}, err
+	}
+	if err := x.checkMetadataSize(bucket, object, opts.UserDefined); err != nil {
+		return minio.ObjectInfo{}, err
+	}
+	if err := x.checkWritable(); err != nil {
+		return minio.ObjectInfo{}, err
+	}
+	if err := x.checkRateLimit(ctx, bucket); err != nil {
+		return minio.ObjectInfo{}, err
+	}
+	err = x.ledgerStore.AssertBucketExits(bucket)
 	if err != nil {
 		return minio.ObjectInfo{}, x.toMinioErr(err, bucket, "", "")
 	}
-	hash, size, err := ipfsFileUpload(ctx, x.fileClient, r)
+	if err := x.checkObjectEncryption(bucket, object, opts.UserDefined); err != nil {
+		return minio.ObjectInfo{}, err
+	}
+	obinfo := newObjectInfo(bucket, object, 0, opts)
+	obinfo.setEncryptionMetadata(opts.UserDefined)
+	if err := x.applyDefaultContentType(ctx, &obinfo); err != nil {
+		return minio.ObjectInfo{}, err
+	}
+	if err := obinfo.applyObjectTTL(time.Now()); err != nil {
+		return minio.ObjectInfo{}, err
+	}
+	token := obinfo.uploadToken()
+	var hash string
+	if x.shouldInline(r.Size()) {
+		hash, err = inlineObjectData(r, &obinfo)
+	} else {
+		hash, err = x.uploadObjectData(ctx, bucket, token, r, &obinfo)
+	}
 	if err != nil {
 		return minio.ObjectInfo{}, x.toMinioErr(err, bucket, object, "")
 	}
-	obinfo := newObjectInfo(bucket, object, size, opts)
+	rl.cid, rl.bytes = hash, obinfo.GetSize_()
+	// checksums sent as trailers are only known once the data is consumed
+	obinfo.setChecksums(opts.UserDefined)
 	err = x.ledgerStore.PutObject(ctx, bucket, object, &Object{
 		DataHash:   hash,
 		ObjectInfo: obinfo,
@@ -170,8 +543,56 @@ func (x *xObjects) PutObject(
 	if err != nil {
 		return minio.ObjectInfo{}, x.toMinioErr(err, bucket, object, "")
 	}
+	if token != "" {
+		// the object is saved, a checkpoint left behind only takes space until the bucket is deleted
+		_ = x.ledgerStore.DeleteUploadCheckpoints(bucket, token)
+	}
 	log.Printf("bucket-name: %s, object-name: %s, file-hash: %s", bucket, object, hash)
-	return getMinioObjectInfo(&obinfo), nil
+	return getObjectETagInfo(&obinfo, hash), nil
+}
+
+// uploadObjectData adds the object data to ipfs, and returns the data hash.
+// The object size and compression information is updated in obinfo.
+// If token is not empty the data is added resumably, see fileUploadResumable, otherwise
+// it's added in concurrent segments if addConcurrency is set, see fileUploadParallel.
+func (x *xObjects) uploadObjectData(ctx context.Context, bucket, token string, r io.Reader, obinfo *ObjectInfo) (string, error) {
+	counter := &countingReader{r: r}
+	chain := x.writeTransformers(obinfo.ContentType)
+	data, done := wrapReader(counter, chain)
+	defer done()
+	var (
+		hash string
+		size int
+		err  error
+	)
+	switch {
+	case token != "":
+		hash, size, err = x.fileUploadResumable(ctx, bucket, token, data)
+	case x.addConcurrency > 0:
+		hash, size, err = x.fileUploadParallel(ctx, data)
+	default:
+		hash, size, err = x.fileUpload(ctx, data)
+	}
+	if err != nil {
+		return "", err
+	}
+	obinfo.Size_ = int64(size)
+	if len(chain) == 0 {
+		return hash, nil
+	}
+	obinfo.Size_ = counter.n
+	if obinfo.UserDefined == nil {
+		obinfo.UserDefined = make(map[string]string)
+	}
+	if _, ok := chain[0].(gzipTransformer); ok {
+		obinfo.UserDefined[s3xMetaCompression] = compressionGzip
+		chain = chain[1:]
+	}
+	if len(chain) != 0 {
+		obinfo.UserDefined[s3xMetaTransformers] = transformerNames(chain)
+	}
+	obinfo.UserDefined[s3xMetaStoredSize] = strconv.Itoa(size)
+	return hash, nil
 }
 
 // CopyObject copies an object from source bucket to a destination bucket.
@@ -184,9 +605,21 @@ func (x *xObjects) CopyObject(
 	srcInfo minio.ObjectInfo,
 	srcOpts, dstOpts minio.ObjectOptions,
 ) (objInfo minio.ObjectInfo, err error) {
+	srcObject, dstObject = x.objectKey(srcObject), x.objectKey(dstObject)
 	// TODO(bonedaddy): implement usage of options
 	// TODO(bonedaddy): ensure we properly update the ledger with the destination object
-	// TODO(bonedaddy): ensure the destination object is properly adjusted with metadata
+	if err := x.checkObjectName(dstBucket, dstObject); err != nil {
+		return objInfo, err
+	}
+	if err := x.checkMetadataSize(dstBucket, dstObject, srcInfo.UserDefined); err != nil {
+		return objInfo, err
+	}
+	if err := x.checkWritable(); err != nil {
+		return objInfo, err
+	}
+	if err := x.checkRateLimit(ctx, dstBucket); err != nil {
+		return objInfo, err
+	}
 
 	//lock ordering by bucket name
 	if srcBucket == dstBucket {
@@ -205,7 +638,7 @@ func (x *xObjects) CopyObject(
 		return objInfo, x.toMinioErr(err, dstBucket, "", "")
 	}
 
-	obj1, err := x.ledgerStore.object(ctx, srcBucket, srcObject)
+	obj1, err := x.ledgerStore.liveObject(ctx, srcBucket, srcObject)
 	if err != nil {
 		return objInfo, x.toMinioErr(err, srcBucket, srcObject, "")
 	}
@@ -213,14 +646,43 @@ func (x *xObjects) CopyObject(
 		return objInfo, x.toMinioErr(ErrLedgerObjectDoesNotExist, srcBucket, srcObject, "")
 	}
 
-	//copy object so the original will not be modified
-	data, err := obj1.Marshal()
-	if err != nil {
-		panic(err)
+	var obj *Object
+	if srcInfo.PutObjReader != nil &&
+		(crypto.IsEncrypted(obj1.ObjectInfo.UserDefined) || crypto.IsEncrypted(srcInfo.UserDefined)) {
+		// the handler decrypts the data of encrypted copies and encrypts it again with
+		// the destination key, so the copy is stored as new data with a new cid
+		obj, err = x.copyObjectData(ctx, dstBucket, dstObject, srcInfo)
+		if err != nil {
+			return objInfo, x.toMinioErr(err, dstBucket, dstObject, "")
+		}
+	} else {
+		//copy object so the original will not be modified
+		data, err := obj1.Marshal()
+		if err != nil {
+			panic(err)
+		}
+		obj = &Object{}
+		if err = obj.Unmarshal(data); err != nil {
+			panic(err)
+		}
 	}
-	obj := &Object{}
-	if err = obj.Unmarshal(data); err != nil {
-		panic(err)
+
+	// srcInfo.UserDefined holds the metadata resolved by the handler according to
+	// x-amz-metadata-directive (source metadata for COPY, request metadata for REPLACE),
+	// which is applied to the destination.
+	if srcInfo.UserDefined != nil {
+		obj.ObjectInfo.setMetadata(srcInfo.UserDefined)
+		if obj.ObjectInfo.ContentType == "" {
+			obj.ObjectInfo.ContentType = srcInfo.ContentType
+		}
+	}
+
+	// the ttl of the source is not inherited by the copy
+	delete(obj.ObjectInfo.UserDefined, s3xMetaExpires)
+
+	// the metadata of the copy tells if it's stored encrypted
+	if err := x.checkObjectEncryption(dstBucket, dstObject, obj.ObjectInfo.UserDefined); err != nil {
+		return objInfo, err
 	}
 
 	// update relevant fields
@@ -236,32 +698,132 @@ func (x *xObjects) CopyObject(
 		"dst-bucket: %s,  dst-object: %s\n",
 		dstBucket, dstObject,
 	)
-	objInfo = getMinioObjectInfo(&obj.ObjectInfo)
+	// the copy references the same data, a single file or assembled parts, so it has the same ETag
+	objInfo = getObjectETagInfo(&obj.ObjectInfo, obj.GetDataHash())
 	return objInfo, x.toMinioErr(err, dstBucket, dstObject, "")
 }
 
+// copyObjectData stores the data of srcInfo.PutObjReader as a new object, with the
+// encryption metadata the handler set for it.
+func (x *xObjects) copyObjectData(ctx context.Context, bucket, object string, srcInfo minio.ObjectInfo) (*Object, error) {
+	r := srcInfo.PutObjReader
+	obinfo := newObjectInfo(bucket, object, 0, minio.ObjectOptions{})
+	obinfo.setEncryptionMetadata(srcInfo.UserDefined)
+	var (
+		hash string
+		err  error
+	)
+	if x.shouldInline(r.Size()) {
+		hash, err = inlineObjectData(r, &obinfo)
+	} else {
+		hash, err = x.uploadObjectData(ctx, bucket, "", r, &obinfo)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Object{DataHash: hash, ObjectInfo: obinfo}, nil
+}
+
 // DeleteObject deletes a blob in bucket
 func (x *xObjects) DeleteObject(
 	ctx context.Context,
 	bucket, object string,
 ) error {
+	object = x.objectKey(object)
+	if err := x.checkWritable(); err != nil {
+		return err
+	}
+	if err := x.checkRateLimit(ctx, bucket); err != nil {
+		return err
+	}
 	err := x.ledgerStore.RemoveObject(ctx, bucket, object)
 	return x.toMinioErr(err, bucket, object, "")
 }
 
+// DeleteObjectIfMatch deletes a blob in bucket only if its current ETag matches etag,
+// otherwise the object is left as it is and PreConditionFailed is returned.
+func (x *xObjects) DeleteObjectIfMatch(ctx context.Context, bucket, object, etag string) error {
+	object = x.objectKey(object)
+	if err := x.checkWritable(); err != nil {
+		return err
+	}
+	if err := x.checkRateLimit(ctx, bucket); err != nil {
+		return err
+	}
+	err := x.ledgerStore.RemoveObjectIfMatch(ctx, bucket, object, etag)
+	return x.toMinioErr(err, bucket, object, "")
+}
+
 func (x *xObjects) DeleteObjects(
 	ctx context.Context,
 	bucket string,
 	objects []string,
 ) ([]error, error) {
+	if err := x.checkWritable(); err != nil {
+		return nil, err
+	}
+	if err := x.checkRateLimit(ctx, bucket); err != nil {
+		return nil, err
+	}
+	if x.canonicalKeys {
+		keys := make([]string, len(objects))
+		for i, object := range objects {
+			keys[i] = x.objectKey(object)
+		}
+		objects = keys
+	}
 	missing, err := x.ledgerStore.RemoveObjects(ctx, bucket, objects...)
+	if err == ErrObjectLegalHold {
+		return x.deleteObjectsEach(ctx, bucket, objects), nil
+	}
 	if err != nil {
 		return nil, x.toMinioErr(err, bucket, "", "")
 	}
 	// TODO(bonedaddy): implement removal from ipfs
-	errs := make([]error, len(missing))
-	for i, m := range missing {
-		errs[i] = x.toMinioErr(ErrLedgerObjectDoesNotExist, bucket, m, "")
+	// errs has an entry for each object in order, so the multi-object delete response
+	// reports each key, missing keys are reported as ObjectNotFound
+	notFound := make(map[string]bool, len(missing))
+	for _, m := range missing {
+		notFound[m] = true
+	}
+	errs := make([]error, len(objects))
+	for i, object := range objects {
+		if notFound[object] {
+			errs[i] = x.toMinioErr(ErrLedgerObjectDoesNotExist, bucket, object, "")
+		}
 	}
 	return errs, nil
 }
+
+// deleteObjectsEach deletes objects one at a time after deleting them together failed because
+// some are under legal hold, so that only the held objects are reported as locked.
+func (x *xObjects) deleteObjectsEach(ctx context.Context, bucket string, objects []string) []error {
+	errs := make([]error, len(objects))
+	for i, object := range objects {
+		errs[i] = x.toMinioErr(x.ledgerStore.RemoveObject(ctx, bucket, object), bucket, object, "")
+	}
+	return errs
+}
+
+// RestoreObjectToCID rolls an object back to a prior version, cid is the ipfs hash
+// of the object as returned by the info api when that version was current.
+func (x *xObjects) RestoreObjectToCID(ctx context.Context, bucket, object, cid string) error {
+	object = x.objectKey(object)
+	if err := x.checkWritable(); err != nil {
+		return err
+	}
+	if err := x.ledgerStore.AssertBucketExits(bucket); err != nil {
+		return x.toMinioErr(err, bucket, "", "")
+	}
+	obj, err := ipfsObject(ctx, x.dagClient, cid)
+	if err != nil {
+		return x.toMinioErr(ErrInvalidObjectCID, bucket, object, "")
+	}
+	if obj.ObjectInfo.GetBucket() != bucket || obj.ObjectInfo.GetName() != object {
+		return x.toMinioErr(ErrInvalidObjectCID, bucket, object, "")
+	}
+	return x.toMinioErr(
+		x.ledgerStore.RestoreObject(ctx, bucket, object, cid, obj),
+		bucket, object, "",
+	)
+}
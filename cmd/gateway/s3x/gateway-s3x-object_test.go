@@ -3,12 +3,22 @@ package s3x
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"math"
+	"net/http"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	pb "github.com/RTradeLtd/TxPB/v3/go"
 	minio "github.com/RTradeLtd/s3x/cmd"
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
 	"github.com/RTradeLtd/s3x/pkg/hash"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -96,6 +106,24 @@ func testS3XGObject(t *testing.T, dsType DSType) {
 	t.Run("GetObject", func(t *testing.T) {
 		testGetObject(t, gateway)
 	})
+	t.Run("PutObject read-your-writes", func(t *testing.T) {
+		var names []string
+		for i := 0; i < 20; i++ {
+			name := fmt.Sprintf("ryw-object-%d", i)
+			_, err := gateway.PutObject(ctx, testBucket1, name,
+				getTestPutObjectReader(t, []byte(name)), minio.ObjectOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := gateway.ledgerStore.GetObjectHash(ctx, testBucket1, name); err != nil {
+				t.Fatalf("object %v not found immediately after put: %v", name, err)
+			}
+			names = append(names, name)
+		}
+		if _, err := gateway.DeleteObjects(ctx, testBucket1, names); err != nil {
+			t.Fatal(err)
+		}
+	})
 	t.Run("GetObject from datastore", func(t *testing.T) {
 		gateway.restart(t)
 		testGetObject(t, gateway)
@@ -190,6 +218,25 @@ func testS3XGObject(t *testing.T, dsType DSType) {
 		}
 
 	})
+	t.Run("ResolveObjectForPresign", func(t *testing.T) {
+		info, err := gateway.ResolveObjectForPresign(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Bucket != testBucket1 || info.Name != testObject1 {
+			t.Fatalf("unexpected bucket or object name: %v", info)
+		}
+		if info.Size != int64(len(testObject1Data)) {
+			t.Fatalf("expected size %v, but got %v", len(testObject1Data), info.Size)
+		}
+		if info.ETag == "" {
+			t.Fatal("expected ETag to be set")
+		}
+		_, err = gateway.ResolveObjectForPresign(ctx, testBucket1, "notarealobj")
+		if _, ok := err.(minio.ObjectNotFound); !ok {
+			t.Fatal("expected error ObjectNotFound, but got", err)
+		}
+	})
 	t.Run("ListObjectsV2", func(t *testing.T) {
 		t.Skip()
 	})
@@ -251,6 +298,54 @@ func testS3XGObject(t *testing.T, dsType DSType) {
 			t.Fatal("expected destination object name, got:", info.Name)
 		}
 	})
+	t.Run("CopyObject metadata directive", func(t *testing.T) {
+		opts := minio.ObjectOptions{UserDefined: map[string]string{"content-type": "application/json"}}
+		if _, err := gateway.PutObject(ctx, testBucket1, "metadata", getTestPutObjectReader(t, []byte("{}")), opts); err != nil {
+			t.Fatal(err)
+		}
+		srcInfo, err := gateway.GetObjectInfo(ctx, testBucket1, "metadata", minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, "metadata")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// COPY directive passes the source metadata through
+		srcInfo.UserDefined = map[string]string{}
+		info, err := gateway.CopyObject(ctx, testBucket1, "metadata", testBucket1, "metadata-copy", srcInfo, minio.ObjectOptions{}, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.ContentType != "application/json" {
+			t.Fatal("expected COPY to preserve content type, got:", info.ContentType)
+		}
+		// REPLACE directive on the same object only changes metadata
+		srcInfo.UserDefined = map[string]string{"content-type": "text/plain", "X-Amz-Meta-Key": "value"}
+		if _, err := gateway.CopyObject(ctx, testBucket1, "metadata", testBucket1, "metadata", srcInfo, minio.ObjectOptions{}, minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		info, err = gateway.GetObjectInfo(ctx, testBucket1, "metadata", minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.ContentType != "text/plain" {
+			t.Fatal("expected REPLACE to change content type, got:", info.ContentType)
+		}
+		if info.UserDefined["X-Amz-Meta-Key"] != "value" {
+			t.Fatal("expected REPLACE to set user metadata, got:", info.UserDefined)
+		}
+		newHash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, "metadata")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if newHash != hash {
+			t.Fatalf("expected data hash %v to be kept, but got %v", hash, newHash)
+		}
+		if _, err := gateway.DeleteObjects(ctx, testBucket1, []string{"metadata", "metadata-copy"}); err != nil {
+			t.Fatal(err)
+		}
+	})
 	t.Run("DeleteObject", func(t *testing.T) {
 		err := gateway.DeleteObject(ctx, testBucket1, testObject1)
 		if err != nil {
@@ -269,17 +364,40 @@ func testS3XGObject(t *testing.T, dsType DSType) {
 	})
 	t.Run("DeleteObjects", func(t *testing.T) {
 		testPutObject(t, gateway) // put object back before testing delete
-		list := []string{testObject1, "not an object"}
+		if _, err := gateway.PutObject(ctx, testBucket1, "another object", getTestPutObjectReader(t, []byte("data")), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		list := []string{"not an object", testObject1, "missing object", "another object"}
 		errs, err := gateway.DeleteObjects(ctx, testBucket1, list)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if len(errs) != 1 {
-			t.Fatal("expected one missing object, but go errors: ", errs)
+		if len(errs) != len(list) {
+			t.Fatalf("expected an error slot for each of %v objects, but got errors: %v", len(list), errs)
+		}
+		for i, missing := range []bool{true, false, true, false} {
+			if _, ok := errs[i].(minio.ObjectNotFound); ok != missing || (!missing && errs[i] != nil) {
+				t.Fatalf("unexpected error for %q: %v", list[i], errs[i])
+			}
+		}
+		for _, object := range []string{testObject1, "another object"} {
+			if _, err := gateway.GetObjectInfo(ctx, testBucket1, object, minio.ObjectOptions{}); !isObjectNotFound(err) {
+				t.Fatalf("expected %q to be deleted, but got %v", object, err)
+			}
 		}
 	})
 }
 
+func isObjectNotFound(err error) bool {
+	_, ok := err.(minio.ObjectNotFound)
+	return ok
+}
+
+func isInvalidRequest(err, cause error) bool {
+	e, ok := err.(minio.InvalidRequest)
+	return ok && e.Err == cause
+}
+
 func getTestHashReader(t testing.TB, input io.Reader, size int64) *hash.Reader {
 	r, err := hash.NewReader(input, size, "", "", size, false)
 	if err != nil {
@@ -296,3 +414,412 @@ func getTestPutObjectReader(t testing.TB, data []byte) *minio.PutObjReader {
 			int64(len(data)),
 		), nil, nil)
 }
+
+func TestS3X_CheckObjectName(t *testing.T) {
+	x := &xObjects{maxKeyLength: defaultMaxKeyLength}
+	tests := []struct {
+		name    string
+		object  string
+		wantErr bool
+	}{
+		{"normal key", "folder/object.txt", false},
+		{"max length key", strings.Repeat("a", defaultMaxKeyLength), false},
+		{"over length key", strings.Repeat("a", defaultMaxKeyLength+1), true},
+		{"NUL byte", "object\x00name", true},
+		{"newline", "object\nname", true},
+		{"empty key", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := x.checkObjectName(testBucket1, tt.object)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkObjectName() err %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, ok := err.(minio.ObjectNameInvalid); err != nil && !ok {
+				t.Fatal("expected error ObjectNameInvalid, but got", err)
+			}
+		})
+	}
+}
+
+func TestS3X_CopyObject_InvalidName(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	srcInfo, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const dstObject = "object\x00name"
+	_, err = gateway.CopyObject(ctx, testBucket1, testObject1, testBucket1, dstObject, srcInfo, minio.ObjectOptions{}, minio.ObjectOptions{})
+	if _, ok := err.(minio.ObjectNameInvalid); !ok {
+		t.Fatal("expected error ObjectNameInvalid, but got", err)
+	}
+	if _, err := gateway.GetObjectInfo(ctx, testBucket1, dstObject, minio.ObjectOptions{}); !isObjectNotFound(err) {
+		t.Fatalf("expected ObjectNotFound, but got %v", err)
+	}
+}
+
+func TestS3X_MaxMetadataSize(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	// the key and value of the metadata are counted, but not the other headers
+	key := "X-Amz-Meta-Foo"
+	atLimit := map[string]string{
+		"content-type": "text/plain",
+		key:            strings.Repeat("a", defaultMaxMetadataSize-len(key)),
+	}
+	overLimit := map[string]string{
+		key:              strings.Repeat("a", defaultMaxMetadataSize-len(key)),
+		"X-Amz-Meta-Bar": "b",
+	}
+	t.Run("over limit", func(t *testing.T) {
+		opts := minio.ObjectOptions{UserDefined: overLimit}
+		_, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, nil), opts)
+		if _, ok := err.(minio.MetadataTooLarge); !ok {
+			t.Fatal("expected error MetadataTooLarge, but got", err)
+		}
+		if _, err := gateway.NewMultipartUpload(ctx, testBucket1, testObject1, opts); err == nil {
+			t.Fatal("expected multipart upload to be rejected")
+		}
+		if _, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{}); err == nil {
+			t.Fatal("expected object to not be saved")
+		}
+	})
+	t.Run("at limit", func(t *testing.T) {
+		opts := minio.ObjectOptions{UserDefined: atLimit}
+		if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, nil), opts); err != nil {
+			t.Fatal(err)
+		}
+		info, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.UserDefined[key] != atLimit[key] {
+			t.Fatalf("expected metadata to be saved, but got %v", info.UserDefined)
+		}
+	})
+	t.Run("copy replacing metadata", func(t *testing.T) {
+		srcInfo := minio.ObjectInfo{UserDefined: overLimit}
+		_, err := gateway.CopyObject(ctx, testBucket1, testObject1, testBucket1, "copy", srcInfo, minio.ObjectOptions{}, minio.ObjectOptions{})
+		if _, ok := err.(minio.MetadataTooLarge); !ok {
+			t.Fatal("expected error MetadataTooLarge, but got", err)
+		}
+	})
+}
+
+func TestS3X_ListObjects_Delimiter(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	for _, name := range []string{"a/b/c/1", "a/b/2", "a/3", "d/e/f/g/4", "top"} {
+		if _, err := gateway.PutObject(ctx, testBucket1, name, getTestPutObjectReader(t, []byte(name)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name         string
+		prefix       string
+		delimiter    string
+		wantObjects  []string
+		wantPrefixes []string
+	}{
+		{"top level", "", "/", []string{"top"}, []string{"a/", "d/"}},
+		{"nested level", "a/", "/", []string{"a/3"}, []string{"a/b/"}},
+		{"no delimiter", "a/b/", "", []string{"a/b/2", "a/b/c/1"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := gateway.ListObjectsV2(ctx, testBucket1, tt.prefix, "", tt.delimiter, 1000, false, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, obj := range list.Objects {
+				names = append(names, obj.Name)
+			}
+			if !reflect.DeepEqual(names, tt.wantObjects) {
+				t.Fatalf("expected objects %v, but got %v", tt.wantObjects, names)
+			}
+			if !reflect.DeepEqual(list.Prefixes, tt.wantPrefixes) {
+				t.Fatalf("expected prefixes %v, but got %v", tt.wantPrefixes, list.Prefixes)
+			}
+		})
+	}
+}
+
+func BenchmarkS3X_ListObjects_Delimiter(b *testing.B) {
+	ctx := context.Background()
+	gateway := newTestGateway(b, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
+		b.Fatal(err)
+	}
+	oHash, err := ipfsSave(ctx, gateway.dagClient, &Object{ObjectInfo: ObjectInfo{Bucket: testBucket1}}, MetadataCodecDefault)
+	if err != nil {
+		b.Fatal(err)
+	}
+	hashes := make(map[string]string)
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 10; j++ {
+			for k := 0; k < 10; k++ {
+				hashes[fmt.Sprintf("%d/%d/%d", i, j, k)] = oHash
+			}
+		}
+	}
+	w := gateway.ledgerStore.newWriteBatch()
+	if err := gateway.ledgerStore.putObjectHashes(ctx, w, testBucket1, hashes); err != nil {
+		b.Fatal(err)
+	}
+	if err := w.Commit(); err != nil {
+		b.Fatal(err)
+	}
+	for _, delimiter := range []string{"", "/"} {
+		b.Run(fmt.Sprintf("delimiter=%q", delimiter), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				if _, err := gateway.ListObjects(ctx, testBucket1, "", "", delimiter, 1000); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestS3X_RestoreObjectToCID(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte("version 1")), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	oldHash, err := gateway.ledgerStore.GetObjectHash(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte("version 2")), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, "other", getTestPutObjectReader(t, []byte("other")), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	otherHash, err := gateway.ledgerStore.GetObjectHash(ctx, testBucket1, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("invalid cid", func(t *testing.T) {
+		for _, cid := range []string{"notacid", otherHash} {
+			if err := gateway.RestoreObjectToCID(ctx, testBucket1, testObject1, cid); !isInvalidRequest(err, ErrInvalidObjectCID) {
+				t.Fatalf("expected InvalidRequest for ErrInvalidObjectCID for %v, but got %v", cid, err)
+			}
+		}
+	})
+	t.Run("restore", func(t *testing.T) {
+		if err := gateway.RestoreObjectToCID(ctx, testBucket1, testObject1, oldHash); err != nil {
+			t.Fatal(err)
+		}
+		buf := bytes.NewBuffer(nil)
+		if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, 0, buf, "", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != "version 1" {
+			t.Fatalf("expected restored content %q, but got %q", "version 1", buf.String())
+		}
+	})
+}
+
+func TestS3X_DeleteObjectIfMatch(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	stale, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte("version 1")), minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte("version 2")), minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := gateway.ledgerStore.GetObjectHash(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("stale etag", func(t *testing.T) {
+		err := gateway.DeleteObjectIfMatch(ctx, testBucket1, testObject1, stale.ETag)
+		if _, ok := err.(minio.PreConditionFailed); !ok {
+			t.Fatalf("expected PreConditionFailed, but got %v", err)
+		}
+		got, err := gateway.ledgerStore.GetObjectHash(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != hash {
+			t.Fatalf("expected the ledger to be unchanged, but the object hash changed from %v to %v", hash, got)
+		}
+	})
+	t.Run("matching etag", func(t *testing.T) {
+		if err := gateway.DeleteObjectIfMatch(ctx, testBucket1, testObject1, `"`+current.ETag+`"`); err != nil {
+			t.Fatal(err)
+		}
+		_, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+		if _, ok := err.(minio.ObjectNotFound); !ok {
+			t.Fatalf("expected ObjectNotFound, but got %v", err)
+		}
+	})
+	t.Run("missing object", func(t *testing.T) {
+		err := gateway.DeleteObjectIfMatch(ctx, testBucket1, testObject1, current.ETag)
+		if _, ok := err.(minio.ObjectNotFound); !ok {
+			t.Fatalf("expected ObjectNotFound, but got %v", err)
+		}
+	})
+}
+
+// offlineDagClient fails all dag requests, to check that requests are answered from caches
+type offlineDagClient struct {
+	pb.NodeAPIClient
+}
+
+func (offlineDagClient) Dag(ctx context.Context, in *pb.DagRequest, opts ...grpc.CallOption) (*pb.DagResponse, error) {
+	return nil, status.Error(codes.Unavailable, "offline")
+}
+
+func TestS3X_GetObjectInfo_ETag(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	put, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := gateway.PutObject(ctx, testBucket1, "other", getTestPutObjectReader(t, []byte("other data")), minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := minio.ToS3ETag(hash); put.ETag != want {
+		t.Fatalf("expected ETag %v, but got %v", want, put.ETag)
+	}
+	if put.ETag == other.ETag {
+		t.Fatal("expected objects with different data to have different ETags")
+	}
+	// the bucket and object are cached, so HEAD requests do not need ipfs
+	gateway.ledgerStore.dag = offlineDagClient{gateway.ledgerStore.dag}
+	for _, tt := range []struct {
+		object string
+		want   string
+	}{
+		{testObject1, put.ETag},
+		{"other", other.ETag},
+	} {
+		info, err := gateway.GetObjectInfo(ctx, testBucket1, tt.object, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.ETag != tt.want {
+			t.Fatalf("expected ETag %v, but got %v", tt.want, info.ETag)
+		}
+	}
+	// listings report the same ETags
+	want := map[string]string{testObject1: put.ETag, "other": other.ETag}
+	loi, err := gateway.ListObjects(ctx, testBucket1, "", "", "", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loi2, err := gateway.ListObjectsV2(ctx, testBucket1, "", "", "", 1000, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, objs := range [][]minio.ObjectInfo{loi.Objects, loi2.Objects} {
+		if len(objs) != len(want) {
+			t.Fatalf("expected %v objects, but got %v", len(want), len(objs))
+		}
+		for _, info := range objs {
+			if info.ETag != want[info.Name] {
+				t.Fatalf("expected listed ETag %v of %v, but got %v", want[info.Name], info.Name, info.ETag)
+			}
+		}
+	}
+}
+
+func TestS3X_PutObject_ResponseHeaders(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	expires := time.Date(2026, 10, 21, 7, 28, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		name        string
+		disposition string
+		want        string
+	}{
+		{"ascii", `attachment; filename="report.pdf"`, `attachment; filename="report.pdf"`},
+		{"unicode", `attachment; filename="résumé 2020.pdf"`, `attachment; filename="r_sum_ 2020.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9%202020.pdf`},
+		{"encoded", `attachment; filename*=UTF-8''%E2%82%AC.txt`, `attachment; filename="_.txt"; filename*=UTF-8''%E2%82%AC.txt`},
+		{"invalid", `attachment; filename=résumé.pdf`, `attachment; filename=résumé.pdf`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := minio.ObjectOptions{UserDefined: map[string]string{
+				"content-disposition": tt.disposition,
+				"expires":             expires.Format(http.TimeFormat),
+			}}
+			if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(testObject1Data)), opts); err != nil {
+				t.Fatal(err)
+			}
+			info, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.UserDefined[xhttp.ContentDisposition]; got != tt.want {
+				t.Fatalf("expected content disposition %q, but got %q", tt.want, got)
+			}
+			if !info.Expires.Equal(expires) {
+				t.Fatalf("expected expires %v, but got %v", expires, info.Expires)
+			}
+		})
+	}
+}
+
+func TestS3X_ListObjects_Cache(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	gateway.ledgerStore.listings = newListingCache(time.Minute, 16)
+	put := func(t *testing.T, object string) {
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list := func(t *testing.T, want int) {
+		loi, err := gateway.ListObjects(ctx, testBucket1, "", "", "", 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(loi.Objects) != want {
+			t.Fatalf("expected %v objects, but got %v", want, len(loi.Objects))
+		}
+	}
+	put(t, "a")
+	list(t, 1)
+	list(t, 1)
+	if hits, misses := gateway.ledgerStore.listings.stats(); hits != 1 || misses != 1 {
+		t.Fatalf("expected the repeated listing to be cached, but got %v hits and %v misses", hits, misses)
+	}
+	put(t, "b")
+	list(t, 2)
+	if hits, misses := gateway.ledgerStore.listings.stats(); hits != 1 || misses != 2 {
+		t.Fatalf("expected the write to invalidate the listing, but got %v hits and %v misses", hits, misses)
+	}
+	t.Run("ttl", func(t *testing.T) {
+		gateway.ledgerStore.listings.ttl = time.Nanosecond
+		list(t, 2)
+		if hits, _ := gateway.ledgerStore.listings.stats(); hits != 1 {
+			t.Fatalf("expected expired listing to not be used, but got %v hits", hits)
+		}
+	})
+}
@@ -0,0 +1,17 @@
+package s3x
+
+import (
+	"context"
+)
+
+// SetBucketObjectLimit limits the number of objects in a bucket to limit, a limit of 0 removes the limit.
+// Objects already in a bucket above a new limit are kept, but no new objects can be added.
+func (x *xObjects) SetBucketObjectLimit(ctx context.Context, bucket string, limit int) error {
+	return x.toMinioErr(x.setBucketNumber(bucket, bucketConfigObjectLimit, int64(limit)), bucket, "", "")
+}
+
+// GetBucketObjectLimit returns the maximum number of objects in a bucket, or 0 if unlimited.
+func (x *xObjects) GetBucketObjectLimit(ctx context.Context, bucket string) (int, error) {
+	limit, err := x.bucketNumber(bucket, bucketConfigObjectLimit)
+	return int(limit), x.toMinioErr(err, bucket, "", "")
+}
@@ -0,0 +1,73 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_BucketObjectLimit(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for _, bucket := range []string{testBucket1, testBucket2} {
+		if err := gateway.MakeBucketWithLocation(ctx, bucket, "us-east-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const limit = 3
+	if err := gateway.SetBucketObjectLimit(ctx, testBucket1, limit); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := gateway.GetBucketObjectLimit(ctx, testBucket1); err != nil || got != limit {
+		t.Fatalf("expected limit %v, but got %v (%v)", limit, got, err)
+	}
+	put := func(bucket, object string) error {
+		_, err := gateway.PutObject(ctx, bucket, object, getTestPutObjectReader(t, []byte(object)), minio.ObjectOptions{})
+		return err
+	}
+	t.Run("limited", func(t *testing.T) {
+		for i := 0; i < limit; i++ {
+			if err := put(testBucket1, fmt.Sprint("object", i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, ok := put(testBucket1, "one-too-many").(minio.BucketQuotaExceeded); !ok {
+			t.Fatal("expected BucketQuotaExceeded once the limit is reached")
+		}
+		if err := put(testBucket1, "object0"); err != nil {
+			t.Fatalf("expected overwrites to be allowed at the limit, but got %v", err)
+		}
+		loi, err := gateway.ListObjects(ctx, testBucket1, "", "", "", 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(loi.Objects) != limit {
+			t.Fatalf("expected %v objects, but got %v", limit, len(loi.Objects))
+		}
+	})
+	t.Run("unlimited", func(t *testing.T) {
+		if got, err := gateway.GetBucketObjectLimit(ctx, testBucket2); err != nil || got != 0 {
+			t.Fatalf("expected no limit, but got %v (%v)", got, err)
+		}
+		for i := 0; i < limit*2; i++ {
+			if err := put(testBucket2, fmt.Sprint("object", i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	t.Run("limit removed", func(t *testing.T) {
+		if err := gateway.SetBucketObjectLimit(ctx, testBucket1, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := put(testBucket1, "one-too-many"); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+)
+
+// fileUploadParallel adds the data of r to ipfs in segments of resumeSegmentSize bytes, with at most
+// addConcurrency segments being added at once, and returns the hash of a file linking the segments
+// and the size of the data. The segments are linked in the order of the data, so the hash doesn't
+// depend on the concurrency, and is the same as the hash of a resumable put of the data.
+func (x *xObjects) fileUploadParallel(ctx context.Context, r io.Reader) (string, int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		links    []*fileLink
+		size     int
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		slots    = make(chan struct{}, x.addConcurrency)
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	for {
+		// at most addConcurrency segments are buffered and being added
+		slots <- struct{}{}
+		if ctx.Err() != nil {
+			<-slots
+			break
+		}
+		buf := make([]byte, x.resumeSegmentSize)
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF && len(links) > 0 {
+			<-slots
+			break
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			<-slots
+			fail(err)
+			break
+		}
+		link := &fileLink{size: uint64(n)}
+		links = append(links, link)
+		size += n
+		wg.Add(1)
+		go func(data []byte) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			hash, _, err := x.fileUpload(ctx, bytes.NewReader(data))
+			if err == nil {
+				link.cid, err = cid.Decode(hash)
+			}
+			if err != nil {
+				fail(err)
+			}
+		}(buf[:n])
+		if n < len(buf) {
+			break
+		}
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return "", size, firstErr
+	}
+	if len(links) == 1 {
+		return links[0].cid.String(), size, nil
+	}
+	files := make([]fileLink, len(links))
+	for i, link := range links {
+		files[i] = *link
+	}
+	hash, _, err := assembleFileLinks(ctx, x.dagClient, files, x.maxPartLinks, x.addConcurrency)
+	return hash, size, err
+}
@@ -0,0 +1,87 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_PutObject_AddConcurrency(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	const segmentSize = 1024
+	gateway.resumeSegmentSize = segmentSize
+	data := make([]byte, 20*segmentSize+segmentSize/3)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	hashes := make(map[string]int)
+	for _, concurrency := range []int{1, 8} {
+		gateway.addConcurrency = concurrency
+		object := fmt.Sprintf("concurrency-%v", concurrency)
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, data), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		hash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes[hash] = concurrency
+		buf := bytes.NewBuffer(nil)
+		if err := gateway.GetObject(ctx, testBucket1, object, 0, 0, buf, "", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Fatalf("object data added with concurrency %v does not match", concurrency)
+		}
+	}
+	if len(hashes) != 1 {
+		t.Fatalf("expected the same cid regardless of the concurrency, but got %v", hashes)
+	}
+
+	// a resumable put adds the same segments
+	gateway.addConcurrency = 0
+	opts := minio.ObjectOptions{UserDefined: map[string]string{s3xUploadTokenHeader: "token"}}
+	if _, err := gateway.PutObject(ctx, testBucket1, "resumable", getTestPutObjectReader(t, data), opts); err != nil {
+		t.Fatal(err)
+	}
+	hash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, "resumable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := hashes[hash]; !ok {
+		t.Fatalf("expected a resumable put to have the same cid %v, but got %v", hashes, hash)
+	}
+}
+
+func BenchmarkS3X_PutObject_AddConcurrency(b *testing.B) {
+	ctx := context.Background()
+	gateway := newTestGateway(b, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
+		b.Fatal(err)
+	}
+	data := make([]byte, 500<<20)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	for _, concurrency := range []int{1, 8} {
+		b.Run(fmt.Sprintf("concurrency=%v", concurrency), func(b *testing.B) {
+			gateway.addConcurrency = concurrency
+			b.SetBytes(int64(len(data)))
+			for n := 0; n < b.N; n++ {
+				if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(b, data), minio.ObjectOptions{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
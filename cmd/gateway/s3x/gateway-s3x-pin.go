@@ -0,0 +1,120 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+)
+
+const (
+	// defaultPinnedCacheSize is the number of pinned cids remembered, so they are not pinned again
+	defaultPinnedCacheSize = 4096
+	// defaultPinConcurrency is the maximum number of pins in progress
+	defaultPinConcurrency = 16
+)
+
+// readPinner pins the data of objects on the TemporalX node in the background the first time
+// they are read, so deployments that don't pin on write keep the data that is actually used.
+//
+// Only the most recently pinned cids are remembered, once full the oldest is forgotten and
+// pinned again on its next read, which the node treats as a no-op. Reads while the maximum
+// number of pins is in progress are not pinned, the next read tries again.
+type readPinner struct {
+	dag    pb.NodeAPIClient
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	sem    chan struct{} //a slot for each pin in progress
+	size   int           //the number of pinned cids remembered
+
+	mu     sync.Mutex
+	pinned map[string]bool //cids that are pinned or being pinned
+	order  []string        //pinned cids, oldest first
+}
+
+// newReadPinner returns a readPinner that pins with dag until stopped
+func newReadPinner(ctx context.Context, dag pb.NodeAPIClient) *readPinner {
+	ctx, cancel := context.WithCancel(ctx)
+	return &readPinner{
+		dag:    dag,
+		ctx:    ctx,
+		cancel: cancel,
+		sem:    make(chan struct{}, defaultPinConcurrency),
+		size:   defaultPinnedCacheSize,
+		pinned: make(map[string]bool),
+	}
+}
+
+// pin starts pinning cid unless it is already pinned or being pinned, it does not wait for the pin.
+// A failed pin is forgotten, so the next read tries again.
+func (p *readPinner) pin(cid string) {
+	if cid == "" {
+		return
+	}
+	p.mu.Lock()
+	if p.pinned[cid] || p.ctx.Err() != nil {
+		p.mu.Unlock()
+		return
+	}
+	select {
+	case p.sem <- struct{}{}:
+	default:
+		p.mu.Unlock()
+		return // too many pins in progress
+	}
+	p.pinned[cid] = true
+	p.wg.Add(1)
+	p.mu.Unlock()
+	go func() {
+		defer p.wg.Done()
+		defer func() { <-p.sem }()
+		resp, err := p.dag.Persist(p.ctx, &pb.PersistRequest{Cids: []string{cid}})
+		if err == nil && !resp.GetStatus()[cid] {
+			err = fmt.Errorf("%s", resp.GetErrors()[cid])
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if err == nil {
+			p.remember(cid)
+			return
+		}
+		if p.ctx.Err() == nil {
+			log.Printf("failed to pin %s on read: %v", cid, err)
+		}
+		delete(p.pinned, cid)
+	}()
+}
+
+// remember records cid as pinned, forgetting the oldest pinned cid once full. The caller must hold mu.
+func (p *readPinner) remember(cid string) {
+	p.order = append(p.order, cid)
+	if len(p.order) > p.size {
+		delete(p.pinned, p.order[0])
+		p.order = p.order[1:]
+	}
+}
+
+// count returns the number of cids remembered as pinned
+func (p *readPinner) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.order)
+}
+
+// stop cancels the pins in progress and waits for them to return
+func (p *readPinner) stop() {
+	p.mu.Lock()
+	p.cancel()
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// pinOnRead pins the data of a read object, if pinning on read is enabled
+func (x *xObjects) pinOnRead(cid string) {
+	if x.pinner != nil {
+		x.pinner.pin(cid)
+	}
+}
@@ -0,0 +1,188 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+	"google.golang.org/grpc"
+)
+
+// persistCountingClient is a NodeAPIClient that records the cids it is asked to persist
+type persistCountingClient struct {
+	pb.NodeAPIClient
+	mu   sync.Mutex
+	pins map[string]int
+}
+
+func (c *persistCountingClient) Persist(ctx context.Context, in *pb.PersistRequest, opts ...grpc.CallOption) (*pb.PersistResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status := make(map[string]bool, len(in.GetCids()))
+	for _, cid := range in.GetCids() {
+		c.pins[cid]++
+		status[cid] = true
+	}
+	return &pb.PersistResponse{Status: status}, nil
+}
+
+func TestS3X_PinOnRead(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	client := &persistCountingClient{NodeAPIClient: gateway.dagClient, pins: make(map[string]int)}
+	gateway.pinner = newReadPinner(ctx, client)
+	defer gateway.pinner.stop()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	hash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.pins[hash] != 0 {
+		t.Fatal("expected the object not to be pinned before it is read")
+	}
+	read := func() {
+		var buf bytes.Buffer
+		if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, int64(len(testObject1Data)), &buf, "", minio.ObjectOptions{}); err != nil {
+			t.Error(err)
+		}
+	}
+	// concurrent first reads start a single pin
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			read()
+		}()
+	}
+	wg.Wait()
+	gateway.pinner.wg.Wait()
+	read()
+	gateway.pinner.wg.Wait()
+	client.mu.Lock()
+	if client.pins[hash] != 1 {
+		t.Fatalf("expected the object to be pinned once, but it was pinned %v times", client.pins[hash])
+	}
+	client.mu.Unlock()
+	assertPinned := func(t *testing.T, object string) {
+		hash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gateway.pinner.wg.Wait()
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		if client.pins[hash] != 1 {
+			t.Fatalf("expected %v to be pinned once, but it was pinned %v times", object, client.pins[hash])
+		}
+	}
+	t.Run("gzip", func(t *testing.T) {
+		gateway.compressTypes = []string{"text/*"}
+		const object = "compressed"
+		data := []byte(strings.Repeat("compressible text data ", 1000))
+		opts := minio.ObjectOptions{UserDefined: map[string]string{"content-type": "text/plain"}}
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, data), opts); err != nil {
+			t.Fatal(err)
+		}
+		h := http.Header{}
+		h.Set("Accept-Encoding", "gzip")
+		gr, err := gateway.GetObjectNInfo(ctx, testBucket1, object, nil, h, 0, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer gr.Close()
+		if _, err := ioutil.ReadAll(gr); err != nil {
+			t.Fatal(err)
+		}
+		if gr.ObjInfo.ContentEncoding != "gzip" {
+			t.Fatalf("expected the object to be served compressed, but got %q", gr.ObjInfo.ContentEncoding)
+		}
+		assertPinned(t, object)
+	})
+	t.Run("redirect", func(t *testing.T) {
+		gateway.ipfsGatewayURL = "https://ipfs.example.com"
+		const object = "redirected"
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte(testObject1Data+"redirected")), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		p, err := policy.ParseConfig(strings.NewReader(testPublicReadPolicy), testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := gateway.SetBucketPolicy(ctx, testBucket1, p); err != nil {
+			t.Fatal(err)
+		}
+		if u, err := gateway.GetObjectRedirectURL(ctx, testBucket1, object); err != nil || u == "" {
+			t.Fatalf("expected a redirect, but got %q, %v", u, err)
+		}
+		assertPinned(t, object)
+	})
+}
+
+// persistGatedClient is a persistCountingClient whose persists wait until release is closed
+type persistGatedClient struct {
+	*persistCountingClient
+	release chan struct{}
+}
+
+func (c *persistGatedClient) Persist(ctx context.Context, in *pb.PersistRequest, opts ...grpc.CallOption) (*pb.PersistResponse, error) {
+	<-c.release
+	return c.persistCountingClient.Persist(ctx, in, opts...)
+}
+
+func TestS3X_ReadPinner_Bounds(t *testing.T) {
+	ctx := context.Background()
+	t.Run("pinned", func(t *testing.T) {
+		client := &persistCountingClient{pins: make(map[string]int)}
+		p := newReadPinner(ctx, client)
+		defer p.stop()
+		p.size = 2
+		for _, cid := range []string{"a", "b", "c", "c", "b", "a"} {
+			p.pin(cid)
+			p.wg.Wait()
+		}
+		// a was forgotten once b and c were pinned, so it's pinned again
+		if want := map[string]int{"a": 2, "b": 1, "c": 1}; !reflect.DeepEqual(client.pins, want) {
+			t.Fatalf("expected pins %v, but got %v", want, client.pins)
+		}
+		if len(p.pinned) != p.size {
+			t.Fatalf("expected %v cids to be remembered, but got %v", p.size, len(p.pinned))
+		}
+	})
+	t.Run("concurrency", func(t *testing.T) {
+		client := &persistGatedClient{
+			persistCountingClient: &persistCountingClient{pins: make(map[string]int)},
+			release:               make(chan struct{}),
+		}
+		p := newReadPinner(ctx, client)
+		defer p.stop()
+		p.sem = make(chan struct{}, 1)
+		p.pin("a")
+		p.pin("b") // skipped while a is pinned
+		close(client.release)
+		p.wg.Wait()
+		p.pin("b")
+		p.wg.Wait()
+		if want := map[string]int{"a": 1, "b": 1}; !reflect.DeepEqual(client.pins, want) {
+			t.Fatalf("expected pins %v, but got %v", want, client.pins)
+		}
+	})
+}
@@ -1,23 +1,62 @@
 package s3x
 
 import (
+	"bytes"
 	"context"
-	"errors"
+	"encoding/json"
 
+	minio "github.com/RTradeLtd/s3x/cmd"
 	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
 )
 
 // SetBucketPolicy sets policy on bucket
 func (x *xObjects) SetBucketPolicy(ctx context.Context, bucket string, bucketPolicy *policy.Policy) error {
-	return errors.New("not yet implemented")
+	data, err := json.Marshal(bucketPolicy)
+	if err != nil {
+		return err
+	}
+	return x.toMinioErr(x.ledgerStore.PutBucketConfig(bucket, bucketConfigPolicy, data), bucket, "", "")
 }
 
 // GetBucketPolicy will get policy on bucket
 func (x *xObjects) GetBucketPolicy(ctx context.Context, bucket string) (*policy.Policy, error) {
-	return nil, errors.New("not yet implemented")
+	data, err := x.ledgerStore.GetBucketConfig(bucket, bucketConfigPolicy)
+	if err != nil {
+		return nil, x.toMinioErr(err, bucket, "", "")
+	}
+	if data == nil {
+		return nil, minio.BucketPolicyNotFound{Bucket: bucket}
+	}
+	return policy.ParseConfig(bytes.NewReader(data), bucket)
 }
 
 // DeleteBucketPolicy deletes all policies on bucket
 func (x *xObjects) DeleteBucketPolicy(ctx context.Context, bucket string) error {
-	return errors.New("not yet implemented")
+	return x.toMinioErr(x.ledgerStore.DeleteBucketConfig(bucket, bucketConfigPolicy), bucket, "", "")
+}
+
+// isPublicObject returns true if the bucket policy allows anonymous users to get the object
+func (x *xObjects) isPublicObject(ctx context.Context, bucket, object string) (bool, error) {
+	return x.isAnonymousAllowed(ctx, policy.GetObjectAction, bucket, object)
+}
+
+// isAnonymousAllowed returns true if the bucket policy allows anonymous users the action,
+// object is empty for bucket actions such as policy.ListBucketAction.
+//
+// This is the same evaluation the minio handlers do for unauthenticated requests,
+// since in gateway mode their policy checks are answered by GetBucketPolicy.
+func (x *xObjects) isAnonymousAllowed(ctx context.Context, action policy.Action, bucket, object string) (bool, error) {
+	p, err := x.GetBucketPolicy(ctx, bucket)
+	if _, ok := err.(minio.BucketPolicyNotFound); ok {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return p.IsAllowed(policy.Args{
+		Action:          action,
+		BucketName:      bucket,
+		ObjectName:      object,
+		ConditionValues: map[string][]string{},
+	}), nil
 }
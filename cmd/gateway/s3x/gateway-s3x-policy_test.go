@@ -0,0 +1,166 @@
+package s3x
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/cmd/crypto"
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+)
+
+const testPublicReadPolicy = `{
+	"Version": "2012-10-17",
+	"Statement": [{
+		"Effect": "Allow",
+		"Principal": {"AWS": ["*"]},
+		"Action": ["s3:GetObject"],
+		"Resource": ["arn:aws:s3:::` + testBucket1 + `/*"]
+	}]
+}`
+
+func TestS3X_Policy_Redirect(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	gateway.ipfsGatewayURL = "https://ipfs.example.com"
+	for _, bucket := range []string{testBucket1, testBucket2} {
+		if err := gateway.MakeBucketWithLocation(ctx, bucket, "us-east-1"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gateway.PutObject(ctx, bucket, testObject1, getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Run("no policy", func(t *testing.T) {
+		_, err := gateway.GetBucketPolicy(ctx, testBucket1)
+		if _, ok := err.(minio.BucketPolicyNotFound); !ok {
+			t.Fatalf("expected BucketPolicyNotFound, but got %v", err)
+		}
+	})
+	p, err := policy.ParseConfig(strings.NewReader(testPublicReadPolicy), testBucket1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.SetBucketPolicy(ctx, testBucket1, p); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("public object", func(t *testing.T) {
+		hash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := gateway.GetObjectRedirectURL(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "https://ipfs.example.com/ipfs/" + hash; u != want {
+			t.Fatalf("expected redirect to %v, but got %v", want, u)
+		}
+	})
+	t.Run("encrypted object", func(t *testing.T) {
+		opts := minio.ObjectOptions{UserDefined: map[string]string{
+			crypto.SSESealAlgorithm: crypto.SealAlgorithm,
+			crypto.S3SealedKey:      "sealed",
+		}}
+		if _, err := gateway.PutObject(ctx, testBucket1, "encrypted", getTestPutObjectReader(t, []byte(testObject1Data)), opts); err != nil {
+			t.Fatal(err)
+		}
+		u, err := gateway.GetObjectRedirectURL(ctx, testBucket1, "encrypted")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u != "" {
+			t.Fatalf("expected encrypted object to be served directly, but got redirect to %v", u)
+		}
+	})
+	t.Run("private object", func(t *testing.T) {
+		u, err := gateway.GetObjectRedirectURL(ctx, testBucket2, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u != "" {
+			t.Fatalf("expected private object to be served directly, but got redirect to %v", u)
+		}
+	})
+	t.Run("missing object", func(t *testing.T) {
+		_, err := gateway.GetObjectRedirectURL(ctx, testBucket1, "missing")
+		if _, ok := err.(minio.ObjectNotFound); !ok {
+			t.Fatalf("expected ObjectNotFound, but got %v", err)
+		}
+	})
+	t.Run("delete policy", func(t *testing.T) {
+		if err := gateway.DeleteBucketPolicy(ctx, testBucket1); err != nil {
+			t.Fatal(err)
+		}
+		u, err := gateway.GetObjectRedirectURL(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u != "" {
+			t.Fatalf("expected no redirect without policy, but got %v", u)
+		}
+	})
+}
+
+const testPublicListPolicy = `{
+	"Version": "2012-10-17",
+	"Statement": [{
+		"Effect": "Allow",
+		"Principal": {"AWS": ["*"]},
+		"Action": ["s3:ListBucket"],
+		"Resource": ["arn:aws:s3:::` + testBucket1 + `"]
+	}]
+}`
+
+func TestS3X_Policy_AnonymousList(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for _, bucket := range []string{testBucket1, testBucket2} {
+		if err := gateway.MakeBucketWithLocation(ctx, bucket, "us-east-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p, err := policy.ParseConfig(strings.NewReader(testPublicListPolicy), testBucket1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.SetBucketPolicy(ctx, testBucket1, p); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		bucket  string
+		action  policy.Action
+		allowed bool
+	}{
+		{"public list", testBucket1, policy.ListBucketAction, true},
+		{"public list does not allow get", testBucket1, policy.GetObjectAction, false},
+		{"private bucket", testBucket2, policy.ListBucketAction, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var object string
+			if tt.action == policy.GetObjectAction {
+				object = testObject1
+			}
+			allowed, err := gateway.isAnonymousAllowed(ctx, tt.action, tt.bucket, object)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if allowed != tt.allowed {
+				t.Fatalf("expected allowed %v, but got %v", tt.allowed, allowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package s3x
+
+import (
+	"context"
+	"time"
+)
+
+// ObjectVersion identifies a version of an object on ipfs
+type ObjectVersion struct {
+	// MetadataCID is the ipfs hash of the object and its metadata, as returned by the info api
+	MetadataCID string
+	// DataCID is the ipfs hash of the object data, for inline objects it's the cid of the data
+	// but the data is only stored in the ledger
+	DataCID string
+	Size    int64
+	ETag    string
+	ModTime time.Time
+	// Unreachable is set on prior versions whose object can't be read from ipfs anymore,
+	// such as versions that were unpinned and collected, only MetadataCID is set then
+	Unreachable bool
+}
+
+// ObjectProvenance is the current version of an object and its prior versions,
+// which can be verified against ipfs independently of the gateway.
+type ObjectProvenance struct {
+	Bucket string
+	Object string
+	ObjectVersion
+	// Versions are the prior versions of the object, newest first,
+	// only the latest maxObjectVersions versions are kept
+	Versions []ObjectVersion
+}
+
+// SetBucketVersionHistory enables or disables recording the prior versions of objects when they are
+// replaced, which is disabled by default, as every version keeps the data of the object pinned.
+// Versions recorded before the history is disabled are kept until the object is removed.
+func (x *xObjects) SetBucketVersionHistory(ctx context.Context, bucket string, on bool) error {
+	return x.toMinioErr(x.setBucketFlag(bucket, bucketConfigVersionHistory, on), bucket, "", "")
+}
+
+// GetBucketVersionHistory returns true if the prior versions of the objects of a bucket are recorded
+func (x *xObjects) GetBucketVersionHistory(ctx context.Context, bucket string) (bool, error) {
+	on, err := x.bucketFlag(bucket, bucketConfigVersionHistory)
+	return on, x.toMinioErr(err, bucket, "", "")
+}
+
+// GetObjectProvenance returns the cids of the current version of an object and of its prior versions.
+// Versions are recorded when an object is replaced in a bucket with version history, see
+// SetBucketVersionHistory, and removed with the object. Prior versions that can't be read from ipfs
+// are returned as Unreachable instead of failing the whole provenance.
+func (x *xObjects) GetObjectProvenance(ctx context.Context, bucket, object string) (ObjectProvenance, error) {
+	object = x.objectKey(object)
+	h, obj, versions, err := x.ledgerStore.ObjectVersionHashes(ctx, bucket, object)
+	if err != nil {
+		return ObjectProvenance{}, x.toMinioErr(err, bucket, object, "")
+	}
+	p := ObjectProvenance{
+		Bucket:        bucket,
+		Object:        object,
+		ObjectVersion: newObjectVersion(h, obj),
+		Versions:      make([]ObjectVersion, 0, len(versions)),
+	}
+	for _, vh := range versions {
+		v, err := x.ledgerStore.ipfsObject(ctx, vh)
+		if ctx.Err() != nil {
+			return ObjectProvenance{}, ctx.Err()
+		}
+		if err != nil {
+			p.Versions = append(p.Versions, ObjectVersion{MetadataCID: vh, Unreachable: true})
+			continue
+		}
+		p.Versions = append(p.Versions, newObjectVersion(vh, v))
+	}
+	return p, nil
+}
+
+// newObjectVersion returns the version of obj saved to ipfs as h
+func newObjectVersion(h string, obj *Object) ObjectVersion {
+	return ObjectVersion{
+		MetadataCID: h,
+		DataCID:     obj.GetDataHash(),
+		Size:        obj.ObjectInfo.GetSize_(),
+		ETag:        getObjectETagInfo(&obj.ObjectInfo, obj.GetDataHash()).ETag,
+		ModTime:     obj.ObjectInfo.GetModTime(),
+	}
+}
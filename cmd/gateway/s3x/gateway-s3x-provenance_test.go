@@ -0,0 +1,130 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_GetObjectProvenance(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for _, bucket := range []string{testBucket1, testBucket2} {
+		if err := gateway.MakeBucketWithLocation(ctx, bucket, "us-east-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gateway.SetBucketVersionHistory(ctx, testBucket1, true); err != nil {
+		t.Fatal(err)
+	}
+	if on, err := gateway.GetBucketVersionHistory(ctx, testBucket1); err != nil || !on {
+		t.Fatalf("expected version history to be enabled, but got %v, %v", on, err)
+	}
+	contents := []string{"version 1", "version 2", "version 3!"}
+	var hashes []string
+	for _, c := range contents {
+		if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(c)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		h, err := gateway.ledgerStore.GetObjectHash(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, h)
+	}
+	// the mod time is not checked, it is not set in tests
+	check := func(t *testing.T, v ObjectVersion, hash, content string) {
+		obj, err := ipfsObject(ctx, gateway.dagClient, hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.MetadataCID != hash {
+			t.Fatalf("expected metadata cid %v, but got %v", hash, v.MetadataCID)
+		}
+		if v.DataCID == "" || v.DataCID != obj.GetDataHash() {
+			t.Fatalf("expected data cid %v, but got %v", obj.GetDataHash(), v.DataCID)
+		}
+		if v.Size != int64(len(content)) {
+			t.Fatalf("expected size %v, but got %v", len(content), v.Size)
+		}
+		if v.ETag == "" {
+			t.Fatal("expected an etag")
+		}
+	}
+
+	t.Run("versioned", func(t *testing.T) {
+		p, err := gateway.GetObjectProvenance(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Bucket != testBucket1 || p.Object != testObject1 {
+			t.Fatalf("expected %v/%v, but got %v/%v", testBucket1, testObject1, p.Bucket, p.Object)
+		}
+		check(t, p.ObjectVersion, hashes[2], contents[2])
+		if len(p.Versions) != 2 {
+			t.Fatalf("expected 2 prior versions, but got %v", len(p.Versions))
+		}
+		check(t, p.Versions[0], hashes[1], contents[1])
+		check(t, p.Versions[1], hashes[0], contents[0])
+		if p.Versions[0].ETag == p.ETag || p.Versions[0].DataCID == p.DataCID {
+			t.Fatal("expected versions to differ from the current version")
+		}
+	})
+	t.Run("unreachable", func(t *testing.T) {
+		ls := gateway.ledgerStore
+		dag, objects := ls.dag, ls.objects
+		defer func() {
+			ls.dag, ls.objects = dag, objects
+		}()
+		ls.dag = &lostDagClient{NodeAPIClient: dag, lost: hashes[0]}
+		ls.objects = newObjectCache(defaultObjectCacheSize)
+		p, err := gateway.GetObjectProvenance(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatalf("expected a lost version not to fail the provenance, but got %v", err)
+		}
+		if len(p.Versions) != 2 || p.Versions[0].Unreachable {
+			t.Fatalf("expected the reachable version to be kept, but got %v", p.Versions)
+		}
+		if want := (ObjectVersion{MetadataCID: hashes[0], Unreachable: true}); p.Versions[1] != want {
+			t.Fatalf("expected %v, but got %v", want, p.Versions[1])
+		}
+	})
+	t.Run("no history", func(t *testing.T) {
+		for _, c := range contents {
+			if _, err := gateway.PutObject(ctx, testBucket2, testObject1, getTestPutObjectReader(t, []byte(c)), minio.ObjectOptions{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		p, err := gateway.GetObjectProvenance(ctx, testBucket2, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(p.Versions) != 0 {
+			t.Fatalf("expected no versions without version history, but got %v", p.Versions)
+		}
+	})
+	t.Run("removed", func(t *testing.T) {
+		if err := gateway.DeleteObject(ctx, testBucket1, testObject1); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gateway.GetObjectProvenance(ctx, testBucket1, testObject1); err == nil {
+			t.Fatal("expected an error for a removed object")
+		}
+		if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(contents[0])), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		p, err := gateway.GetObjectProvenance(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(p.Versions) != 0 {
+			t.Fatalf("expected no versions of a new object, but got %v", p.Versions)
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package s3x
+
+import (
+	"context"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+)
+
+// PurgeCID removes every object whose proto, data or one of its parts is the ipfs hash cid from
+// the ledger, then force deletes the cid from the blockstore of the TemporalX node, even if it's pinned.
+// Prior versions that reference cid are dropped from the version history of the objects that are
+// kept, so they can't be restored. The removed objects are returned so the purge can be audited,
+// the dropped versions are not.
+//
+// The data of the removed objects is unpinned and a garbage collection of the node is started,
+// which deletes the blocks of the data that nothing else pins. Blocks shared with other objects
+// are kept, as they are still pinned by those objects.
+//
+// Nothing is purged if any of the objects is under legal hold, ErrObjectLegalHold is returned
+// and the cid is kept until the hold is released.
+//
+// Left behind are the parts of multipart uploads in progress, which are not searched, and copies
+// of the data under other cids, such as objects with the same data stored compressed, transformed or inline.
+func (x *xObjects) PurgeCID(ctx context.Context, cid string) ([]ObjectRef, error) {
+	if err := x.checkWritable(); err != nil {
+		return nil, err
+	}
+	removed, hashes, err := x.ledgerStore.RemoveObjectsForCID(ctx, cid)
+	if err != nil {
+		return nil, err
+	}
+	if len(hashes) != 0 {
+		if _, err := x.dagClient.Blockstore(ctx, &pb.BlockstoreRequest{
+			RequestType: pb.BSREQTYPE_BS_DELETE,
+			Cids:        hashes,
+		}); err != nil {
+			return removed, err
+		}
+	}
+	if _, err := x.dagClient.Blockstore(ctx, &pb.BlockstoreRequest{
+		RequestType: pb.BSREQTYPE_BS_DELETE,
+		ReqOpts:     []pb.BSREQOPTS{pb.BSREQOPTS_BS_FORCE},
+		Cids:        []string{cid},
+	}); err != nil {
+		return removed, err
+	}
+	if _, err := x.adminClient.ManageGC(ctx, &pb.ManageGCRequest{Type: pb.GCREQTYPE_GC_START}); err != nil {
+		return removed, err
+	}
+	return removed, nil
+}
@@ -0,0 +1,181 @@
+package s3x
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc"
+)
+
+// deleteRecordingClient is a NodeAPIClient that records blockstore deletes instead of deleting,
+// forced deletes are recorded in deleted and the others, which only unpin, in unpinned
+type deleteRecordingClient struct {
+	pb.NodeAPIClient
+	deleted  []string
+	unpinned []string
+}
+
+func (c *deleteRecordingClient) Blockstore(ctx context.Context, in *pb.BlockstoreRequest, opts ...grpc.CallOption) (*pb.BlockstoreResponse, error) {
+	if in.GetRequestType() != pb.BSREQTYPE_BS_DELETE {
+		return c.NodeAPIClient.Blockstore(ctx, in, opts...)
+	}
+	for _, opt := range in.GetReqOpts() {
+		if opt == pb.BSREQOPTS_BS_FORCE {
+			c.deleted = append(c.deleted, in.GetCids()...)
+			return &pb.BlockstoreResponse{}, nil
+		}
+	}
+	c.unpinned = append(c.unpinned, in.GetCids()...)
+	return &pb.BlockstoreResponse{}, nil
+}
+
+// gcRecordingClient is an AdminAPIClient that counts the garbage collections started
+type gcRecordingClient struct {
+	pb.AdminAPIClient
+	started int
+}
+
+func (c *gcRecordingClient) ManageGC(ctx context.Context, in *pb.ManageGCRequest, opts ...grpc.CallOption) (*pb.ManageGCResponse, error) {
+	if in.GetType() == pb.GCREQTYPE_GC_START {
+		c.started++
+	}
+	return &pb.ManageGCResponse{}, nil
+}
+
+func TestS3X_PurgeCID(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	client := &deleteRecordingClient{NodeAPIClient: gateway.dagClient}
+	gateway.dagClient = client
+	gc := &gcRecordingClient{AdminAPIClient: gateway.adminClient}
+	gateway.adminClient = gc
+	for _, bucket := range []string{testBucket1, testBucket2} {
+		if err := gateway.MakeBucketWithLocation(ctx, bucket, "us-east-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gateway.SetBucketVersionHistory(ctx, testBucket1, true); err != nil {
+		t.Fatal(err)
+	}
+	put := func(bucket, object, data string) {
+		if _, err := gateway.PutObject(ctx, bucket, object, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put(testBucket1, testObject1, "takedown")
+	put(testBucket2, testObject1, "takedown")
+	put(testBucket1, "other", "takedown")
+	put(testBucket1, "other", "other")
+	cid, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a held object in any bucket keeps the purge from removing anything
+	if err := gateway.ledgerStore.SetObjectLegalHold(ctx, testBucket2, testObject1, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PurgeCID(ctx, cid); err != ErrObjectLegalHold {
+		t.Fatalf("expected ErrObjectLegalHold, but got %v", err)
+	}
+	if len(client.deleted) != 0 || len(client.unpinned) != 0 || gc.started != 0 {
+		t.Fatalf("expected nothing to be deleted from the blockstore, but got %v, unpinned %v and %v gcs", client.deleted, client.unpinned, gc.started)
+	}
+	if _, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{}); err != nil {
+		t.Fatalf("expected objects to be kept while another is held, but got %v", err)
+	}
+	if err := gateway.ledgerStore.SetObjectLegalHold(ctx, testBucket2, testObject1, false); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := gateway.PurgeCID(ctx, cid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ObjectRef{{Bucket: testBucket1, Object: testObject1}, {Bucket: testBucket2, Object: testObject1}}
+	if !reflect.DeepEqual(removed, want) {
+		t.Fatalf("expected %v to be removed, but got %v", want, removed)
+	}
+	if !reflect.DeepEqual(client.deleted, []string{cid}) {
+		t.Fatalf("expected %v to be deleted from the blockstore, but got %v", cid, client.deleted)
+	}
+	// the data of the removed objects is unpinned, so the gc collects the blocks below cid
+	if !reflect.DeepEqual(client.unpinned, []string{cid}) {
+		t.Fatalf("expected %v to be unpinned, but got %v", cid, client.unpinned)
+	}
+	if gc.started != 1 {
+		t.Fatalf("expected a gc to be started, but got %v", gc.started)
+	}
+	// the removals are persisted, not only dropped from the cache
+	gateway.restart(t)
+	for _, ref := range want {
+		if _, err := gateway.GetObjectInfo(ctx, ref.Bucket, ref.Object, minio.ObjectOptions{}); !isObjectNotFound(err) {
+			t.Fatalf("expected %v to be removed, but got %v", ref, err)
+		}
+	}
+	if _, err := gateway.GetObjectInfo(ctx, testBucket1, "other", minio.ObjectOptions{}); err != nil {
+		t.Fatalf("expected unrelated objects to be kept, but got %v", err)
+	}
+	// the prior version of the kept object holding the purged data can't be restored
+	if _, _, versions, err := gateway.ledgerStore.ObjectVersionHashes(ctx, testBucket1, "other"); err != nil {
+		t.Fatal(err)
+	} else if len(versions) != 0 {
+		t.Fatalf("expected the versions referencing %v to be dropped, but got %v", cid, versions)
+	}
+}
+
+func TestS3X_PurgeCID_MultipartPart(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	client := &deleteRecordingClient{NodeAPIClient: gateway.dagClient}
+	gateway.dagClient = client
+	gateway.adminClient = &gcRecordingClient{AdminAPIClient: gateway.adminClient}
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
+		t.Fatal(err)
+	}
+	uID, err := gateway.NewMultipartUpload(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uploadParts []minio.CompletePart
+	for i, data := range []string{"first-", "takedown"} {
+		pi, err := gateway.PutObjectPart(ctx, testBucket1, testObject1, uID, i+1, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uploadParts = append(uploadParts, minio.CompletePart{PartNumber: pi.PartNumber, ETag: pi.ETag})
+	}
+	if _, err := gateway.CompleteMultipartUpload(ctx, testBucket1, testObject1, uID, uploadParts, minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := gateway.ledgerStore.Object(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part := obj.ObjectInfo.Parts[1].DataHash
+	removed, err := gateway.PurgeCID(ctx, part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []ObjectRef{{Bucket: testBucket1, Object: testObject1}}; !reflect.DeepEqual(removed, want) {
+		t.Fatalf("expected the object completed from the part to be removed, but got %v", removed)
+	}
+	// the root of the completed object links the part, it is unpinned so both can be collected
+	if !reflect.DeepEqual(client.unpinned, []string{obj.GetDataHash()}) {
+		t.Fatalf("expected %v to be unpinned, but got %v", obj.GetDataHash(), client.unpinned)
+	}
+	if !reflect.DeepEqual(client.deleted, []string{part}) {
+		t.Fatalf("expected %v to be deleted from the blockstore, but got %v", part, client.deleted)
+	}
+}
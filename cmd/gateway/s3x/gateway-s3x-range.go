@@ -0,0 +1,82 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	minio "github.com/RTradeLtd/s3x/cmd"
+	proto "github.com/gogo/protobuf/proto"
+	"github.com/ipfs/go-merkledag"
+	unixfs_pb "github.com/ipfs/go-unixfs/pb"
+)
+
+// s3xMetaPartLevels is the internal metadata key of the number of levels of unixfs nodes
+// above the parts of a completed multipart object, see assembleFileLinks
+const s3xMetaPartLevels = minio.ReservedMetadataPrefix + "S3x-Part-Levels"
+
+// partLevels returns the number of node levels above the parts of the object data,
+// or 0 if the object was not assembled from parts
+func (m *ObjectInfo) partLevels() int {
+	levels, err := strconv.Atoi(m.GetUserDefined()[s3xMetaPartLevels])
+	if err != nil || levels < 0 {
+		return 0
+	}
+	return levels
+}
+
+// ipfsPartsDownload writes length bytes from startOffset of a file assembled from parts by
+// assembleFileLinks, with levels of nodes above the parts. Only the nodes and parts that
+// overlap the range are fetched, a range over part boundaries is joined from each part.
+func ipfsPartsDownload(ctx context.Context, dag pb.NodeAPIClient, fileClient pb.FileAPIClient, w io.Writer, hash string, levels int, startOffset, length int64) (int64, error) {
+	data, err := ipfsBytes(ctx, dag, hash)
+	if err != nil {
+		return 0, err
+	}
+	node, err := merkledag.DecodeProtobuf(data)
+	if err != nil {
+		return 0, err
+	}
+	var file unixfs_pb.Data
+	if err := proto.Unmarshal(node.Data(), &file); err != nil {
+		return 0, err
+	}
+	links := node.Links()
+	if len(file.Blocksizes) != len(links) {
+		return 0, fmt.Errorf("node %v has %v links, but %v block sizes", hash, len(links), len(file.Blocksizes))
+	}
+	var n, offset int64
+	for i, link := range links {
+		size := int64(file.Blocksizes[i])
+		if length == 0 {
+			break
+		}
+		if offset+size <= startOffset {
+			offset += size
+			continue
+		}
+		start := startOffset - offset
+		if start < 0 {
+			start = 0
+		}
+		l := size - start
+		if l > length {
+			l = length
+		}
+		var m int64
+		if levels > 1 {
+			m, err = ipfsPartsDownload(ctx, dag, fileClient, w, link.Cid.String(), levels-1, start, l)
+		} else {
+			m, err = ipfsFileDownload(ctx, fileClient, w, link.Cid.String(), start, l)
+		}
+		n += m
+		if err != nil {
+			return n, err
+		}
+		length -= l
+		offset += size
+	}
+	return n, nil
+}
@@ -0,0 +1,56 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"net/http"
+	"strings"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+const (
+	// s3xRangeChecksumHeader is the request header asking for the checksum of a range GET,
+	// the only supported value is rangeChecksumCRC32C
+	s3xRangeChecksumHeader = "X-Amz-Meta-S3x-Range-Checksum"
+	// s3xRangeCRC32CHeader is the response header with the base64 encoded big endian crc32c
+	// of the bytes served by a range GET
+	s3xRangeCRC32CHeader = "X-Amz-Meta-S3x-Range-Crc32c"
+	rangeChecksumCRC32C  = "CRC32C"
+
+	// maxRangeChecksumSize is the largest range that is checksummed, as the range is buffered
+	// to send the checksum in the headers, larger ranges are served without it
+	maxRangeChecksumSize = 16 << 20
+)
+
+// wantsRangeChecksum returns true if the request headers ask for the crc32c of the range
+func wantsRangeChecksum(h http.Header) bool {
+	return strings.EqualFold(h.Get(s3xRangeChecksumHeader), rangeChecksumCRC32C)
+}
+
+// getObjectNInfoChecksummed returns a reader of a range of an object, which is read ahead,
+// with the crc32c of the range in the s3xRangeCRC32CHeader of the object info
+func (x *xObjects) getObjectNInfoChecksummed(
+	ctx context.Context,
+	bucket, object string,
+	objinfo minio.ObjectInfo,
+	startOffset, length int64,
+	opts minio.ObjectOptions,
+) (*minio.GetObjectReader, error) {
+	var buf bytes.Buffer
+	if err := x.getObject(ctx, bucket, object, startOffset, length, &buf); err != nil {
+		return nil, err
+	}
+	sum := make([]byte, crc32.Size)
+	binary.BigEndian.PutUint32(sum, crc32.Checksum(buf.Bytes(), crc32c))
+	meta := make(map[string]string, len(objinfo.UserDefined)+1)
+	for k, v := range objinfo.UserDefined {
+		meta[k] = v
+	}
+	meta[s3xRangeCRC32CHeader] = base64.StdEncoding.EncodeToString(sum)
+	objinfo.UserDefined = meta
+	return minio.NewGetObjectReaderFromReader(&buf, objinfo, opts.CheckCopyPrecondFn)
+}
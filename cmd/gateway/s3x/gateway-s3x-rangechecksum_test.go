@@ -0,0 +1,53 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_RangeChecksum(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, data), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	get := func(h http.Header) ([]byte, minio.ObjectInfo) {
+		rs := &minio.HTTPRangeSpec{Start: 1234, End: 5677}
+		gr, err := gateway.GetObjectNInfo(ctx, testBucket1, testObject1, rs, h, 0, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer gr.Close()
+		got, err := ioutil.ReadAll(gr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data[1234:5678]) {
+			t.Fatal("unexpected range data")
+		}
+		return got, gr.ObjInfo
+	}
+	t.Run("requested", func(t *testing.T) {
+		got, info := get(http.Header{s3xRangeChecksumHeader: []string{"crc32c"}})
+		sum := make([]byte, crc32.Size)
+		binary.BigEndian.PutUint32(sum, crc32.Checksum(got, crc32.MakeTable(crc32.Castagnoli)))
+		if want := base64.StdEncoding.EncodeToString(sum); info.UserDefined[s3xRangeCRC32CHeader] != want {
+			t.Fatalf("expected range crc32c %v, but got %q", want, info.UserDefined[s3xRangeCRC32CHeader])
+		}
+	})
+	t.Run("not requested", func(t *testing.T) {
+		if _, info := get(http.Header{}); info.UserDefined[s3xRangeCRC32CHeader] != "" {
+			t.Fatal("expected no range checksum unless requested")
+		}
+	})
+}
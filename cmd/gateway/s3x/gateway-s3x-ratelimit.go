@@ -0,0 +1,84 @@
+package s3x
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+// SetBucketRateLimit limits the requests to a bucket to requestsPerSecond,
+// a limit of 0 removes the limit.
+func (x *xObjects) SetBucketRateLimit(ctx context.Context, bucket string, requestsPerSecond int64) error {
+	return x.toMinioErr(x.setBucketNumber(bucket, bucketConfigRateLimit, requestsPerSecond), bucket, "", "")
+}
+
+// GetBucketRateLimit returns the maximum number of requests per second to a bucket, or 0 if unlimited.
+func (x *xObjects) GetBucketRateLimit(ctx context.Context, bucket string) (int64, error) {
+	limit, err := x.bucketNumber(bucket, bucketConfigRateLimit)
+	return limit, x.toMinioErr(err, bucket, "", "")
+}
+
+// checkRateLimit takes a request from the bucket rate limit,
+// minio.SlowDown is returned if the bucket has no requests left.
+func (x *xObjects) checkRateLimit(ctx context.Context, bucket string) error {
+	limit, err := x.GetBucketRateLimit(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !x.limiters.allow(bucket, limit, time.Now()) {
+		return minio.SlowDown{}
+	}
+	return nil
+}
+
+// bucketLimiters holds the token buckets of rate limited buckets
+type bucketLimiters struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// allow returns true if a request to the bucket is allowed at now with the given limit,
+// a limit of 0 or less always allows requests.
+func (l *bucketLimiters) allow(bucket string, limit int64, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if limit <= 0 {
+		delete(l.buckets, bucket)
+		return true
+	}
+	if l.buckets == nil {
+		l.buckets = make(map[string]*tokenBucket)
+	}
+	b, ok := l.buckets[bucket]
+	if !ok || b.rate != float64(limit) {
+		// a new or changed limit starts with a full bucket
+		b = &tokenBucket{rate: float64(limit), tokens: float64(limit), last: now}
+		l.buckets[bucket] = b
+	}
+	return b.take(now)
+}
+
+// tokenBucket refills rate tokens per second, up to a burst of rate tokens
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket up to now and takes a token if there is one
+func (b *tokenBucket) take(now time.Time) bool {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.rate {
+			b.tokens = b.rate
+		}
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
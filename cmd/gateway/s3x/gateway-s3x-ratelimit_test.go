@@ -0,0 +1,58 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_RateLimit(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for _, bucket := range []string{testBucket1, testBucket2} {
+		if err := gateway.MakeBucketWithLocation(ctx, bucket, "us-east-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const limit = 5
+	if err := gateway.SetBucketRateLimit(ctx, testBucket1, limit); err != nil {
+		t.Fatal(err)
+	}
+	hammer := func(t *testing.T, bucket string) (slowDowns int) {
+		for i := 0; i < limit*4; i++ {
+			_, err := gateway.ListObjects(ctx, bucket, "", "", "", 1000)
+			switch err.(type) {
+			case nil:
+			case minio.SlowDown:
+				slowDowns++
+			default:
+				t.Fatal(err)
+			}
+		}
+		return slowDowns
+	}
+	t.Run("limited", func(t *testing.T) {
+		if n := hammer(t, testBucket1); n == 0 {
+			t.Fatal("expected excess requests to get SlowDown")
+		}
+	})
+	t.Run("unlimited", func(t *testing.T) {
+		if n := hammer(t, testBucket2); n != 0 {
+			t.Fatalf("expected no SlowDown, but got %v", n)
+		}
+	})
+	t.Run("limit removed", func(t *testing.T) {
+		if err := gateway.SetBucketRateLimit(ctx, testBucket1, 0); err != nil {
+			t.Fatal(err)
+		}
+		if n := hammer(t, testBucket1); n != 0 {
+			t.Fatalf("expected no SlowDown, but got %v", n)
+		}
+	})
+}
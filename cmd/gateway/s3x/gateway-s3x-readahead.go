@@ -0,0 +1,56 @@
+package s3x
+
+import (
+	"io"
+)
+
+// defaultReadAhead is the default number of chunks prefetched from ipfs for sequential reads
+const defaultReadAhead = 2
+
+// aheadWriter writes to w in a separate goroutine, so up to n writes are queued
+// while w is still busy, which lets the ipfs download continue while a slow client reads.
+// Memory is bounded by n times the size of the writes.
+type aheadWriter struct {
+	queue  chan []byte
+	failed chan struct{} // closed after err is set
+	done   chan struct{} // closed once all queued writes are done
+	err    error
+}
+
+func newAheadWriter(w io.Writer, n int) *aheadWriter {
+	a := &aheadWriter{
+		queue:  make(chan []byte, n),
+		failed: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(a.done)
+		for p := range a.queue {
+			if a.err != nil {
+				continue // drain the queue so Write never blocks after an error
+			}
+			if _, err := w.Write(p); err != nil {
+				a.err = err
+				close(a.failed)
+			}
+		}
+	}()
+	return a
+}
+
+// Write queues a copy of p to be written, errors of earlier writes are returned
+func (a *aheadWriter) Write(p []byte) (int, error) {
+	select {
+	case <-a.failed:
+		return 0, a.err
+	case a.queue <- append([]byte(nil), p...):
+		return len(p), nil
+	}
+}
+
+// Close waits until all queued writes are done and returns the first write error
+func (a *aheadWriter) Close() error {
+	close(a.queue)
+	<-a.done
+	return a.err
+}
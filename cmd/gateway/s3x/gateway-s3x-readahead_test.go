@@ -0,0 +1,116 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_GetObject_ReadAhead(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	data := make([]byte, 10<<20)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, data), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name           string
+		readAhead      int
+		offset, length int64
+	}{
+		{"disabled", 0, 0, 0},
+		{"whole object", defaultReadAhead, 0, 0},
+		{"whole object with length", defaultReadAhead, 0, int64(len(data))},
+		{"range", defaultReadAhead, 5 << 20, 1 << 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway.readAhead = tt.readAhead
+			buf := bytes.NewBuffer(nil)
+			if err := gateway.GetObject(ctx, testBucket1, testObject1, tt.offset, tt.length, buf, "", minio.ObjectOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			want := data[tt.offset:]
+			if tt.length != 0 {
+				want = want[:tt.length]
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Fatal("bad data")
+			}
+		})
+	}
+	t.Run("client error", func(t *testing.T) {
+		gateway.readAhead = defaultReadAhead
+		if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, 0, failingWriter{}, "", minio.ObjectOptions{}); err == nil {
+			t.Fatal("expected error of writer")
+		}
+	})
+}
+
+// failingWriter fails all writes
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("client went away")
+}
+
+// slowWriter simulates a client reading at a limited speed
+type slowWriter struct {
+	bytesPerSecond int
+}
+
+func (w slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(time.Duration(len(p)) * time.Second / time.Duration(w.bytesPerSecond))
+	return len(p), nil
+}
+
+func BenchmarkS3X_GetObject_ReadAhead(b *testing.B) {
+	ctx := context.Background()
+	gateway := newTestGateway(b, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
+		b.Fatal(err)
+	}
+	data := make([]byte, 100<<20)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(b, data), minio.ObjectOptions{}); err != nil {
+		b.Fatal(err)
+	}
+	for _, readAhead := range []int{0, defaultReadAhead} {
+		b.Run(fmt.Sprintf("read-ahead=%v", readAhead), func(b *testing.B) {
+			gateway.readAhead = readAhead
+			b.Run("fast client", func(b *testing.B) {
+				b.SetBytes(int64(len(data)))
+				for n := 0; n < b.N; n++ {
+					if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, 0, ioutil.Discard, "", minio.ObjectOptions{}); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+			b.Run("slow client", func(b *testing.B) {
+				b.SetBytes(int64(len(data)))
+				for n := 0; n < b.N; n++ {
+					if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, 0, slowWriter{bytesPerSecond: 100 << 20}, "", minio.ObjectOptions{}); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
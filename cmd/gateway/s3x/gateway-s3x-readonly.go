@@ -0,0 +1,120 @@
+package s3x
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultReadOnlyThreshold is the default number of consecutive failed ipfs writes
+	// after which the gateway becomes read-only
+	defaultReadOnlyThreshold = 5
+	// writeProbeInterval is the interval between ipfs write probes while read-only
+	writeProbeInterval = 10 * time.Second
+)
+
+// writeProbeData is saved to ipfs to check if writes work again
+var writeProbeData = []byte("s3x write probe")
+
+// writeHealth tracks failed ipfs writes, once threshold consecutive writes failed
+// the gateway is read-only until a write succeeds again, a threshold of 0 disables this.
+type writeHealth struct {
+	threshold int
+
+	mu       sync.Mutex
+	failures int
+	readOnly bool
+}
+
+// record records the result of an ipfs write, only errors returned
+// by TemporalX are counted as failed writes.
+func (h *writeHealth) record(err error) {
+	if h == nil || h.threshold <= 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err == nil {
+		if h.readOnly {
+			log.Printf("ipfs writes recovered, leaving read-only mode")
+		}
+		h.failures = 0
+		h.readOnly = false
+		return
+	}
+	if !isWriteError(err) {
+		return
+	}
+	h.failures++
+	if h.failures >= h.threshold && !h.readOnly {
+		log.Printf("%v consecutive ipfs writes failed, entering read-only mode: %v", h.failures, err)
+		h.readOnly = true
+	}
+}
+
+// isWriteError returns true if err was returned by TemporalX, other than for a canceled request
+func isWriteError(err error) bool {
+	s, ok := status.FromError(errors.Cause(err))
+	return ok && s.Code() != codes.OK && s.Code() != codes.Canceled
+}
+
+// isReadOnly returns true if ipfs writes are failing
+func (h *writeHealth) isReadOnly() bool {
+	if h == nil {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.readOnly
+}
+
+// healthDagClient records the results of dag puts in a writeHealth
+type healthDagClient struct {
+	pb.NodeAPIClient
+	health *writeHealth
+}
+
+func (c *healthDagClient) Dag(ctx context.Context, in *pb.DagRequest, opts ...grpc.CallOption) (*pb.DagResponse, error) {
+	resp, err := c.NodeAPIClient.Dag(ctx, in, opts...)
+	if in.GetRequestType() == pb.DAGREQTYPE_DAG_PUT {
+		c.health.record(err)
+	}
+	return resp, err
+}
+
+// checkWritable returns minio.BackendDown if the gateway is read-only
+func (x *xObjects) checkWritable() error {
+	if x.writeHealth.isReadOnly() {
+		return minio.BackendDown{}
+	}
+	return nil
+}
+
+// fileUpload uploads r as a file to ipfs, recording the result in the write health and metrics
+func (x *xObjects) fileUpload(ctx context.Context, r io.Reader) (string, int, error) {
+	hash, size, err := ipfsFileUpload(ctx, x.fileClient, r)
+	x.writeHealth.record(err)
+	if err == nil {
+		x.metrics.add(&x.metrics.bytesUploaded, int64(size))
+	}
+	return hash, size, err
+}
+
+// probeWrites tries an ipfs write while read-only, which leaves read-only mode if it succeeds
+func (x *xObjects) probeWrites(ctx context.Context) error {
+	if !x.writeHealth.isReadOnly() {
+		return nil
+	}
+	_, err := ipfsSaveBytes(ctx, x.dagClient, writeProbeData)
+	return err
+}
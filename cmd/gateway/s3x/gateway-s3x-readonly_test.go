@@ -0,0 +1,92 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// failingDagClient wraps a NodeAPIClient and fails dag puts while fail is set,
+// with code or ResourceExhausted if it's not set
+type failingDagClient struct {
+	pb.NodeAPIClient
+	fail int32
+	code codes.Code
+}
+
+func (c *failingDagClient) Dag(ctx context.Context, in *pb.DagRequest, opts ...grpc.CallOption) (*pb.DagResponse, error) {
+	if in.GetRequestType() == pb.DAGREQTYPE_DAG_PUT && atomic.LoadInt32(&c.fail) == 1 {
+		code := c.code
+		if code == codes.OK {
+			code = codes.ResourceExhausted
+		}
+		return nil, status.Error(code, "disk full")
+	}
+	return c.NodeAPIClient.Dag(ctx, in, opts...)
+}
+
+func TestS3X_ReadOnly(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	const threshold = 3
+	gateway.writeHealth.threshold = threshold
+	failing := &failingDagClient{NodeAPIClient: gateway.dagClient.(*healthDagClient).NodeAPIClient, fail: 1}
+	dag := &healthDagClient{NodeAPIClient: failing, health: gateway.writeHealth}
+	gateway.dagClient = dag
+	gateway.ledgerStore.dag = dag
+
+	for i := 0; i < threshold; i++ {
+		_, err := gateway.PutObject(ctx, testBucket1, fmt.Sprintf("object%d", i), getTestPutObjectReader(t, []byte("data")), minio.ObjectOptions{})
+		if err == nil {
+			t.Fatal("expected failing write to return an error")
+		}
+	}
+	t.Run("read-only", func(t *testing.T) {
+		_, err := gateway.PutObject(ctx, testBucket1, "rejected", getTestPutObjectReader(t, []byte("data")), minio.ObjectOptions{})
+		if _, ok := err.(minio.BackendDown); !ok {
+			t.Fatalf("expected BackendDown, but got %v", err)
+		}
+		if err := gateway.DeleteObject(ctx, testBucket1, testObject1); err == nil {
+			t.Fatal("expected delete to be rejected")
+		}
+		buf := bytes.NewBuffer(nil)
+		if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, 0, buf, "", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != testObject1Data {
+			t.Fatalf("expected %q, but got %q", testObject1Data, buf.String())
+		}
+	})
+	t.Run("probe still failing", func(t *testing.T) {
+		if err := gateway.probeWrites(ctx); err == nil {
+			t.Fatal("expected probe to fail")
+		}
+		if !gateway.writeHealth.isReadOnly() {
+			t.Fatal("expected gateway to stay read-only")
+		}
+	})
+	t.Run("recovery", func(t *testing.T) {
+		atomic.StoreInt32(&failing.fail, 0)
+		if err := gateway.probeWrites(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if gateway.writeHealth.isReadOnly() {
+			t.Fatal("expected gateway to leave read-only mode")
+		}
+		if _, err := gateway.PutObject(ctx, testBucket1, "accepted", getTestPutObjectReader(t, []byte("data")), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
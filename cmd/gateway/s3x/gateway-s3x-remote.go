@@ -0,0 +1,88 @@
+package s3x
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/pkg/hash"
+	miniogo "github.com/minio/minio-go/v6"
+	"github.com/minio/minio-go/v6/pkg/credentials"
+)
+
+// remoteMetadataHeaders are the headers of a remote object kept as metadata of the copy,
+// in addition to user metadata
+var remoteMetadataHeaders = []string{
+	"Cache-Control",
+	"Content-Disposition",
+	"Content-Encoding",
+	"Content-Language",
+	"Content-Type",
+}
+
+// CopyFromRemote copies an object from a remote s3 endpoint, such as another s3x gateway,
+// remoteURL is the endpoint url (ie: https://s3.example.com). The object data is streamed
+// into ipfs and the content headers and user metadata of the source are kept.
+//
+// The remote is accessed with the configured remote credentials, or anonymously if none are set.
+func (x *xObjects) CopyFromRemote(ctx context.Context, remoteURL, srcBucket, srcObject, destBucket, destObject string) error {
+	destObject = x.objectKey(destObject)
+	if err := x.ledgerStore.AssertBucketExits(destBucket); err != nil {
+		return x.toMinioErr(err, destBucket, "", "")
+	}
+	client, err := x.newRemoteClient(remoteURL)
+	if err != nil {
+		return err
+	}
+	obj, err := client.GetObjectWithContext(ctx, srcBucket, srcObject, miniogo.GetObjectOptions{})
+	if err != nil {
+		return minio.ErrorRespToObjectError(err, srcBucket, srcObject)
+	}
+	defer obj.Close()
+	info, err := obj.Stat()
+	if err != nil {
+		return minio.ErrorRespToObjectError(err, srcBucket, srcObject)
+	}
+	r, err := hash.NewReader(obj, info.Size, "", "", info.Size, false)
+	if err != nil {
+		return err
+	}
+	_, err = x.PutObject(ctx, destBucket, destObject, minio.NewPutObjReader(r, nil, nil), minio.ObjectOptions{
+		UserDefined: remoteMetadata(info),
+	})
+	return err
+}
+
+// newRemoteClient returns an s3 client of the remote endpoint
+func (x *xObjects) newRemoteClient(remoteURL string) (*miniogo.Client, error) {
+	endpoint, secure, err := minio.ParseGatewayEndpoint(remoteURL)
+	if err != nil {
+		return nil, err
+	}
+	return miniogo.NewWithOptions(endpoint, &miniogo.Options{
+		Creds:        credentials.NewStaticV4(x.remoteAccessKey, x.remoteSecretKey, ""),
+		Secure:       secure,
+		Region:       "us-east-1",
+		BucketLookup: miniogo.BucketLookupAuto,
+	})
+}
+
+// remoteMetadata returns the metadata of a remote object to set on the copy
+func remoteMetadata(info miniogo.ObjectInfo) map[string]string {
+	meta := make(map[string]string)
+	for k, v := range info.Metadata {
+		if len(v) > 0 && strings.HasPrefix(http.CanonicalHeaderKey(k), "X-Amz-Meta-") {
+			meta[http.CanonicalHeaderKey(k)] = v[0]
+		}
+	}
+	for _, k := range remoteMetadataHeaders {
+		if v := info.Metadata.Get(k); v != "" {
+			meta[k] = v
+		}
+	}
+	if info.ContentType != "" {
+		meta["Content-Type"] = info.ContentType
+	}
+	return meta
+}
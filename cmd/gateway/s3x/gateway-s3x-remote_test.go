@@ -0,0 +1,82 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+// newFakeRemote returns a fake s3 server serving a single object to clients using accessKey
+func newFakeRemote(t *testing.T, bucket, object, accessKey string, data []byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "Credential="+accessKey+"/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/"+bucket+"/"+object {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("X-Amz-Meta-Origin", "remote")
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	}))
+}
+
+func TestS3X_CopyFromRemote(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	const accessKey = "remoteaccess"
+	gateway.remoteAccessKey = accessKey
+	gateway.remoteSecretKey = "remotesecret"
+	data := []byte("data of the remote object")
+	remote := newFakeRemote(t, "src", "remote.txt", accessKey, data)
+	defer remote.Close()
+
+	t.Run("copy", func(t *testing.T) {
+		if err := gateway.CopyFromRemote(ctx, remote.URL, "src", "remote.txt", testBucket1, testObject1); err != nil {
+			t.Fatal(err)
+		}
+		buf := bytes.NewBuffer(nil)
+		if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, 0, buf, "", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Fatalf("expected %q, but got %q", data, buf.Bytes())
+		}
+		info, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.ContentType != "text/plain" {
+			t.Fatalf("expected content type text/plain, but got %v", info.ContentType)
+		}
+		if v := info.UserDefined["X-Amz-Meta-Origin"]; v != "remote" {
+			t.Fatalf("expected metadata of the source, but got %v", info.UserDefined)
+		}
+	})
+	t.Run("remote object not found", func(t *testing.T) {
+		if err := gateway.CopyFromRemote(ctx, remote.URL, "src", "missing", testBucket1, "missing"); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+	t.Run("destination bucket not found", func(t *testing.T) {
+		err := gateway.CopyFromRemote(ctx, remote.URL, "src", "remote.txt", testBucket2, testObject1)
+		if _, ok := err.(minio.BucketNotFound); !ok {
+			t.Fatalf("expected BucketNotFound, but got %v", err)
+		}
+	})
+}
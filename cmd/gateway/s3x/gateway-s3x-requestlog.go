@@ -0,0 +1,56 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/RTradeLtd/s3x/cmd/logger"
+)
+
+// RequestLogLevel is the verbosity of object request logging
+type RequestLogLevel string
+
+const (
+	// RequestLogOff disables request logging, this is the default
+	RequestLogOff = RequestLogLevel("off")
+	// RequestLogErrors logs failed requests
+	RequestLogErrors = RequestLogLevel("errors")
+	// RequestLogAll logs all requests
+	RequestLogAll = RequestLogLevel("all")
+)
+
+// requestLog is filled in while an object request is processed, and logged once it's done.
+// The cid is the hash of the object data, so requests can be correlated with ipfs logs.
+type requestLog struct {
+	api, bucket, object string
+	cid                 string
+	bytes               int64
+	start               time.Time
+}
+
+func newRequestLog(api, bucket, object string) *requestLog {
+	return &requestLog{api: api, bucket: bucket, object: object, start: time.Now()}
+}
+
+// logRequest logs a finished request according to the request log level
+func (x *xObjects) logRequest(ctx context.Context, r *requestLog, err error) {
+	switch x.requestLogLevel {
+	case RequestLogAll:
+	case RequestLogErrors:
+		if err == nil {
+			return
+		}
+	default:
+		return
+	}
+	msg := fmt.Sprintf("s3x request: api=%s bucket=%s object=%s cid=%s bytes=%d duration=%s",
+		r.api, r.bucket, r.object, r.cid, r.bytes, time.Since(r.start))
+	if info := logger.GetReqInfo(ctx); info != nil && info.RequestID != "" {
+		msg += " request-id=" + info.RequestID
+	}
+	if err != nil {
+		msg += fmt.Sprintf(" error=%q", err.Error())
+	}
+	x.requestLogger("%s", msg)
+}
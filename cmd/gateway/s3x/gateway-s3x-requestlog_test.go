@@ -0,0 +1,62 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_RequestLog(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	cid, size, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logs []string
+	gateway.requestLogger = func(format string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}
+	get := func(object string) {
+		logs = nil
+		_ = gateway.GetObject(ctx, testBucket1, object, 0, 0, ioutil.Discard, "", minio.ObjectOptions{})
+	}
+	t.Run("all", func(t *testing.T) {
+		gateway.requestLogLevel = RequestLogAll
+		get(testObject1)
+		if len(logs) != 1 {
+			t.Fatalf("expected 1 log line, but got %v", logs)
+		}
+		for _, want := range []string{"api=GetObject", "cid=" + cid, fmt.Sprintf("bytes=%d", size)} {
+			if !strings.Contains(logs[0], want) {
+				t.Fatalf("expected %q in log %q", want, logs[0])
+			}
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		gateway.requestLogLevel = RequestLogErrors
+		get(testObject1)
+		if len(logs) != 0 {
+			t.Fatalf("expected successful request not to be logged, but got %v", logs)
+		}
+		get("missing")
+		if len(logs) != 1 || !strings.Contains(logs[0], "error=") {
+			t.Fatalf("expected failed request to be logged, but got %v", logs)
+		}
+	})
+	t.Run("off", func(t *testing.T) {
+		gateway.requestLogLevel = RequestLogOff
+		get("missing")
+		if len(logs) != 0 {
+			t.Fatalf("expected no logs, but got %v", logs)
+		}
+	})
+}
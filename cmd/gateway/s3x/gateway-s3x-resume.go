@@ -0,0 +1,80 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+)
+
+// s3xUploadTokenHeader is the metadata key of an optional upload token set on PutObject, a put
+// interrupted while adding the object data resumes from its last checkpoint when it's retried
+// with the same token and data, see fileUploadResumable
+const s3xUploadTokenHeader = "X-Amz-Meta-S3x-Upload-Token"
+
+// uploadToken removes the upload token from the object metadata and returns it, or "" if it's not set
+func (m *ObjectInfo) uploadToken() string {
+	var token string
+	for k, v := range m.UserDefined {
+		if strings.EqualFold(k, s3xUploadTokenHeader) {
+			delete(m.UserDefined, k)
+			token = v
+		}
+	}
+	return token
+}
+
+// fileUploadResumable adds the data of r to ipfs in segments of resumeSegmentSize bytes, and returns the
+// hash of a file linking the segments and the size of the data. Every added segment is checkpointed under
+// the token, and the segments of a previous put with the token are reused as long as the data matches,
+// so only the segments after the last checkpoint of an interrupted put are added again.
+func (x *xObjects) fileUploadResumable(ctx context.Context, bucket, token string, r io.Reader) (string, int, error) {
+	checkpoints, err := x.ledgerStore.GetUploadCheckpoints(bucket, token)
+	if err != nil {
+		return "", 0, err
+	}
+	var (
+		files []fileLink
+		size  int
+		buf   = make([]byte, x.resumeSegmentSize)
+	)
+	for i := 0; ; i++ {
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF && i > 0 {
+			break
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return "", size, err
+		}
+		sum := sha256.Sum256(buf[:n])
+		if i >= len(checkpoints) || !bytes.Equal(checkpoints[i].Sum, sum[:]) {
+			// the data differs from the previous put from here on
+			checkpoints = checkpoints[:i]
+			hash, _, err := x.fileUpload(ctx, bytes.NewReader(buf[:n]))
+			if err != nil {
+				return "", size, err
+			}
+			checkpoints = append(checkpoints, uploadCheckpoint{Hash: hash, Size: uint64(n), Sum: sum[:]})
+			if err := x.ledgerStore.PutUploadCheckpoints(bucket, token, checkpoints); err != nil {
+				return "", size, err
+			}
+		}
+		c, err := cid.Decode(checkpoints[i].Hash)
+		if err != nil {
+			return "", size, err
+		}
+		files = append(files, fileLink{cid: c, size: checkpoints[i].Size})
+		size += n
+		if n < len(buf) {
+			break
+		}
+	}
+	if len(files) == 1 {
+		return files[0].cid.String(), size, nil
+	}
+	hash, _, err := assembleFileLinks(ctx, x.dagClient, files, x.maxPartLinks, x.completeConcurrency)
+	return hash, size, err
+}
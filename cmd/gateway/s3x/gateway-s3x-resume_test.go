@@ -0,0 +1,98 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc"
+)
+
+// uploadCountingFileClient counts the files uploaded through it
+type uploadCountingFileClient struct {
+	pb.FileAPIClient
+	uploads int
+}
+
+func (c *uploadCountingFileClient) UploadFile(ctx context.Context, opts ...grpc.CallOption) (pb.FileAPI_UploadFileClient, error) {
+	c.uploads++
+	return c.FileAPIClient.UploadFile(ctx, opts...)
+}
+
+// failingReader fails once n bytes were read, like a put interrupted by a crash
+type failingReader struct {
+	r io.Reader
+	n int
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	if f.n <= 0 {
+		return 0, errors.New("interrupted")
+	}
+	if len(p) > f.n {
+		p = p[:f.n]
+	}
+	n, err := f.r.Read(p)
+	f.n -= n
+	return n, err
+}
+
+func TestS3X_PutObject_Resume(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	const segmentSize = 1024
+	data := make([]byte, 4*segmentSize)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	opts := minio.ObjectOptions{UserDefined: map[string]string{s3xUploadTokenHeader: "resume-token"}}
+	files := &uploadCountingFileClient{FileAPIClient: gateway.fileClient}
+	gateway.fileClient = files
+	gateway.resumeSegmentSize = segmentSize
+
+	// the put is interrupted half way through the third segment
+	r := minio.NewPutObjReader(getTestHashReader(t, &failingReader{r: bytes.NewReader(data), n: 2*segmentSize + segmentSize/2}, int64(len(data))), nil, nil)
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, r, opts); err == nil {
+		t.Fatal("expected the interrupted put to fail")
+	}
+	if files.uploads != 2 {
+		t.Fatalf("expected 2 segments added before the interruption, but got %v", files.uploads)
+	}
+
+	gateway.restart(t)
+	files = &uploadCountingFileClient{FileAPIClient: gateway.fileClient}
+	gateway.fileClient = files
+	gateway.resumeSegmentSize = segmentSize
+	checkpoints, err := gateway.ledgerStore.GetUploadCheckpoints(testBucket1, "resume-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checkpoints) != 2 {
+		t.Fatalf("expected 2 checkpoints to survive a restart, but got %v", len(checkpoints))
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, data), opts); err != nil {
+		t.Fatal(err)
+	}
+	if files.uploads != 2 {
+		t.Fatalf("expected only the 2 remaining segments to be added, but got %v", files.uploads)
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, 0, buf, "", minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("resumed object data does not match")
+	}
+	checkpoints, err = gateway.ledgerStore.GetUploadCheckpoints(testBucket1, "resume-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoints != nil {
+		t.Fatalf("expected the checkpoints to be removed once the object is saved, but got %v", checkpoints)
+	}
+}
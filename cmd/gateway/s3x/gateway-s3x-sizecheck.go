@@ -0,0 +1,43 @@
+package s3x
+
+import (
+	"fmt"
+	"io"
+)
+
+// ObjectSizeMismatch is returned when the data of an object in ipfs does not match the size
+// recorded in the ledger, which means the ledger or the data is corrupted
+type ObjectSizeMismatch struct {
+	Bucket string
+	Object string
+	Size   int64
+}
+
+func (e ObjectSizeMismatch) Error() string {
+	return fmt.Sprintf("data of object %v/%v does not match its recorded size of %v bytes", e.Bucket, e.Object, e.Size)
+}
+
+// sizeCheckWriter writes the expected number of bytes to w, and returns ObjectSizeMismatch
+// instead of writing more, so that the bytes after the recorded end of an object are not served
+type sizeCheckWriter struct {
+	w         io.Writer
+	remaining int64
+	err       ObjectSizeMismatch
+}
+
+func (s *sizeCheckWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > s.remaining {
+		return 0, s.err
+	}
+	n, err := s.w.Write(p)
+	s.remaining -= int64(n)
+	return n, err
+}
+
+// check returns ObjectSizeMismatch if less than the expected number of bytes were written
+func (s *sizeCheckWriter) check() error {
+	if s.remaining != 0 {
+		return s.err
+	}
+	return nil
+}
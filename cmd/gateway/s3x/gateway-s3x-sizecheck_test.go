@@ -0,0 +1,47 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_ObjectSizeMismatch(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, data), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	hash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int64{int64(len(data)) - 100, int64(len(data)) + 100} {
+		// the ledger records a size that differs from the data in ipfs
+		if err := gateway.ledgerStore.PutObject(ctx, testBucket1, "mismatch", &Object{
+			DataHash:   hash,
+			ObjectInfo: ObjectInfo{Bucket: testBucket1, Name: "mismatch", Size_: size},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		buf := bytes.NewBuffer(nil)
+		err := gateway.GetObject(ctx, testBucket1, "mismatch", 0, size, buf, "", minio.ObjectOptions{})
+		if _, ok := err.(ObjectSizeMismatch); !ok {
+			t.Fatalf("expected ObjectSizeMismatch for a recorded size of %v, but got %v", size, err)
+		}
+		if int64(buf.Len()) > size {
+			t.Fatalf("expected at most %v bytes to be served, but got %v", size, buf.Len())
+		}
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, int64(len(data)), buf, "", minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("unexpected object data")
+	}
+}
@@ -0,0 +1,36 @@
+package s3x
+
+import (
+	"context"
+)
+
+const (
+	// storageClassStandard is the storage class of objects pinned by the TemporalX node,
+	// which objects have unless they are transitioned
+	storageClassStandard = "STANDARD"
+	// storageClassCold is the storage class of objects transitioned to a remote pinning service
+	storageClassCold = "GLACIER"
+)
+
+// TransitionObject records that the object data moved to the storage class,
+// which is reported in the object info from then on.
+func (x *xObjects) TransitionObject(ctx context.Context, bucket, object, storageClass string) error {
+	object = x.objectKey(object)
+	if err := x.checkWritable(); err != nil {
+		return err
+	}
+	if err := x.checkRateLimit(ctx, bucket); err != nil {
+		return err
+	}
+	switch storageClass {
+	case storageClassStandard:
+		storageClass = "" // keep the hash of objects that were never transitioned consistent
+	case storageClassCold:
+	default:
+		return ErrInvalidStorageClass
+	}
+	return x.toMinioErr(
+		x.ledgerStore.SetObjectStorageClass(ctx, bucket, object, storageClass),
+		bucket, object, "",
+	)
+}
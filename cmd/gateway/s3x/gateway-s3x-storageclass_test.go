@@ -0,0 +1,74 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_StorageClass(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	for _, object := range []string{"fresh", "transitioned"} {
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte(object)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gateway.TransitionObject(ctx, testBucket1, "transitioned", storageClassCold); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"fresh":        storageClassStandard,
+		"transitioned": storageClassCold,
+	}
+	t.Run("GetObjectInfo", func(t *testing.T) {
+		for object, class := range want {
+			info, err := gateway.GetObjectInfo(ctx, testBucket1, object, minio.ObjectOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.StorageClass != class {
+				t.Fatalf("expected %v to have storage class %v, but got %v", object, class, info.StorageClass)
+			}
+		}
+	})
+	t.Run("ListObjects", func(t *testing.T) {
+		loi, err := gateway.ListObjects(ctx, testBucket1, "", "", "", 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(loi.Objects) != len(want) {
+			t.Fatalf("expected %v objects, but got %v", len(want), len(loi.Objects))
+		}
+		for _, info := range loi.Objects {
+			if info.StorageClass != want[info.Name] {
+				t.Fatalf("expected %v to have storage class %v, but got %v", info.Name, want[info.Name], info.StorageClass)
+			}
+		}
+	})
+	t.Run("back to standard", func(t *testing.T) {
+		if err := gateway.TransitionObject(ctx, testBucket1, "transitioned", storageClassStandard); err != nil {
+			t.Fatal(err)
+		}
+		info, err := gateway.GetObjectInfo(ctx, testBucket1, "transitioned", minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.StorageClass != storageClassStandard {
+			t.Fatalf("expected storage class %v, but got %v", storageClassStandard, info.StorageClass)
+		}
+	})
+	t.Run("invalid storage class", func(t *testing.T) {
+		if err := gateway.TransitionObject(ctx, testBucket1, "fresh", "DEEP_FREEZE"); err != ErrInvalidStorageClass {
+			t.Fatalf("expected ErrInvalidStorageClass, but got %v", err)
+		}
+	})
+	t.Run("object not found", func(t *testing.T) {
+		err := gateway.TransitionObject(ctx, testBucket1, "missing", storageClassCold)
+		if _, ok := err.(minio.ObjectNotFound); !ok {
+			t.Fatalf("expected ObjectNotFound, but got %v", err)
+		}
+	})
+}
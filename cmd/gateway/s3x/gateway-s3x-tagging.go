@@ -0,0 +1,68 @@
+package s3x
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/RTradeLtd/s3x/pkg/bucket/object/tagging"
+)
+
+// maxBucketTags is the maximum number of tags of a bucket, the same as in S3
+const maxBucketTags = 50
+
+// ErrTooManyBucketTags is returned when a bucket tagging has more than maxBucketTags tags
+var ErrTooManyBucketTags = tagging.Errorf("Bucket tags cannot be greater than 50", "BadRequest")
+
+// SetBucketTagging replaces the tags of a bucket, an empty tagging removes all tags
+func (x *xObjects) SetBucketTagging(ctx context.Context, bucket string, t *tagging.Tagging) error {
+	if len(t.TagSet.Tags) == 0 {
+		return x.DeleteBucketTagging(ctx, bucket)
+	}
+	if len(t.TagSet.Tags) > maxBucketTags {
+		return ErrTooManyBucketTags
+	}
+	tags := make(map[string]string, len(t.TagSet.Tags))
+	for _, tag := range t.TagSet.Tags {
+		if err := tag.Validate(); err != nil {
+			return err
+		}
+		if _, ok := tags[tag.Key]; ok {
+			return tagging.ErrInvalidTag
+		}
+		tags[tag.Key] = tag.Value
+	}
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return err
+	}
+	return x.toMinioErr(x.ledgerStore.PutBucketConfig(bucket, bucketConfigTagging, data), bucket, "", "")
+}
+
+// GetBucketTagging returns the tags of a bucket ordered by key, it has no tags if none are set
+func (x *xObjects) GetBucketTagging(ctx context.Context, bucket string) (*tagging.Tagging, error) {
+	data, err := x.ledgerStore.GetBucketConfig(bucket, bucketConfigTagging)
+	if err != nil {
+		return nil, x.toMinioErr(err, bucket, "", "")
+	}
+	t := &tagging.Tagging{}
+	if data == nil {
+		return t, nil
+	}
+	tags := make(map[string]string)
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return nil, err
+	}
+	for k, v := range tags {
+		t.TagSet.Tags = append(t.TagSet.Tags, tagging.Tag{Key: k, Value: v})
+	}
+	sort.Slice(t.TagSet.Tags, func(i, j int) bool {
+		return t.TagSet.Tags[i].Key < t.TagSet.Tags[j].Key
+	})
+	return t, nil
+}
+
+// DeleteBucketTagging removes all tags of a bucket
+func (x *xObjects) DeleteBucketTagging(ctx context.Context, bucket string) error {
+	return x.toMinioErr(x.ledgerStore.DeleteBucketConfig(bucket, bucketConfigTagging), bucket, "", "")
+}
@@ -0,0 +1,83 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/pkg/bucket/object/tagging"
+)
+
+func TestS3X_BucketTagging(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	newTagging := func(n int) *tagging.Tagging {
+		t := &tagging.Tagging{}
+		for i := 0; i < n; i++ {
+			t.TagSet.Tags = append(t.TagSet.Tags, tagging.Tag{
+				Key:   fmt.Sprintf("key%03d", i),
+				Value: fmt.Sprint("value", i),
+			})
+		}
+		return t
+	}
+	t.Run("round trip", func(t *testing.T) {
+		want := newTagging(maxBucketTags)
+		if err := gateway.SetBucketTagging(ctx, testBucket1, want); err != nil {
+			t.Fatal(err)
+		}
+		got, err := gateway.GetBucketTagging(ctx, testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got.TagSet.Tags, want.TagSet.Tags) {
+			t.Fatalf("expected tags %v, but got %v", want, got)
+		}
+	})
+	t.Run("too many tags", func(t *testing.T) {
+		if err := gateway.SetBucketTagging(ctx, testBucket1, newTagging(maxBucketTags+1)); err != ErrTooManyBucketTags {
+			t.Fatalf("expected ErrTooManyBucketTags, but got %v", err)
+		}
+		got, err := gateway.GetBucketTagging(ctx, testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.TagSet.Tags) != maxBucketTags {
+			t.Fatal("expected rejected tagging to keep the previous tags")
+		}
+	})
+	t.Run("invalid tags", func(t *testing.T) {
+		dup := newTagging(2)
+		dup.TagSet.Tags[1].Key = dup.TagSet.Tags[0].Key
+		if err := gateway.SetBucketTagging(ctx, testBucket1, dup); err != tagging.ErrInvalidTag {
+			t.Fatalf("expected ErrInvalidTag, but got %v", err)
+		}
+		empty := newTagging(1)
+		empty.TagSet.Tags[0].Key = ""
+		if err := gateway.SetBucketTagging(ctx, testBucket1, empty); err != tagging.ErrInvalidTagKey {
+			t.Fatalf("expected ErrInvalidTagKey, but got %v", err)
+		}
+	})
+	t.Run("delete", func(t *testing.T) {
+		if err := gateway.DeleteBucketTagging(ctx, testBucket1); err != nil {
+			t.Fatal(err)
+		}
+		got, err := gateway.GetBucketTagging(ctx, testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.TagSet.Tags) != 0 {
+			t.Fatalf("expected no tags, but got %v", got)
+		}
+	})
+	t.Run("bucket not found", func(t *testing.T) {
+		if err := gateway.SetBucketTagging(ctx, testBucket2, newTagging(1)); err == nil {
+			t.Fatal("expected error")
+		} else if _, ok := err.(minio.BucketNotFound); !ok {
+			t.Fatalf("expected BucketNotFound, but got %v", err)
+		}
+	})
+}
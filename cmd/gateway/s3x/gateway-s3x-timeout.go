@@ -0,0 +1,188 @@
+package s3x
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	"google.golang.org/grpc"
+)
+
+const (
+	// defaultDagReadTimeout is the default timeout of a dag get
+	defaultDagReadTimeout = time.Minute
+	// defaultDagWriteTimeout is the default timeout of a dag put, which includes pinning
+	// and can legitimately take longer than a read
+	defaultDagWriteTimeout = 5 * time.Minute
+	// defaultStreamTimeout is the default total time of an ipfs file upload or download
+	defaultStreamTimeout = time.Hour
+)
+
+// timeoutDagClient caps the time of dag requests, puts and gets have separate timeouts
+// which are applied on top of the request context, a timeout of 0 leaves the context as is.
+type timeoutDagClient struct {
+	pb.NodeAPIClient
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+func (c *timeoutDagClient) Dag(ctx context.Context, in *pb.DagRequest, opts ...grpc.CallOption) (*pb.DagResponse, error) {
+	timeout := c.readTimeout
+	if in.GetRequestType() == pb.DAGREQTYPE_DAG_PUT {
+		timeout = c.writeTimeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return c.NodeAPIClient.Dag(ctx, in, opts...)
+}
+
+// timeoutFileClient caps the time of file streams. Each message of a stream has to be sent or
+// received within the idle timeout of its direction, the time spent on the client of the gateway
+// between messages doesn't count, and the whole stream has to finish within the stream timeout.
+// A timeout of 0 disables it.
+type timeoutFileClient struct {
+	pb.FileAPIClient
+	uploadIdle   time.Duration
+	downloadIdle time.Duration
+	timeout      time.Duration
+}
+
+func (c *timeoutFileClient) UploadFile(ctx context.Context, opts ...grpc.CallOption) (pb.FileAPI_UploadFileClient, error) {
+	ctx, d := newStreamDeadline(ctx, c.uploadIdle, c.timeout)
+	var stream pb.FileAPI_UploadFileClient
+	if err := d.call(func() (err error) {
+		stream, err = c.FileAPIClient.UploadFile(ctx, opts...)
+		return err
+	}); err != nil {
+		d.stop()
+		return nil, err
+	}
+	return &timeoutUpload{FileAPI_UploadFileClient: stream, d: d}, nil
+}
+
+func (c *timeoutFileClient) DownloadFile(ctx context.Context, in *pb.DownloadRequest, opts ...grpc.CallOption) (pb.FileAPI_DownloadFileClient, error) {
+	ctx, d := newStreamDeadline(ctx, c.downloadIdle, c.timeout)
+	var stream pb.FileAPI_DownloadFileClient
+	if err := d.call(func() (err error) {
+		stream, err = c.FileAPIClient.DownloadFile(ctx, in, opts...)
+		return err
+	}); err != nil {
+		d.stop()
+		return nil, err
+	}
+	return &timeoutDownload{FileAPI_DownloadFileClient: stream, d: d}, nil
+}
+
+// timeoutUpload is an upload stream of timeoutFileClient
+type timeoutUpload struct {
+	pb.FileAPI_UploadFileClient
+	d *streamDeadline
+}
+
+func (u *timeoutUpload) Send(req *pb.UploadRequest) error {
+	return u.d.call(func() error { return u.FileAPI_UploadFileClient.Send(req) })
+}
+
+func (u *timeoutUpload) CloseSend() error {
+	defer u.d.stop()
+	return u.FileAPI_UploadFileClient.CloseSend()
+}
+
+func (u *timeoutUpload) CloseAndRecv() (resp *pb.UploadResponse, err error) {
+	defer u.d.stop()
+	err = u.d.call(func() (err error) {
+		resp, err = u.FileAPI_UploadFileClient.CloseAndRecv()
+		return err
+	})
+	return resp, err
+}
+
+// timeoutDownload is a download stream of timeoutFileClient
+type timeoutDownload struct {
+	pb.FileAPI_DownloadFileClient
+	d *streamDeadline
+}
+
+func (d *timeoutDownload) Recv() (resp *pb.DownloadResponse, err error) {
+	err = d.d.call(func() (err error) {
+		resp, err = d.FileAPI_DownloadFileClient.Recv()
+		return err
+	})
+	if err != nil {
+		d.d.stop()
+	}
+	return resp, err
+}
+
+// CloseSend is called by readers that stop before the end of the download, which is not read anymore
+func (d *timeoutDownload) CloseSend() error {
+	defer d.d.stop()
+	return d.FileAPI_DownloadFileClient.CloseSend()
+}
+
+// streamDeadline cancels the context of a stream once a call takes longer than the idle timeout
+type streamDeadline struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	idle    time.Duration
+	timer   *time.Timer //runs expire, only while a call is in progress
+	expired int32
+}
+
+// newStreamDeadline returns the context of a stream, which is done after the stream timeout
+// or once a call takes longer than the idle timeout, until stopped
+func newStreamDeadline(ctx context.Context, idle, timeout time.Duration) (context.Context, *streamDeadline) {
+	var cancelTimeout context.CancelFunc = func() {}
+	if timeout > 0 {
+		ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	d := &streamDeadline{
+		ctx: ctx,
+		cancel: func() {
+			cancel()
+			cancelTimeout()
+		},
+		idle: idle,
+	}
+	if idle > 0 {
+		d.timer = time.AfterFunc(idle, d.expire)
+		d.timer.Stop()
+	}
+	return ctx, d
+}
+
+func (d *streamDeadline) expire() {
+	atomic.StoreInt32(&d.expired, 1)
+	d.cancel()
+}
+
+// call runs f within the idle timeout, errors caused by either timeout are returned as
+// context.DeadlineExceeded, so they are reported the same as dag timeouts
+func (d *streamDeadline) call(f func() error) error {
+	if d.timer != nil {
+		d.timer.Reset(d.idle)
+	}
+	err := f()
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	if err != nil && err != io.EOF &&
+		(atomic.LoadInt32(&d.expired) == 1 || d.ctx.Err() == context.DeadlineExceeded) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// stop releases the context of a finished stream
+func (d *streamDeadline) stop() {
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.cancel()
+}
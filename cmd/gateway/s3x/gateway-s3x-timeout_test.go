@@ -0,0 +1,161 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+)
+
+// slowDagClient wraps a NodeAPIClient and delays all dag requests
+type slowDagClient struct {
+	pb.NodeAPIClient
+	delay time.Duration
+}
+
+func (c *slowDagClient) Dag(ctx context.Context, in *pb.DagRequest, opts ...grpc.CallOption) (*pb.DagResponse, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(c.delay):
+	}
+	return c.NodeAPIClient.Dag(ctx, in, opts...)
+}
+
+// slowFileClient wraps a FileAPIClient and delays all stream messages
+type slowFileClient struct {
+	pb.FileAPIClient
+	delay time.Duration
+}
+
+func (c *slowFileClient) UploadFile(ctx context.Context, opts ...grpc.CallOption) (pb.FileAPI_UploadFileClient, error) {
+	stream, err := c.FileAPIClient.UploadFile(ctx, opts...)
+	return &slowUpload{FileAPI_UploadFileClient: stream, ctx: ctx, delay: c.delay}, err
+}
+
+func (c *slowFileClient) DownloadFile(ctx context.Context, in *pb.DownloadRequest, opts ...grpc.CallOption) (pb.FileAPI_DownloadFileClient, error) {
+	stream, err := c.FileAPIClient.DownloadFile(ctx, in, opts...)
+	return &slowDownload{FileAPI_DownloadFileClient: stream, ctx: ctx, delay: c.delay}, err
+}
+
+type slowUpload struct {
+	pb.FileAPI_UploadFileClient
+	ctx   context.Context
+	delay time.Duration
+}
+
+func (u *slowUpload) Send(req *pb.UploadRequest) error {
+	select {
+	case <-u.ctx.Done():
+		return u.ctx.Err()
+	case <-time.After(u.delay):
+	}
+	return u.FileAPI_UploadFileClient.Send(req)
+}
+
+type slowDownload struct {
+	pb.FileAPI_DownloadFileClient
+	ctx   context.Context
+	delay time.Duration
+}
+
+func (d *slowDownload) Recv() (*pb.DownloadResponse, error) {
+	select {
+	case <-d.ctx.Done():
+		return nil, d.ctx.Err()
+	case <-time.After(d.delay):
+	}
+	return d.FileAPI_DownloadFileClient.Recv()
+}
+
+// slowWriter stands for a slow client of the gateway
+type slowWriter struct {
+	bytes.Buffer
+	delay time.Duration
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	return w.Buffer.Write(p)
+}
+
+func TestS3X_DagTimeouts(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// every dag request takes longer than the write timeout, but not the read timeout
+	node := gateway.dagClient.(*healthDagClient).NodeAPIClient.(*timeoutDagClient).NodeAPIClient
+	dag := &healthDagClient{
+		NodeAPIClient: &timeoutDagClient{
+			NodeAPIClient: &slowDagClient{NodeAPIClient: node, delay: 200 * time.Millisecond},
+			readTimeout:   10 * time.Second,
+			writeTimeout:  50 * time.Millisecond,
+		},
+		health: gateway.writeHealth,
+	}
+	gateway.dagClient = dag
+	gateway.ledgerStore.dag = dag
+	gateway.ledgerStore.objects = newObjectCache(0) // make sure reads go to ipfs
+	t.Run("write exceeds write timeout", func(t *testing.T) {
+		_, err := gateway.PutObject(ctx, testBucket1, "slow", getTestPutObjectReader(t, []byte("data")), minio.ObjectOptions{})
+		if errors.Cause(err) != context.DeadlineExceeded {
+			t.Fatalf("expected context.DeadlineExceeded, but got %v", err)
+		}
+	})
+	t.Run("read under read timeout", func(t *testing.T) {
+		if _, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestS3X_StreamTimeouts(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	hash, _, err := ipfsFileUpload(ctx, gateway.fileClient, strings.NewReader(testObject1Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := gateway.fileClient.(*timeoutFileClient).FileAPIClient
+	slow := &slowFileClient{FileAPIClient: files, delay: 200 * time.Millisecond}
+	t.Run("upload exceeds idle timeout", func(t *testing.T) {
+		client := &timeoutFileClient{FileAPIClient: slow, uploadIdle: 50 * time.Millisecond}
+		_, _, err := ipfsFileUpload(ctx, client, strings.NewReader("data"))
+		if errors.Cause(err) != context.DeadlineExceeded {
+			t.Fatalf("expected context.DeadlineExceeded, but got %v", err)
+		}
+	})
+	t.Run("download exceeds stream timeout", func(t *testing.T) {
+		client := &timeoutFileClient{FileAPIClient: slow, downloadIdle: 10 * time.Second, timeout: 50 * time.Millisecond}
+		_, err := ipfsFileDownload(ctx, client, ioutil.Discard, hash, 0, 0)
+		if errors.Cause(err) != context.DeadlineExceeded {
+			t.Fatalf("expected context.DeadlineExceeded, but got %v", err)
+		}
+	})
+	t.Run("slow client under idle timeout", func(t *testing.T) {
+		// only the time TemporalX takes counts against the idle timeout
+		client := &timeoutFileClient{FileAPIClient: files, downloadIdle: 50 * time.Millisecond}
+		w := &slowWriter{delay: 100 * time.Millisecond}
+		if _, err := ipfsFileDownload(ctx, client, w, hash, 0, 0); err != nil {
+			t.Fatal(err)
+		}
+		if w.String() != testObject1Data {
+			t.Fatalf("expected %q, but got %q", testObject1Data, w.String())
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package s3x
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+// s3xMetaTransformers is the internal metadata key of the names of the transformers
+// applied to the object data stored on ipfs, comma separated in the order they were applied
+const s3xMetaTransformers = minio.ReservedMetadataPrefix + "S3x-Transformers"
+
+// ObjectTransformer transforms object data before it's added to ipfs and back when it's read,
+// such as to encrypt it or to scan it. Transformers are configured with TEMX.Transformers.
+//
+// SSE is not a transformer: the handlers encrypt and decrypt object data with the keys of each
+// request before it reaches the gateway, which only stores the sealed data with its encryption
+// metadata, see setEncryptionMetadata. Transformers are applied to the sealed data.
+type ObjectTransformer interface {
+	// Name identifies the transformer in the metadata of the objects it transformed,
+	// so it must not change, and must not contain commas
+	Name() string
+	// Wrap returns a reader of the transformed data of r
+	Wrap(r io.Reader) io.Reader
+	// Unwrap returns a reader of the original data of r, the inverse of Wrap
+	Unwrap(r io.Reader) io.Reader
+}
+
+// gzipTransformer gzip compresses object data, it's applied to content types in TEMX.CompressTypes
+// and recorded as s3xMetaCompression instead of by name, see ObjectInfo.isCompressed
+type gzipTransformer struct{}
+
+func (gzipTransformer) Name() string { return compressionGzip }
+
+func (gzipTransformer) Wrap(r io.Reader) io.Reader { return gzipReader(r) }
+
+func (gzipTransformer) Unwrap(r io.Reader) io.Reader { return &gunzipReader{r: r} }
+
+// gunzipReader decompresses the gzip data of r, the gzip header is read on the first read
+type gunzipReader struct {
+	r  io.Reader
+	gr *gzip.Reader
+}
+
+func (g *gunzipReader) Read(p []byte) (int, error) {
+	if g.gr == nil {
+		gr, err := gzip.NewReader(g.r)
+		if err != nil {
+			return 0, err
+		}
+		g.gr = gr
+	}
+	return g.gr.Read(p)
+}
+
+// isTransformed returns true if the object data is stored transformed by TEMX.Transformers
+func (m *ObjectInfo) isTransformed() bool {
+	return m.GetUserDefined()[s3xMetaTransformers] != ""
+}
+
+// writeTransformers returns the transformers to apply to new object data with the content type
+func (x *xObjects) writeTransformers(contentType string) []ObjectTransformer {
+	if !x.shouldCompress(contentType) {
+		return x.transformers
+	}
+	return append([]ObjectTransformer{gzipTransformer{}}, x.transformers...)
+}
+
+// readTransformers returns the transformers that were applied to the stored object data,
+// in the order they were applied. Objects stored before a transformer was configured
+// are read as they are, but objects stored with a transformer can only be read while it's configured.
+func (x *xObjects) readTransformers(m *ObjectInfo) ([]ObjectTransformer, error) {
+	var chain []ObjectTransformer
+	if m.isCompressed() {
+		chain = append(chain, gzipTransformer{})
+	}
+	if !m.isTransformed() {
+		return chain, nil
+	}
+	for _, name := range strings.Split(m.GetUserDefined()[s3xMetaTransformers], ",") {
+		t := x.transformer(name)
+		if t == nil {
+			return nil, ErrUnknownTransformer
+		}
+		chain = append(chain, t)
+	}
+	return chain, nil
+}
+
+// transformer returns the configured transformer with the name, or nil if there is none
+func (x *xObjects) transformer(name string) ObjectTransformer {
+	for _, t := range x.transformers {
+		if t.Name() == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// transformerNames returns the names of the transformers, comma separated
+func transformerNames(chain []ObjectTransformer) string {
+	names := make([]string, len(chain))
+	for i, t := range chain {
+		names[i] = t.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// wrapReader applies the transformers to r in order, the returned function
+// releases the resources of the readers returned by the transformers.
+func wrapReader(r io.Reader, chain []ObjectTransformer) (io.Reader, func()) {
+	var closers []io.Closer
+	for _, t := range chain {
+		r = t.Wrap(r)
+		if c, ok := r.(io.Closer); ok {
+			closers = append(closers, c)
+		}
+	}
+	return r, func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			_ = closers[i].Close()
+		}
+	}
+}
+
+// unwrapReader applies the inverse of the transformers to r in reverse order, the returned
+// function releases the resources of the readers returned by the transformers.
+func unwrapReader(r io.Reader, chain []ObjectTransformer) (io.Reader, func()) {
+	var closers []io.Closer
+	for i := len(chain) - 1; i >= 0; i-- {
+		r = chain[i].Unwrap(r)
+		if c, ok := r.(io.Closer); ok {
+			closers = append(closers, c)
+		}
+	}
+	return r, func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			_ = closers[i].Close()
+		}
+	}
+}
+
+// transformedDownload returns a function like ipfsFileDownload for data stored transformed
+// by the chain. The stored data is downloaded from the start, as it can only be unwrapped
+// in order, and the data before startOffset is discarded.
+func transformedDownload(chain []ObjectTransformer) func(context.Context, pb.FileAPIClient, io.Writer, string, int64, int64) (int64, error) {
+	return func(ctx context.Context, fileClient pb.FileAPIClient, w io.Writer, hash string, startOffset, length int64) (int64, error) {
+		pr, pw := io.Pipe()
+		defer pr.Close()
+		go func() {
+			_, err := ipfsFileDownload(ctx, fileClient, pw, hash, 0, 0)
+			_ = pw.CloseWithError(err)
+		}()
+		r, done := unwrapReader(pr, chain)
+		defer done()
+		if _, err := io.CopyN(ioutil.Discard, r, startOffset); err != nil {
+			return 0, err
+		}
+		if length == 0 {
+			return io.Copy(w, r)
+		}
+		return io.CopyN(w, r, length)
+	}
+}
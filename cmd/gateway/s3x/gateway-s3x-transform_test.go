@@ -0,0 +1,120 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+// xorTransformer xors object data with a key, so it's its own inverse
+type xorTransformer struct {
+	key byte
+}
+
+func (xorTransformer) Name() string { return "xor" }
+
+func (t xorTransformer) Wrap(r io.Reader) io.Reader { return &xorReader{r: r, key: t.key} }
+
+func (t xorTransformer) Unwrap(r io.Reader) io.Reader { return &xorReader{r: r, key: t.key} }
+
+type xorReader struct {
+	r   io.Reader
+	key byte
+}
+
+func (x *xorReader) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	for i := range p[:n] {
+		p[i] ^= x.key
+	}
+	return n, err
+}
+
+func TestS3X_Transformers(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	get := func(t *testing.T, object string, startOffset, length int64) ([]byte, error) {
+		buf := bytes.NewBuffer(nil)
+		err := gateway.GetObject(ctx, testBucket1, object, startOffset, length, buf, "", minio.ObjectOptions{})
+		return buf.Bytes(), err
+	}
+	legacy := []byte("stored before the transformer was configured")
+	if _, err := gateway.PutObject(ctx, testBucket1, "legacy", getTestPutObjectReader(t, legacy), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	gateway.transformers = []ObjectTransformer{xorTransformer{key: 0x5a}}
+	gateway.compressTypes = []string{"text/*"}
+	data := []byte(strings.Repeat("transformed object data ", 100))
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, data), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	opts := minio.ObjectOptions{UserDefined: map[string]string{"content-type": "text/plain"}}
+	if _, err := gateway.PutObject(ctx, testBucket1, "compressed", getTestPutObjectReader(t, data), opts); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("stored transformed", func(t *testing.T) {
+		hash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := bytes.NewBuffer(nil)
+		if _, err := ipfsFileDownload(ctx, gateway.fileClient, buf, hash, 0, 0); err != nil {
+			t.Fatal(err)
+		}
+		stored := buf.Bytes()
+		if len(stored) != len(data) {
+			t.Fatalf("expected %v stored bytes, but got %v", len(data), len(stored))
+		}
+		for i := range stored {
+			if stored[i] != data[i]^0x5a {
+				t.Fatalf("expected stored data to be transformed, but byte %v is %v", i, stored[i])
+			}
+		}
+	})
+	t.Run("round trip", func(t *testing.T) {
+		for _, object := range []string{testObject1, "compressed"} {
+			got, err := get(t, object, 0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("expected the original data of %v, but got %q", object, got)
+			}
+			got, err = get(t, object, 100, 50)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data[100:150]) {
+				t.Fatalf("expected the original range of %v, but got %q", object, got)
+			}
+		}
+	})
+	t.Run("legacy objects", func(t *testing.T) {
+		got, err := get(t, "legacy", 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, legacy) {
+			t.Fatalf("expected legacy data unchanged, but got %q", got)
+		}
+	})
+	t.Run("disabled", func(t *testing.T) {
+		gateway.transformers = nil
+		got, err := get(t, "legacy", 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, legacy) {
+			t.Fatalf("expected legacy data unchanged, but got %q", got)
+		}
+		if _, err := get(t, testObject1, 0, 0); !isInvalidRequest(err, ErrUnknownTransformer) {
+			t.Fatalf("expected InvalidRequest for ErrUnknownTransformer, but got %v", err)
+		}
+	})
+}
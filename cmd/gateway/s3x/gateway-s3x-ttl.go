@@ -0,0 +1,73 @@
+package s3x
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+const (
+	// s3xTTLHeader is the metadata key of an optional object ttl in seconds set on PutObject
+	s3xTTLHeader = "X-Amz-Meta-S3x-Ttl"
+	// s3xMetaExpires is the internal metadata key recording when an object with a ttl expires
+	s3xMetaExpires = minio.ReservedMetadataPrefix + "S3x-Expires"
+
+	// defaultTTLSweepInterval is the default interval between removals of expired objects
+	defaultTTLSweepInterval = time.Minute
+)
+
+// applyObjectTTL converts a ttl in the object metadata into an expiry time,
+// the ttl itself is not kept as user metadata.
+func (m *ObjectInfo) applyObjectTTL(now time.Time) error {
+	for k, v := range m.UserDefined {
+		if !strings.EqualFold(k, s3xTTLHeader) {
+			continue
+		}
+		delete(m.UserDefined, k)
+		ttl, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || ttl <= 0 {
+			return minio.UnsupportedMetadata{}
+		}
+		m.UserDefined[s3xMetaExpires] = now.Add(time.Duration(ttl) * time.Second).UTC().Format(time.RFC3339Nano)
+	}
+	return nil
+}
+
+// expiry returns when the object expires, ok is false if the object has no ttl
+func (m *ObjectInfo) expiry() (t time.Time, ok bool) {
+	v, ok := m.GetUserDefined()[s3xMetaExpires]
+	if !ok {
+		return t, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, v)
+	return t, err == nil
+}
+
+// expired returns true if the object has a ttl that has passed at now
+func (m *ObjectInfo) expired(now time.Time) bool {
+	t, ok := m.expiry()
+	return ok && !now.Before(t)
+}
+
+// sweepExpiredObjects removes all objects which expired before now from the ledger
+func (x *xObjects) sweepExpiredObjects(ctx context.Context) error {
+	now := time.Now()
+	expired, err := x.ledgerStore.GetExpiredObjects(now)
+	if err != nil {
+		return err
+	}
+	for bucket, objects := range expired {
+		removed, err := x.ledgerStore.RemoveExpiredObjects(ctx, bucket, now, objects...)
+		if err != nil {
+			return err
+		}
+		for _, object := range removed {
+			log.Printf("bucket-name: %s, object-name: %s, expired", bucket, object)
+		}
+	}
+	return nil
+}
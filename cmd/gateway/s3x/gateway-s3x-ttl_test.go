@@ -0,0 +1,98 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_TTL_Badger(t *testing.T) {
+	testS3XTTL(t, DSTypeBadger)
+}
+func TestS3X_TTL_Crdt(t *testing.T) {
+	testS3XTTL(t, DSTypeCrdt)
+}
+func testS3XTTL(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, dsType)
+	defer shutdown()
+	ttlOpts := minio.ObjectOptions{UserDefined: map[string]string{s3xTTLHeader: "1"}}
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(testObject1Data)), ttlOpts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, "no-ttl", getTestPutObjectReader(t, []byte(testObject1Data)), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("readable before expiry", func(t *testing.T) {
+		info, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := info.UserDefined[s3xTTLHeader]; ok {
+			t.Fatal("ttl should not be stored as user metadata")
+		}
+		buf := bytes.NewBuffer(nil)
+		if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, 0, buf, "", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("invalid ttl", func(t *testing.T) {
+		opts := minio.ObjectOptions{UserDefined: map[string]string{s3xTTLHeader: "soon"}}
+		if _, err := gateway.PutObject(ctx, testBucket1, "invalid", getTestPutObjectReader(t, []byte(testObject1Data)), opts); err == nil {
+			t.Fatal("expected error for invalid ttl")
+		}
+	})
+	time.Sleep(1100 * time.Millisecond)
+	t.Run("not found after expiry", func(t *testing.T) {
+		_, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+		if _, ok := err.(minio.ObjectNotFound); !ok {
+			t.Fatalf("expected ObjectNotFound, but got %v", err)
+		}
+		err = gateway.GetObject(ctx, testBucket1, testObject1, 0, 0, bytes.NewBuffer(nil), "", minio.ObjectOptions{})
+		if _, ok := err.(minio.ObjectNotFound); !ok {
+			t.Fatalf("expected ObjectNotFound, but got %v", err)
+		}
+		loi, err := gateway.ListObjects(ctx, testBucket1, "", "", "", 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(loi.Objects) != 1 || loi.Objects[0].Name != "no-ttl" {
+			t.Fatalf("expected only the object without ttl to be listed, but got %+v", loi.Objects)
+		}
+	})
+	t.Run("swept", func(t *testing.T) {
+		if err := gateway.sweepExpiredObjects(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gateway.ledgerStore.GetObjectHash(ctx, testBucket1, testObject1); err != ErrLedgerObjectDoesNotExist {
+			t.Fatalf("expected expired object to be removed from the ledger, but got %v", err)
+		}
+		if _, err := gateway.ledgerStore.GetObjectHash(ctx, testBucket1, "no-ttl"); err != nil {
+			t.Fatal(err)
+		}
+		expired, err := gateway.ledgerStore.GetExpiredObjects(time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(expired) != 0 {
+			t.Fatalf("expected no expired objects left, but got %v", expired)
+		}
+	})
+	t.Run("deleted with bucket", func(t *testing.T) {
+		if err := gateway.MakeBucketWithLocation(ctx, testBucket2, "us-east-1"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gateway.PutObject(ctx, testBucket2, testObject1, getTestPutObjectReader(t, []byte(testObject1Data)), ttlOpts); err != nil {
+			t.Fatal(err)
+		}
+		if err := gateway.ledgerStore.DeleteBucket(testBucket2); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok, err := gateway.ledgerStore.getExpiry(testBucket2, testObject1); err != nil || ok {
+			t.Fatalf("expected the expiry to be deleted with the bucket, but got %v, %v", ok, err)
+		}
+	})
+}
@@ -1,7 +1,14 @@
 package s3x
 
 import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
 	minio "github.com/RTradeLtd/s3x/cmd"
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
 )
 
 /* Design Notes
@@ -16,13 +23,124 @@ func getMinioObjectInfo(o *ObjectInfo) minio.ObjectInfo {
 	if o == nil {
 		return minio.ObjectInfo{}
 	}
+	userDefined := o.UserDefined
+	_, inline := userDefined[s3xMetaInlineData]
+	if inline || o.ContentDisposition != "" || o.ContentLanguage != "" {
+		// inline data is only used internally, avoid copying it around
+		userDefined = make(map[string]string, len(o.UserDefined)+2)
+		for k, v := range o.UserDefined {
+			if k != s3xMetaInlineData {
+				userDefined[k] = v
+			}
+		}
+		// minio only replays these headers from the user defined metadata
+		if o.ContentDisposition != "" {
+			userDefined[xhttp.ContentDisposition] = o.ContentDisposition
+		}
+		if o.ContentLanguage != "" {
+			userDefined[xhttp.ContentLanguage] = o.ContentLanguage
+		}
+	}
+	var expires time.Time
+	if o.Expires != "" {
+		// an invalid expires is not served
+		expires, _ = http.ParseTime(o.Expires)
+	}
+	storageClass := o.StorageClass
+	if storageClass == "" {
+		storageClass = storageClassStandard
+	}
+	var parts []minio.ObjectPartInfo
+	for _, p := range o.Parts {
+		parts = append(parts, minio.ObjectPartInfo{
+			Number:     int(p.Number),
+			Size:       p.Size_,
+			ActualSize: p.ActualSize,
+		})
+	}
 	return minio.ObjectInfo{
-		Bucket:      o.Bucket,
-		Name:        o.Name,
-		ETag:        minio.ToS3ETag(o.Etag),
-		Size:        o.Size_,
-		ModTime:     o.ModTime,
-		ContentType: o.ContentType,
-		UserDefined: o.UserDefined,
+		Bucket:          o.Bucket,
+		Name:            o.Name,
+		ETag:            minio.ToS3ETag(o.Etag),
+		Size:            o.Size_,
+		ModTime:         o.ModTime,
+		ContentType:     o.ContentType,
+		ContentEncoding: o.ContentEncoding,
+		Expires:         expires,
+		StorageClass:    storageClass,
+		UserDefined:     userDefined,
+		Parts:           parts,
+	}
+}
+
+// runBounded calls fn for every index in [0, n) using at most concurrency goroutines.
+// The first error returned by fn cancels the context given to the remaining calls,
+// and is returned once all running calls are done.
+func runBounded(ctx context.Context, n, concurrency int, fn func(ctx context.Context, i int) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		next     = make(chan int)
+	)
+	for w := 0; w < concurrency && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if err := fn(ctx, i); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// startPeriodic calls fn every interval in a new goroutine until ctx is done,
+// errors are logged with the given description of what failed.
+// The returned function stops the goroutine and waits for it to return.
+func startPeriodic(ctx context.Context, interval time.Duration, desc string, fn func(ctx context.Context) error) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := fn(ctx); err != nil && ctx.Err() == nil {
+					log.Printf("failed to %s: %v", desc, err)
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
 	}
 }
@@ -6,15 +6,20 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strings"
+	"time"
 
 	pb "github.com/RTradeLtd/TxPB/v3/go"
 	badger "github.com/RTradeLtd/go-ds-badger/v2"
 	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/cmd/logger"
 	"github.com/RTradeLtd/s3x/pkg/auth"
+	humanize "github.com/dustin/go-humanize"
 	"github.com/grpc-ecosystem/grpc-gateway/runtime"
 	"github.com/ipfs/go-datastore"
 	crdt "github.com/ipfs/go-ds-crdt"
 	"github.com/minio/cli"
+	"go.uber.org/multierr"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
@@ -23,6 +28,21 @@ import (
 
 const (
 	temxBackend = "s3x"
+	// defaultRegion is the default location of buckets, which is the default region of S3
+	defaultRegion = "us-east-1"
+	// defaultMaxKeyLength is the maximum object key length allowed by S3
+	defaultMaxKeyLength = 1024
+	// defaultMaxMetadataSize is the maximum total size of the user metadata of an object allowed by S3
+	defaultMaxMetadataSize = 2 << 10
+	// defaultMaxPartLinks is the maximum number of links in a node of a multipart object,
+	// this is the same as the default used by unixfs.
+	defaultMaxPartLinks = 174
+	// defaultMaxPartSize is the maximum size of a part of a multipart upload allowed by S3
+	defaultMaxPartSize = "5GiB"
+	// defaultCloseTimeout is the time shutdown waits for the datastore to close
+	defaultCloseTimeout = 30 * time.Second
+	// defaultResumeSegmentSize is the size of the checkpointed segments of resumable puts
+	defaultResumeSegmentSize = 4 * chunkSize
 )
 
 //DSType is a type of datastore that s3x supports, please remove all existing data before changing the datastore
@@ -35,6 +55,47 @@ const (
 	DSTypeCrdt = DSType("crdt")
 )
 
+// Durability is the guarantee given for ledger writes once a request returns,
+// stronger guarantees add latency to every write request.
+type Durability string
+
+const (
+	// DurabilityAsync returns once the datastore accepted a write,
+	// which survives a crash of the gateway, but not of the machine
+	DurabilityAsync = Durability("async")
+	// DurabilitySync returns once the datastore wrote a write to disk, this is the default
+	DurabilitySync = Durability("sync")
+	// DurabilityFsync is like DurabilitySync, but also syncs every layer of the datastore,
+	// such as the crdt datastore, on every ledger commit
+	DurabilityFsync = Durability("fsync")
+)
+
+// MetadataCodec is the ipld codec of the ledger nodes of objects and buckets saved on ipfs.
+// The codec is part of the cid of a node, so nodes of any codec are read whatever the setting,
+// and buckets may hold objects saved with different codecs. Object data is always saved as
+// unixfs files, which are dag-pb.
+type MetadataCodec string
+
+const (
+	// MetadataCodecDefault saves ledger nodes with the default dag put options of the node,
+	// which stores them as raw blocks, this is the default
+	MetadataCodecDefault = MetadataCodec("default")
+	// MetadataCodecDagCBOR saves ledger nodes as dag-cbor byte strings of their protobuf encoding
+	MetadataCodecDagCBOR = MetadataCodec("dag-cbor")
+)
+
+// PartAssembly is the way the parts of a completed multipart upload are linked into one file.
+// The file reads the same with any assembly, but the shape of the dag differs, which changes its cid.
+type PartAssembly string
+
+const (
+	// PartAssemblyBalanced links the parts through a tree of nodes with at most MaxPartLinks links,
+	// so a range read only fetches the nodes above the range, this is the default
+	PartAssemblyBalanced = PartAssembly("balanced")
+	// PartAssemblySequential links all parts from a single node
+	PartAssemblySequential = PartAssembly("sequential")
+)
+
 // TEMX implements a MinIO gateway on top of TemporalX
 type TEMX struct {
 	HTTPAddr  string
@@ -44,6 +105,141 @@ type TEMX struct {
 	CrdtTopic string
 	XAddr     string
 	Insecure  bool // whether or not we have an insecure connection to TemporalX
+	// XPoolSize is the number of connections to TemporalX, requests are spread over them
+	// round-robin and failed connections are replaced, 1 if 0
+	XPoolSize int
+	// XFallbackAddrs are TemporalX endpoints dag and file reads fail over to, in order, once XAddr fails
+	// them, writes only go to XAddr. XAddr is probed until it works again, disabled if empty.
+	XFallbackAddrs []string
+
+	// DSNamespace is prepended to all ledger keys, so that multiple gateways
+	// can share a datastore without seeing each other's data
+	DSNamespace string
+	// Durability is the guarantee given for ledger writes, DurabilitySync if empty
+	Durability Durability
+	// MetadataCodec is the codec of the ledger nodes of objects and buckets, MetadataCodecDefault if empty
+	MetadataCodec MetadataCodec
+	// CompressTypes is a list of content types to gzip compress before storing on ipfs,
+	// entries ending with "/*" match all subtypes, compression is disabled if empty.
+	CompressTypes []string
+	// Transformers are applied in order to the data of objects before it's added to ipfs, and
+	// inverted when it's read. Objects are not stored inline while any are configured, and
+	// multipart uploads are not transformed. A transformer must stay configured to read the
+	// objects stored with it.
+	Transformers []ObjectTransformer
+	// Authenticator authenticates and authorizes requests that are not signed with an AWS signature,
+	// such as with tokens of an external identity provider, requests are left to the gateway if not set.
+	Authenticator minio.RequestAuthenticator
+	// MaxKeyLength is the maximum length of object keys in bytes, defaults to 1024 if not set
+	MaxKeyLength int
+	// MaxMetadataSize is the maximum total size in bytes of the keys and values of the user
+	// metadata of an object, defaults to 2KB if not set
+	MaxMetadataSize int
+	// CanonicalKeys stores and looks up objects under the canonical form of their keys, without
+	// leading slashes and empty or "." segments, so "/foo/./bar" and "foo//bar" are "foo/bar"
+	CanonicalKeys bool
+	// InlineThreshold is the size in bytes under which object data is stored inline
+	// in the ledger instead of being added to ipfs as a file, disabled if 0
+	InlineThreshold int64
+	// CompleteConcurrency is the maximum number of concurrent dag operations used to
+	// assemble the parts of a multipart upload on completion
+	CompleteConcurrency int
+	// PartAssembly is the way parts are linked on completion, PartAssemblyBalanced if empty
+	PartAssembly PartAssembly
+	// MaxPartLinks is the maximum number of links in a node of a balanced assembly of parts,
+	// defaults to the unixfs maximum of 174 if not set
+	MaxPartLinks int
+	// MaxPartSize is the maximum size in bytes of a part of a multipart upload, larger parts are
+	// rejected as soon as they are known to be too large, disabled if 0
+	MaxPartSize int64
+	// ResumeSegmentSize is the size in bytes of the segments of object data that are checkpointed
+	// by puts with an upload token, see s3xUploadTokenHeader, defaults to about 16MB if not set
+	ResumeSegmentSize int
+	// AddConcurrency is the maximum number of segments of ResumeSegmentSize bytes of object data
+	// that are added to ipfs at once, instead of adding the data as a single stream, disabled if 0
+	AddConcurrency int
+	// AccessInterval is the minimum interval between recorded reads of an object, reads are
+	// recorded for access based expiry, see SetBucketAccessExpiry, disabled if 0
+	AccessInterval time.Duration
+	// TTLSweepInterval is the interval between removals of objects with an expired ttl, disabled if 0
+	TTLSweepInterval time.Duration
+	// LifecycleInterval is the interval between removals of objects expired by bucket lifecycles, disabled if 0
+	LifecycleInterval time.Duration
+	// LifecycleRetries is the number of failed removals of an expired object after which lifecycle
+	// rounds stop removing it and report it, see LifecycleQuarantine, never quarantined if 0
+	LifecycleRetries int
+	// IPFSGatewayURL is the base url of an ipfs http gateway that GET requests of public objects
+	// are redirected to (ie: https://ipfs.io), disabled if empty
+	IPFSGatewayURL string
+	// ShardThreshold is the number of objects in a bucket above which the objects are
+	// sharded over multiple ipfs nodes, so that a change only saves one shard, disabled if 0
+	ShardThreshold int
+	// DefaultRegion is the location of buckets created without one, defaults to us-east-1 if not set
+	DefaultRegion string
+	// ReconcileInterval is the interval between checks of cached buckets against the datastore,
+	// so changes by other gateways sharing the datastore are seen, disabled if 0
+	ReconcileInterval time.Duration
+	// PinOnRead pins the data of objects on the TemporalX node in the background the first time
+	// they are read, for deployments that don't pin on write
+	PinOnRead bool
+	// InfoMetrics serves the metrics of the gateway on /metrics of the info http api, which is
+	// not authenticated, so only enable it if the info http endpoint is not publicly reachable
+	InfoMetrics bool
+	// CloseTimeout is the time shutdown waits for the datastore to close, so a stuck
+	// datastore doesn't block shutdown, disabled if 0
+	CloseTimeout time.Duration
+	// ListCacheTTL is the time listing results are cached, a write to a bucket invalidates its
+	// cached listings, disabled if 0
+	ListCacheTTL time.Duration
+	// ListCacheSize is the maximum number of cached listing results, disabled if 0
+	ListCacheSize int
+	// ListUploadsInProgress includes keys with multipart uploads in progress in object listings,
+	// annotated with the X-Amz-Meta-S3x-Upload-In-Progress metadata
+	ListUploadsInProgress bool
+	// MetadataIndex enables the index of objects by content type and tags, see FindObjectsByTag.
+	// Only objects put while it's enabled are indexed, the index is dropped when starting with it
+	// disabled, so gateways sharing a datastore have to agree on it.
+	MetadataIndex bool
+	// ReadOnlyThreshold is the number of consecutive failed ipfs writes after which writes
+	// are rejected until ipfs writes work again, disabled if 0
+	ReadOnlyThreshold int
+	// ReadAhead is the number of ipfs chunks downloaded ahead of the client on sequential
+	// reads of whole objects, each chunk buffers up to 4MB, disabled if 0
+	ReadAhead int
+	// DagReadTimeout and DagWriteTimeout cap the time of ipfs dag gets and puts,
+	// puts include pinning so they may need longer, disabled if 0. They also cap the time
+	// TemporalX takes for each message of file downloads and uploads.
+	DagReadTimeout  time.Duration
+	DagWriteTimeout time.Duration
+	// StreamTimeout caps the total time of an ipfs file upload or download, disabled if 0
+	StreamTimeout time.Duration
+	// DagConcurrency is the maximum number of concurrent ipfs dag requests, excess requests
+	// wait, or fail with SlowDown if DagRejectExcess is set, disabled if 0
+	DagConcurrency  int
+	DagRejectExcess bool
+	// RequestLog is the verbosity of object request logging, RequestLogOff if empty
+	RequestLog RequestLogLevel
+	// RemoteAccessKey and RemoteSecretKey are the credentials used to read objects
+	// from remote s3 endpoints in CopyFromRemote, remotes are read anonymously if empty
+	RemoteAccessKey string
+	RemoteSecretKey string
+	// MultipartMaxAge is the age after which incomplete multipart uploads are aborted,
+	// checked every MultipartReapInterval, disabled if either is 0
+	MultipartMaxAge       time.Duration
+	MultipartReapInterval time.Duration
+	// MultipartMaxUploads is the maximum number of multipart uploads in progress in a bucket,
+	// new uploads are rejected with SlowDown until one is completed or aborted, disabled if 0
+	MultipartMaxUploads int
+	// IPNSPublisher publishes the roots of buckets to ipns, for buckets enabled with SetBucketIPNS,
+	// so the latest root of a bucket is reachable by a stable name. Disabled if not set.
+	// Programs embedding the gateway can set their own, like Transformers, see IPNSNameSys.
+	IPNSPublisher IPNSPublisher
+	// IPNSNameSys publishes the roots of buckets with the NameSys api of the TemporalX node,
+	// under keys kept in the keystore of the node, it's ignored if IPNSPublisher is set
+	IPNSNameSys bool
+	// IPNSPublishInterval is the interval between ipns publishes of the roots of updated
+	// buckets, updates in between are published together, defaults to 1 minute if not set
+	IPNSPublishInterval time.Duration
 }
 
 // infoAPIServer provides access to the InfoAPI
@@ -62,10 +258,84 @@ type xObjects struct {
 	ctx        context.Context
 	dagClient  pb.NodeAPIClient
 	fileClient pb.FileAPIClient
+	// adminClient starts garbage collections of the TemporalX node, see PurgeCID
+	adminClient pb.AdminAPIClient
 
 	// ledgerStore is responsible for updating our internal ledger state
 	ledgerStore *ledgerStore
 
+	// compressTypes is a list of content types to compress, see TEMX.CompressTypes
+	compressTypes []string
+	// transformers are applied to object data, see TEMX.Transformers
+	transformers []ObjectTransformer
+	// authenticator authenticates requests, see TEMX.Authenticator
+	authenticator minio.RequestAuthenticator
+	// defaultRegion is the location of buckets created without one, see TEMX.DefaultRegion
+	defaultRegion string
+	// maxKeyLength is the maximum length of object keys in bytes
+	maxKeyLength int
+	// maxMetadataSize is the maximum total size of user metadata, see TEMX.MaxMetadataSize
+	maxMetadataSize int
+	// canonicalKeys enables canonical object keys, see TEMX.CanonicalKeys
+	canonicalKeys bool
+	// inlineThreshold is the maximum size of objects stored inline, see TEMX.InlineThreshold
+	inlineThreshold int64
+	// completeConcurrency is the number of workers used to complete multipart uploads
+	completeConcurrency int
+	// maxPartLinks is the maximum number of links in a node of a multipart object
+	maxPartLinks int
+	// partAssembly is the way parts are linked on completion, see TEMX.PartAssembly
+	partAssembly PartAssembly
+	// maxPartSize is the maximum size of a part of a multipart upload, see TEMX.MaxPartSize
+	maxPartSize int64
+	// resumeSegmentSize is the size of checkpointed segments, see TEMX.ResumeSegmentSize
+	resumeSegmentSize int
+	// addConcurrency is the number of segments added at once by puts, see TEMX.AddConcurrency
+	addConcurrency int
+	// accessInterval is the minimum interval between recorded reads, see TEMX.AccessInterval
+	accessInterval time.Duration
+	// ttlSweepInterval is the interval between removals of expired objects, see TEMX.TTLSweepInterval
+	ttlSweepInterval time.Duration
+	// lifecycleInterval is the interval between lifecycle rounds, see TEMX.LifecycleInterval
+	lifecycleInterval time.Duration
+	// lifecycleRetries is the retry budget of expired object removals, see TEMX.LifecycleRetries
+	lifecycleRetries int
+	// lifecycles tracks the buckets of a running lifecycle round
+	lifecycles bucketLifecycles
+	// multipartMaxAge is the age after which multipart uploads are aborted, see TEMX.MultipartMaxAge
+	multipartMaxAge time.Duration
+	// multipartReapInterval is the interval between aborts of stale multipart uploads
+	multipartReapInterval time.Duration
+	// stopBackground stops the background tasks and waits for them to return
+	stopBackground []func()
+	// ipfsGatewayURL is the base url public objects are redirected to, see TEMX.IPFSGatewayURL
+	ipfsGatewayURL string
+	// failover fails dag reads over to fallback endpoints, see TEMX.XFallbackAddrs
+	failover *failoverDagClient
+	// writeHealth tracks failing ipfs writes to make the gateway read-only
+	writeHealth *writeHealth
+	// remoteAccessKey and remoteSecretKey are the credentials of remote s3 endpoints, see TEMX.RemoteAccessKey
+	remoteAccessKey string
+	remoteSecretKey string
+	// metrics counts requests and bytes, see GetMetrics
+	metrics *s3xMetrics
+	// requestLogLevel is the verbosity of request logging, see TEMX.RequestLog
+	requestLogLevel RequestLogLevel
+	// requestLogger prints request logs
+	requestLogger func(format string, args ...interface{})
+	// readAhead is the number of chunks downloaded ahead of the client, see TEMX.ReadAhead
+	readAhead int
+	// listUploads merges uploads in progress into listings, see TEMX.ListUploadsInProgress
+	listUploads bool
+	// limiters rate limits requests to buckets with a rate limit
+	limiters bucketLimiters
+	// pinner pins the data of objects when they are read, nil if disabled, see TEMX.PinOnRead
+	pinner *readPinner
+	// ipns publishes the roots of buckets to ipns, nil if disabled, see TEMX.IPNSPublisher
+	ipns *bucketPublisher
+	// ipnsPublishInterval is the interval between ipns publishes, see TEMX.IPNSPublishInterval
+	ipnsPublishInterval time.Duration
+
 	infoAPI *infoAPIServer
 
 	listener net.Listener
@@ -83,6 +353,10 @@ func init() {
 				Usage: "the endpoint to serve the info http api on",
 				Value: "0.0.0.0:8889",
 			},
+			cli.BoolFlag{
+				Name:  "info.http.metrics",
+				Usage: "serve unauthenticated prometheus metrics on /metrics of the info http endpoint",
+			},
 			cli.StringFlag{
 				Name:  "info.grpc.endpoint",
 				Usage: "the endpoint to serve the info grpc api on",
@@ -98,6 +372,20 @@ func init() {
 				Usage: "the type backend to store ledger data in, supported values are [badger, crdt]",
 				Value: "badger",
 			},
+			cli.StringFlag{
+				Name:  "ds.namespace",
+				Usage: "a namespace to keep ledger data under, to share a datastore with other gateways",
+			},
+			cli.StringFlag{
+				Name:  "ds.durability",
+				Usage: "the guarantee for ledger writes before a request returns, supported values are [async, sync, fsync], stronger guarantees add latency to writes",
+				Value: string(DurabilitySync),
+			},
+			cli.StringFlag{
+				Name:  "ipfs.metadata-codec",
+				Usage: "the ipld codec of the ledger nodes of objects and buckets, supported values are [default, dag-cbor]",
+				Value: string(MetadataCodecDefault),
+			},
 			cli.StringFlag{
 				Name:  "ds.topic",
 				Usage: "the topic used for crdt pubsub",
@@ -112,6 +400,199 @@ func init() {
 				Name:  "temporalx.insecure",
 				Usage: "initiate an insecure connection to the temporalx endpoint",
 			},
+			cli.IntFlag{
+				Name:  "temporalx.pool-size",
+				Usage: "the number of connections to the temporalx endpoint, requests are spread over them",
+				Value: 1,
+			},
+			cli.StringFlag{
+				Name:  "temporalx.fallback-endpoints",
+				Usage: "comma separated temporalx endpoints reads fail over to when the endpoint fails, disabled if empty",
+			},
+			cli.StringFlag{
+				Name:  "compression.types",
+				Usage: "comma separated list of content types to gzip compress (ie: text/*,application/json), disabled if empty",
+			},
+			cli.IntFlag{
+				Name:  "object.max-key-length",
+				Usage: "the maximum length of object keys in bytes",
+				Value: defaultMaxKeyLength,
+			},
+			cli.IntFlag{
+				Name:  "object.max-metadata-size",
+				Usage: "the maximum total size in bytes of the user metadata keys and values of an object",
+				Value: defaultMaxMetadataSize,
+			},
+			cli.BoolFlag{
+				Name:  "object.canonical-keys",
+				Usage: "remove leading slashes and collapse empty and . segments of object keys, so /foo/./bar and foo//bar are the same object",
+			},
+			cli.IntFlag{
+				Name:  "object.inline-threshold",
+				Usage: "store objects up to this size in bytes inline in the ledger (ie: 1024), disabled if 0",
+			},
+			cli.IntFlag{
+				Name:  "multipart.complete-concurrency",
+				Usage: "the maximum number of concurrent dag operations when completing a multipart upload",
+				Value: 4,
+			},
+			cli.StringFlag{
+				Name:  "multipart.assembly",
+				Usage: "the way parts are linked into a completed object, supported values are [balanced, sequential], balanced trees speed up range reads of large objects",
+				Value: string(PartAssemblyBalanced),
+			},
+			cli.IntFlag{
+				Name:  "multipart.max-links",
+				Usage: "the maximum number of links in a node of a balanced assembly of parts",
+				Value: defaultMaxPartLinks,
+			},
+			cli.StringFlag{
+				Name:  "multipart.max-part-size",
+				Usage: "the maximum size of a part of a multipart upload, such as 512MiB or 5GiB, disabled if 0",
+				Value: defaultMaxPartSize,
+			},
+			cli.IntFlag{
+				Name:  "object.resume-segment-size",
+				Usage: "the size in bytes of the checkpointed segments of puts with an upload token",
+				Value: defaultResumeSegmentSize,
+			},
+			cli.IntFlag{
+				Name:  "object.add-concurrency",
+				Usage: "the maximum number of segments of object data added to ipfs at once, disabled if 0",
+			},
+			cli.DurationFlag{
+				Name:  "object.access-interval",
+				Usage: "the minimum interval between recorded reads of an object, for access based expiry, disabled if 0",
+			},
+			cli.DurationFlag{
+				Name:  "object.ttl-sweep-interval",
+				Usage: "the interval between removals of objects with an expired ttl, disabled if 0",
+				Value: defaultTTLSweepInterval,
+			},
+			cli.DurationFlag{
+				Name:  "object.lifecycle-interval",
+				Usage: "the interval between removals of objects expired by bucket lifecycles, disabled if 0",
+			},
+			cli.IntFlag{
+				Name:  "object.lifecycle-retries",
+				Usage: "the number of failed removals of an expired object after which it's quarantined, never quarantined if 0",
+				Value: defaultLifecycleRetries,
+			},
+			cli.IntFlag{
+				Name:  "bucket.shard-threshold",
+				Usage: "shard the objects of buckets with more than this number of objects, disabled if 0",
+				Value: defaultShardThreshold,
+			},
+			cli.StringFlag{
+				Name:  "bucket.default-region",
+				Usage: "the location of buckets created without a location constraint",
+				Value: defaultRegion,
+			},
+			cli.DurationFlag{
+				Name:  "list.cache-ttl",
+				Usage: "the time listing results are cached, a write to a bucket invalidates its cached listings, disabled if 0",
+				Value: defaultListCacheTTL,
+			},
+			cli.IntFlag{
+				Name:  "list.cache-size",
+				Usage: "the maximum number of cached listing results, disabled if 0",
+				Value: defaultListCacheSize,
+			},
+			cli.BoolFlag{
+				Name:  "list.uploads-in-progress",
+				Usage: "include keys with multipart uploads in progress in object listings, annotated with the x-amz-meta-s3x-upload-in-progress metadata",
+			},
+			cli.BoolFlag{
+				Name:  "object.metadata-index",
+				Usage: "index objects by content type and tags, only objects put while enabled are indexed",
+			},
+			cli.DurationFlag{
+				Name:  "ds.reconcile-interval",
+				Usage: "the interval between checks of cached buckets for changes by other gateways sharing the datastore, disabled if 0",
+			},
+			cli.BoolFlag{
+				Name:  "ipfs.pin-on-read",
+				Usage: "pin the data of objects in the background the first time they are read",
+			},
+			cli.DurationFlag{
+				Name:  "ds.close-timeout",
+				Usage: "the time shutdown waits for the datastore to close, disabled if 0",
+				Value: defaultCloseTimeout,
+			},
+			cli.IntFlag{
+				Name:  "multipart.max-uploads-per-bucket",
+				Usage: "the maximum number of multipart uploads in progress in a bucket, disabled if 0",
+			},
+			cli.DurationFlag{
+				Name:  "multipart.max-age",
+				Usage: "abort incomplete multipart uploads initiated longer ago than this, disabled if 0",
+				Value: defaultMultipartMaxAge,
+			},
+			cli.DurationFlag{
+				Name:  "multipart.reap-interval",
+				Usage: "the interval between aborts of stale multipart uploads, disabled if 0",
+				Value: defaultMultipartReapInterval,
+			},
+			cli.IntFlag{
+				Name:  "ipfs.read-only-threshold",
+				Usage: "reject writes after this number of consecutive failed ipfs writes, until writes work again, disabled if 0",
+				Value: defaultReadOnlyThreshold,
+			},
+			cli.IntFlag{
+				Name:  "object.read-ahead",
+				Usage: "the number of ipfs chunks (up to 4MB each) downloaded ahead of the client on sequential reads, disabled if 0",
+				Value: defaultReadAhead,
+			},
+			cli.DurationFlag{
+				Name:  "ipfs.read-timeout",
+				Usage: "the maximum time of an ipfs dag get or file download message, disabled if 0",
+				Value: defaultDagReadTimeout,
+			},
+			cli.DurationFlag{
+				Name:  "ipfs.write-timeout",
+				Usage: "the maximum time of an ipfs dag put including pinning or file upload message, disabled if 0",
+				Value: defaultDagWriteTimeout,
+			},
+			cli.DurationFlag{
+				Name:  "ipfs.stream-timeout",
+				Usage: "the maximum total time of an ipfs file upload or download, disabled if 0",
+				Value: defaultStreamTimeout,
+			},
+			cli.IntFlag{
+				Name:  "ipfs.max-concurrency",
+				Usage: "the maximum number of concurrent ipfs dag requests, disabled if 0",
+				Value: defaultDagConcurrency,
+			},
+			cli.BoolFlag{
+				Name:  "ipfs.reject-excess",
+				Usage: "fail dag requests above ipfs.max-concurrency with SlowDown, instead of waiting",
+			},
+			cli.StringFlag{
+				Name:  "log.requests",
+				Usage: "log object requests with the cid of the object data, supported values are [off, errors, all]",
+				Value: string(RequestLogOff),
+			},
+			cli.StringFlag{
+				Name:  "remote.access-key",
+				Usage: "the access key used to copy objects from remote s3 endpoints, remotes are read anonymously if empty",
+			},
+			cli.StringFlag{
+				Name:  "remote.secret-key",
+				Usage: "the secret key used to copy objects from remote s3 endpoints",
+			},
+			cli.BoolFlag{
+				Name:  "ipns.namesys",
+				Usage: "publish the roots of buckets enabled for ipns with the namesys api of the temporalx node",
+			},
+			cli.DurationFlag{
+				Name:  "ipns.publish-interval",
+				Usage: "the interval between ipns publishes of the roots of updated buckets",
+				Value: defaultIPNSPublishInterval,
+			},
+			cli.StringFlag{
+				Name:  "ipfs.gateway-url",
+				Usage: "redirect GET requests of public objects to this ipfs http gateway (ie: https://ipfs.io), disabled if empty",
+			},
 		},
 	}); err != nil {
 		panic(err)
@@ -119,41 +600,140 @@ func init() {
 }
 
 func temxGatewayMain(ctx *cli.Context) {
+	maxPartSize, err := humanize.ParseBytes(ctx.String("multipart.max-part-size"))
+	logger.FatalIf(err, "Invalid multipart.max-part-size")
 	minio.StartGateway(ctx, &TEMX{
-		HTTPAddr:  ctx.String("info.http.endpoint"),
-		GRPCAddr:  ctx.String("info.grpc.endpoint"),
-		DSPath:    ctx.String("ds.path"),
-		DSType:    DSType(ctx.String("ds.type")),
-		CrdtTopic: ctx.String("ds.topic"),
-		XAddr:     ctx.String("temporalx.endpoint"),
-		Insecure:  ctx.Bool("temporalx.insecure"),
+		HTTPAddr:       ctx.String("info.http.endpoint"),
+		GRPCAddr:       ctx.String("info.grpc.endpoint"),
+		DSPath:         ctx.String("ds.path"),
+		DSType:         DSType(ctx.String("ds.type")),
+		CrdtTopic:      ctx.String("ds.topic"),
+		XAddr:          ctx.String("temporalx.endpoint"),
+		Insecure:       ctx.Bool("temporalx.insecure"),
+		XPoolSize:      ctx.Int("temporalx.pool-size"),
+		XFallbackAddrs: splitList(ctx.String("temporalx.fallback-endpoints")),
+
+		DSNamespace:           ctx.String("ds.namespace"),
+		Durability:            Durability(ctx.String("ds.durability")),
+		MetadataCodec:         MetadataCodec(ctx.String("ipfs.metadata-codec")),
+		CompressTypes:         splitList(ctx.String("compression.types")),
+		MaxKeyLength:          ctx.Int("object.max-key-length"),
+		MaxMetadataSize:       ctx.Int("object.max-metadata-size"),
+		CanonicalKeys:         ctx.Bool("object.canonical-keys"),
+		InlineThreshold:       int64(ctx.Int("object.inline-threshold")),
+		CompleteConcurrency:   ctx.Int("multipart.complete-concurrency"),
+		PartAssembly:          PartAssembly(ctx.String("multipart.assembly")),
+		MaxPartLinks:          ctx.Int("multipart.max-links"),
+		MaxPartSize:           int64(maxPartSize),
+		ResumeSegmentSize:     ctx.Int("object.resume-segment-size"),
+		AddConcurrency:        ctx.Int("object.add-concurrency"),
+		AccessInterval:        ctx.Duration("object.access-interval"),
+		TTLSweepInterval:      ctx.Duration("object.ttl-sweep-interval"),
+		LifecycleInterval:     ctx.Duration("object.lifecycle-interval"),
+		LifecycleRetries:      ctx.Int("object.lifecycle-retries"),
+		IPFSGatewayURL:        ctx.String("ipfs.gateway-url"),
+		ShardThreshold:        ctx.Int("bucket.shard-threshold"),
+		DefaultRegion:         ctx.String("bucket.default-region"),
+		ReconcileInterval:     ctx.Duration("ds.reconcile-interval"),
+		CloseTimeout:          ctx.Duration("ds.close-timeout"),
+		ListCacheTTL:          ctx.Duration("list.cache-ttl"),
+		ListCacheSize:         ctx.Int("list.cache-size"),
+		ListUploadsInProgress: ctx.Bool("list.uploads-in-progress"),
+		MetadataIndex:         ctx.Bool("object.metadata-index"),
+		ReadOnlyThreshold:     ctx.Int("ipfs.read-only-threshold"),
+		ReadAhead:             ctx.Int("object.read-ahead"),
+		DagReadTimeout:        ctx.Duration("ipfs.read-timeout"),
+		DagWriteTimeout:       ctx.Duration("ipfs.write-timeout"),
+		StreamTimeout:         ctx.Duration("ipfs.stream-timeout"),
+		DagConcurrency:        ctx.Int("ipfs.max-concurrency"),
+		DagRejectExcess:       ctx.Bool("ipfs.reject-excess"),
+		PinOnRead:             ctx.Bool("ipfs.pin-on-read"),
+		InfoMetrics:           ctx.Bool("info.http.metrics"),
+		RequestLog:            RequestLogLevel(ctx.String("log.requests")),
+		RemoteAccessKey:       ctx.String("remote.access-key"),
+		RemoteSecretKey:       ctx.String("remote.secret-key"),
+
+		MultipartMaxAge:       ctx.Duration("multipart.max-age"),
+		MultipartMaxUploads:   ctx.Int("multipart.max-uploads-per-bucket"),
+		MultipartReapInterval: ctx.Duration("multipart.reap-interval"),
+		IPNSNameSys:           ctx.Bool("ipns.namesys"),
+		IPNSPublishInterval:   ctx.Duration("ipns.publish-interval"),
 	})
 }
 
+// splitList splits a comma separated list, ignoring empty entries
+func splitList(s string) []string {
+	var list []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
 // newLedgerStore returns an instance of ledgerStore
 func (g *TEMX) newLedgerStore(ctx context.Context, dag pb.NodeAPIClient, pub pb.PubSubAPIClient) (*ledgerStore, error) {
+	switch g.Durability {
+	case "":
+		g.Durability = DurabilitySync
+	case DurabilityAsync, DurabilitySync, DurabilityFsync:
+	default:
+		return nil, fmt.Errorf(`durability "%v" not supported`, g.Durability)
+	}
+	switch g.MetadataCodec {
+	case "":
+		g.MetadataCodec = MetadataCodecDefault
+	case MetadataCodecDefault, MetadataCodecDagCBOR:
+	default:
+		return nil, fmt.Errorf(`metadata codec "%v" not supported`, g.MetadataCodec)
+	}
+	switch g.PartAssembly {
+	case "":
+		g.PartAssembly = PartAssemblyBalanced
+	case PartAssemblyBalanced, PartAssemblySequential:
+	default:
+		return nil, fmt.Errorf(`part assembly "%v" not supported`, g.PartAssembly)
+	}
+	var (
+		ls  *ledgerStore
+		err error
+	)
 	switch g.DSType {
 	case DSTypeBadger:
-		return g.newBadgerLedgerStore(dag)
+		ls, err = g.newBadgerLedgerStore(dag)
 	case DSTypeCrdt:
-		return g.newCrdtLedgerStore(ctx, dag, pub)
+		ls, err = g.newCrdtLedgerStore(ctx, dag, pub)
+	default:
+		return nil, fmt.Errorf(`data store type "%v" not supported`, g.DSType)
+	}
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf(`data store type "%v" not supported`, g.DSType)
+	ls.syncCommits = g.Durability == DurabilityFsync
+	ls.metadataCodec = g.MetadataCodec
+	return ls, nil
+}
+
+// badgerOptions returns the badger options for the configured durability
+func (g *TEMX) badgerOptions() *badger.Options {
+	opts := badger.DefaultOptions
+	opts.SyncWrites = g.Durability != DurabilityAsync
+	return &opts
 }
 
 // newBadgerLedgerStore returns an instance of ledgerStore that uses badgerv2
 func (g *TEMX) newBadgerLedgerStore(dag pb.NodeAPIClient) (*ledgerStore, error) {
-	opts := badger.DefaultOptions
-	ds, err := badger.NewDatastore(g.DSPath, &opts)
+	ds, err := badger.NewDatastore(g.DSPath, g.badgerOptions())
 	if err != nil {
 		return nil, err
 	}
-	return newLedgerStore(ds, dag)
+	return newLedgerStore(ds, dag, g.DSNamespace)
 }
 
 // newCrdtLedgerStore returns an instance of ledgerStore that uses crdt and backed by badgerv2
 func (g *TEMX) newCrdtLedgerStore(ctx context.Context, dag pb.NodeAPIClient, pub pb.PubSubAPIClient) (*ledgerStore, error) {
-	store, err := badger.NewDatastore(g.DSPath, &badger.DefaultOptions)
+	store, err := badger.NewDatastore(g.DSPath, g.badgerOptions())
 	if err != nil {
 		return nil, err
 	}
@@ -177,7 +757,7 @@ func (g *TEMX) newCrdtLedgerStore(ctx context.Context, dag pb.NodeAPIClient, pub
 	if err != nil {
 		return nil, err
 	}
-	ls, err := newLedgerStore(crdtds, dag)
+	ls, err := newLedgerStore(crdtds, dag, g.DSNamespace)
 	if err != nil {
 		return nil, err
 	}
@@ -189,6 +769,13 @@ func (g *TEMX) newCrdtLedgerStore(ctx context.Context, dag pb.NodeAPIClient, pub
 // returns an instance of xObjects
 func (g *TEMX) getXObjects(creds auth.Credentials) (*xObjects, error) {
 	ctx := context.TODO()
+	switch g.RequestLog {
+	case "":
+		g.RequestLog = RequestLogOff
+	case RequestLogOff, RequestLogErrors, RequestLogAll:
+	default:
+		return nil, fmt.Errorf(`request log level "%v" not supported`, g.RequestLog)
+	}
 	var dialOpts []grpc.DialOption
 	if g.Insecure {
 		dialOpts = append(dialOpts, grpc.WithInsecure())
@@ -202,38 +789,162 @@ func (g *TEMX) getXObjects(creds auth.Credentials) (*xObjects, error) {
 		))
 	}
 	// connect to TemporalX
-	conn, err := grpc.Dial(g.XAddr, dialOpts...)
+	dial := func(addr string) func() (*grpc.ClientConn, error) {
+		return func() (*grpc.ClientConn, error) {
+			return grpc.Dial(addr, dialOpts...)
+		}
+	}
+	pool, err := newConnPool(g.XPoolSize, dial(g.XAddr))
 	if err != nil {
 		return nil, err
 	}
-	dag := pb.NewNodeAPIClient(conn)
-	pub := pb.NewPubSubAPIClient(conn)
+	pools := []*connPool{pool}
+	closePools := func() error {
+		var err error
+		for _, p := range pools {
+			err = multierr.Append(err, p.Close())
+		}
+		return err
+	}
+	failover := &failoverDagClient{NodeAPIClient: newPoolDagClient(pool)}
+	files := &failoverFileClient{FileAPIClient: newPoolFileClient(pool), dag: failover}
+	for _, addr := range g.XFallbackAddrs {
+		fallback, err := newConnPool(g.XPoolSize, dial(addr))
+		if err != nil {
+			return nil, multierr.Append(err, closePools())
+		}
+		pools = append(pools, fallback)
+		failover.fallbacks = append(failover.fallbacks, newPoolDagClient(fallback))
+		files.fallbacks = append(files.fallbacks, newPoolFileClient(fallback))
+	}
+	health := &writeHealth{threshold: g.ReadOnlyThreshold}
+	var node pb.NodeAPIClient = failover
+	if g.DagConcurrency > 0 {
+		node = newLimitDagClient(node, g.DagConcurrency, g.DagRejectExcess)
+	}
+	metrics := &s3xMetrics{}
+	dag := &healthDagClient{
+		NodeAPIClient: &timeoutDagClient{
+			NodeAPIClient: &metricsDagClient{NodeAPIClient: node, metrics: metrics},
+			readTimeout:   g.DagReadTimeout,
+			writeTimeout:  g.DagWriteTimeout,
+		},
+		health: health,
+	}
+	timeoutFiles := &timeoutFileClient{
+		FileAPIClient: files,
+		uploadIdle:    g.DagWriteTimeout,
+		downloadIdle:  g.DagReadTimeout,
+		timeout:       g.StreamTimeout,
+	}
+	pub := pb.NewPubSubAPIClient(pool.get())
 	// instantiate our internal ledger
 	ledger, err := g.newLedgerStore(ctx, dag, pub)
+	if err != nil {
+		return nil, multierr.Append(err, closePools())
+	}
+	ledger.cleanup = append(ledger.cleanup, closePools)
+	ledger.shardThreshold = g.ShardThreshold
+	ledger.reconcileInterval = g.ReconcileInterval
+	ledger.closeTimeout = g.CloseTimeout
+	ledger.listings = newListingCache(g.ListCacheTTL, g.ListCacheSize)
+	ledger.indexMetadata = g.MetadataIndex
+	if !g.MetadataIndex {
+		if err := ledger.dropMetadataIndex(); err != nil {
+			return nil, err
+		}
+	}
+	// uploads in progress before the start are counted, so completing them keeps the gauge right
+	uploads, err := ledger.getMultipartIDs()
 	if err != nil {
 		return nil, err
 	}
+	metrics.multipartInFlight = int64(len(uploads))
+	if g.MultipartMaxUploads > 0 {
+		if err := ledger.limitBucketUploads(g.MultipartMaxUploads); err != nil {
+			return nil, err
+		}
+	}
 	// create a grpc listener
 	listener, err := net.Listen("tcp", g.GRPCAddr)
 	if err != nil {
 		return nil, err
 	}
+	if g.MaxKeyLength <= 0 {
+		g.MaxKeyLength = defaultMaxKeyLength
+	}
+	if g.MaxMetadataSize <= 0 {
+		g.MaxMetadataSize = defaultMaxMetadataSize
+	}
+	if g.DefaultRegion == "" {
+		g.DefaultRegion = defaultRegion
+	}
+	if g.ResumeSegmentSize <= 0 {
+		g.ResumeSegmentSize = defaultResumeSegmentSize
+	}
+	if g.MaxPartLinks <= 0 {
+		g.MaxPartLinks = defaultMaxPartLinks
+	}
+	if g.IPNSPublishInterval <= 0 {
+		g.IPNSPublishInterval = defaultIPNSPublishInterval
+	}
 	// instantiate initial xObjects type
 	// responsible for bridging S3 -> TemporalX (IPFS)
 	xobj := &xObjects{
-		ctx:         ctx,
-		dagClient:   dag,
-		fileClient:  pb.NewFileAPIClient(conn),
-		ledgerStore: ledger,
+		ctx:                 ctx,
+		dagClient:           dag,
+		fileClient:          timeoutFiles,
+		adminClient:         pb.NewAdminAPIClient(pool.get()),
+		ledgerStore:         ledger,
+		compressTypes:       g.CompressTypes,
+		transformers:        g.Transformers,
+		authenticator:       g.Authenticator,
+		defaultRegion:       g.DefaultRegion,
+		maxKeyLength:        g.MaxKeyLength,
+		maxMetadataSize:     g.MaxMetadataSize,
+		canonicalKeys:       g.CanonicalKeys,
+		inlineThreshold:     g.InlineThreshold,
+		completeConcurrency: g.CompleteConcurrency,
+		maxPartLinks:        g.MaxPartLinks,
+		partAssembly:        g.PartAssembly,
+		maxPartSize:         g.MaxPartSize,
+		resumeSegmentSize:   g.ResumeSegmentSize,
+		addConcurrency:      g.AddConcurrency,
+		accessInterval:      g.AccessInterval,
+		ttlSweepInterval:    g.TTLSweepInterval,
+		lifecycleInterval:   g.LifecycleInterval,
+		lifecycleRetries:    g.LifecycleRetries,
+		ipfsGatewayURL:      strings.TrimSuffix(g.IPFSGatewayURL, "/"),
+		writeHealth:         health,
+		failover:            failover,
+		metrics:             metrics,
+		requestLogLevel:     g.RequestLog,
+		requestLogger:       logger.Info,
+		readAhead:           g.ReadAhead,
+		listUploads:         g.ListUploadsInProgress,
+		remoteAccessKey:     g.RemoteAccessKey,
+		remoteSecretKey:     g.RemoteSecretKey,
+
+		multipartMaxAge:       g.MultipartMaxAge,
+		multipartReapInterval: g.MultipartReapInterval,
+		ipnsPublishInterval:   g.IPNSPublishInterval,
 		infoAPI: &infoAPIServer{
 			httpMux:    runtime.NewServeMux(),
 			grpcServer: grpc.NewServer(),
 		},
 		listener: listener,
 	}
+	if g.IPNSPublisher == nil && g.IPNSNameSys {
+		// the keys are only kept by the primary node, so publishes don't fail over
+		g.IPNSPublisher = newNameSysPublisher(newPoolDagClient(pool), pb.NewNameSysAPIClient(pool.get()))
+	}
+	if g.IPNSPublisher != nil {
+		xobj.ipns = newBucketPublisher(g.IPNSPublisher)
+		ledger.OnBucketSaved(xobj.ipns.saved)
+	}
 	xobj.infoAPI.httpServer = &http.Server{
 		Addr:    g.HTTPAddr,
-		Handler: xobj.infoAPI.httpMux,
+		Handler: xobj.infoHTTPHandler(g.InfoMetrics),
 	}
 	// register the grpc server
 	RegisterInfoAPIServer(xobj.infoAPI.grpcServer, xobj)
@@ -261,6 +972,40 @@ func (g *TEMX) NewGatewayLayer(creds auth.Credentials) (minio.ObjectLayer, error
 	go func() {
 		_ = xobj.infoAPI.httpServer.ListenAndServe()
 	}()
+	if xobj.ttlSweepInterval > 0 {
+		xobj.stopBackground = append(xobj.stopBackground, startPeriodic(
+			xobj.ctx, xobj.ttlSweepInterval, "remove expired objects", xobj.sweepExpiredObjects,
+		))
+	}
+	if xobj.lifecycleInterval > 0 {
+		xobj.stopBackground = append(xobj.stopBackground, startPeriodic(
+			xobj.ctx, xobj.lifecycleInterval, "apply bucket lifecycles", xobj.lifecycleRound,
+		))
+	}
+	if len(xobj.failover.fallbacks) > 0 {
+		xobj.stopBackground = append(xobj.stopBackground, startPeriodic(
+			xobj.ctx, failoverProbeInterval, "probe primary ipfs endpoint", xobj.failover.probe,
+		))
+	}
+	if xobj.writeHealth.threshold > 0 {
+		xobj.stopBackground = append(xobj.stopBackground, startPeriodic(
+			xobj.ctx, writeProbeInterval, "probe ipfs writes", xobj.probeWrites,
+		))
+	}
+	if g.PinOnRead {
+		xobj.pinner = newReadPinner(xobj.ctx, xobj.dagClient)
+		xobj.stopBackground = append(xobj.stopBackground, xobj.pinner.stop)
+	}
+	if xobj.multipartMaxAge > 0 && xobj.multipartReapInterval > 0 {
+		xobj.stopBackground = append(xobj.stopBackground, startPeriodic(
+			xobj.ctx, xobj.multipartReapInterval, "abort stale multipart uploads", xobj.abortStaleMultipartUploads,
+		))
+	}
+	if xobj.ipns != nil {
+		xobj.stopBackground = append(xobj.stopBackground, startPeriodic(
+			xobj.ctx, xobj.ipnsPublishInterval, "publish bucket roots to ipns", xobj.publishBucketRoots,
+		))
+	}
 	return xobj, nil
 }
 
@@ -276,6 +1021,9 @@ func (g *TEMX) Production() bool {
 
 // Shutdown is used to shutdown our xObjects service layer
 func (x *xObjects) Shutdown(ctx context.Context) error {
+	for _, stop := range x.stopBackground {
+		stop()
+	}
 	x.infoAPI.grpcServer.Stop()
 	x.infoAPI.httpServer.Close()
 	return x.ledgerStore.Close()
@@ -1,9 +1,18 @@
 package s3x
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
 	"reflect"
 	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/pkg/auth"
+	"github.com/ipfs/go-cid"
 )
 
 func TestS3X_xObjects_GetHash_Badger(t *testing.T) {
@@ -26,15 +35,8 @@ func testS3XxObjectsGetHash(t *testing.T, dsType DSType) {
 	}
 
 	ctx := context.Background()
-	gateway := newTestGateway(t, dsType)
-	defer func() {
-		if err := gateway.Shutdown(ctx); err != nil {
-			t.Fatal(err)
-		}
-	}()
-	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
-		t.Fatal(err)
-	}
+	gateway, shutdown := newTestGatewayWithBucket(t, dsType)
+	defer shutdown()
 	testPutObject(t, gateway)
 
 	for _, tt := range tests {
@@ -50,3 +52,138 @@ func testS3XxObjectsGetHash(t *testing.T, dsType DSType) {
 		})
 	}
 }
+
+// the environment of the child process of TestS3X_Durability_Crash, which puts an object
+// into the datastore at crashPathEnv and gets killed before closing it
+const (
+	crashPathEnv       = "S3X_TEST_CRASH_PATH"
+	crashDurabilityEnv = "S3X_TEST_CRASH_DURABILITY"
+	// crashPutMarker is printed by the child once the put returned
+	crashPutMarker = "put returned"
+)
+
+func TestS3X_Durability_Crash(t *testing.T) {
+	ctx := context.Background()
+	if path := os.Getenv(crashPathEnv); path != "" {
+		crashAfterPut(t, path, Durability(os.Getenv(crashDurabilityEnv)))
+		return
+	}
+	for _, durability := range []Durability{DurabilitySync, DurabilityFsync} {
+		t.Run(string(durability), func(t *testing.T) {
+			path, err := ioutil.TempDir("", "s3x-crash")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(path)
+			cmd := exec.Command(os.Args[0], "-test.run=^TestS3X_Durability_Crash$")
+			cmd.Env = append(os.Environ(), crashPathEnv+"="+path, crashDurabilityEnv+"="+string(durability))
+			out, err := cmd.CombinedOutput()
+			if cmd.ProcessState == nil || cmd.ProcessState.ExitCode() != -1 || !bytes.Contains(out, []byte(crashPutMarker)) {
+				t.Fatalf("expected the child to be killed after the put returned, but got %v:\n%s", err, out)
+			}
+			temx := newTestTEMX(DSTypeBadger, path)
+			temx.Durability = durability
+			g, err := temx.NewGatewayLayer(auth.Credentials{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer g.Shutdown(ctx)
+			object := "durable-" + string(durability)
+			var buf bytes.Buffer
+			if err := g.GetObject(ctx, testBucket1, object, 0, 0, &buf, "", minio.ObjectOptions{}); err != nil {
+				t.Fatalf("expected object to survive a crash after PutObject returned: %v", err)
+			}
+			if buf.String() != object {
+				t.Fatalf("expected %q, but got %q", object, buf.String())
+			}
+		})
+	}
+}
+
+// crashAfterPut puts an object into the datastore at path and kills the process,
+// so nothing is flushed as it would be on a clean shutdown
+func crashAfterPut(t *testing.T, path string, durability Durability) {
+	ctx := context.Background()
+	temx := newTestTEMX(DSTypeBadger, path)
+	temx.Durability = durability
+	g, err := temx.NewGatewayLayer(auth.Credentials{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
+		t.Fatal(err)
+	}
+	object := "durable-" + string(durability)
+	if _, err := g.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte(object)), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(crashPutMarker)
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	select {}
+}
+
+func TestS3X_MetadataCodec(t *testing.T) {
+	ctx := context.Background()
+	gateway, shutdown := newTestGatewayWithBucket(t, DSTypeBadger)
+	defer shutdown()
+	codecs := map[MetadataCodec]uint64{
+		MetadataCodecDagCBOR: cid.DagCBOR,
+		MetadataCodecDefault: cid.Raw,
+	}
+	for _, codec := range []MetadataCodec{MetadataCodecDagCBOR, MetadataCodecDefault} {
+		gateway.temx.MetadataCodec = codec
+		gateway.restart(t)
+		object := "object-" + string(codec)
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte(object)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := gateway.ledgerStore.GetObjectHash(ctx, testBucket1, object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c, err := cid.Decode(hash); err != nil || c.Type() != codecs[codec] {
+			t.Fatalf("expected %v object node, but got %v (%v)", codec, hash, err)
+		}
+		dataHash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c, err := cid.Decode(dataHash); err != nil || c.Type() != cid.DagProtobuf {
+			t.Fatalf("expected dag-pb object data, but got %v (%v)", dataHash, err)
+		}
+	}
+	// both objects are read from ipfs, whatever codec the bucket is saved with
+	gateway.restart(t)
+	for codec := range codecs {
+		object := "object-" + string(codec)
+		buf := bytes.NewBuffer(nil)
+		if err := gateway.GetObject(ctx, testBucket1, object, 0, 0, buf, "", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != object {
+			t.Fatalf("expected data %q, but got %q", object, buf.String())
+		}
+	}
+}
+
+func TestS3X_CborByteString(t *testing.T) {
+	for _, n := range []int{0, 23, 24, 255, 256, 65536} {
+		data := bytes.Repeat([]byte{1}, n)
+		got, err := cborBytes(cborByteString(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("expected %v bytes back, but got %v", n, len(got))
+		}
+	}
+	if _, err := cborBytes([]byte{0xa0}); err == nil {
+		t.Fatal("expected error for a cbor map")
+	}
+}
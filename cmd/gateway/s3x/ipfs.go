@@ -2,8 +2,10 @@ package s3x
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 
 	pb "github.com/RTradeLtd/TxPB/v3/go"
 	"github.com/ipfs/go-cid"
@@ -27,9 +29,22 @@ func ipfsBytes(ctx context.Context, dag pb.NodeAPIClient, h string) ([]byte, err
 	return resp.GetRawData(), err
 }
 
+// ipfsMetadataBytes returns the marshalled data of a ledger node from IPFS using its hash,
+// the node is decoded according to the codec of the hash, see MetadataCodec
+func ipfsMetadataBytes(ctx context.Context, dag pb.NodeAPIClient, h string) ([]byte, error) {
+	data, err := ipfsBytes(ctx, dag, h)
+	if err != nil {
+		return nil, err
+	}
+	if c, err := cid.Decode(h); err == nil && c.Type() == cid.DagCBOR {
+		return cborBytes(data)
+	}
+	return data, nil
+}
+
 // ipfsUnmarshal unmarshalls any data structure from IPFS using its hash
 func ipfsUnmarshal(ctx context.Context, dag pb.NodeAPIClient, h string, u unmarshaller) error {
-	data, err := ipfsBytes(ctx, dag, h)
+	data, err := ipfsMetadataBytes(ctx, dag, h)
 	if err != nil {
 		return err
 	}
@@ -54,13 +69,86 @@ func ipfsBucket(ctx context.Context, dag pb.NodeAPIClient, h string) (*Bucket, e
 	return b, nil
 }
 
-// ipfsSave saves any marshaller object and returns it's IPFS hash
-func ipfsSave(ctx context.Context, dag pb.NodeAPIClient, m marshaller) (string, error) {
+// ipfsSave saves any marshaller object with the codec and returns it's IPFS hash
+func ipfsSave(ctx context.Context, dag pb.NodeAPIClient, m marshaller, codec MetadataCodec) (string, error) {
 	data, err := m.Marshal()
 	if err != nil {
 		return "", err
 	}
-	return ipfsSaveBytes(ctx, dag, data)
+	return ipfsSaveMetadata(ctx, dag, data, codec)
+}
+
+// ipfsSaveMetadata saves the marshalled data of a ledger node with the codec and returns it's IPFS hash
+func ipfsSaveMetadata(ctx context.Context, dag pb.NodeAPIClient, data []byte, codec MetadataCodec) (string, error) {
+	if codec != MetadataCodecDagCBOR {
+		return ipfsSaveBytes(ctx, dag, data)
+	}
+	resp, err := dag.Dag(ctx, &pb.DagRequest{
+		RequestType:         pb.DAGREQTYPE_DAG_PUT,
+		Data:                cborByteString(data),
+		ObjectEncoding:      "cbor",
+		SerializationFormat: "cbor",
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "dag client error in ipfsSaveMetadata")
+	}
+	if len(resp.GetHashes()) != 1 {
+		return "", errors.New("unexpected number of hashes returned")
+	}
+	return resp.GetHashes()[0], nil
+}
+
+// cborByteString returns the cbor encoding of data as a byte string, which is a valid dag-cbor node
+func cborByteString(data []byte) []byte {
+	const major = 2 << 5 // byte string
+	n := uint64(len(data))
+	var head []byte
+	switch {
+	case n < 24:
+		head = []byte{major | byte(n)}
+	case n <= math.MaxUint8:
+		head = []byte{major | 24, byte(n)}
+	case n <= math.MaxUint16:
+		head = make([]byte, 3)
+		head[0] = major | 25
+		binary.BigEndian.PutUint16(head[1:], uint16(n))
+	case n <= math.MaxUint32:
+		head = make([]byte, 5)
+		head[0] = major | 26
+		binary.BigEndian.PutUint32(head[1:], uint32(n))
+	default:
+		head = make([]byte, 9)
+		head[0] = major | 27
+		binary.BigEndian.PutUint64(head[1:], n)
+	}
+	return append(head, data...)
+}
+
+// cborBytes returns the data of a cbor byte string, as encoded by cborByteString
+func cborBytes(b []byte) ([]byte, error) {
+	if len(b) == 0 || b[0]>>5 != 2 {
+		return nil, errors.New("dag-cbor node is not a byte string")
+	}
+	info, b := b[0]&0x1f, b[1:]
+	var n uint64
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info == 24 && len(b) >= 1:
+		n, b = uint64(b[0]), b[1:]
+	case info == 25 && len(b) >= 2:
+		n, b = uint64(binary.BigEndian.Uint16(b)), b[2:]
+	case info == 26 && len(b) >= 4:
+		n, b = uint64(binary.BigEndian.Uint32(b)), b[4:]
+	case info == 27 && len(b) >= 8:
+		n, b = binary.BigEndian.Uint64(b), b[8:]
+	default:
+		return nil, errors.New("invalid dag-cbor byte string length")
+	}
+	if uint64(len(b)) != n {
+		return nil, errors.New("invalid dag-cbor byte string length")
+	}
+	return b, nil
 }
 
 // ipfsSaveBytes saves data and returns it's IPFS hash
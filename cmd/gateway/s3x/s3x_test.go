@@ -5,11 +5,14 @@ import (
 	"io/ioutil"
 	"os"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	pb "github.com/RTradeLtd/TxPB/v3/go"
 	minio "github.com/RTradeLtd/s3x/cmd"
 	"github.com/RTradeLtd/s3x/pkg/auth"
+	"google.golang.org/grpc"
 )
 
 func init() {
@@ -53,7 +56,7 @@ var (
 
 // newTestGateway returns a testGateway that implements minio.ObjectLayer.
 // testGateway also removes all data save on disk when shutdown
-func newTestGateway(t *testing.T, dsType DSType) *testGateway {
+func newTestGateway(t testing.TB, dsType DSType) *testGateway {
 	pathOnce.Do(func() {
 		testPath, testPathErr = ioutil.TempDir("", "s3x-test")
 	})
@@ -64,27 +67,68 @@ func newTestGateway(t *testing.T, dsType DSType) *testGateway {
 		t.Fatal(err)
 	}
 
+	os.Setenv("S3X_DS_PATH", testPath)
+	temx := newTestTEMX(dsType, testPath)
+	g, err := temx.NewGatewayLayer(auth.Credentials{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testGateway{
+		xObjects: g.(*xObjects),
+		temx:     temx,
+		testPath: testPath,
+	}
+}
+
+// newTestTEMX returns the gateway config of tests, with the datastore at path
+// newTestGatewayWithBucket returns a new test gateway with testBucket1, and a function
+// shutting it down to defer
+func newTestGatewayWithBucket(t testing.TB, dsType DSType) (*testGateway, func()) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	shutdown := func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
+		shutdown()
+		t.Fatal(err)
+	}
+	return gateway, shutdown
+}
+
+func newTestTEMX(dsType DSType, path string) *TEMX {
 	xaddr := os.Getenv("TEST_XAPI")
 	if xaddr == "" {
 		xaddr = "xapi.temporal.cloud:9090"
 	}
-	os.Setenv("S3X_DS_PATH", testPath)
-	temx := &TEMX{
+	return &TEMX{
 		HTTPAddr:  "localhost:8889",
 		GRPCAddr:  "localhost:8888",
 		DSType:    dsType,
-		DSPath:    testPath,
-		CrdtTopic: testPath + time.Now().String(), //make sure the topic is unique
+		DSPath:    path,
+		CrdtTopic: path + time.Now().String(), //make sure the topic is unique
 		XAddr:     xaddr,
 		Insecure:  true,
 	}
-	g, err := temx.NewGatewayLayer(auth.Credentials{})
-	if err != nil {
-		t.Fatal(err)
-	}
-	return &testGateway{
-		xObjects: g.(*xObjects),
-		temx:     temx,
-		testPath: testPath,
+}
+
+// countingDagClient wraps a NodeAPIClient and records the maximum number of concurrent dag requests
+type countingDagClient struct {
+	pb.NodeAPIClient
+	inFlight    int64
+	maxInFlight int64
+}
+
+func (c *countingDagClient) Dag(ctx context.Context, in *pb.DagRequest, opts ...grpc.CallOption) (*pb.DagResponse, error) {
+	n := atomic.AddInt64(&c.inFlight, 1)
+	defer atomic.AddInt64(&c.inFlight, -1)
+	for {
+		max := atomic.LoadInt64(&c.maxInFlight)
+		if n <= max || atomic.CompareAndSwapInt64(&c.maxInFlight, max, n) {
+			break
+		}
 	}
+	return c.NodeAPIClient.Dag(ctx, in, opts...)
 }
@@ -67,6 +67,14 @@ func isValidLocation(location string) bool {
 	return globalServerRegion == "" || globalServerRegion == location
 }
 
+var validLocationName = regexp.MustCompile(`^[a-z0-9-]{1,64}$`)
+
+// Validates the format of input location for object layers
+// keeping bucket locations, which accept any region.
+func isValidLocationName(location string) bool {
+	return location == "" || validLocationName.MatchString(location)
+}
+
 // Supported headers that needs to be extracted.
 var supportedHeaders = []string{
 	"content-type",
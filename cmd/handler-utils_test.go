@@ -91,6 +91,29 @@ func TestIsValidLocationContraint(t *testing.T) {
 	}
 }
 
+// Tests validate the format of locations kept by bucket locators.
+func TestIsValidLocationName(t *testing.T) {
+	testCases := []struct {
+		location string
+		valid    bool
+	}{
+		{"", true},
+		{"us-east-1", true},
+		{"my-datacenter-2", true},
+		{strings.Repeat("a", 64), true},
+		{strings.Repeat("a", 65), false},
+		{"EU-WEST-1", false},
+		{"eu west 1", false},
+		{"eu_west_1", false},
+		{"<script>", false},
+	}
+	for i, testCase := range testCases {
+		if valid := isValidLocationName(testCase.location); valid != testCase.valid {
+			t.Errorf("Test %d: Expected %q to be valid %v, but instead found %v", i+1, testCase.location, testCase.valid, valid)
+		}
+	}
+}
+
 // Test validate form field size.
 func TestValidateFormFieldSize(t *testing.T) {
 	testCases := []struct {
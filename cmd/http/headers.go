@@ -24,6 +24,8 @@ const (
 	ContentType        = "Content-Type"
 	ContentMD5         = "Content-Md5"
 	ContentEncoding    = "Content-Encoding"
+	AcceptEncoding     = "Accept-Encoding"
+	Vary               = "Vary"
 	Expires            = "Expires"
 	ContentLength      = "Content-Length"
 	ContentLanguage    = "Content-Language"
@@ -94,6 +96,15 @@ const (
 	AmzSecurityToken        = "X-Amz-Security-Token"
 	AmzDecodedContentLength = "X-Amz-Decoded-Content-Length"
 
+	// Additional checksum related constants.
+	AmzChecksumAlgo   = "X-Amz-Sdk-Checksum-Algorithm"
+	AmzTrailer        = "X-Amz-Trailer"
+	AmzChecksumPrefix = "x-amz-checksum-"
+	AmzChecksumCRC32  = "x-amz-checksum-crc32"
+	AmzChecksumCRC32C = "x-amz-checksum-crc32c"
+	AmzChecksumSHA1   = "x-amz-checksum-sha1"
+	AmzChecksumSHA256 = "x-amz-checksum-sha256"
+
 	// Signature v2 related constants
 	AmzSignatureV2 = "Signature"
 	AmzAccessKeyID = "AWSAccessKeyId"
@@ -173,3 +173,37 @@ func (h *HTTPRangeSpec) String(resourceSize int64) string {
 	}
 	return fmt.Sprintf("%d-%d", off, off+length-1)
 }
+
+// PartNumberToRangeSpec returns the range of the part of a multipart object with the part number.
+// Objects without parts are a single part, so part 1 is the whole object, returned as a nil range.
+func PartNumberToRangeSpec(oi ObjectInfo, partNumber int) (*HTTPRangeSpec, error) {
+	if len(oi.Parts) == 0 {
+		if partNumber == 1 {
+			return nil, nil
+		}
+		return nil, InvalidPartNumber{PartNumber: partNumber}
+	}
+	var start int64
+	for _, part := range oi.Parts {
+		if part.Number == partNumber {
+			if part.ActualSize <= 0 {
+				return nil, InvalidPartNumber{PartNumber: partNumber}
+			}
+			return &HTTPRangeSpec{Start: start, End: start + part.ActualSize - 1}, nil
+		}
+		start += part.ActualSize
+	}
+	return nil, InvalidPartNumber{PartNumber: partNumber}
+}
+
+// parsePartNumber parses the partNumber query value of a GET or HEAD request, 0 if it's not set
+func parsePartNumber(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	partNumber, err := strconv.Atoi(value)
+	if err != nil || partNumber <= 0 {
+		return 0, InvalidPartNumber{PartNumber: partNumber}
+	}
+	return partNumber, nil
+}
@@ -100,3 +100,49 @@ func TestHTTPRequestRangeSpec(t *testing.T) {
 		t.Errorf("Case %d: Expected errInvalidRange but: %v %v %d %d %v", i, rs, err1, o, l, err2)
 	}
 }
+
+func TestPartNumberToRangeSpec(t *testing.T) {
+	oi := ObjectInfo{
+		Size: 12,
+		Parts: []ObjectPartInfo{
+			{Number: 1, Size: 5, ActualSize: 5},
+			{Number: 2, Size: 5, ActualSize: 5},
+			{Number: 4, Size: 2, ActualSize: 2},
+		},
+	}
+	testCases := []struct {
+		partNumber           int
+		expOffset, expLength int64
+		expErr               bool
+	}{
+		{1, 0, 5, false},
+		{2, 5, 5, false},
+		{4, 10, 2, false},
+		{3, 0, 0, true},
+		{5, 0, 0, true},
+	}
+	for i, testCase := range testCases {
+		rs, err := PartNumberToRangeSpec(oi, testCase.partNumber)
+		if testCase.expErr {
+			if _, ok := err.(InvalidPartNumber); !ok {
+				t.Errorf("Case %d: expected InvalidPartNumber, but got %v", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Case %d: unexpected error %v", i, err)
+		}
+		offset, length, err := rs.GetOffsetLength(oi.Size)
+		if err != nil || offset != testCase.expOffset || length != testCase.expLength {
+			t.Errorf("Case %d: expected offset %d and length %d, but got %d, %d, %v", i, testCase.expOffset, testCase.expLength, offset, length, err)
+		}
+	}
+
+	// an object without parts is a single part
+	if rs, err := PartNumberToRangeSpec(ObjectInfo{Size: 12}, 1); rs != nil || err != nil {
+		t.Errorf("expected the whole object for part 1, but got %v, %v", rs, err)
+	}
+	if _, err := PartNumberToRangeSpec(ObjectInfo{Size: 12}, 2); err == nil {
+		t.Error("expected an error for part 2 of an object without parts")
+	}
+}
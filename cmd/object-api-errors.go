@@ -179,6 +179,13 @@ func (e BucketNotEmpty) Error() string {
 	return "Bucket not empty: " + e.Bucket
 }
 
+// BucketQuotaExceeded bucket has reached its quota.
+type BucketQuotaExceeded GenericError
+
+func (e BucketQuotaExceeded) Error() string {
+	return "Bucket quota exceeded: " + e.Bucket
+}
+
 // ObjectNotFound object does not exist.
 type ObjectNotFound GenericError
 
@@ -193,6 +200,13 @@ func (e ObjectAlreadyExists) Error() string {
 	return "Object: " + e.Bucket + "#" + e.Object + " already exists"
 }
 
+// ObjectLocked object is WORM protected and cannot be overwritten or deleted.
+type ObjectLocked GenericError
+
+func (e ObjectLocked) Error() string {
+	return "Object: " + e.Bucket + "#" + e.Object + " is WORM protected"
+}
+
 // ObjectExistsAsDirectory object already exists as a directory.
 type ObjectExistsAsDirectory GenericError
 
@@ -269,6 +283,13 @@ func (e BucketSSEConfigNotFound) Error() string {
 	return "No bucket encryption found for bucket: " + e.Bucket
 }
 
+// ObjectEncryptionRequired - the bucket encryption config requires objects to be encrypted.
+type ObjectEncryptionRequired GenericError
+
+func (e ObjectEncryptionRequired) Error() string {
+	return "Bucket requires server side encryption: " + e.Bucket + "#" + e.Object
+}
+
 /// Bucket related errors.
 
 // BucketNameInvalid - bucketname provided is invalid.
@@ -305,6 +326,14 @@ func (e ObjectNamePrefixAsSlash) Error() string {
 	return "Object name contains forward slash as pefix: " + e.Bucket + "#" + e.Object
 }
 
+// MetadataTooLarge - the user metadata of an object exceeds the size limit.
+type MetadataTooLarge GenericError
+
+// Error returns string an error formatted as the given text.
+func (e MetadataTooLarge) Error() string {
+	return "Object metadata too large: " + e.Bucket + "#" + e.Object
+}
+
 // AllAccessDisabled All access to this object has been disabled
 type AllAccessDisabled GenericError
 
@@ -388,6 +417,15 @@ func (e InvalidPart) Error() string {
 		e.PartNumber, e.ExpETag, e.GotETag)
 }
 
+// InvalidPartNumber - the object has no part with the requested number.
+type InvalidPartNumber struct {
+	PartNumber int
+}
+
+func (e InvalidPartNumber) Error() string {
+	return fmt.Sprintf("The requested part number %d is not satisfiable", e.PartNumber)
+}
+
 // PartTooSmall - error if part size is less than 5MB.
 type PartTooSmall struct {
 	PartSize   int64
@@ -427,6 +465,15 @@ func (e UnsupportedMetadata) Error() string {
 	return "Unsupported headers in Metadata"
 }
 
+// InvalidRequest - the request can't be served by the object layer as made.
+type InvalidRequest struct {
+	Err error
+}
+
+func (e InvalidRequest) Error() string {
+	return "Invalid request: " + e.Err.Error()
+}
+
 // BackendDown is returned for network errors or if the gateway's backend is down.
 type BackendDown struct{}
 
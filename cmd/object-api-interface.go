@@ -23,6 +23,7 @@ import (
 
 	bucketsse "github.com/RTradeLtd/s3x/pkg/bucket/encryption"
 	"github.com/RTradeLtd/s3x/pkg/bucket/lifecycle"
+	objectlock "github.com/RTradeLtd/s3x/pkg/bucket/object/lock"
 	"github.com/RTradeLtd/s3x/pkg/bucket/object/tagging"
 	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
 	"github.com/RTradeLtd/s3x/pkg/madmin"
@@ -40,6 +41,9 @@ type ObjectOptions struct {
 	ServerSideEncryption encrypt.ServerSide
 	UserDefined          map[string]string
 	CheckCopyPrecondFn   CheckCopyPreconditionFn
+	// AcceptEncoding is the Accept-Encoding of a HEAD request for a whole object, so object
+	// layers serving stored compressed data can report the size and encoding GET would serve.
+	AcceptEncoding string
 }
 
 // LockType represents required locking for ObjectLayer operations
@@ -139,3 +143,59 @@ type ObjectLayer interface {
 	GetObjectTag(context.Context, string, string) (tagging.Tagging, error)
 	DeleteObjectTag(context.Context, string, string) error
 }
+
+// ObjectRedirector is an optional interface of object layers which can serve
+// the data of some objects from another location, such as a public http gateway.
+type ObjectRedirector interface {
+	// GetObjectRedirectURL returns the url to redirect GET requests of the object to,
+	// or an empty string if the object must be served by the object layer.
+	GetObjectRedirectURL(ctx context.Context, bucket, object string) (string, error)
+}
+
+// BucketLocator is an optional interface of object layers which keep the location
+// constraint of each bucket, instead of serving all buckets from the server region.
+type BucketLocator interface {
+	// GetBucketLocation returns the location the bucket was created with.
+	GetBucketLocation(ctx context.Context, bucket string) (string, error)
+}
+
+// ObjectLegalHolder is an optional interface of object layers which keep the legal hold
+// of objects themselves, on any bucket, instead of in the object metadata.
+type ObjectLegalHolder interface {
+	// PutObjectLegalHold places the object under legal hold, or releases it.
+	PutObjectLegalHold(ctx context.Context, bucket, object string, hold *objectlock.ObjectLegalHold) error
+	// GetObjectLegalHold returns the legal hold status of the object.
+	GetObjectLegalHold(ctx context.Context, bucket, object string) (*objectlock.ObjectLegalHold, error)
+}
+
+// BucketIPNSPublisher is an optional interface of object layers which can publish the root
+// of a bucket under an ipns name, so the latest content of the bucket is reachable by a stable name.
+type BucketIPNSPublisher interface {
+	// SetBucketIPNS enables or disables publishing the root of the bucket.
+	SetBucketIPNS(ctx context.Context, bucket string, enabled bool) error
+	// GetBucketIPNSName returns the ipns name the root of the bucket is published under.
+	GetBucketIPNSName(ctx context.Context, bucket string) (string, error)
+}
+
+// RequestAuthenticator is an optional interface of object layers which authenticate S3 API requests
+// that are not signed with AWS signatures, such as requests with a bearer token or a client
+// certificate of another identity system. Signed requests are always verified by the server,
+// and so are STS requests and the requests of the web, admin and internal routers.
+type RequestAuthenticator interface {
+	// AuthenticateRequest returns the principal making the request, or an error if the request
+	// is denied. An empty principal without an error leaves the request to the server, which
+	// authenticates it as usual, such as a bearer token that is a JWT issued by the server.
+	AuthenticateRequest(r *http.Request) (principal string, err error)
+	// AuthorizeRequest returns true if a principal returned by AuthenticateRequest may perform
+	// the action on the bucket and object, the object is empty for bucket actions.
+	AuthorizeRequest(principal string, action policy.Action, bucket, object string) bool
+}
+
+// KeyRotationReencrypter is an optional interface of object layers which re-encrypt
+// the object data when an SSE-C key is rotated, instead of only resealing the object key.
+// Content addressed layers need this, since data encrypted with the old key keeps its
+// address and stays readable by anyone who knows the old key.
+type KeyRotationReencrypter interface {
+	// ReencryptOnKeyRotation reports whether a key rotation must rewrite the object data.
+	ReencryptOnKeyRotation() bool
+}
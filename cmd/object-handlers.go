@@ -82,6 +82,16 @@ func setHeadGetRespHeaders(w http.ResponseWriter, reqParams url.Values) {
 	}
 }
 
+// hasHeadGetRespOverrides - returns true if any response header is overridden by the request parameters.
+func hasHeadGetRespOverrides(reqParams url.Values) bool {
+	for k := range reqParams {
+		if _, ok := supportedHeadGetReqParams[k]; ok {
+			return true
+		}
+	}
+	return false
+}
+
 // SelectObjectContentHandler - GET Object?select
 // ----------
 // This implementation of the GET operation retrieves object content based
@@ -294,6 +304,12 @@ func (api objectAPIHandlers) GetObjectHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	partNumber, err := parsePartNumber(r.URL.Query().Get("partNumber"))
+	if err != nil {
+		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
 	// Check for auth type to return S3 compatible error.
 	// type to return the correct error (NoSuchKey vs AccessDenied)
 	if s3Error := checkRequestAuthType(ctx, r, policy.GetObjectAction, bucket, object); s3Error != ErrNone {
@@ -332,6 +348,34 @@ func (api objectAPIHandlers) GetObjectHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	// Redirect to another location serving the object data if supported by the object layer,
+	// unless response headers are overridden, which the other location would not honor.
+	// Pre-conditions are validated first, the other location does not know about them.
+	if redirector, ok := objectAPI.(ObjectRedirector); ok && !crypto.SSEC.IsRequested(r.Header) && partNumber == 0 &&
+		!hasHeadGetRespOverrides(r.URL.Query()) {
+		getObjectInfo := objectAPI.GetObjectInfo
+		if api.CacheAPI() != nil {
+			getObjectInfo = api.CacheAPI().GetObjectInfo
+		}
+		oi, err := getObjectInfo(ctx, bucket, object, opts)
+		if err != nil {
+			writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+			return
+		}
+		if checkPreconditions(ctx, w, r, oi) {
+			return
+		}
+		redirectURL, err := redirector.GetObjectRedirectURL(ctx, bucket, object)
+		if err != nil {
+			writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+			return
+		}
+		if redirectURL != "" {
+			http.Redirect(w, r, redirectURL, http.StatusFound)
+			return
+		}
+	}
+
 	getObjectNInfo := objectAPI.GetObjectNInfo
 	if api.CacheAPI() != nil {
 		getObjectNInfo = api.CacheAPI().GetObjectNInfo
@@ -354,6 +398,27 @@ func (api objectAPIHandlers) GetObjectHandler(w http.ResponseWriter, r *http.Req
 		}
 	}
 
+	// A part of a multipart object is read as the range of the part.
+	if partNumber > 0 {
+		if rangeHeader != "" {
+			writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrInvalidRangePartNumber), r.URL, guessIsBrowserReq(r))
+			return
+		}
+		getObjectInfo := objectAPI.GetObjectInfo
+		if api.CacheAPI() != nil {
+			getObjectInfo = api.CacheAPI().GetObjectInfo
+		}
+		oi, err := getObjectInfo(ctx, bucket, object, opts)
+		if err != nil {
+			writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+			return
+		}
+		if rs, err = PartNumberToRangeSpec(oi, partNumber); err != nil {
+			writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+			return
+		}
+	}
+
 	gr, err := getObjectNInfo(ctx, bucket, object, rs, r.Header, readLock, opts)
 	if err != nil {
 		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
@@ -399,6 +464,9 @@ func (api objectAPIHandlers) GetObjectHandler(w http.ResponseWriter, r *http.Req
 		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
 		return
 	}
+	if partNumber > 0 && len(objInfo.Parts) > 0 {
+		w.Header().Set(xhttp.AmzMpPartsCount, strconv.Itoa(len(objInfo.Parts)))
+	}
 
 	setHeadGetRespHeaders(w, r.URL.Query())
 
@@ -480,6 +548,16 @@ func (api objectAPIHandlers) HeadObjectHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	partNumber, err := parsePartNumber(r.URL.Query().Get("partNumber"))
+	if err != nil {
+		writeErrorResponseHeadersOnly(w, toAPIError(ctx, err))
+		return
+	}
+	if r.Header.Get(xhttp.Range) == "" && partNumber == 0 {
+		// whole objects may be served compressed, depending on the accepted encodings
+		opts.AcceptEncoding = strings.Join(r.Header[xhttp.AcceptEncoding], ",")
+	}
+
 	if s3Error := checkRequestAuthType(ctx, r, policy.GetObjectAction, bucket, object); s3Error != ErrNone {
 		if getRequestAuthType(r) == authTypeAnonymous {
 			// As per "Permission" section in
@@ -534,6 +612,18 @@ func (api objectAPIHandlers) HeadObjectHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	// A part of a multipart object is described as the range of the part.
+	if partNumber > 0 {
+		if rangeHeader != "" {
+			writeErrorResponseHeadersOnly(w, errorCodes.ToAPIErr(ErrInvalidRangePartNumber))
+			return
+		}
+		if rs, err = PartNumberToRangeSpec(objInfo, partNumber); err != nil {
+			writeErrorResponseHeadersOnly(w, toAPIError(ctx, err))
+			return
+		}
+	}
+
 	// filter object lock metadata if permission does not permit
 	getRetPerms := checkRequestAuthType(ctx, r, policy.GetObjectRetentionAction, bucket, object)
 	legalHoldPerms := checkRequestAuthType(ctx, r, policy.GetObjectLegalHoldAction, bucket, object)
@@ -577,6 +667,9 @@ func (api objectAPIHandlers) HeadObjectHandler(w http.ResponseWriter, r *http.Re
 		writeErrorResponseHeadersOnly(w, toAPIError(ctx, err))
 		return
 	}
+	if partNumber > 0 && len(objInfo.Parts) > 0 {
+		w.Header().Set(xhttp.AmzMpPartsCount, strconv.Itoa(len(objInfo.Parts)))
+	}
 
 	// Set any additional requested response headers.
 	setHeadGetRespHeaders(w, r.URL.Query())
@@ -926,8 +1019,14 @@ func (api objectAPIHandlers) CopyObjectHandler(w http.ResponseWriter, r *http.Re
 		// - the object is encrypted using SSE-C and two different SSE-C keys are present
 		// - the object is encrypted using SSE-S3 and the SSE-S3 header is present
 		// than execute a key rotation.
+		// Object layers which re-encrypt on key rotation decrypt and encrypt the data
+		// again as for any other encrypted copy.
+		reencrypt := false
+		if reencrypter, ok := objectAPI.(KeyRotationReencrypter); ok {
+			reencrypt = reencrypter.ReencryptOnKeyRotation()
+		}
 		var keyRotation bool
-		if cpSrcDstSame && (sseCopyC && sseC) {
+		if cpSrcDstSame && (sseCopyC && sseC) && !reencrypt {
 			oldKey, err = ParseSSECopyCustomerRequest(r.Header, srcInfo.UserDefined)
 			if err != nil {
 				writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
@@ -1184,7 +1283,7 @@ func (api objectAPIHandlers) PutObjectHandler(w http.ResponseWriter, r *http.Req
 	/// if Content-Length is unknown/missing, deny the request
 	size := r.ContentLength
 	rAuthType := getRequestAuthType(r)
-	if rAuthType == authTypeStreamingSigned {
+	if rAuthType == authTypeStreamingSigned || isRequestUnsignedTrailer(r) {
 		if sizeStr, ok := r.Header[xhttp.AmzDecodedContentLength]; ok {
 			if sizeStr[0] == "" {
 				writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrMissingContentLength), r.URL, guessIsBrowserReq(r))
@@ -1222,7 +1321,7 @@ func (api objectAPIHandlers) PutObjectHandler(w http.ResponseWriter, r *http.Req
 		}
 	}
 
-	if rAuthType == authTypeStreamingSigned {
+	if rAuthType == authTypeStreamingSigned || isRequestUnsignedTrailer(r) {
 		if contentEncoding, ok := metadata["content-encoding"]; ok {
 			contentEncoding = trimAwsChunkedContentEncoding(contentEncoding)
 			if contentEncoding != "" {
@@ -1282,6 +1381,21 @@ func (api objectAPIHandlers) PutObjectHandler(w http.ResponseWriter, r *http.Req
 		}
 	}
 
+	// Validate additional checksums, which may be sent as a trailer
+	checksumHeader, checksumValue, s3Err := getChecksumHeader(r)
+	if s3Err != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+	var trailerReader *unsignedTrailerReader
+	if isRequestUnsignedTrailer(r) {
+		trailerReader = newUnsignedTrailerReader(reader)
+		reader = trailerReader
+	}
+	if checksumHeader != "" {
+		reader = newChecksumReader(reader, size, checksumHeader, checksumValue, trailerReader, metadata)
+	}
+
 	// Check if bucket encryption is enabled
 	_, encEnabled := globalBucketSSEConfigSys.Get(bucket)
 	// This request header needs to be set prior to setting ObjectOptions
@@ -2613,7 +2727,9 @@ func (api objectAPIHandlers) PutObjectLegalHoldHandler(w http.ResponseWriter, r
 		return
 	}
 
-	if _, ok := globalBucketObjectLockConfig.Get(bucket); !ok {
+	// object layers keeping legal holds themselves place them on any bucket
+	holder, isHolder := objectAPI.(ObjectLegalHolder)
+	if _, ok := globalBucketObjectLockConfig.Get(bucket); !ok && !isHolder {
 		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrInvalidBucketObjectLockConfiguration), r.URL, guessIsBrowserReq(r))
 		return
 	}
@@ -2639,10 +2755,14 @@ func (api objectAPIHandlers) PutObjectLegalHoldHandler(w http.ResponseWriter, r
 		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
 		return
 	}
-	objInfo.UserDefined[strings.ToLower(xhttp.AmzObjectLockLegalHold)] = strings.ToUpper(string(legalHold.Status))
-	objInfo.metadataOnly = true
-
-	if _, err = objectAPI.CopyObject(ctx, bucket, object, bucket, object, objInfo, ObjectOptions{}, ObjectOptions{}); err != nil {
+	if isHolder {
+		err = holder.PutObjectLegalHold(ctx, bucket, object, legalHold)
+	} else {
+		objInfo.UserDefined[strings.ToLower(xhttp.AmzObjectLockLegalHold)] = strings.ToUpper(string(legalHold.Status))
+		objInfo.metadataOnly = true
+		_, err = objectAPI.CopyObject(ctx, bucket, object, bucket, object, objInfo, ObjectOptions{}, ObjectOptions{})
+	}
+	if err != nil {
 		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
 		return
 	}
@@ -2707,11 +2827,21 @@ func (api objectAPIHandlers) GetObjectLegalHoldHandler(w http.ResponseWriter, r
 		return
 	}
 
-	if _, ok := globalBucketObjectLockConfig.Get(bucket); !ok {
-		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrInvalidBucketObjectLockConfiguration), r.URL, guessIsBrowserReq(r))
-		return
+	var legalHold objectlock.ObjectLegalHold
+	if holder, ok := objectAPI.(ObjectLegalHolder); ok {
+		hold, err := holder.GetObjectLegalHold(ctx, bucket, object)
+		if err != nil {
+			writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+			return
+		}
+		legalHold = *hold
+	} else {
+		if _, ok := globalBucketObjectLockConfig.Get(bucket); !ok {
+			writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrInvalidBucketObjectLockConfiguration), r.URL, guessIsBrowserReq(r))
+			return
+		}
+		legalHold = objectlock.GetObjectLegalHoldMeta(objInfo.UserDefined)
 	}
-	legalHold := objectlock.GetObjectLegalHoldMeta(objInfo.UserDefined)
 	if legalHold.IsEmpty() {
 		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrNoSuchObjectLockConfiguration), r.URL, guessIsBrowserReq(r))
 		return
@@ -39,6 +39,7 @@ import (
 	"github.com/RTradeLtd/s3x/cmd/crypto"
 	xhttp "github.com/RTradeLtd/s3x/cmd/http"
 	"github.com/RTradeLtd/s3x/pkg/auth"
+	objectlock "github.com/RTradeLtd/s3x/pkg/bucket/object/lock"
 	ioutilx "github.com/RTradeLtd/s3x/pkg/ioutil"
 	humanize "github.com/dustin/go-humanize"
 )
@@ -646,6 +647,366 @@ func testAPIGetObjectHandler(obj ObjectLayer, instanceType, bucketName string, a
 	ExecObjectLayerAPINilTest(t, nilBucket, nilObject, instanceType, apiRouter, nilReq)
 }
 
+// Wrapper for calling GetObject response header override tests for both XL multiple disks and FS single drive setup.
+func TestAPIGetObjectRespOverridesHandler(t *testing.T) {
+	globalPolicySys = NewPolicySys()
+	defer func() { globalPolicySys = nil }()
+
+	defer DetectTestLeak(t)()
+	ExecObjectLayerAPITest(t, testAPIGetObjectRespOverridesHandler, []string{"GetObject"})
+}
+
+func testAPIGetObjectRespOverridesHandler(obj ObjectLayer, instanceType, bucketName string, apiRouter http.Handler,
+	credentials auth.Credentials, t *testing.T) {
+
+	objectName := "test-object"
+	data := []byte("hello world")
+	// upload the object with a stored content type.
+	metaData := map[string]string{"content-type": "application/json"}
+	if _, err := obj.PutObject(context.Background(), bucketName, objectName, mustGetPutObjReader(t, bytes.NewReader(data), int64(len(data)), "", ""), ObjectOptions{UserDefined: metaData}); err != nil {
+		t.Fatalf("%s: Error uploading object: <ERROR> %v", instanceType, err)
+	}
+
+	testCases := []struct {
+		queryParams         url.Values
+		expectedContentType string
+	}{
+		// Test case - 1.
+		// The stored content type is overridden by the request parameter.
+		{url.Values{"response-content-type": []string{"text/plain"}}, "text/plain"},
+		// Test case - 2.
+		// A subsequent GET without the parameter returns the stored content type.
+		{url.Values{}, "application/json"},
+	}
+	for i, testCase := range testCases {
+		rec := httptest.NewRecorder()
+		req, err := newTestSignedRequestV4("GET", makeTestTargetURL("", bucketName, objectName, testCase.queryParams),
+			0, nil, credentials.AccessKey, credentials.SecretKey, nil)
+		if err != nil {
+			t.Fatalf("Test %d: Failed to create HTTP request for Get Object: <ERROR> %v", i+1, err)
+		}
+		apiRouter.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Test %d: %s: Expected the response status to be `%d`, but instead found `%d`", i+1, instanceType, http.StatusOK, rec.Code)
+		}
+		if contentType := rec.Header().Get(xhttp.ContentType); contentType != testCase.expectedContentType {
+			t.Errorf("Test %d: %s: Expected content type `%s`, but instead found `%s`", i+1, instanceType, testCase.expectedContentType, contentType)
+		}
+		if !bytes.Equal(rec.Body.Bytes(), data) {
+			t.Errorf("Test %d: %s: Object content differs from expected value", i+1, instanceType)
+		}
+	}
+}
+
+// legalHolderLayer is an object layer keeping the legal holds of objects in memory.
+type legalHolderLayer struct {
+	ObjectLayer
+	holds map[string]objectlock.LegalHoldStatus
+}
+
+func (l legalHolderLayer) PutObjectLegalHold(ctx context.Context, bucket, object string, hold *objectlock.ObjectLegalHold) error {
+	l.holds[pathJoin(bucket, object)] = hold.Status
+	return nil
+}
+
+func (l legalHolderLayer) GetObjectLegalHold(ctx context.Context, bucket, object string) (*objectlock.ObjectLegalHold, error) {
+	status, ok := l.holds[pathJoin(bucket, object)]
+	if !ok {
+		status = objectlock.OFF
+	}
+	return &objectlock.ObjectLegalHold{Status: status}, nil
+}
+
+// Wrapper for calling legal hold HTTP handler tests with an object layer keeping legal holds.
+func TestAPIObjectLegalHoldHandlerLegalHolder(t *testing.T) {
+	defer DetectTestLeak(t)()
+	ExecObjectLayerAPITest(t, testAPIObjectLegalHoldHandlerLegalHolder, []string{"PutObjectLegalHold", "GetObjectLegalHold"})
+}
+
+func testAPIObjectLegalHoldHandlerLegalHolder(obj ObjectLayer, instanceType, bucketName string, apiRouter http.Handler,
+	credentials auth.Credentials, t *testing.T) {
+	layer := legalHolderLayer{ObjectLayer: obj, holds: make(map[string]objectlock.LegalHoldStatus)}
+	globalObjLayerMutex.Lock()
+	globalObjectAPI = layer
+	globalObjLayerMutex.Unlock()
+	defer func() {
+		globalObjLayerMutex.Lock()
+		globalObjectAPI = obj
+		globalObjLayerMutex.Unlock()
+	}()
+
+	objectName := "test-object"
+	data := []byte("hello world")
+	if _, err := obj.PutObject(context.Background(), bucketName, objectName, mustGetPutObjReader(t, bytes.NewReader(data), int64(len(data)), "", ""), ObjectOptions{}); err != nil {
+		t.Fatalf("%s: Error uploading object: <ERROR> %v", instanceType, err)
+	}
+	legalHoldURL := makeTestTargetURL("", bucketName, objectName, url.Values{"legal-hold": []string{""}})
+
+	// The bucket has no object lock configuration, the hold is placed by the object layer.
+	for i, status := range []objectlock.LegalHoldStatus{objectlock.ON, objectlock.OFF} {
+		body, err := xml.Marshal(objectlock.ObjectLegalHold{Status: status})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		req, err := newTestSignedRequestV4("PUT", legalHoldURL, int64(len(body)), bytes.NewReader(body),
+			credentials.AccessKey, credentials.SecretKey, map[string]string{"Content-Md5": getMD5HashBase64(body)})
+		if err != nil {
+			t.Fatalf("Test %d: Failed to create HTTP request for Put Object Legal Hold: <ERROR> %v", i+1, err)
+		}
+		apiRouter.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Test %d: %s: Expected the response status to be `%d`, but instead found `%d`", i+1, instanceType, http.StatusOK, rec.Code)
+		}
+		if layer.holds[pathJoin(bucketName, objectName)] != status {
+			t.Fatalf("Test %d: %s: Expected the object layer to hold `%s`, but instead found `%s`", i+1, instanceType, status, layer.holds[pathJoin(bucketName, objectName)])
+		}
+
+		rec = httptest.NewRecorder()
+		req, err = newTestSignedRequestV4("GET", legalHoldURL, 0, nil, credentials.AccessKey, credentials.SecretKey, nil)
+		if err != nil {
+			t.Fatalf("Test %d: Failed to create HTTP request for Get Object Legal Hold: <ERROR> %v", i+1, err)
+		}
+		apiRouter.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Test %d: %s: Expected the response status to be `%d`, but instead found `%d`", i+1, instanceType, http.StatusOK, rec.Code)
+		}
+		hold := objectlock.ObjectLegalHold{}
+		if err = xml.Unmarshal(rec.Body.Bytes(), &hold); err != nil {
+			t.Fatalf("Test %d: %s: Unable to unmarshal response body %s", i+1, instanceType, rec.Body.String())
+		}
+		if hold.Status != status {
+			t.Errorf("Test %d: %s: Expected the legal hold to be `%s`, but instead found `%s`", i+1, instanceType, status, hold.Status)
+		}
+	}
+}
+
+// objectRedirectorLayer is an object layer redirecting GET requests of all objects to url.
+type objectRedirectorLayer struct {
+	ObjectLayer
+	url string
+}
+
+func (l objectRedirectorLayer) GetObjectRedirectURL(ctx context.Context, bucket, object string) (string, error) {
+	return l.url, nil
+}
+
+// Wrapper for calling GetObject response header override tests with an object layer redirecting GETs.
+func TestAPIGetObjectRespOverridesRedirectorHandler(t *testing.T) {
+	globalPolicySys = NewPolicySys()
+	defer func() { globalPolicySys = nil }()
+
+	defer DetectTestLeak(t)()
+	ExecObjectLayerAPITest(t, testAPIGetObjectRespOverridesRedirectorHandler, []string{"GetObject"})
+}
+
+func testAPIGetObjectRespOverridesRedirectorHandler(obj ObjectLayer, instanceType, bucketName string, apiRouter http.Handler,
+	credentials auth.Credentials, t *testing.T) {
+	const redirectURL = "https://gateway.example.com/ipfs/QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH"
+	globalObjLayerMutex.Lock()
+	globalObjectAPI = objectRedirectorLayer{ObjectLayer: obj, url: redirectURL}
+	globalObjLayerMutex.Unlock()
+	defer func() {
+		globalObjLayerMutex.Lock()
+		globalObjectAPI = obj
+		globalObjLayerMutex.Unlock()
+	}()
+
+	objectName := "test-object"
+	data := []byte("hello world")
+	metaData := map[string]string{"content-type": "application/json"}
+	if _, err := obj.PutObject(context.Background(), bucketName, objectName, mustGetPutObjReader(t, bytes.NewReader(data), int64(len(data)), "", ""), ObjectOptions{UserDefined: metaData}); err != nil {
+		t.Fatalf("%s: Error uploading object: <ERROR> %v", instanceType, err)
+	}
+
+	testCases := []struct {
+		queryParams         url.Values
+		expectedRespStatus  int
+		expectedContentType string
+		expectedLocation    string
+	}{
+		// Test case - 1.
+		// Overridden response headers are served by the object layer instead of the redirect.
+		{url.Values{"response-content-type": []string{"text/plain"}}, http.StatusOK, "text/plain", ""},
+		// Test case - 2.
+		// Without overrides the GET is redirected.
+		{url.Values{}, http.StatusFound, "", redirectURL},
+	}
+	for i, testCase := range testCases {
+		rec := httptest.NewRecorder()
+		req, err := newTestSignedRequestV4("GET", makeTestTargetURL("", bucketName, objectName, testCase.queryParams),
+			0, nil, credentials.AccessKey, credentials.SecretKey, nil)
+		if err != nil {
+			t.Fatalf("Test %d: Failed to create HTTP request for Get Object: <ERROR> %v", i+1, err)
+		}
+		apiRouter.ServeHTTP(rec, req)
+		if rec.Code != testCase.expectedRespStatus {
+			t.Fatalf("Test %d: %s: Expected the response status to be `%d`, but instead found `%d`", i+1, instanceType, testCase.expectedRespStatus, rec.Code)
+		}
+		if testCase.expectedRespStatus == http.StatusFound {
+			if location := rec.Header().Get(xhttp.Location); location != testCase.expectedLocation {
+				t.Errorf("Test %d: %s: Expected redirect to `%s`, but instead found `%s`", i+1, instanceType, testCase.expectedLocation, location)
+			}
+			continue
+		}
+		if contentType := rec.Header().Get(xhttp.ContentType); contentType != testCase.expectedContentType {
+			t.Errorf("Test %d: %s: Expected content type `%s`, but instead found `%s`", i+1, instanceType, testCase.expectedContentType, contentType)
+		}
+		if !bytes.Equal(rec.Body.Bytes(), data) {
+			t.Errorf("Test %d: %s: Object content differs from expected value", i+1, instanceType)
+		}
+	}
+}
+
+// Wrapper for calling GetObject pre-condition tests with an object layer redirecting GETs.
+func TestAPIGetObjectRedirectPreconditionsHandler(t *testing.T) {
+	globalPolicySys = NewPolicySys()
+	defer func() { globalPolicySys = nil }()
+
+	defer DetectTestLeak(t)()
+	ExecObjectLayerAPITest(t, testAPIGetObjectRedirectPreconditionsHandler, []string{"GetObject"})
+}
+
+func testAPIGetObjectRedirectPreconditionsHandler(obj ObjectLayer, instanceType, bucketName string, apiRouter http.Handler,
+	credentials auth.Credentials, t *testing.T) {
+	const redirectURL = "https://gateway.example.com/ipfs/QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH"
+	globalObjLayerMutex.Lock()
+	globalObjectAPI = objectRedirectorLayer{ObjectLayer: obj, url: redirectURL}
+	globalObjLayerMutex.Unlock()
+	defer func() {
+		globalObjLayerMutex.Lock()
+		globalObjectAPI = obj
+		globalObjLayerMutex.Unlock()
+	}()
+
+	objectName := "test-object"
+	data := []byte("hello world")
+	info, err := obj.PutObject(context.Background(), bucketName, objectName, mustGetPutObjReader(t, bytes.NewReader(data), int64(len(data)), "", ""), ObjectOptions{})
+	if err != nil {
+		t.Fatalf("%s: Error uploading object: <ERROR> %v", instanceType, err)
+	}
+	etag := "\"" + info.ETag + "\""
+
+	testCases := []struct {
+		header             string
+		value              string
+		expectedRespStatus int
+	}{
+		// Test case - 1.
+		// A matching If-None-Match is not modified instead of redirected.
+		{xhttp.IfNoneMatch, etag, http.StatusNotModified},
+		// Test case - 2.
+		// A failing If-Match fails instead of being redirected.
+		{xhttp.IfMatch, "\"mismatching-etag\"", http.StatusPreconditionFailed},
+		// Test case - 3.
+		// A matching If-Match is redirected.
+		{xhttp.IfMatch, etag, http.StatusFound},
+		// Test case - 4.
+		// A modified object is redirected.
+		{xhttp.IfNoneMatch, "\"mismatching-etag\"", http.StatusFound},
+	}
+	for i, testCase := range testCases {
+		rec := httptest.NewRecorder()
+		req, err := newTestSignedRequestV4("GET", getGetObjectURL("", bucketName, objectName),
+			0, nil, credentials.AccessKey, credentials.SecretKey, map[string]string{testCase.header: testCase.value})
+		if err != nil {
+			t.Fatalf("Test %d: Failed to create HTTP request for Get Object: <ERROR> %v", i+1, err)
+		}
+		apiRouter.ServeHTTP(rec, req)
+		if rec.Code != testCase.expectedRespStatus {
+			t.Errorf("Test %d: %s: Expected the response status to be `%d`, but instead found `%d`", i+1, instanceType, testCase.expectedRespStatus, rec.Code)
+		}
+	}
+}
+
+// contentETagLayer is an object layer using the content hash of objects as their ETag, like the s3x gateway.
+type contentETagLayer struct {
+	ObjectLayer
+	etag string
+}
+
+func (l contentETagLayer) GetObjectInfo(ctx context.Context, bucket, object string, opts ObjectOptions) (ObjectInfo, error) {
+	info, err := l.ObjectLayer.GetObjectInfo(ctx, bucket, object, opts)
+	info.ETag = l.etag
+	return info, err
+}
+
+func (l contentETagLayer) GetObjectNInfo(ctx context.Context, bucket, object string, rs *HTTPRangeSpec, h http.Header, lockType LockType, opts ObjectOptions) (*GetObjectReader, error) {
+	reader, err := l.ObjectLayer.GetObjectNInfo(ctx, bucket, object, rs, h, lockType, opts)
+	if reader != nil {
+		reader.ObjInfo.ETag = l.etag
+	}
+	return reader, err
+}
+
+// Wrapper for calling conditional HeadObject and GetObject tests with an object layer using content hash ETags.
+func TestAPIObjectIfNoneMatchHandler(t *testing.T) {
+	globalPolicySys = NewPolicySys()
+	defer func() { globalPolicySys = nil }()
+
+	defer DetectTestLeak(t)()
+	ExecObjectLayerAPITest(t, testAPIObjectIfNoneMatchHandler, []string{"HeadObject", "GetObject"})
+}
+
+func testAPIObjectIfNoneMatchHandler(obj ObjectLayer, instanceType, bucketName string, apiRouter http.Handler,
+	credentials auth.Credentials, t *testing.T) {
+	const etag = "bafybeidespqxhoavxmrq6sxcypcwatb6u3splitarmw7z46pivdhahluaa"
+	globalObjLayerMutex.Lock()
+	globalObjectAPI = contentETagLayer{ObjectLayer: obj, etag: etag}
+	globalObjLayerMutex.Unlock()
+	defer func() {
+		globalObjLayerMutex.Lock()
+		globalObjectAPI = obj
+		globalObjLayerMutex.Unlock()
+	}()
+
+	objectName := "test-object"
+	data := []byte("hello world")
+	if _, err := obj.PutObject(context.Background(), bucketName, objectName, mustGetPutObjReader(t, bytes.NewReader(data), int64(len(data)), "", ""), ObjectOptions{}); err != nil {
+		t.Fatalf("%s: Error uploading object: <ERROR> %v", instanceType, err)
+	}
+
+	testCases := []struct {
+		method             string
+		ifNoneMatch        string
+		expectedRespStatus int
+	}{
+		// Test case - 1.
+		// A HEAD with the ETag of the object is not modified.
+		{http.MethodHead, "\"" + etag + "\"", http.StatusNotModified},
+		// Test case - 2.
+		// A GET with the ETag of the object is not modified.
+		{http.MethodGet, "\"" + etag + "\"", http.StatusNotModified},
+		// Test case - 3.
+		// Unquoted ETags match too.
+		{http.MethodGet, etag, http.StatusNotModified},
+		// Test case - 4.
+		// A HEAD with another ETag is served.
+		{http.MethodHead, "\"mismatching-etag\"", http.StatusOK},
+		// Test case - 5.
+		// A GET with another ETag is served.
+		{http.MethodGet, "\"mismatching-etag\"", http.StatusOK},
+	}
+	for i, testCase := range testCases {
+		rec := httptest.NewRecorder()
+		req, err := newTestSignedRequestV4(testCase.method, getGetObjectURL("", bucketName, objectName),
+			0, nil, credentials.AccessKey, credentials.SecretKey, map[string]string{xhttp.IfNoneMatch: testCase.ifNoneMatch})
+		if err != nil {
+			t.Fatalf("Test %d: Failed to create HTTP request for %s Object: <ERROR> %v", i+1, testCase.method, err)
+		}
+		apiRouter.ServeHTTP(rec, req)
+		if rec.Code != testCase.expectedRespStatus {
+			t.Errorf("Test %d: %s: Expected the response status to be `%d`, but instead found `%d`", i+1, instanceType, testCase.expectedRespStatus, rec.Code)
+		}
+		if rec.Code == http.StatusNotModified && rec.Body.Len() != 0 {
+			t.Errorf("Test %d: %s: Expected no body for a not modified object", i+1, instanceType)
+		}
+		if testCase.method == http.MethodGet && rec.Code == http.StatusOK && !bytes.Equal(rec.Body.Bytes(), data) {
+			t.Errorf("Test %d: %s: Object content differs from expected value", i+1, instanceType)
+		}
+	}
+}
+
 // Wrapper for calling GetObject API handler tests for both XL multiple disks and FS single drive setup.
 func TestAPIGetObjectWithMPHandler(t *testing.T) {
 	globalPolicySys = NewPolicySys()
@@ -1409,6 +1770,53 @@ func testAPIPutObjectHandler(obj ObjectLayer, instanceType, bucketName string, a
 
 }
 
+// Wrapper for calling PutObject API handler tests with additional checksums.
+func TestAPIPutObjectChecksumHandler(t *testing.T) {
+	defer DetectTestLeak(t)()
+	ExecObjectLayerAPITest(t, testAPIPutObjectChecksumHandler, []string{"PutObject"})
+}
+
+func testAPIPutObjectChecksumHandler(obj ObjectLayer, instanceType, bucketName string, apiRouter http.Handler,
+	credentials auth.Credentials, t *testing.T) {
+	objectName := "test-object"
+	data := []byte("123456789")
+	testCases := []struct {
+		checksum           string
+		expectedRespStatus int
+		expectedErrCode    string
+	}{
+		// Test case - 1.
+		// CRC32C of the data is 0xE3069283.
+		{"4waSgw==", http.StatusOK, ""},
+		// Test case - 2.
+		// Mismatched checksum.
+		{"AAAAAA==", http.StatusBadRequest, "BadDigest"},
+	}
+	for i, testCase := range testCases {
+		rec := httptest.NewRecorder()
+		req, err := newTestSignedRequestV4(http.MethodPut, getPutObjectURL("", bucketName, objectName),
+			int64(len(data)), bytes.NewReader(data), credentials.AccessKey, credentials.SecretKey,
+			map[string]string{xhttp.AmzChecksumCRC32C: testCase.checksum})
+		if err != nil {
+			t.Fatalf("Test %d: Failed to create HTTP request for Put Object: <ERROR> %v", i+1, err)
+		}
+		apiRouter.ServeHTTP(rec, req)
+		if rec.Code != testCase.expectedRespStatus {
+			t.Fatalf("Test %d: %s: Expected the response status to be `%d`, but instead found `%d`", i+1, instanceType, testCase.expectedRespStatus, rec.Code)
+		}
+		if testCase.expectedErrCode == "" {
+			continue
+		}
+		errResp := APIErrorResponse{}
+		if err = xml.Unmarshal(rec.Body.Bytes(), &errResp); err != nil {
+			t.Fatalf("Test %d: %s: Failed to parse the error response: <ERROR> %v", i+1, instanceType, err)
+		}
+		if errResp.Code != testCase.expectedErrCode {
+			t.Errorf("Test %d: %s: Expected the error code to be `%s`, but instead found `%s`", i+1, instanceType, testCase.expectedErrCode, errResp.Code)
+		}
+	}
+}
+
 // Tests sanity of attempting to copying each parts at offsets from an existing
 // file and create a new object. Also validates if the written is same as what we
 // expected.
@@ -2319,6 +2727,105 @@ func testAPICopyObjectHandler(obj ObjectLayer, instanceType, bucketName string,
 
 }
 
+// reencrypterLayer is an object layer which re-encrypts the object data on SSE-C key rotation.
+type reencrypterLayer struct {
+	ObjectLayer
+}
+
+func (reencrypterLayer) ReencryptOnKeyRotation() bool {
+	return true
+}
+
+// Wrapper for calling SSE-C key rotation tests with an object layer re-encrypting the object data.
+func TestAPICopyObjectHandlerKeyRotationReencrypter(t *testing.T) {
+	defer DetectTestLeak(t)()
+	ExecObjectLayerAPITest(t, testAPICopyObjectHandlerKeyRotationReencrypter, []string{"CopyObject", "PutObject", "GetObject"})
+}
+
+func testAPICopyObjectHandlerKeyRotationReencrypter(obj ObjectLayer, instanceType, bucketName string, apiRouter http.Handler,
+	credentials auth.Credentials, t *testing.T) {
+	layer := reencrypterLayer{ObjectLayer: obj}
+	globalObjLayerMutex.Lock()
+	globalObjectAPI = layer
+	globalObjLayerMutex.Unlock()
+	defer func() {
+		globalObjLayerMutex.Lock()
+		globalObjectAPI = obj
+		globalObjLayerMutex.Unlock()
+	}()
+
+	// Set SSL to on to do encryption tests
+	globalIsSSL = true
+	defer func() { globalIsSSL = false }()
+
+	sseCHeaders := func(algorithm, key, keyMD5 string, clientKey []byte) map[string]string {
+		sum := md5.Sum(clientKey)
+		return map[string]string{
+			algorithm: crypto.SSEAlgorithmAES256,
+			key:       base64.StdEncoding.EncodeToString(clientKey),
+			keyMD5:    base64.StdEncoding.EncodeToString(sum[:]),
+		}
+	}
+	oldKey, newKey := generateBytesData(32*humanize.Byte), bytes.Repeat([]byte{'b'}, 32)
+	objectName := "test-object"
+	data := generateBytesData(6 * humanize.KiByte)
+
+	rec := httptest.NewRecorder()
+	req, err := newTestSignedRequestV4("PUT", getPutObjectURL("", bucketName, objectName), int64(len(data)), bytes.NewReader(data),
+		credentials.AccessKey, credentials.SecretKey, sseCHeaders(crypto.SSECAlgorithm, crypto.SSECKey, crypto.SSECKeyMD5, oldKey))
+	if err != nil {
+		t.Fatalf("%s: Failed to create HTTP request for Put Object: <ERROR> %v", instanceType, err)
+	}
+	apiRouter.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: Expected the response status to be `%d`, but instead found `%d`", instanceType, http.StatusOK, rec.Code)
+	}
+
+	// Copy the object to itself, rotating its SSE-C key.
+	headers := sseCHeaders(crypto.SSECopyAlgorithm, crypto.SSECopyKey, crypto.SSECopyKeyMD5, oldKey)
+	for k, v := range sseCHeaders(crypto.SSECAlgorithm, crypto.SSECKey, crypto.SSECKeyMD5, newKey) {
+		headers[k] = v
+	}
+	headers["X-Amz-Copy-Source"] = url.QueryEscape(SlashSeparator + bucketName + SlashSeparator + objectName)
+	rec = httptest.NewRecorder()
+	req, err = newTestSignedRequestV4("PUT", getCopyObjectURL("", bucketName, objectName), 0, nil,
+		credentials.AccessKey, credentials.SecretKey, headers)
+	if err != nil {
+		t.Fatalf("%s: Failed to create HTTP request for Copy Object: <ERROR> %v", instanceType, err)
+	}
+	apiRouter.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: Expected the response status to be `%d`, but instead found `%d`: %s", instanceType, http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	testCases := []struct {
+		clientKey          []byte
+		expectedRespStatus int
+	}{
+		// Test case - 1.
+		// The rotated object is decrypted with the new key.
+		{newKey, http.StatusOK},
+		// Test case - 2.
+		// The old key can no longer read the object.
+		{oldKey, http.StatusForbidden},
+	}
+	for i, testCase := range testCases {
+		rec := httptest.NewRecorder()
+		req, err := newTestSignedRequestV4("GET", getGetObjectURL("", bucketName, objectName), 0, nil,
+			credentials.AccessKey, credentials.SecretKey, sseCHeaders(crypto.SSECAlgorithm, crypto.SSECKey, crypto.SSECKeyMD5, testCase.clientKey))
+		if err != nil {
+			t.Fatalf("Test %d: %s: Failed to create HTTP request for Get Object: <ERROR> %v", i+1, instanceType, err)
+		}
+		apiRouter.ServeHTTP(rec, req)
+		if rec.Code != testCase.expectedRespStatus {
+			t.Fatalf("Test %d: %s: Expected the response status to be `%d`, but instead found `%d`", i+1, instanceType, testCase.expectedRespStatus, rec.Code)
+		}
+		if rec.Code == http.StatusOK && !bytes.Equal(rec.Body.Bytes(), data) {
+			t.Errorf("Test %d: %s: Object content differs from expected value", i+1, instanceType)
+		}
+	}
+}
+
 // Wrapper for calling NewMultipartUpload tests for both XL multiple disks and single node setup.
 // First register the HTTP handler for NewMutlipartUpload, then a HTTP request for NewMultipart upload is made.
 // The UploadID from the response body is parsed and its existence is asserted with an attempt to ListParts using it.
@@ -2109,6 +2109,12 @@ func registerBucketLevelFunc(bucket *mux.Router, api objectAPIHandlers, apiFunct
 		case "ListenBucketNotification":
 			// Register ListenBucketNotification Handler.
 			bucket.Methods("GET").HandlerFunc(api.ListenBucketNotificationHandler).Queries("events", "{events:.*}")
+		case "PutObjectLegalHold":
+			// Register PutObjectLegalHold Handler.
+			bucket.Methods("PUT").Path("/{object:.+}").HandlerFunc(api.PutObjectLegalHoldHandler).Queries("legal-hold", "")
+		case "GetObjectLegalHold":
+			// Register GetObjectLegalHold Handler.
+			bucket.Methods("GET").Path("/{object:.+}").HandlerFunc(api.GetObjectLegalHoldHandler).Queries("legal-hold", "")
 		}
 	}
 }